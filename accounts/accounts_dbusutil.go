@@ -336,6 +336,45 @@ func (v *User) emitPropChangedLocked(value bool) error {
 	return v.service.EmitPropertyChanged(v, "Locked", value)
 }
 
+func (v *User) setPropExpirationDate(value int32) (changed bool) {
+	if v.ExpirationDate != value {
+		v.ExpirationDate = value
+		v.emitPropChangedExpirationDate(value)
+		return true
+	}
+	return false
+}
+
+func (v *User) emitPropChangedExpirationDate(value int32) error {
+	return v.service.EmitPropertyChanged(v, "ExpirationDate", value)
+}
+
+func (v *User) setPropExpiredAction(value int32) (changed bool) {
+	if v.ExpiredAction != value {
+		v.ExpiredAction = value
+		v.emitPropChangedExpiredAction(value)
+		return true
+	}
+	return false
+}
+
+func (v *User) emitPropChangedExpiredAction(value int32) error {
+	return v.service.EmitPropertyChanged(v, "ExpiredAction", value)
+}
+
+func (v *User) setPropLoginTimeWindows(value string) (changed bool) {
+	if v.LoginTimeWindows != value {
+		v.LoginTimeWindows = value
+		v.emitPropChangedLoginTimeWindows(value)
+		return true
+	}
+	return false
+}
+
+func (v *User) emitPropChangedLoginTimeWindows(value string) error {
+	return v.service.EmitPropertyChanged(v, "LoginTimeWindows", value)
+}
+
 func (v *User) setPropAutomaticLogin(value bool) (changed bool) {
 	if v.AutomaticLogin != value {
 		v.AutomaticLogin = value
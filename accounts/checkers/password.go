@@ -39,6 +39,12 @@ const (
 	passwordOK passwordErrorCode = iota
 	passwordErrCodeShort
 	passwordErrCodeSimple
+	passwordErrCodeRepeat
+	passwordErrCodeSequence
+	passwordErrCodeDictionary
+	passwordErrCodeUsername
+	passwordErrCodeHistory
+	passwordErrCodeHashed
 )
 
 func (code passwordErrorCode) IsOk() bool {
@@ -70,33 +76,11 @@ func (p password) hasAnySpecialChar() bool {
 	return strings.ContainsAny(str, passwordSpecialChars)
 }
 
-func (p password) hasUpperAndLowerAlphabet() bool {
-	str := string(p)
-	return passwordUpperAlphabetRegexp.MatchString(str) &&
-		passwordLowerAlphabetRegexp.MatchString(str)
-}
-
+// CheckPasswordValid 使用默认的密码策略检查密码，返回第一个不满足的要求
 func CheckPasswordValid(releaseType, passwd string) passwordErrorCode {
-	if releaseType != "Server" {
+	violations := DefaultPasswordPolicy(releaseType).Check("", passwd, nil)
+	if len(violations) == 0 {
 		return passwordOK
 	}
-
-	if len(passwd) < passwordMinLength {
-		return passwordErrCodeShort
-	}
-
-	p := password(passwd)
-	if !p.hasAnyNumber() {
-		return passwordErrCodeSimple
-	}
-
-	if !p.hasAnySpecialChar() {
-		return passwordErrCodeSimple
-	}
-
-	if !p.hasUpperAndLowerAlphabet() {
-		return passwordErrCodeSimple
-	}
-
-	return passwordOK
+	return passwordErrorCode(violations[0].Code)
 }
@@ -0,0 +1,386 @@
+/*
+ * Copyright (C) 2016 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package checkers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	pwqualityConfigFile = "/etc/security/pwquality.conf"
+	pwqualityConfigDir  = "/etc/security/pwquality.conf.d"
+	// 系统的密码策略配置，优先级高于 pwquality 的配置
+	passwordPolicyConfigFile = "/etc/deepin/dde-daemon/password-policy.conf"
+	defaultDictFile          = "/usr/share/dict/words"
+
+	// 字典中长度小于它的单词不检查
+	dictWordMinLength = 4
+)
+
+// PasswordPolicy 是密码的质量要求，值为 0 或者 false 的项不检查
+type PasswordPolicy struct {
+	MinLength int
+	// 至少包含几类字符，字符分为小写字母、大写字母、数字和特殊符号四类
+	MinClasses int
+	// 同一个字符最多连续出现的次数
+	MaxRepeat int
+	// 连续递增或者递减的字符（比如 abcd、4321）的最大长度
+	MaxSequence int
+	DictCheck   bool
+	DictFile    string
+	// 不能包含用户名，或者倒序的用户名
+	UserCheck bool
+	// 不能和最近使用过的几个密码相同
+	HistorySize int
+	// 是否允许设置已经加密的密码，已经加密的密码无法检查质量。
+	// 没有配置时，只有配置文件没有要求任何检查才允许
+	AllowHashed bool
+
+	dictWords map[string]struct{}
+	// 配置文件中是否有值不为 0 的检查项
+	checkConfigured bool
+	// 配置文件中是否有 allow_hashed
+	allowHashedSet bool
+}
+
+// DefaultPasswordPolicy 返回没有配置文件时的策略，服务器版要求 8 位以上并且包含四类字符，其他版本不检查
+func DefaultPasswordPolicy(releaseType string) *PasswordPolicy {
+	policy := &PasswordPolicy{
+		AllowHashed: true,
+	}
+	if releaseType == "Server" {
+		policy.MinLength = passwordMinLength
+		policy.MinClasses = 4
+	}
+	return policy
+}
+
+// LoadPasswordPolicy 在默认策略的基础上依次读取 pwquality 和 dde-daemon 的配置文件，
+// 配置文件有错误时也会返回可以使用的策略，err 是遇到的第一个错误。
+func LoadPasswordPolicy(releaseType string) (policy *PasswordPolicy, err error) {
+	policy = DefaultPasswordPolicy(releaseType)
+
+	files := []string{pwqualityConfigFile}
+	confFiles, _ := filepath.Glob(filepath.Join(pwqualityConfigDir, "*.conf"))
+	sort.Strings(confFiles)
+	files = append(files, confFiles...)
+	files = append(files, passwordPolicyConfigFile)
+
+	for _, file := range files {
+		loadErr := policy.loadFile(file)
+		if loadErr != nil && !os.IsNotExist(loadErr) && err == nil {
+			err = fmt.Errorf("%s: %v", file, loadErr)
+		}
+	}
+	policy.updateAllowHashed()
+
+	if policy.DictCheck {
+		if policy.DictFile == "" {
+			policy.DictFile = defaultDictFile
+		}
+		loadErr := policy.loadDict()
+		if loadErr != nil && err == nil {
+			err = loadErr
+		}
+	}
+	return policy, err
+}
+
+// updateAllowHashed 在配置文件要求了检查但没有配置 allow_hashed 时不允许设置已经加密的密码，
+// 否则客户端传入加密的密码就可以绕过策略。没有配置文件时兼容只传入加密密码的旧客户端。
+func (p *PasswordPolicy) updateAllowHashed() {
+	if p.checkConfigured && !p.allowHashedSet {
+		p.AllowHashed = false
+	}
+}
+
+func (p *PasswordPolicy) loadFile(filename string) error {
+	fh, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	return p.parse(fh)
+}
+
+// parse 解析 "key = value" 格式的配置，忽略注释和不认识的项。
+// 除了 pwquality.conf 中的 minlen、minclass、maxrepeat、maxsequence、dictcheck、usercheck，
+// 还支持 dictfile、history 和 allow_hashed。pwquality 的 dictpath 是 cracklib 的字典，不能使用。
+func (p *PasswordPolicy) parse(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.SplitN(line, "=", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(fields[0])
+		value := strings.TrimSpace(fields[1])
+
+		var err error
+		var checked bool
+		switch key {
+		case "minlen":
+			p.MinLength, err = parseNonNegativeInt(value)
+			checked = p.MinLength > 0
+		case "minclass":
+			p.MinClasses, err = parseNonNegativeInt(value)
+			if p.MinClasses > 4 {
+				p.MinClasses = 4
+			}
+			checked = p.MinClasses > 0
+		case "maxrepeat":
+			p.MaxRepeat, err = parseNonNegativeInt(value)
+			checked = p.MaxRepeat > 0
+		case "maxsequence":
+			p.MaxSequence, err = parseNonNegativeInt(value)
+			checked = p.MaxSequence > 0
+		case "dictcheck":
+			p.DictCheck, err = parseBool(value)
+			checked = p.DictCheck
+		case "dictfile":
+			p.DictFile = value
+		case "usercheck":
+			p.UserCheck, err = parseBool(value)
+			checked = p.UserCheck
+		case "history":
+			p.HistorySize, err = parseNonNegativeInt(value)
+			checked = p.HistorySize > 0
+		case "allow_hashed":
+			p.AllowHashed, err = parseBool(value)
+			p.allowHashedSet = err == nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid value %q for %s", value, key)
+		}
+		if checked {
+			p.checkConfigured = true
+		}
+	}
+	return scanner.Err()
+}
+
+func parseNonNegativeInt(value string) (int, error) {
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if v < 0 {
+		// pwquality 中负数表示其他含义，这里当作不检查
+		return 0, nil
+	}
+	return v, nil
+}
+
+func parseBool(value string) (bool, error) {
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return false, err
+	}
+	return v != 0, nil
+}
+
+// PasswordViolation 是密码不满足的一项要求
+type PasswordViolation struct {
+	Code    int32
+	Message string
+}
+
+func newViolation(code passwordErrorCode, msg string) PasswordViolation {
+	return PasswordViolation{Code: int32(code), Message: msg}
+}
+
+// Check 检查密码 passwd，返回所有不满足的要求；username 为空时不检查用户名，
+// isOldPassword 为 nil 时不检查历史密码。
+func (p *PasswordPolicy) Check(username, passwd string,
+	isOldPassword func(passwd string) bool) []PasswordViolation {
+	var result []PasswordViolation
+
+	if p.MinLength > 0 && len([]rune(passwd)) < p.MinLength {
+		result = append(result, newViolation(passwordErrCodeShort,
+			fmt.Sprintf(Tr("Please enter a password not less than %d characters"), p.MinLength)))
+	}
+
+	if p.MinClasses > 0 && countCharClasses(passwd) < p.MinClasses {
+		msg := passwordErrCodeSimple.Prompt()
+		if p.MinClasses < 4 {
+			msg = fmt.Sprintf(Tr("The password must contain at least %d of the following: lowercase letters, uppercase letters, numbers and special symbols (~!@#$%%^&*()[]{}\\|/?,.<>)"), p.MinClasses)
+		}
+		result = append(result, newViolation(passwordErrCodeSimple, msg))
+	}
+
+	if p.MaxRepeat > 0 && maxRepeatLength(passwd) > p.MaxRepeat {
+		result = append(result, newViolation(passwordErrCodeRepeat,
+			fmt.Sprintf(Tr("The password must not contain more than %d identical consecutive characters"), p.MaxRepeat)))
+	}
+
+	if p.MaxSequence > 0 && maxSequenceLength(passwd) > p.MaxSequence {
+		result = append(result, newViolation(passwordErrCodeSequence,
+			fmt.Sprintf(Tr("The password must not contain a sequence of more than %d characters, such as abcd or 4321"), p.MaxSequence)))
+	}
+
+	if p.DictCheck && p.isDictWord(passwd) {
+		result = append(result, newViolation(passwordErrCodeDictionary,
+			Tr("The password is based on a dictionary word")))
+	}
+
+	if p.UserCheck && containsUsername(passwd, username) {
+		result = append(result, newViolation(passwordErrCodeUsername,
+			Tr("The password must not contain the username")))
+	}
+
+	if p.HistorySize > 0 && isOldPassword != nil && isOldPassword(passwd) {
+		result = append(result, newViolation(passwordErrCodeHistory,
+			fmt.Sprintf(Tr("The password must not be the same as the last %d passwords"), p.HistorySize)))
+	}
+
+	return result
+}
+
+// HashedViolation 返回不允许设置已经加密的密码时的错误
+func HashedViolation() PasswordViolation {
+	return newViolation(passwordErrCodeHashed,
+		Tr("Setting an encrypted password is not allowed by the password policy"))
+}
+
+func countCharClasses(passwd string) int {
+	p := password(passwd)
+	var n int
+	if passwordLowerAlphabetRegexp.MatchString(passwd) {
+		n++
+	}
+	if passwordUpperAlphabetRegexp.MatchString(passwd) {
+		n++
+	}
+	if p.hasAnyNumber() {
+		n++
+	}
+	if p.hasAnySpecialChar() {
+		n++
+	}
+	return n
+}
+
+func maxRepeatLength(passwd string) int {
+	var maxLen, curLen int
+	var last rune
+	for i, r := range []rune(passwd) {
+		if i > 0 && r == last {
+			curLen++
+		} else {
+			curLen = 1
+		}
+		last = r
+		if curLen > maxLen {
+			maxLen = curLen
+		}
+	}
+	return maxLen
+}
+
+// maxSequenceLength 返回最长的连续递增或者递减 1 的字符序列的长度
+func maxSequenceLength(passwd string) int {
+	runes := []rune(passwd)
+	if len(runes) == 0 {
+		return 0
+	}
+	maxLen := 1
+	curLen := 1
+	var step rune
+	for i := 1; i < len(runes); i++ {
+		d := runes[i] - runes[i-1]
+		switch {
+		case d != 1 && d != -1:
+			curLen = 1
+		case curLen > 1 && d == step:
+			curLen++
+		default:
+			curLen = 2
+		}
+		step = d
+		if curLen > maxLen {
+			maxLen = curLen
+		}
+	}
+	return maxLen
+}
+
+func reverseString(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+func containsUsername(passwd, username string) bool {
+	if len(username) < userNameMinLength {
+		return false
+	}
+	passwd = strings.ToLower(passwd)
+	username = strings.ToLower(username)
+	return strings.Contains(passwd, username) ||
+		strings.Contains(passwd, reverseString(username))
+}
+
+func (p *PasswordPolicy) loadDict() error {
+	fh, err := os.Open(p.DictFile)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	return p.readDict(fh)
+}
+
+func (p *PasswordPolicy) readDict(r io.Reader) error {
+	if p.dictWords == nil {
+		p.dictWords = make(map[string]struct{})
+	}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		word := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if len([]rune(word)) < dictWordMinLength {
+			continue
+		}
+		p.dictWords[word] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+// isDictWord 判断密码去掉首尾的数字和符号后是不是字典中的单词
+func (p *PasswordPolicy) isDictWord(passwd string) bool {
+	word := strings.TrimFunc(strings.ToLower(passwd), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if _, ok := p.dictWords[word]; ok {
+		return true
+	}
+	_, ok := p.dictWords[reverseString(word)]
+	return ok
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright (C) 2016 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package checkers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getViolationCodes(violations []PasswordViolation) []passwordErrorCode {
+	var codes []passwordErrorCode
+	for _, v := range violations {
+		codes = append(codes, passwordErrorCode(v.Code))
+	}
+	return codes
+}
+
+func Test_PasswordPolicyParse(t *testing.T) {
+	policy := DefaultPasswordPolicy("Desktop")
+	err := policy.parse(strings.NewReader(`# pwquality
+minlen = 10
+# minclass = 4
+minclass = 3
+maxrepeat = 2
+maxsequence=3
+dcredit = -1
+dictcheck = 1
+usercheck = 1
+history = 5
+allow_hashed = 0
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, 10, policy.MinLength)
+	assert.Equal(t, 3, policy.MinClasses)
+	assert.Equal(t, 2, policy.MaxRepeat)
+	assert.Equal(t, 3, policy.MaxSequence)
+	assert.True(t, policy.DictCheck)
+	assert.True(t, policy.UserCheck)
+	assert.Equal(t, 5, policy.HistorySize)
+	assert.False(t, policy.AllowHashed)
+
+	err = policy.parse(strings.NewReader("minlen = abc"))
+	assert.NotNil(t, err)
+}
+
+func Test_PasswordPolicyAllowHashed(t *testing.T) {
+	// 没有配置检查时兼容传入加密密码的客户端
+	policy := DefaultPasswordPolicy("Server")
+	err := policy.parse(strings.NewReader("# minlen = 10\ndictfile = /tmp/words\n"))
+	assert.Nil(t, err)
+	policy.updateAllowHashed()
+	assert.True(t, policy.AllowHashed)
+
+	// 配置了检查时不接受加密的密码
+	policy = DefaultPasswordPolicy("Desktop")
+	err = policy.parse(strings.NewReader("minlen = 10\n"))
+	assert.Nil(t, err)
+	policy.updateAllowHashed()
+	assert.False(t, policy.AllowHashed)
+
+	policy = DefaultPasswordPolicy("Desktop")
+	err = policy.parse(strings.NewReader("maxrepeat = 0\nhistory = -1\n"))
+	assert.Nil(t, err)
+	policy.updateAllowHashed()
+	assert.True(t, policy.AllowHashed)
+
+	// 明确配置的 allow_hashed 优先
+	policy = DefaultPasswordPolicy("Desktop")
+	err = policy.parse(strings.NewReader("history = 5\nallow_hashed = 1\n"))
+	assert.Nil(t, err)
+	policy.updateAllowHashed()
+	assert.True(t, policy.AllowHashed)
+}
+
+func Test_PasswordPolicyCheck(t *testing.T) {
+	policy := &PasswordPolicy{
+		MinLength:   8,
+		MinClasses:  3,
+		MaxRepeat:   2,
+		MaxSequence: 3,
+		DictCheck:   true,
+		UserCheck:   true,
+		HistorySize: 3,
+	}
+	err := policy.readDict(strings.NewReader("dragon\npassword\nsun\n"))
+	assert.Nil(t, err)
+	isOldPassword := func(passwd string) bool {
+		return passwd == "Old-Passw0rd"
+	}
+
+	var tests = []struct {
+		passwd string
+		codes  []passwordErrorCode
+	}{
+		{"Good-Pass7", nil},
+		{"aB1?", []passwordErrorCode{passwordErrCodeShort}},
+		{"abcdefgh", []passwordErrorCode{passwordErrCodeSimple, passwordErrCodeSequence}},
+		{"Paaass-1", []passwordErrorCode{passwordErrCodeRepeat}},
+		{"Pass-4321", []passwordErrorCode{passwordErrCodeSequence}},
+		{"12Dragon!", []passwordErrorCode{passwordErrCodeDictionary}},
+		{"Xtom-cat9", []passwordErrorCode{passwordErrCodeUsername}},
+		{"Xtac-mot9", []passwordErrorCode{passwordErrCodeUsername}},
+		{"Old-Passw0rd", []passwordErrorCode{passwordErrCodeHistory}},
+	}
+	for _, test := range tests {
+		violations := policy.Check("tom-cat", test.passwd, isOldPassword)
+		assert.Equal(t, test.codes, getViolationCodes(violations), test.passwd)
+	}
+
+	violations := policy.Check("tom-cat", "aB1?", isOldPassword)
+	assert.Equal(t, "Please enter a password not less than 8 characters", violations[0].Message)
+}
+
+func Test_maxSequenceLength(t *testing.T) {
+	assert.Equal(t, 0, maxSequenceLength(""))
+	assert.Equal(t, 1, maxSequenceLength("a"))
+	assert.Equal(t, 3, maxSequenceLength("xabcx"))
+	assert.Equal(t, 4, maxSequenceLength("9876a"))
+	assert.Equal(t, 2, maxSequenceLength("abab"))
+	assert.Equal(t, 3, maxSequenceLength("abcba"))
+}
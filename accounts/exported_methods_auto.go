@@ -49,6 +49,12 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:     v.EnablePasswdChangedHandler,
 			InArgs: []string{"enable"},
 		},
+		{
+			Name:    "ExportUser",
+			Fn:      v.ExportUser,
+			InArgs:  []string{"name"},
+			OutArgs: []string{"userJSON"},
+		},
 		{
 			Name:    "FindUserById",
 			Fn:      v.FindUserById,
@@ -84,6 +90,12 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			InArgs:  []string{"name"},
 			OutArgs: []string{"valid", "msg", "code"},
 		},
+		{
+			Name:    "ProvisionUsers",
+			Fn:      v.ProvisionUsers,
+			InArgs:  []string{"usersJSON", "dryRun"},
+			OutArgs: []string{"changesJSON"},
+		},
 		{
 			Name:    "RandUserIcon",
 			Fn:      v.RandUserIcon,
@@ -98,6 +110,12 @@ func (v *User) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:     v.AddGroup,
 			InArgs: []string{"group"},
 		},
+		{
+			Name:    "CheckPassword",
+			Fn:      v.CheckPassword,
+			InArgs:  []string{"password"},
+			OutArgs: []string{"violationsJSON"},
+		},
 		{
 			Name:   "DeleteGroup",
 			Fn:     v.DeleteGroup,
@@ -138,6 +156,16 @@ func (v *User) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:     v.SetDesktopBackgrounds,
 			InArgs: []string{"val"},
 		},
+		{
+			Name:   "SetExpirationDate",
+			Fn:     v.SetExpirationDate,
+			InArgs: []string{"days"},
+		},
+		{
+			Name:   "SetExpiredAction",
+			Fn:     v.SetExpiredAction,
+			InArgs: []string{"action"},
+		},
 		{
 			Name:   "SetFullName",
 			Fn:     v.SetFullName,
@@ -183,6 +211,11 @@ func (v *User) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:     v.SetLocked,
 			InArgs: []string{"locked"},
 		},
+		{
+			Name:   "SetLoginTimeWindows",
+			Fn:     v.SetLoginTimeWindows,
+			InArgs: []string{"windows"},
+		},
 		{
 			Name:   "SetLongDateFormat",
 			Fn:     v.SetLongDateFormat,
@@ -81,6 +81,9 @@ type Manager struct {
 	delayTaskManager *tasker.DelayTaskManager
 	userAddedChanMap map[string]chan string
 	udcpCache        udcp.UdcpCache
+	expiryQuit       chan struct{}
+	// 已经归档了主目录但是还没有删除成功的过期用户，只在检查过期的 goroutine 中使用
+	archivedUsers map[string]string
 
 	//nolint
 	signals *struct {
@@ -112,6 +115,7 @@ func NewManager(service *dbusutil.Service) *Manager {
 
 	m.usersMap = make(map[string]*User)
 	m.userAddedChanMap = make(map[string]chan string)
+	m.archivedUsers = make(map[string]string)
 
 	m.GuestIcon = userIconGuest
 	m.AllowGuest = isGuestUserEnabled()
@@ -145,6 +149,8 @@ func NewManager(service *dbusutil.Service) *Manager {
 			return
 		}
 
+		m.checkNewSession(id, userInfo.UID)
+
 		if userInfo.UID < 10000 {
 			return
 		}
@@ -159,10 +165,13 @@ func NewManager(service *dbusutil.Service) *Manager {
 		}
 	})
 
+	m.startExpiryCheck()
 	return m
 }
 
 func (m *Manager) destroy() {
+	m.stopExpiryCheck()
+
 	if m.watcher != nil {
 		m.watcher.EndWatch()
 		m.watcher = nil
@@ -167,16 +167,24 @@ func (m *Manager) DeleteUser(sender dbus.Sender,
 		return dbusutil.ToError(err)
 	}
 
+	return dbusutil.ToError(m.deleteLocalUser(user, rmFiles))
+}
+
+// deleteLocalUser 删除本地用户，rmFiles 为 true 时同时删除用户的数据
+func (m *Manager) deleteLocalUser(user *User, rmFiles bool) error {
+	name := user.UserName
 	if err := users.DeleteUser(rmFiles, name); err != nil {
 		logger.Warningf("DoAction: delete user '%s' failed: %v\n",
 			name, err)
-		return dbusutil.ToError(err)
+		return err
 	}
 
 	if users.IsAutoLoginUser(name) {
 		_ = users.SetAutoLoginUser("", "")
 	}
 
+	removePasswordHistory(name)
+
 	//delete user config and icons
 	if rmFiles {
 		user.clearData()
@@ -273,11 +281,13 @@ func (m *Manager) IsUsernameValid(sender dbus.Sender, name string) (valid bool,
 // ret1: 提示信息
 //
 // ret2: 不合法代码
+// 按照密码策略检查，不检查用户名和历史密码，有多项不满足时返回第一项，全部的列表见 User.CheckPassword
 func (m *Manager) IsPasswordValid(password string) (valid bool, msg string, code int32, busErr *dbus.Error) {
-	releaseType := getDeepinReleaseType()
-	logger.Infof("release type %q", releaseType)
-	errCode := checkers.CheckPasswordValid(releaseType, password)
-	return errCode.IsOk(), errCode.Prompt(), int32(errCode), nil
+	violations := loadPasswordPolicy().Check("", password, nil)
+	if len(violations) == 0 {
+		return true, "", 0, nil
+	}
+	return false, violations[0].Message, violations[0].Code, nil
 }
 
 func (m *Manager) AllowGuestAccount(sender dbus.Sender, allow bool) *dbus.Error {
@@ -0,0 +1,142 @@
+/*
+ * Copyright (C) 2016 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package accounts
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	dbus "github.com/godbus/dbus"
+	"pkg.deepin.io/dde/daemon/accounts/checkers"
+	"pkg.deepin.io/dde/daemon/accounts/users"
+)
+
+// 密码不满足密码策略时返回的错误，错误信息是 JSON 格式的 []checkers.PasswordViolation
+const dbusErrorPasswordViolation = dbusInterface + ".Error.PasswordViolation"
+
+// 每个用户一个文件，每行是一个 crypt 加密后的密码，最后一行是最近设置的密码
+var passwordHistoryDir = actConfigDir + "/deepin/password-history"
+
+func loadPasswordPolicy() *checkers.PasswordPolicy {
+	policy, err := checkers.LoadPasswordPolicy(getDeepinReleaseType())
+	if err != nil {
+		logger.Warning("failed to load password policy:", err)
+	}
+	return policy
+}
+
+// isHashedPassword 判断密码是不是 crypt 加密后的格式，比如 $6$salt$hash
+func isHashedPassword(passwd string) bool {
+	return strings.HasPrefix(passwd, "$") && strings.Count(passwd, "$") >= 3
+}
+
+func newPasswordViolationError(violations []checkers.PasswordViolation) *dbus.Error {
+	data, _ := json.Marshal(violations)
+	return dbus.NewError(dbusErrorPasswordViolation, []interface{}{string(data)})
+}
+
+func getPasswordHistoryFile(username string) string {
+	return filepath.Join(passwordHistoryDir, username)
+}
+
+func loadPasswordHistory(username string) []string {
+	content, err := ioutil.ReadFile(getPasswordHistoryFile(username))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warning(err)
+		}
+		return nil
+	}
+
+	var result []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if line != "" {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+// addPasswordHistory 记录用户设置的密码，只保留最近的 size 个
+func addPasswordHistory(username, hash string, size int) error {
+	history := append(loadPasswordHistory(username), hash)
+	if len(history) > size {
+		history = history[len(history)-size:]
+	}
+
+	err := os.MkdirAll(passwordHistoryDir, 0700)
+	if err != nil {
+		return err
+	}
+	content := strings.Join(history, "\n") + "\n"
+	return ioutil.WriteFile(getPasswordHistoryFile(username), []byte(content), 0600)
+}
+
+func removePasswordHistory(username string) {
+	err := os.Remove(getPasswordHistoryFile(username))
+	if err != nil && !os.IsNotExist(err) {
+		logger.Warning("remove password history failed:", err)
+	}
+}
+
+func renamePasswordHistory(oldUsername, newUsername string) {
+	err := os.Rename(getPasswordHistoryFile(oldUsername), getPasswordHistoryFile(newUsername))
+	if err != nil && !os.IsNotExist(err) {
+		logger.Warning("rename password history failed:", err)
+	}
+}
+
+// newOldPasswordChecker 返回判断明文密码是不是最近 size 个密码之一的函数
+func newOldPasswordChecker(username string, size int) func(passwd string) bool {
+	return func(passwd string) bool {
+		history := loadPasswordHistory(username)
+		if len(history) > size {
+			history = history[len(history)-size:]
+		}
+		for _, hash := range history {
+			if users.VerifyPasswd(passwd, hash) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// checkPasswordPolicy 按照密码策略检查用户 username 要设置的密码，
+// 明文密码检查通过后会被加密，返回要写入 shadow 文件的密码
+func checkPasswordPolicy(policy *checkers.PasswordPolicy, username, passwd string) (string, []checkers.PasswordViolation) {
+	if passwd == "" {
+		// 由 users.ModifyPasswd 返回错误
+		return passwd, nil
+	}
+	if isHashedPassword(passwd) {
+		if !policy.AllowHashed {
+			return "", []checkers.PasswordViolation{checkers.HashedViolation()}
+		}
+		return passwd, nil
+	}
+
+	violations := policy.Check(username, passwd, newOldPasswordChecker(username, policy.HistorySize))
+	if len(violations) > 0 {
+		return "", violations
+	}
+	return users.EncodePasswd(passwd), nil
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright (C) 2016 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package accounts
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_isHashedPassword(t *testing.T) {
+	assert.True(t, isHashedPassword("$6$abcdefgh$0123456789"))
+	assert.True(t, isHashedPassword("$y$j9T$salt$hash"))
+	assert.False(t, isHashedPassword("$hello$"))
+	assert.False(t, isHashedPassword("Passw0rd!"))
+	assert.False(t, isHashedPassword(""))
+}
+
+func Test_PasswordHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "password-history")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	oldDir := passwordHistoryDir
+	passwordHistoryDir = dir
+	defer func() {
+		passwordHistoryDir = oldDir
+	}()
+
+	assert.Nil(t, loadPasswordHistory("test1"))
+	for _, hash := range []string{"$6$a$1", "$6$b$2", "$6$c$3", "$6$d$4"} {
+		err = addPasswordHistory("test1", hash, 3)
+		require.Nil(t, err)
+	}
+	assert.Equal(t, []string{"$6$b$2", "$6$c$3", "$6$d$4"}, loadPasswordHistory("test1"))
+
+	renamePasswordHistory("test1", "test2")
+	assert.Nil(t, loadPasswordHistory("test1"))
+	assert.Len(t, loadPasswordHistory("test2"), 3)
+
+	removePasswordHistory("test2")
+	assert.Nil(t, loadPasswordHistory("test2"))
+}
@@ -67,6 +67,8 @@ const (
 	confKeyShortTimeFormat    = "ShortTimeFormat"
 	confKeyLongTimeFormat     = "LongTimeFormat"
 	confKeyWeekBegins         = "WeekBegins"
+	confKeyExpiredAction      = "ExpiredAction"
+	confKeyLoginTimeWindows   = "LoginTimeWindows"
 
 	defaultUse24HourFormat = true
 	defaultWeekdayFormat   = 0
@@ -76,6 +78,7 @@ const (
 	defaultLongTimeFormat  = 0
 	defaultWeekBegins      = 0
 	defaultWorkspace       = 1
+	defaultExpiredAction   = expiredActionLock
 )
 
 func getDefaultUserBackground() string {
@@ -122,6 +125,12 @@ type User struct {
 	PasswordLastChange int32
 	// 用户是否被禁用
 	Locked bool
+	// 账户过期的日期，1970-01-01 以来的天数，-1 表示永不过期
+	ExpirationDate int32
+	// 账户过期后的处理方式
+	ExpiredAction int32
+	// 允许登录的时间段，JSON 格式，空字符串表示不限制
+	LoginTimeWindows string
 	// 是否允许此用户自动登录
 	AutomaticLogin bool
 	// 当前工作区
@@ -155,7 +164,7 @@ func NewUser(userPath string, service *dbusutil.Service, ignoreErr bool) (*User,
 		if !ignoreErr {
 			return nil, err
 		} else {
-			shadowInfo = &users.ShadowInfo{Name: userInfo.Name, Status: users.PasswordStatusLocked}
+			shadowInfo = &users.ShadowInfo{Name: userInfo.Name, Status: users.PasswordStatusLocked, ExpireDate: -1}
 		}
 	}
 
@@ -173,6 +182,8 @@ func NewUser(userPath string, service *dbusutil.Service, ignoreErr bool) (*User,
 		PasswordStatus:     shadowInfo.Status,
 		MaxPasswordAge:     int32(shadowInfo.MaxDays),
 		PasswordLastChange: int32(shadowInfo.LastChange),
+		ExpirationDate:     int32(shadowInfo.ExpireDate),
+		ExpiredAction:      defaultExpiredAction,
 	}
 
 	u.AccountType = u.getAccountType()
@@ -338,6 +349,13 @@ func NewUser(userPath string, service *dbusutil.Service, ignoreErr bool) (*User,
 		isSave = true
 	}
 
+	// 这两项只有管理员设置后才会写入配置文件
+	expiredAction, err := kf.GetInteger(confGroupUser, confKeyExpiredAction)
+	if err == nil && isExpiredActionValid(expiredAction) {
+		u.ExpiredAction = expiredAction
+	}
+	u.LoginTimeWindows, _ = kf.GetString(confGroupUser, confKeyLoginTimeWindows)
+
 	if isSave {
 		err := u.writeUserConfig()
 		if err != nil {
@@ -369,6 +387,7 @@ func NewUdcpUser(usrId uint32, service *dbusutil.Service, groups []string) (*Use
 		PasswordStatus:     users.PasswordStatusUsable,
 		MaxPasswordAge:     30,
 		PasswordLastChange: 18737,
+		ExpirationDate:     -1,
 	}
 
 	u.AccountType = users.UserTypeUdcp
@@ -618,6 +637,7 @@ func (u *User) updatePropsPasswd(uInfo *users.UserInfo) {
 		if err != nil {
 			logger.Warning(err)
 		}
+		renamePasswordHistory(oldUserName, uInfo.Name)
 	}
 }
 
@@ -628,10 +648,36 @@ func (u *User) updatePropsShadow(shadowInfo *users.ShadowInfo) {
 	u.setPropLocked(shadowInfo.Status == users.PasswordStatusLocked)
 	u.setPropMaxPasswordAge(int32(shadowInfo.MaxDays))
 	u.setPropPasswordLastChange(int32(shadowInfo.LastChange))
+	u.setPropExpirationDate(int32(shadowInfo.ExpireDate))
 
 	u.PropsMu.Unlock()
 }
 
+// setLocked 禁用或者启用用户，禁用时取消自动登录，调用者需要持有 PropsMu
+func (u *User) setLocked(locked bool) error {
+	if u.Locked == locked {
+		return nil
+	}
+
+	if err := users.LockedUser(locked, u.UserName); err != nil {
+		logger.Warning("DoAction: locked user failed:", err)
+		return err
+	}
+
+	u.Locked = locked
+	_ = u.emitPropChangedLocked(locked)
+
+	if locked && u.AutomaticLogin {
+		if err := users.SetAutoLoginUser("", ""); err != nil {
+			logger.Warning("failed to clear auto login user:", err)
+			return err
+		}
+		u.AutomaticLogin = false
+		_ = u.emitPropChangedAutomaticLogin(false)
+	}
+	return nil
+}
+
 func (u *User) getAccountType() int32 {
 	if users.IsAdminUser(u.UserName) {
 		return users.UserTypeAdmin
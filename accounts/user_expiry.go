@@ -0,0 +1,380 @@
+/*
+ * Copyright (C) 2016 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package accounts
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"pkg.deepin.io/dde/daemon/accounts/users"
+)
+
+// 账户过期后的处理方式
+const (
+	expiredActionLock = iota
+	expiredActionDelete
+	// 先把主目录打包到 userArchiveDir，再删除用户
+	expiredActionArchiveAndDelete
+)
+
+const (
+	userArchiveDir = actConfigDir + "/deepin/archive"
+	// 每个用户最多保留的主目录归档数量，更早的归档会被删除
+	userArchivesMaxNum    = 3
+	userArchiveTimeLayout = "20060102-150405"
+
+	expiryCheckInterval = time.Minute
+)
+
+func isExpiredActionValid(action int32) bool {
+	return action >= expiredActionLock && action <= expiredActionArchiveAndDelete
+}
+
+// loginTimeWindow 是允许登录的时间段，Start 和 End 的格式是 HH:MM，End 最大为 24:00
+type loginTimeWindow struct {
+	// 0 是星期日，为空表示每天
+	Weekdays []int `json:",omitempty"`
+	Start    string
+	End      string
+
+	startMinute int
+	endMinute   int
+}
+
+func parseClock(value string) (int, error) {
+	var hour, minute int
+	_, err := fmt.Sscanf(value, "%d:%d", &hour, &minute)
+	if err != nil || len(value) != 5 {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	if hour < 0 || minute < 0 || minute > 59 || hour > 24 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	return hour*60 + minute, nil
+}
+
+// parseLoginTimeWindows 解析 JSON 格式的登录时间段列表，空字符串表示不限制
+func parseLoginTimeWindows(value string) ([]loginTimeWindow, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var windows []loginTimeWindow
+	err := json.Unmarshal([]byte(value), &windows)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range windows {
+		w := &windows[i]
+		for _, day := range w.Weekdays {
+			if day < 0 || day > 6 {
+				return nil, fmt.Errorf("invalid weekday %d", day)
+			}
+		}
+		w.startMinute, err = parseClock(w.Start)
+		if err != nil {
+			return nil, err
+		}
+		w.endMinute, err = parseClock(w.End)
+		if err != nil {
+			return nil, err
+		}
+		if w.startMinute >= w.endMinute {
+			return nil, fmt.Errorf("the start time %s is not before the end time %s", w.Start, w.End)
+		}
+	}
+	return windows, nil
+}
+
+// normalizeLoginTimeWindows 检查并重新生成 JSON，空列表和空字符串都表示不限制
+func normalizeLoginTimeWindows(value string) (string, error) {
+	windows, err := parseLoginTimeWindows(value)
+	if err != nil {
+		return "", err
+	}
+	if len(windows) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(windows)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (w *loginTimeWindow) contains(t time.Time) bool {
+	if len(w.Weekdays) > 0 {
+		var found bool
+		for _, day := range w.Weekdays {
+			if day == int(t.Weekday()) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	minute := t.Hour()*60 + t.Minute()
+	return minute >= w.startMinute && minute < w.endMinute
+}
+
+// isLoginAllowed 判断时间 t 是否在允许登录的时间段内，没有时间段时总是允许
+func isLoginAllowed(windows []loginTimeWindow, t time.Time) bool {
+	if len(windows) == 0 {
+		return true
+	}
+	for i := range windows {
+		if windows[i].contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *User) isLoginAllowedAt(t time.Time) bool {
+	u.PropsMu.RLock()
+	value := u.LoginTimeWindows
+	u.PropsMu.RUnlock()
+
+	windows, err := parseLoginTimeWindows(value)
+	if err != nil {
+		logger.Warningf("invalid login time windows of user %s: %v", u.UserName, err)
+		return true
+	}
+	return isLoginAllowed(windows, t)
+}
+
+// archiveHome 把用户的主目录打包成 userArchiveDir/<用户名>-<日期>.tar.gz
+func archiveHome(username, home string, now time.Time) (string, error) {
+	err := os.MkdirAll(userArchiveDir, 0700)
+	if err != nil {
+		return "", err
+	}
+	filename := filepath.Join(userArchiveDir,
+		fmt.Sprintf("%s-%s.tar.gz", username, now.Format(userArchiveTimeLayout)))
+	out, err := exec.Command("tar", "-czf", filename, "-C", filepath.Dir(home),
+		filepath.Base(home)).CombinedOutput()
+	if err != nil {
+		_ = os.Remove(filename)
+		return "", fmt.Errorf("%v: %s", err, out)
+	}
+	return filename, nil
+}
+
+// pruneUserArchives 删除 dir 中用户 username 较早的归档，只保留最新的 keep 个
+func pruneUserArchives(dir, username string, keep int) error {
+	fileInfoList, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for _, fileInfo := range fileInfoList {
+		name := fileInfo.Name()
+		if fileInfo.IsDir() || !strings.HasPrefix(name, username+"-") ||
+			!strings.HasSuffix(name, ".tar.gz") {
+			continue
+		}
+		// 检查时间部分，避免把 bob-1 的归档当作 bob 的
+		timeStr := strings.TrimSuffix(strings.TrimPrefix(name, username+"-"), ".tar.gz")
+		_, err = time.Parse(userArchiveTimeLayout, timeStr)
+		if err != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	if len(names) <= keep {
+		return nil
+	}
+
+	// 文件名中的时间可以直接按字符串排序
+	sort.Strings(names)
+	for _, name := range names[:len(names)-keep] {
+		logger.Info("remove old archive", name)
+		err = os.Remove(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Manager) startExpiryCheck() {
+	quit := make(chan struct{})
+	m.expiryQuit = quit
+	go func() {
+		ticker := time.NewTicker(expiryCheckInterval)
+		defer ticker.Stop()
+		for {
+			m.checkUsersExpiry(time.Now())
+			select {
+			case <-ticker.C:
+			case <-quit:
+				return
+			}
+		}
+	}()
+}
+
+func (m *Manager) stopExpiryCheck() {
+	if m.expiryQuit != nil {
+		close(m.expiryQuit)
+		m.expiryQuit = nil
+	}
+}
+
+// isUdcpUser 判断是不是域账户，域账户不保存在本地的 shadow 文件中
+func (u *User) isUdcpUser() bool {
+	u.PropsMu.RLock()
+	defer u.PropsMu.RUnlock()
+	return u.AccountType == users.UserTypeUdcp
+}
+
+func (m *Manager) getLocalUsers() []*User {
+	m.usersMapMu.Lock()
+	defer m.usersMapMu.Unlock()
+
+	var result []*User
+	for _, u := range m.usersMap {
+		if u.isUdcpUser() {
+			continue
+		}
+		result = append(result, u)
+	}
+	return result
+}
+
+// checkUsersExpiry 处理过期的账户，并结束不在允许登录时间段内的会话
+func (m *Manager) checkUsersExpiry(now time.Time) {
+	for _, u := range m.getLocalUsers() {
+		expired, err := users.IsAccountExpired(u.UserName)
+		if err != nil {
+			logger.Debug("failed to check account expiry:", err)
+			continue
+		}
+		if expired {
+			m.handleExpiredUser(u, now)
+			continue
+		}
+
+		if !u.isLoginAllowedAt(now) {
+			m.terminateUserSessions(u)
+		}
+	}
+}
+
+func (m *Manager) handleExpiredUser(u *User, now time.Time) {
+	u.PropsMu.RLock()
+	action := u.ExpiredAction
+	locked := u.Locked
+	home := u.HomeDir
+	u.PropsMu.RUnlock()
+
+	if action == expiredActionLock && locked {
+		return
+	}
+	logger.Infof("account %s expired, action: %d", u.UserName, action)
+	m.terminateUserSessions(u)
+
+	switch action {
+	case expiredActionLock:
+		u.PropsMu.Lock()
+		err := u.setLocked(true)
+		u.PropsMu.Unlock()
+		if err != nil {
+			logger.Warningf("failed to lock expired user %s: %v", u.UserName, err)
+		}
+
+	case expiredActionArchiveAndDelete, expiredActionDelete:
+		_, archived := m.archivedUsers[u.UserName]
+		if action == expiredActionArchiveAndDelete && !archived {
+			filename, err := archiveHome(u.UserName, home, now)
+			if err != nil {
+				// 不能归档时不删除用户，下次再试
+				logger.Warningf("failed to archive home of expired user %s: %v", u.UserName, err)
+				return
+			}
+			logger.Info("archive home to", filename)
+			m.archivedUsers[u.UserName] = filename
+
+			err = pruneUserArchives(userArchiveDir, u.UserName, userArchivesMaxNum)
+			if err != nil {
+				logger.Warning("failed to prune old archives:", err)
+			}
+		}
+		// 用户的进程还没有退出时会删除失败，下次再试
+		err := m.deleteLocalUser(u, true)
+		if err != nil {
+			logger.Warningf("failed to delete expired user %s: %v", u.UserName, err)
+			return
+		}
+		delete(m.archivedUsers, u.UserName)
+	}
+}
+
+func (m *Manager) terminateUserSessions(u *User) {
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+
+	sessions, err := m.login1Manager.ListSessions(0)
+	if err != nil {
+		logger.Warning("failed to list sessions:", err)
+		return
+	}
+	for _, session := range sessions {
+		if session.UID != uint32(uid) {
+			continue
+		}
+		logger.Infof("terminate session %s of user %s", session.SessionId, u.UserName)
+		err = m.login1Manager.TerminateSession(0, session.SessionId)
+		if err != nil {
+			logger.Warning("failed to terminate session:", err)
+		}
+	}
+}
+
+// checkNewSession 结束过期用户或者不在允许登录时间段内的新会话
+func (m *Manager) checkNewSession(sessionId string, uid uint32) {
+	u := m.getUserByUid(strconv.FormatUint(uint64(uid), 10))
+	if u == nil || u.isUdcpUser() {
+		return
+	}
+
+	expired, _ := users.IsAccountExpired(u.UserName)
+	if !expired && u.isLoginAllowedAt(time.Now()) {
+		return
+	}
+	logger.Infof("user %s is not allowed to login now, terminate session %s", u.UserName, sessionId)
+	err := m.login1Manager.TerminateSession(0, sessionId)
+	if err != nil {
+		logger.Warning("failed to terminate session:", err)
+	}
+}
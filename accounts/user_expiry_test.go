@@ -0,0 +1,143 @@
+/*
+ * Copyright (C) 2016 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package accounts
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseClock(t *testing.T) {
+	for value, minute := range map[string]int{
+		"00:00": 0,
+		"08:30": 510,
+		"23:59": 1439,
+		"24:00": 1440,
+	} {
+		v, err := parseClock(value)
+		assert.Nil(t, err, value)
+		assert.Equal(t, minute, v, value)
+	}
+
+	for _, value := range []string{"", "8:30", "24:01", "12:60", "-1:00", "ab:cd", "08:30:00"} {
+		_, err := parseClock(value)
+		assert.NotNil(t, err, value)
+	}
+}
+
+func Test_parseLoginTimeWindows(t *testing.T) {
+	windows, err := parseLoginTimeWindows("")
+	assert.Nil(t, err)
+	assert.Len(t, windows, 0)
+
+	windows, err = parseLoginTimeWindows(`[{"Weekdays":[1,2,3,4,5],"Start":"08:00","End":"18:00"},{"Start":"20:00","End":"24:00"}]`)
+	require.Nil(t, err)
+	require.Len(t, windows, 2)
+	assert.Equal(t, 480, windows[0].startMinute)
+	assert.Equal(t, 1080, windows[0].endMinute)
+	assert.Equal(t, 1440, windows[1].endMinute)
+
+	for _, value := range []string{
+		`{}`,
+		`[{"Weekdays":[7],"Start":"08:00","End":"18:00"}]`,
+		`[{"Start":"18:00","End":"08:00"}]`,
+		`[{"Start":"08:00","End":"08:00"}]`,
+		`[{"Start":"08:00"}]`,
+	} {
+		_, err = parseLoginTimeWindows(value)
+		assert.NotNil(t, err, value)
+	}
+}
+
+func Test_normalizeLoginTimeWindows(t *testing.T) {
+	value, err := normalizeLoginTimeWindows(`[ ]`)
+	assert.Nil(t, err)
+	assert.Equal(t, "", value)
+
+	value, err = normalizeLoginTimeWindows(` [{"End":"18:00", "Start":"08:00", "Weekdays":[]}]`)
+	assert.Nil(t, err)
+	assert.Equal(t, `[{"Start":"08:00","End":"18:00"}]`, value)
+}
+
+func Test_isLoginAllowed(t *testing.T) {
+	windows, err := parseLoginTimeWindows(`[{"Weekdays":[1,2,3,4,5],"Start":"08:00","End":"18:00"},{"Weekdays":[6],"Start":"10:00","End":"12:00"}]`)
+	require.Nil(t, err)
+
+	// 2020-03-02 是星期一
+	monday := func(hour, minute int) time.Time {
+		return time.Date(2020, 3, 2, hour, minute, 0, 0, time.Local)
+	}
+	assert.True(t, isLoginAllowed(windows, monday(8, 0)))
+	assert.True(t, isLoginAllowed(windows, monday(17, 59)))
+	assert.False(t, isLoginAllowed(windows, monday(18, 0)))
+	assert.False(t, isLoginAllowed(windows, monday(7, 59)))
+
+	saturday := monday(11, 0).AddDate(0, 0, 5)
+	assert.True(t, isLoginAllowed(windows, saturday))
+	assert.False(t, isLoginAllowed(windows, saturday.Add(time.Hour)))
+	assert.False(t, isLoginAllowed(windows, monday(11, 0).AddDate(0, 0, 6)))
+
+	assert.True(t, isLoginAllowed(nil, monday(3, 0)))
+}
+
+func Test_isExpiredActionValid(t *testing.T) {
+	assert.True(t, isExpiredActionValid(expiredActionLock))
+	assert.True(t, isExpiredActionValid(expiredActionDelete))
+	assert.True(t, isExpiredActionValid(expiredActionArchiveAndDelete))
+	assert.False(t, isExpiredActionValid(-1))
+	assert.False(t, isExpiredActionValid(3))
+}
+
+func Test_pruneUserArchives(t *testing.T) {
+	dir, err := ioutil.TempDir("", "user-archive")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{
+		"bob-20200101-080000.tar.gz",
+		"bob-20200102-080000.tar.gz",
+		"bob-20200103-080000.tar.gz",
+		"bob-1-20200101-080000.tar.gz",
+		"bob-notes.tar.gz",
+	} {
+		err = ioutil.WriteFile(filepath.Join(dir, name), nil, 0600)
+		require.NoError(t, err)
+	}
+
+	err = pruneUserArchives(dir, "bob", 2)
+	require.NoError(t, err)
+
+	fileInfoList, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, fileInfo := range fileInfoList {
+		names = append(names, fileInfo.Name())
+	}
+	assert.ElementsMatch(t, []string{
+		"bob-20200102-080000.tar.gz",
+		"bob-20200103-080000.tar.gz",
+		"bob-1-20200101-080000.tar.gz",
+		"bob-notes.tar.gz",
+	}, names)
+}
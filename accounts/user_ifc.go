@@ -25,6 +25,7 @@ typedef struct spwd cspwd;
 */
 import "C"
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -36,6 +37,7 @@ import (
 
 	dbus "github.com/godbus/dbus"
 	"pkg.deepin.io/dde/api/lang_info"
+	"pkg.deepin.io/dde/daemon/accounts/checkers"
 	"pkg.deepin.io/dde/daemon/accounts/users"
 	"pkg.deepin.io/lib/dbusutil"
 	"pkg.deepin.io/lib/gdkpixbuf"
@@ -144,6 +146,28 @@ func (u *User) SetShell(sender dbus.Sender, shell string) *dbus.Error {
 	return nil
 }
 
+// CheckPassword 按照密码策略检查用户要设置的明文密码，包括用户名和历史密码的检查，
+// 返回 JSON 格式的不满足的要求列表，满足时返回 "[]"
+func (u *User) CheckPassword(sender dbus.Sender, password string) (violationsJSON string, busErr *dbus.Error) {
+	err := u.checkAuth(sender, false, "")
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+
+	policy := loadPasswordPolicy()
+	violations := policy.Check(u.UserName, password, newOldPasswordChecker(u.UserName, policy.HistorySize))
+	if violations == nil {
+		violations = []checkers.PasswordViolation{}
+	}
+	data, err := json.Marshal(violations)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+// SetPassword 设置密码，password 可以是 crypt 加密后的密码，也可以是明文密码。
+// 明文密码需要满足密码策略，不满足时返回 com.deepin.daemon.Accounts.Error.PasswordViolation 错误。
 func (u *User) SetPassword(sender dbus.Sender, password string) *dbus.Error {
 	logger.Debug("[SetPassword] start ...")
 
@@ -153,6 +177,13 @@ func (u *User) SetPassword(sender dbus.Sender, password string) *dbus.Error {
 		return dbusutil.ToError(err)
 	}
 
+	policy := loadPasswordPolicy()
+	password, violations := checkPasswordPolicy(policy, u.UserName, password)
+	if len(violations) > 0 {
+		logger.Debug("[SetPassword] password violates the policy:", violations)
+		return newPasswordViolationError(violations)
+	}
+
 	var count = 10
 	for {
 		_, err := users.GetShadowInfo(u.UserName)
@@ -172,6 +203,13 @@ func (u *User) SetPassword(sender dbus.Sender, password string) *dbus.Error {
 		return dbusutil.ToError(err)
 	}
 
+	if policy.HistorySize > 0 {
+		err = addPasswordHistory(u.UserName, password, policy.HistorySize)
+		if err != nil {
+			logger.Warning("add password history failed:", err)
+		}
+	}
+
 	u.PropsMu.Lock()
 	defer u.PropsMu.Unlock()
 
@@ -219,24 +257,99 @@ func (u *User) SetLocked(sender dbus.Sender, locked bool) *dbus.Error {
 	u.PropsMu.Lock()
 	defer u.PropsMu.Unlock()
 
-	if u.Locked != locked {
-		if err := users.LockedUser(locked, u.UserName); err != nil {
-			logger.Warning("DoAction: locked user failed:", err)
-			return dbusutil.ToError(err)
-		}
+	return dbusutil.ToError(u.setLocked(locked))
+}
 
-		u.Locked = locked
-		_ = u.emitPropChangedLocked(locked)
+// SetExpirationDate 设置账户过期的日期，days 是 1970-01-01 以来的天数，-1 表示永不过期
+func (u *User) SetExpirationDate(sender dbus.Sender, days int32) *dbus.Error {
+	logger.Debug("[SetExpirationDate] days:", days)
 
-		if locked && u.AutomaticLogin {
-			if err := users.SetAutoLoginUser("", ""); err != nil {
-				logger.Warning("failed to clear auto login user:", err)
-				return dbusutil.ToError(err)
-			}
-			u.AutomaticLogin = false
-			_ = u.emitPropChangedAutomaticLogin(false)
-		}
+	err := u.checkAuth(sender, false, polkitActionUserAdministration)
+	if err != nil {
+		logger.Debug("[SetExpirationDate] access denied:", err)
+		return dbusutil.ToError(err)
+	}
+
+	if days < -1 {
+		return dbusutil.ToError(fmt.Errorf("invalid expiration date %d", days))
+	}
+
+	u.PropsMu.Lock()
+	defer u.PropsMu.Unlock()
+
+	if u.ExpirationDate == days {
+		return nil
+	}
+
+	err = users.ModifyExpireDate(u.UserName, int(days))
+	if err != nil {
+		logger.Warning("failed to set expiration date:", err)
+		return dbusutil.ToError(err)
+	}
+	u.ExpirationDate = days
+	_ = u.emitPropChangedExpirationDate(days)
+	return nil
+}
+
+// SetExpiredAction 设置账户过期后的处理方式，0 禁用，1 删除，2 归档主目录后删除
+func (u *User) SetExpiredAction(sender dbus.Sender, action int32) *dbus.Error {
+	logger.Debug("[SetExpiredAction] action:", action)
+
+	err := u.checkAuth(sender, false, polkitActionUserAdministration)
+	if err != nil {
+		logger.Debug("[SetExpiredAction] access denied:", err)
+		return dbusutil.ToError(err)
+	}
+
+	if !isExpiredActionValid(action) {
+		return dbusutil.ToError(fmt.Errorf("invalid expired action %d", action))
+	}
+
+	u.PropsMu.Lock()
+	defer u.PropsMu.Unlock()
+
+	if u.ExpiredAction == action {
+		return nil
+	}
+
+	err = u.writeUserConfigWithChange(confKeyExpiredAction, action)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	u.ExpiredAction = action
+	_ = u.emitPropChangedExpiredAction(action)
+	return nil
+}
+
+// SetLoginTimeWindows 设置允许登录的时间段，windows 为空表示不限制，
+// 在时间段外已经登录的会话会被结束。
+func (u *User) SetLoginTimeWindows(sender dbus.Sender, windows string) *dbus.Error {
+	logger.Debug("[SetLoginTimeWindows] windows:", windows)
+
+	err := u.checkAuth(sender, false, polkitActionUserAdministration)
+	if err != nil {
+		logger.Debug("[SetLoginTimeWindows] access denied:", err)
+		return dbusutil.ToError(err)
+	}
+
+	windows, err = normalizeLoginTimeWindows(windows)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	u.PropsMu.Lock()
+	defer u.PropsMu.Unlock()
+
+	if u.LoginTimeWindows == windows {
+		return nil
+	}
+
+	err = u.writeUserConfigWithChange(confKeyLoginTimeWindows, windows)
+	if err != nil {
+		return dbusutil.ToError(err)
 	}
+	u.LoginTimeWindows = windows
+	_ = u.emitPropChangedLoginTimeWindows(windows)
 	return nil
 }
 
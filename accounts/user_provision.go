@@ -0,0 +1,434 @@
+/*
+ * Copyright (C) 2016 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package accounts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	dbus "github.com/godbus/dbus"
+	"pkg.deepin.io/dde/daemon/accounts/users"
+	"pkg.deepin.io/lib/dbusutil"
+)
+
+// userSpec 是 ExportUser 导出和 ProvisionUsers 使用的用户描述，
+// 除了 Name 以外的字段为 nil 时表示不修改。
+type userSpec struct {
+	Name string
+	// 只在创建用户时使用，已存在的用户以 Groups 为准
+	AccountType       *int32    `json:",omitempty"`
+	FullName          *string   `json:",omitempty"`
+	Groups            *[]string `json:",omitempty"`
+	Locale            *string   `json:",omitempty"`
+	Layout            *string   `json:",omitempty"`
+	HistoryLayout     *[]string `json:",omitempty"`
+	IconFile          *string   `json:",omitempty"`
+	Use24HourFormat   *bool     `json:",omitempty"`
+	WeekdayFormat     *int32    `json:",omitempty"`
+	ShortDateFormat   *int32    `json:",omitempty"`
+	LongDateFormat    *int32    `json:",omitempty"`
+	ShortTimeFormat   *int32    `json:",omitempty"`
+	LongTimeFormat    *int32    `json:",omitempty"`
+	WeekBegins        *int32    `json:",omitempty"`
+	GreeterBackground *string   `json:",omitempty"`
+	ExpirationDate    *int32    `json:",omitempty"`
+	ExpiredAction     *int32    `json:",omitempty"`
+	LoginTimeWindows  *string   `json:",omitempty"`
+	AutomaticLogin    *bool     `json:",omitempty"`
+}
+
+// provisionChange 是 ProvisionUsers 对用户做的一项修改，Field 为 "Create" 表示创建用户
+type provisionChange struct {
+	User  string
+	Field string
+	Old   interface{} `json:",omitempty"`
+	New   interface{}
+}
+
+const provisionFieldCreate = "Create"
+
+// provisionField 描述一个可以导出和设置的字段，按照 provisionFields 的顺序设置
+type provisionField struct {
+	name string
+	// 返回字段的值，字段为 nil 时返回 nil
+	get   func(s *userSpec) interface{}
+	equal func(v1, v2 interface{}) bool
+	set   func(u *User, sender dbus.Sender, value interface{}) *dbus.Error
+}
+
+func getStringPtr(p *string) interface{} {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+func getStrvPtr(p *[]string) interface{} {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+func getInt32Ptr(p *int32) interface{} {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+func getBoolPtr(p *bool) interface{} {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+func isValueEqual(v1, v2 interface{}) bool {
+	return v1 == v2
+}
+
+// isStrvValueEqual 不考虑顺序，和 SetGroups、SetHistoryLayout 的判断一致
+func isStrvValueEqual(v1, v2 interface{}) bool {
+	l1, ok1 := v1.([]string)
+	l2, ok2 := v2.([]string)
+	if !ok1 || !ok2 {
+		return false
+	}
+	// isStrvEqual 会排序参数，这里复制一份
+	return isStrvEqual(append([]string(nil), l1...), append([]string(nil), l2...))
+}
+
+func int32Field(name string, get func(s *userSpec) *int32,
+	set func(u *User, sender dbus.Sender, value int32) *dbus.Error) provisionField {
+	return provisionField{
+		name:  name,
+		get:   func(s *userSpec) interface{} { return getInt32Ptr(get(s)) },
+		equal: isValueEqual,
+		set: func(u *User, sender dbus.Sender, value interface{}) *dbus.Error {
+			return set(u, sender, value.(int32))
+		},
+	}
+}
+
+func stringField(name string, get func(s *userSpec) *string,
+	set func(u *User, sender dbus.Sender, value string) *dbus.Error) provisionField {
+	return provisionField{
+		name:  name,
+		get:   func(s *userSpec) interface{} { return getStringPtr(get(s)) },
+		equal: isValueEqual,
+		set: func(u *User, sender dbus.Sender, value interface{}) *dbus.Error {
+			return set(u, sender, value.(string))
+		},
+	}
+}
+
+func strvField(name string, get func(s *userSpec) *[]string,
+	set func(u *User, sender dbus.Sender, value []string) *dbus.Error) provisionField {
+	return provisionField{
+		name:  name,
+		get:   func(s *userSpec) interface{} { return getStrvPtr(get(s)) },
+		equal: isStrvValueEqual,
+		set: func(u *User, sender dbus.Sender, value interface{}) *dbus.Error {
+			return set(u, sender, value.([]string))
+		},
+	}
+}
+
+func boolField(name string, get func(s *userSpec) *bool,
+	set func(u *User, sender dbus.Sender, value bool) *dbus.Error) provisionField {
+	return provisionField{
+		name:  name,
+		get:   func(s *userSpec) interface{} { return getBoolPtr(get(s)) },
+		equal: isValueEqual,
+		set: func(u *User, sender dbus.Sender, value interface{}) *dbus.Error {
+			return set(u, sender, value.(bool))
+		},
+	}
+}
+
+// 自动登录依赖用户的其他设置，放在最后
+var provisionFields = []provisionField{
+	stringField("FullName", func(s *userSpec) *string { return s.FullName }, (*User).SetFullName),
+	strvField("Groups", func(s *userSpec) *[]string { return s.Groups }, (*User).SetGroups),
+	stringField("Locale", func(s *userSpec) *string { return s.Locale }, (*User).SetLocale),
+	stringField("Layout", func(s *userSpec) *string { return s.Layout }, (*User).SetLayout),
+	strvField("HistoryLayout", func(s *userSpec) *[]string { return s.HistoryLayout }, (*User).SetHistoryLayout),
+	stringField("IconFile", func(s *userSpec) *string { return s.IconFile }, (*User).SetIconFile),
+	boolField("Use24HourFormat", func(s *userSpec) *bool { return s.Use24HourFormat }, (*User).SetUse24HourFormat),
+	int32Field("WeekdayFormat", func(s *userSpec) *int32 { return s.WeekdayFormat }, (*User).SetWeekdayFormat),
+	int32Field("ShortDateFormat", func(s *userSpec) *int32 { return s.ShortDateFormat }, (*User).SetShortDateFormat),
+	int32Field("LongDateFormat", func(s *userSpec) *int32 { return s.LongDateFormat }, (*User).SetLongDateFormat),
+	int32Field("ShortTimeFormat", func(s *userSpec) *int32 { return s.ShortTimeFormat }, (*User).SetShortTimeFormat),
+	int32Field("LongTimeFormat", func(s *userSpec) *int32 { return s.LongTimeFormat }, (*User).SetLongTimeFormat),
+	int32Field("WeekBegins", func(s *userSpec) *int32 { return s.WeekBegins }, (*User).SetWeekBegins),
+	stringField("GreeterBackground", func(s *userSpec) *string { return s.GreeterBackground }, (*User).SetGreeterBackground),
+	int32Field("ExpirationDate", func(s *userSpec) *int32 { return s.ExpirationDate }, (*User).SetExpirationDate),
+	int32Field("ExpiredAction", func(s *userSpec) *int32 { return s.ExpiredAction }, (*User).SetExpiredAction),
+	stringField("LoginTimeWindows", func(s *userSpec) *string { return s.LoginTimeWindows }, (*User).SetLoginTimeWindows),
+	boolField("AutomaticLogin", func(s *userSpec) *bool { return s.AutomaticLogin }, (*User).SetAutomaticLogin),
+}
+
+func getProvisionField(name string) *provisionField {
+	for i := range provisionFields {
+		if provisionFields[i].name == name {
+			return &provisionFields[i]
+		}
+	}
+	return nil
+}
+
+// diffUserSpec 比较用户的当前设置 cur 和期望的设置 want，返回需要修改的项；
+// cur 为 nil 表示用户不存在，此时 want 中设置了的字段都需要修改。
+func diffUserSpec(cur, want *userSpec) []provisionChange {
+	var changes []provisionChange
+	if cur == nil {
+		changes = append(changes, provisionChange{
+			User:  want.Name,
+			Field: provisionFieldCreate,
+			New:   getInt32Ptr(want.AccountType),
+		})
+	}
+
+	for _, field := range provisionFields {
+		newVal := field.get(want)
+		if newVal == nil {
+			continue
+		}
+		var oldVal interface{}
+		if cur != nil {
+			oldVal = field.get(cur)
+			if oldVal != nil && field.equal(oldVal, newVal) {
+				continue
+			}
+		}
+		changes = append(changes, provisionChange{
+			User:  want.Name,
+			Field: field.name,
+			Old:   oldVal,
+			New:   newVal,
+		})
+	}
+	return changes
+}
+
+// parseUserSpecs 解析并检查 ProvisionUsers 的参数
+func parseUserSpecs(data string) ([]*userSpec, error) {
+	var specs []*userSpec
+	err := json.Unmarshal([]byte(data), &specs)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]struct{})
+	for _, spec := range specs {
+		if spec == nil || spec.Name == "" {
+			return nil, errors.New("user name is empty")
+		}
+		if _, ok := names[spec.Name]; ok {
+			return nil, fmt.Errorf("duplicate user %q", spec.Name)
+		}
+		names[spec.Name] = struct{}{}
+
+		if spec.AccountType != nil {
+			err = checkAccountType(int(*spec.AccountType))
+			if err != nil {
+				return nil, fmt.Errorf("user %q: %v", spec.Name, err)
+			}
+		}
+
+		// 和 User 中保存的格式一致，否则每次都会被当作修改
+		if spec.LoginTimeWindows != nil {
+			windows, err := normalizeLoginTimeWindows(*spec.LoginTimeWindows)
+			if err != nil {
+				return nil, fmt.Errorf("user %q: %v", spec.Name, err)
+			}
+			spec.LoginTimeWindows = &windows
+		}
+	}
+	return specs, nil
+}
+
+// toSpec 导出用户的当前设置
+func (u *User) toSpec() *userSpec {
+	u.PropsMu.RLock()
+	defer u.PropsMu.RUnlock()
+
+	accountType := u.AccountType
+	fullName := u.FullName
+	groups := append([]string{}, u.Groups...)
+	locale := u.Locale
+	layout := u.Layout
+	historyLayout := append([]string{}, u.HistoryLayout...)
+	iconFile := u.IconFile
+	use24HourFormat := u.Use24HourFormat
+	weekdayFormat := u.WeekdayFormat
+	shortDateFormat := u.ShortDateFormat
+	longDateFormat := u.LongDateFormat
+	shortTimeFormat := u.ShortTimeFormat
+	longTimeFormat := u.LongTimeFormat
+	weekBegins := u.WeekBegins
+	greeterBackground := u.GreeterBackground
+	expirationDate := u.ExpirationDate
+	expiredAction := u.ExpiredAction
+	loginTimeWindows := u.LoginTimeWindows
+	automaticLogin := u.AutomaticLogin
+
+	return &userSpec{
+		Name:              u.UserName,
+		AccountType:       &accountType,
+		FullName:          &fullName,
+		Groups:            &groups,
+		Locale:            &locale,
+		Layout:            &layout,
+		HistoryLayout:     &historyLayout,
+		IconFile:          &iconFile,
+		Use24HourFormat:   &use24HourFormat,
+		WeekdayFormat:     &weekdayFormat,
+		ShortDateFormat:   &shortDateFormat,
+		LongDateFormat:    &longDateFormat,
+		ShortTimeFormat:   &shortTimeFormat,
+		LongTimeFormat:    &longTimeFormat,
+		WeekBegins:        &weekBegins,
+		GreeterBackground: &greeterBackground,
+		ExpirationDate:    &expirationDate,
+		ExpiredAction:     &expiredAction,
+		LoginTimeWindows:  &loginTimeWindows,
+		AutomaticLogin:    &automaticLogin,
+	}
+}
+
+// isExportableIcon 判断头像能不能导出，只导出系统自带的头像。
+// 自定义头像保存在本机的 /var/lib/AccountsService/icons/local 中，在其他机器上不存在，设置时会失败。
+func isExportableIcon(icon string, standardIcons []string) bool {
+	return icon == defaultUserIcon || isStrInArray(icon, standardIcons)
+}
+
+// provisionUser 创建或者更新一个用户，返回已经做的修改
+func (m *Manager) provisionUser(sender dbus.Sender, spec *userSpec, dryRun bool) ([]provisionChange, error) {
+	u := m.getUserByName(spec.Name)
+	var cur *userSpec
+	if u != nil {
+		cur = u.toSpec()
+	}
+	changes := diffUserSpec(cur, spec)
+	if dryRun || len(changes) == 0 {
+		return changes, nil
+	}
+
+	var done []provisionChange
+	for _, change := range changes {
+		if change.Field == provisionFieldCreate {
+			var accountType int32 = users.UserTypeStandard
+			if spec.AccountType != nil {
+				accountType = *spec.AccountType
+			}
+			var fullName string
+			if spec.FullName != nil {
+				fullName = *spec.FullName
+			}
+			_, busErr := m.CreateUser(sender, spec.Name, fullName, accountType)
+			if busErr != nil {
+				return done, fmt.Errorf("create user %q: %v", spec.Name, busErr)
+			}
+			u = m.getUserByName(spec.Name)
+			if u == nil {
+				return done, fmt.Errorf("user %q not found after creation", spec.Name)
+			}
+			done = append(done, change)
+			continue
+		}
+
+		field := getProvisionField(change.Field)
+		busErr := field.set(u, sender, change.New)
+		if busErr != nil {
+			return done, fmt.Errorf("set %s for user %q: %v", change.Field, spec.Name, busErr)
+		}
+		done = append(done, change)
+	}
+	return done, nil
+}
+
+// ExportUser 导出用户的设置，包括头像、用户组、语言、键盘布局、时间日期格式、
+// 登录界面背景、账户期限和自动登录，结果可以直接用于 ProvisionUsers。自定义头像不导出。
+func (m *Manager) ExportUser(sender dbus.Sender, name string) (userJSON string, busErr *dbus.Error) {
+	err := m.checkAuth(sender)
+	if err != nil {
+		logger.Debug("[ExportUser] access denied:", err)
+		return "", dbusutil.ToError(err)
+	}
+
+	u := m.getUserByName(name)
+	if u == nil {
+		return "", dbusutil.ToError(fmt.Errorf("user %q not found", name))
+	}
+
+	spec := u.toSpec()
+	if !isExportableIcon(*spec.IconFile, getUserStandardIcons()) {
+		spec.IconFile = nil
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+// ProvisionUsers 按照 usersJSON（userSpec 的数组）创建不存在的用户，并把已存在的用户修改成描述的设置，
+// 多次调用的结果相同。dryRun 为 true 时只计算不修改，返回 JSON 格式的修改列表。
+// 出错时停止，已经做的修改不会回滚，错误信息中包含 JSON 格式的已经做的修改。
+func (m *Manager) ProvisionUsers(sender dbus.Sender, usersJSON string, dryRun bool) (changesJSON string, busErr *dbus.Error) {
+	logger.Debug("[ProvisionUsers] dry run:", dryRun)
+
+	err := m.checkAuth(sender)
+	if err != nil {
+		logger.Debug("[ProvisionUsers] access denied:", err)
+		return "", dbusutil.ToError(err)
+	}
+
+	specs, err := parseUserSpecs(usersJSON)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+
+	changes := []provisionChange{}
+	for _, spec := range specs {
+		userChanges, err := m.provisionUser(sender, spec, dryRun)
+		changes = append(changes, userChanges...)
+		if err != nil {
+			logger.Warning("[ProvisionUsers]", err)
+			return "", dbusutil.ToError(newProvisionError(err, changes))
+		}
+	}
+
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+// newProvisionError 返回包含已经做的修改的错误，DBus 方法出错时没有返回值，只能放在错误信息中
+func newProvisionError(err error, changes []provisionChange) error {
+	data, jsonErr := json.Marshal(changes)
+	if jsonErr != nil {
+		return err
+	}
+	return fmt.Errorf("%v, applied changes: %s", err, data)
+}
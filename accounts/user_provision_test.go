@@ -0,0 +1,109 @@
+/*
+ * Copyright (C) 2016 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package accounts
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseUserSpecs(t *testing.T) {
+	specs, err := parseUserSpecs(`[{"Name":"lab","AccountType":0,"Locale":"zh_CN.UTF-8","Groups":["audio","video"]}]`)
+	require.Nil(t, err)
+	require.Len(t, specs, 1)
+	assert.Equal(t, "lab", specs[0].Name)
+	require.NotNil(t, specs[0].AccountType)
+	assert.Equal(t, int32(0), *specs[0].AccountType)
+	require.NotNil(t, specs[0].Locale)
+	assert.Equal(t, "zh_CN.UTF-8", *specs[0].Locale)
+	assert.Equal(t, []string{"audio", "video"}, *specs[0].Groups)
+	assert.Nil(t, specs[0].Layout)
+
+	_, err = parseUserSpecs(`[{"Locale":"en_US.UTF-8"}]`)
+	assert.NotNil(t, err)
+	_, err = parseUserSpecs(`[{"Name":"a"},{"Name":"a"}]`)
+	assert.NotNil(t, err)
+	_, err = parseUserSpecs(`[{"Name":"a","AccountType":5}]`)
+	assert.NotNil(t, err)
+	_, err = parseUserSpecs(`{"Name":"a"}`)
+	assert.NotNil(t, err)
+}
+
+func Test_diffUserSpec(t *testing.T) {
+	var cur userSpec
+	err := json.Unmarshal([]byte(`{"Name":"lab","FullName":"Lab","Groups":["video","audio"],
+		"Locale":"en_US.UTF-8","Use24HourFormat":true,"WeekBegins":0,"AutomaticLogin":false}`), &cur)
+	require.Nil(t, err)
+
+	// 和当前设置相同时没有修改，用户组不考虑顺序
+	var want userSpec
+	err = json.Unmarshal([]byte(`{"Name":"lab","Groups":["audio","video"],"Locale":"en_US.UTF-8","WeekBegins":0}`), &want)
+	require.Nil(t, err)
+	assert.Len(t, diffUserSpec(&cur, &want), 0)
+	assert.Equal(t, []string{"audio", "video"}, *want.Groups)
+
+	want = userSpec{}
+	err = json.Unmarshal([]byte(`{"Name":"lab","Locale":"zh_CN.UTF-8","WeekBegins":1,"AutomaticLogin":true}`), &want)
+	require.Nil(t, err)
+	changes := diffUserSpec(&cur, &want)
+	assert.Equal(t, []provisionChange{
+		{User: "lab", Field: "Locale", Old: "en_US.UTF-8", New: "zh_CN.UTF-8"},
+		{User: "lab", Field: "WeekBegins", Old: int32(0), New: int32(1)},
+		{User: "lab", Field: "AutomaticLogin", Old: false, New: true},
+	}, changes)
+
+	// 用户不存在
+	want = userSpec{}
+	err = json.Unmarshal([]byte(`{"Name":"new","AccountType":1,"Layout":"us;"}`), &want)
+	require.Nil(t, err)
+	changes = diffUserSpec(nil, &want)
+	assert.Equal(t, []provisionChange{
+		{User: "new", Field: provisionFieldCreate, New: int32(1)},
+		{User: "new", Field: "Layout", New: "us;"},
+	}, changes)
+}
+
+func Test_provisionFields(t *testing.T) {
+	names := make(map[string]bool)
+	for _, field := range provisionFields {
+		assert.False(t, names[field.name], field.name)
+		names[field.name] = true
+		assert.Nil(t, field.get(&userSpec{}), field.name)
+	}
+	assert.Equal(t, "AutomaticLogin", provisionFields[len(provisionFields)-1].name)
+	assert.NotNil(t, getProvisionField("Locale"))
+	assert.Nil(t, getProvisionField(provisionFieldCreate))
+}
+
+func Test_isExportableIcon(t *testing.T) {
+	standardIcons := []string{"file:///var/lib/AccountsService/icons/1.png"}
+	assert.True(t, isExportableIcon(defaultUserIcon, standardIcons))
+	assert.True(t, isExportableIcon("file:///var/lib/AccountsService/icons/1.png", standardIcons))
+	assert.False(t, isExportableIcon("file:///var/lib/AccountsService/icons/local/lab-abc", standardIcons))
+}
+
+func Test_newProvisionError(t *testing.T) {
+	err := newProvisionError(errors.New("set Locale failed"), []provisionChange{
+		{User: "lab", Field: provisionFieldCreate, New: int32(0)},
+	})
+	assert.Equal(t, `set Locale failed, applied changes: [{"User":"lab","Field":"Create","New":0}]`, err.Error())
+}
@@ -24,6 +24,7 @@ package users
 #cgo LDFLAGS: -lcrypt
 
 #include <stdlib.h>
+#include <crypt.h>
 #include "passwd.h"
 */
 import "C"
@@ -51,6 +52,23 @@ func EncodePasswd(words string) string {
 	return C.GoString(C.mkpasswd(cwords))
 }
 
+// VerifyPasswd 判断明文密码 words 和 crypt 加密后的密码 hash 是否匹配
+func VerifyPasswd(words, hash string) bool {
+	if words == "" || hash == "" {
+		return false
+	}
+	cwords := C.CString(words)
+	defer C.free(unsafe.Pointer(cwords))
+	chash := C.CString(hash)
+	defer C.free(unsafe.Pointer(chash))
+
+	result := C.crypt(cwords, chash)
+	if result == nil {
+		return false
+	}
+	return C.GoString(result) == hash
+}
+
 func ExistPwUid(uid uint32) int {
 	return int(C.exist_pw_uid(C.uint(uid)))
 }
@@ -110,6 +110,30 @@ func isPasswordExpired(shadowInfo *ShadowInfo, today libdate.Date) bool {
 	return today.After(expireDate)
 }
 
+// IsAccountExpired 判断账户是否已经过期，即 shadow 文件中的 expire 字段
+func IsAccountExpired(username string) (bool, error) {
+	shadowInfo, err := GetShadowInfo(username)
+	if err != nil {
+		return false, err
+	}
+
+	today := libdate.TodayUTC()
+	return isAccountExpired(shadowInfo, today), nil
+}
+
+func isAccountExpired(shadowInfo *ShadowInfo, today libdate.Date) bool {
+	// shadow(5) 中 expire 为 0 的含义不明确，有的工具用它表示永不过期，
+	// 为了避免误删用户，和空字段一样当作永不过期
+	if shadowInfo.ExpireDate <= 0 {
+		// never expire
+		return false
+	}
+	// 从 expire 这一天开始账户不可用
+	expireDate := libdate.New(1970, 1, 1).Add(
+		libdate.PeriodOfDays(shadowInfo.ExpireDate))
+	return !today.Before(expireDate)
+}
+
 type Cache struct {
 	mu       sync.Mutex
 	ts       int64
@@ -255,6 +279,11 @@ func ModifyMaxPasswordAge(username string, nDays int) error {
 	return doAction(cmdChAge, []string{"-M", strconv.Itoa(nDays), username})
 }
 
+// ModifyExpireDate 设置账户过期的日期，days 是 1970-01-01 以来的天数，-1 表示永不过期
+func ModifyExpireDate(username string, days int) error {
+	return doAction(cmdChAge, []string{"-E", strconv.Itoa(days), username})
+}
+
 const (
 	// Same as the abbreviation in `passwd --status`
 	PasswordStatusUsable     = "P"
@@ -531,6 +560,11 @@ func parseShadow(data []byte) map[string]ShadowInfo {
 		maxPasswordAgeStr := string(items[4])
 		sInfo.MaxDays = strToInt(maxPasswordAgeStr, -1)
 
+		sInfo.ExpireDate = -1
+		if len(items) >= 8 {
+			sInfo.ExpireDate = strToInt(string(items[7]), -1)
+		}
+
 		result[sInfo.Name] = sInfo
 	}
 	return result
@@ -557,5 +591,6 @@ type ShadowInfo struct {
 	Name       string
 	LastChange int
 	MaxDays    int
+	ExpireDate int    // account expiration date, -1 means never
 	Status     string // password status
 }
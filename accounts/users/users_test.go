@@ -340,3 +340,26 @@ func Test_IsPasswordExpired(t *testing.T) {
 		assert.Equal(t, isPasswordExpired(testCase.shadowInfo, testCase.today), testCase.result)
 	}
 }
+
+func Test_parseShadowExpireDate(t *testing.T) {
+	infos := parseShadow([]byte("test1:*:16435:0:99999:7::18000:\ntest2:*:16435:0:99999:7:::\ntest3:*:16435:0:99999\n"))
+	assert.Equal(t, 18000, infos["test1"].ExpireDate)
+	assert.Equal(t, -1, infos["test2"].ExpireDate)
+	assert.Equal(t, -1, infos["test3"].ExpireDate)
+}
+
+func Test_IsAccountExpired(t *testing.T) {
+	for _, testCase := range []struct {
+		expireDate int
+		today      libdate.Date
+		result     bool
+	}{
+		{expireDate: -1, today: libdate.New(2019, 12, 6), result: false},
+		{expireDate: 2, today: libdate.New(1970, 1, 2), result: false}, // 1970-01-03
+		{expireDate: 2, today: libdate.New(1970, 1, 3), result: true},
+		{expireDate: 0, today: libdate.New(2019, 12, 6), result: false},
+	} {
+		shadowInfo := &ShadowInfo{ExpireDate: testCase.expireDate}
+		assert.Equal(t, testCase.result, isAccountExpired(shadowInfo, testCase.today))
+	}
+}
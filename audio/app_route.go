@@ -0,0 +1,329 @@
+package audio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	dbus "github.com/godbus/dbus"
+	"pkg.deepin.io/lib/dbusutil"
+	"pkg.deepin.io/lib/pulse"
+	"pkg.deepin.io/lib/xdg/basedir"
+)
+
+// AppRoute 记录应用使用的输出设备和音量，应用再次播放声音时恢复
+type AppRoute struct {
+	App    string  // 应用标识，和 SinkInput 的 AppId 相同
+	Sink   string  // sink 的名字，为空时使用默认输出设备
+	Volume float64 // 为 0 时不恢复音量
+}
+
+type appRouteTable struct {
+	mu       sync.Mutex
+	routes   map[string]*AppRoute // App => AppRoute
+	file     string
+	isSaving bool
+}
+
+var globalAppRoutesFile = filepath.Join(basedir.GetUserConfigDir(), "deepin/dde-daemon/audio-app-routes.json")
+
+// 拖动音量条时音量变化很频繁，音量的改变延迟保存
+var appRouteSaveDelay = time.Second
+
+func newAppRouteTable(file string) *appRouteTable {
+	return &appRouteTable{
+		routes: make(map[string]*AppRoute),
+		file:   file,
+	}
+}
+
+func (t *appRouteTable) load() error {
+	data, err := ioutil.ReadFile(t.file)
+	if err != nil {
+		return err
+	}
+	var routes []*AppRoute
+	err = json.Unmarshal(data, &routes)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, route := range routes {
+		if route == nil || route.App == "" {
+			continue
+		}
+		t.routes[route.App] = route
+	}
+	return nil
+}
+
+func (t *appRouteTable) saveNoLock() error {
+	data, err := json.MarshalIndent(t.listNoLock(), "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(t.file), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(t.file, data, 0644)
+}
+
+func (t *appRouteTable) saveLaterNoLock() {
+	if t.isSaving {
+		return
+	}
+	t.isSaving = true
+
+	time.AfterFunc(appRouteSaveDelay, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.isSaving = false
+		err := t.saveNoLock()
+		if err != nil {
+			logger.Warning("failed to save app routes:", err)
+		}
+	})
+}
+
+func (t *appRouteTable) listNoLock() []*AppRoute {
+	routes := make([]*AppRoute, 0, len(t.routes))
+	for _, route := range t.routes {
+		routes = append(routes, route)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].App < routes[j].App
+	})
+	return routes
+}
+
+func (t *appRouteTable) list() []AppRoute {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	routes := t.listNoLock()
+	result := make([]AppRoute, len(routes))
+	for idx, route := range routes {
+		result[idx] = *route
+	}
+	return result
+}
+
+func (t *appRouteTable) get(app string) (AppRoute, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	route, ok := t.routes[app]
+	if !ok {
+		return AppRoute{}, false
+	}
+	return *route, true
+}
+
+func (t *appRouteTable) set(route AppRoute) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.routes[route.App] = &route
+	return t.saveNoLock()
+}
+
+func (t *appRouteTable) getOrCreateNoLock(app string) *AppRoute {
+	route, ok := t.routes[app]
+	if !ok {
+		route = &AppRoute{App: app}
+		t.routes[app] = route
+	}
+	return route
+}
+
+func (t *appRouteTable) setSink(app, sinkName string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	route := t.getOrCreateNoLock(app)
+	if route.Sink == sinkName {
+		return nil
+	}
+	route.Sink = sinkName
+	return t.saveNoLock()
+}
+
+func (t *appRouteTable) setVolume(app string, volume float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	route := t.getOrCreateNoLock(app)
+	if route.Volume == volume {
+		return
+	}
+	route.Volume = volume
+	t.saveLaterNoLock()
+}
+
+func (t *appRouteTable) remove(app string) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.routes[app]; !ok {
+		return false, nil
+	}
+	delete(t.routes, app)
+	return true, t.saveNoLock()
+}
+
+// getSinkInputAppId 获取 sink-input 所属应用的标识，
+// 优先使用 correctIcon 识别出的应用，然后是进程的可执行文件名和应用名。
+func getSinkInputAppId(sinkInputInfo *pulse.SinkInput, correctedIcon string) string {
+	if correctedIcon != "" {
+		return correctedIcon
+	}
+	if processBin := sinkInputInfo.PropList[PropAppProcessBinary]; processBin != "" {
+		return processBin
+	}
+	return sinkInputInfo.PropList[PropAppName]
+}
+
+// 调用者需要持有 a.mu
+func (a *Audio) getSinkByNameNoLock(sinkName string) *Sink {
+	for _, sink := range a.sinks {
+		if sink.Name == sinkName {
+			return sink
+		}
+	}
+	return nil
+}
+
+// getAppRouteSinkNoLock 获取应用设置的输出设备，设备不存在时返回 nil，调用者需要持有 a.mu
+func (a *Audio) getAppRouteSinkNoLock(appId string) *Sink {
+	if appId == "" {
+		return nil
+	}
+	route, ok := a.appRoutes.get(appId)
+	if !ok || route.Sink == "" {
+		return nil
+	}
+	return a.getSinkByNameNoLock(route.Sink)
+}
+
+// applyAppRoute 把应用记录的输出设备和音量应用到 sink-input 上
+func (a *Audio) applyAppRoute(sinkInput *SinkInput) {
+	appId := sinkInput.getAppId()
+	if appId == "" {
+		return
+	}
+	route, ok := a.appRoutes.get(appId)
+	if !ok {
+		return
+	}
+	ctx := a.context()
+	if ctx == nil {
+		return
+	}
+
+	a.mu.Lock()
+	sink := a.getAppRouteSinkNoLock(appId)
+	a.mu.Unlock()
+	if sink != nil && sink.index != sinkInput.getPropSinkIndex() {
+		logger.Debugf("move sink-input #%d of %q to sink %s", sinkInput.index, appId, sink.Name)
+		ctx.MoveSinkInputsByIndex([]uint32{sinkInput.index}, sink.index)
+	}
+
+	if route.Volume > 0 && isVolumeValid(route.Volume) {
+		sinkInput.PropsMu.RLock()
+		cv := sinkInput.cVolume.SetAvg(route.Volume)
+		sinkInput.PropsMu.RUnlock()
+		ctx.SetSinkInputVolume(sinkInput.index, cv)
+	}
+}
+
+func (a *Audio) getSinkInputsOfApp(appId string) []*SinkInput {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var result []*SinkInput
+	for _, sinkInput := range a.sinkInputs {
+		if sinkInput.getAppId() == appId {
+			result = append(result, sinkInput)
+		}
+	}
+	return result
+}
+
+func (a *Audio) moveSinkInputToSinkByName(sinkInput *SinkInput, sinkName string) error {
+	ctx := a.context()
+	if ctx == nil {
+		return errors.New("pulse context is not ready")
+	}
+	a.mu.Lock()
+	sink := a.getSinkByNameNoLock(sinkName)
+	a.mu.Unlock()
+	if sink == nil {
+		return fmt.Errorf("not found sink %q", sinkName)
+	}
+
+	if sink.index != sinkInput.getPropSinkIndex() {
+		ctx.MoveSinkInputsByIndex([]uint32{sinkInput.index}, sink.index)
+	}
+	appId := sinkInput.getAppId()
+	if appId == "" {
+		return nil
+	}
+	return a.appRoutes.setSink(appId, sinkName)
+}
+
+func (a *Audio) rememberAppVolume(sinkInput *SinkInput, volume float64) {
+	appId := sinkInput.getAppId()
+	if appId == "" {
+		return
+	}
+	a.appRoutes.setVolume(appId, volume)
+}
+
+func (a *Audio) GetAppRoutes() (routesJSON string, busErr *dbus.Error) {
+	data, err := json.Marshal(a.appRoutes.list())
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+// SetAppRoute 设置应用使用的输出设备和音量，立即应用到应用正在播放的声音上。
+// sinkName 为空时使用默认输出设备，volume 为 0 时不恢复音量。
+func (a *Audio) SetAppRoute(app, sinkName string, volume float64) *dbus.Error {
+	if app == "" {
+		return dbusutil.ToError(errors.New("app is empty"))
+	}
+	if !isVolumeValid(volume) {
+		return dbusutil.ToError(fmt.Errorf("invalid volume value: %v", volume))
+	}
+	if sinkName == "" && volume == 0 {
+		return dbusutil.ToError(errors.New("neither sink nor volume is set"))
+	}
+
+	err := a.appRoutes.set(AppRoute{
+		App:    app,
+		Sink:   sinkName,
+		Volume: volume,
+	})
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	for _, sinkInput := range a.getSinkInputsOfApp(app) {
+		a.applyAppRoute(sinkInput)
+	}
+	return nil
+}
+
+// ResetAppRoute 删除应用的设置，应用的声音回到默认输出设备
+func (a *Audio) ResetAppRoute(app string) *dbus.Error {
+	removed, err := a.appRoutes.remove(app)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	if removed && a.context() != nil {
+		a.moveSinkInputsToDefaultSink()
+	}
+	return nil
+}
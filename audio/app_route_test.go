@@ -0,0 +1,92 @@
+package audio
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pkg.deepin.io/lib/pulse"
+)
+
+func Test_appRouteTable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audio")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "dde-daemon", "audio-app-routes.json")
+	table := newAppRouteTable(file)
+	_, ok := table.get("firefox")
+	assert.False(t, ok)
+
+	require.NoError(t, table.setSink("zoom", "bluez_sink.headset"))
+	table.setVolume("zoom", 0.8)
+	table.setVolume("firefox", 0.5)
+	require.NoError(t, table.set(AppRoute{App: "deepin-music", Sink: "alsa_output.speaker"}))
+
+	table = newAppRouteTable(file)
+	require.NoError(t, table.load())
+	assert.Equal(t, []AppRoute{
+		{App: "deepin-music", Sink: "alsa_output.speaker"},
+		{App: "firefox", Volume: 0.5},
+		{App: "zoom", Sink: "bluez_sink.headset", Volume: 0.8},
+	}, table.list())
+
+	removed, err := table.remove("firefox")
+	assert.NoError(t, err)
+	assert.True(t, removed)
+	removed, err = table.remove("firefox")
+	assert.NoError(t, err)
+	assert.False(t, removed)
+	_, ok = table.get("firefox")
+	assert.False(t, ok)
+}
+
+func Test_appRouteTableSaveVolumeLater(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audio")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	defer func(delay time.Duration) {
+		appRouteSaveDelay = delay
+	}(appRouteSaveDelay)
+	appRouteSaveDelay = 50 * time.Millisecond
+
+	file := filepath.Join(dir, "audio-app-routes.json")
+	table := newAppRouteTable(file)
+	for _, volume := range []float64{0.1, 0.2, 0.3} {
+		table.setVolume("firefox", volume)
+	}
+	_, err = os.Stat(file)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Eventually(t, func() bool {
+		table := newAppRouteTable(file)
+		if table.load() != nil {
+			return false
+		}
+		route, _ := table.get("firefox")
+		return route.Volume == 0.3
+	}, time.Second, 10*time.Millisecond)
+
+	table.mu.Lock()
+	assert.False(t, table.isSaving)
+	table.mu.Unlock()
+}
+
+func Test_getSinkInputAppId(t *testing.T) {
+	info := &pulse.SinkInput{
+		PropList: map[string]string{
+			PropAppName:          "Firefox",
+			PropAppProcessBinary: "plugin-container",
+		},
+	}
+	assert.Equal(t, "firefox", getSinkInputAppId(info, "firefox"))
+	assert.Equal(t, "plugin-container", getSinkInputAppId(info, ""))
+
+	delete(info.PropList, PropAppProcessBinary)
+	assert.Equal(t, "Firefox", getSinkInputAppId(info, ""))
+}
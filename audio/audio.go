@@ -23,6 +23,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"sort"
 	"strings"
@@ -160,6 +161,17 @@ type Audio struct {
 
 	noRestartPulseAudio bool
 
+	// 应用的输出设备和音量
+	appRoutes *appRouteTable
+
+	// 当前的音效，effectMaster 是音效所在的输出设备
+	effectMu     sync.Mutex
+	effectMaster string
+	effectConfig *EffectConfig
+
+	// 创建、删除和恢复虚拟设备时持有，检查设备是否存在和加载模块不会被打断
+	virtualDeviceMu sync.Mutex
+
 	// 当前输入端口
 	inputCardName string
 	inputPortName string
@@ -187,6 +199,11 @@ func newAudio(service *dbusutil.Service) *Audio {
 		meters:       make(map[string]*Meter),
 		MaxUIVolume:  pulse.VolumeUIMax,
 		enableSource: true,
+		appRoutes:    newAppRouteTable(globalAppRoutesFile),
+	}
+	err := a.appRoutes.load()
+	if err != nil && !os.IsNotExist(err) {
+		logger.Warning("failed to load app routes:", err)
 	}
 
 	a.settings = gio.NewSettings(gsSchemaAudio)
@@ -290,6 +307,8 @@ func (a *Audio) addSinkInput(sinkInputInfo *pulse.SinkInput) {
 	logger.Debug("updatePropSinkInputs")
 	a.updatePropSinkInputs()
 	logger.Debug("updatePropSinkInputs done")
+	// 应用重新播放声音时恢复记录的输出设备和音量
+	a.applyAppRoute(sinkInput)
 }
 
 func (a *Audio) refreshSinks() {
@@ -478,6 +497,7 @@ func (a *Audio) init() error {
 
 	GetBluezAudioManager().Load()
 	GetConfigKeeper().Load()
+	a.restoreVirtualDevices()
 
 	logger.Debug("init cards")
 	a.PropsMu.Lock()
@@ -915,20 +935,28 @@ func (a *Audio) moveSinkInputsToSink(sinkId uint32) {
 		a.mu.Unlock()
 		return
 	}
-	var list []uint32
+	lists := make(map[uint32][]uint32)
 	for _, sinkInput := range a.sinkInputs {
-		if sinkInput.getPropSinkIndex() == sinkId {
+		// filter 的输出由模块的 master 决定，移动会破坏音效等虚拟设备
+		if sinkInput.filter {
+			continue
+		}
+		targetId := sinkId
+		// 设置了输出设备的应用，在设备存在时使用自己的设备
+		if sink := a.getAppRouteSinkNoLock(sinkInput.getAppId()); sink != nil {
+			targetId = sink.index
+		}
+		if sinkInput.getPropSinkIndex() == targetId {
 			continue
 		}
 
-		list = append(list, sinkInput.index)
+		lists[targetId] = append(lists[targetId], sinkInput.index)
 	}
 	a.mu.Unlock()
-	if len(list) == 0 {
-		return
+	for targetId, list := range lists {
+		logger.Debugf("move sink inputs %v to sink #%d", list, targetId)
+		a.ctx.MoveSinkInputsByIndex(list, targetId)
 	}
-	logger.Debugf("move sink inputs %v to sink #%d", list, sinkId)
-	a.ctx.MoveSinkInputsByIndex(list, sinkId)
 }
 
 func isPortExists(name string, ports []pulse.PortInfo) bool {
@@ -971,6 +999,9 @@ func (a *Audio) resumeSinkConfig(s *Sink) {
 		// 意外原因切换到被禁用的端口上，例如没有可用端口
 		s.setMute(true)
 	}
+
+	// 切换到端口对应的音效
+	a.applySinkEffects(s)
 }
 
 func (a *Audio) resumeSourceConfig(s *Source, isPhyDev bool) {
@@ -1028,7 +1059,8 @@ func (a *Audio) updateDefaultSink(sinkName string) {
 	}
 	logger.Debugf("updateDefaultSink #%d %s", sinkInfo.Index, sinkName)
 	a.moveSinkInputsToSink(sinkInfo.Index)
-	if !isPhysicalDevice(sinkName) {
+	// 音效是多个串联的虚拟设备，需要一直找到真正的输出设备
+	for i := 0; !isPhysicalDevice(sinkInfo.Name) && i < 5; i++ {
 		sinkInfo = a.getSinkInfoByName(sinkInfo.PropList["device.master_device"])
 		if sinkInfo == nil {
 			logger.Warning("failed to get virtual device sinkInfo for name:", sinkName)
@@ -487,6 +487,19 @@ func (v *SinkInput) emitPropChangedIcon(value string) error {
 	return v.service.EmitPropertyChanged(v, "Icon", value)
 }
 
+func (v *SinkInput) setPropAppId(value string) (changed bool) {
+	if v.AppId != value {
+		v.AppId = value
+		v.emitPropChangedAppId(value)
+		return true
+	}
+	return false
+}
+
+func (v *SinkInput) emitPropChangedAppId(value string) error {
+	return v.service.EmitPropertyChanged(v, "AppId", value)
+}
+
 func (v *SinkInput) setPropMute(value bool) (changed bool) {
 	if v.Mute != value {
 		v.Mute = value
@@ -522,6 +522,7 @@ func (a *Audio) updatePropSinkInputs() {
 func isPhysicalDevice(deviceName string) bool {
 	for _, virtualDeviceKey := range []string{
 		"echoCancelSource", "echo-cancel", "Echo-Cancel", // virtual key
+		effectSinkPrefix,
 	} {
 		if strings.Contains(deviceName, virtualDeviceKey) {
 			return false
@@ -3,7 +3,9 @@ package audio
 import (
 	"encoding/json"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"sync"
 
 	"pkg.deepin.io/lib/xdg/basedir"
 )
@@ -15,7 +17,8 @@ type PortConfig struct {
 	IncreaseVolume bool
 	Balance        float64
 	ReduceNoise    bool
-	Mute           bool // 静音改为全局，此配置废弃
+	Mute           bool          // 静音改为全局，此配置废弃
+	Effect         *EffectConfig `json:",omitempty"` // 音效
 }
 
 type CardConfig struct {
@@ -29,18 +32,21 @@ type MuteConfig struct {
 }
 
 type ConfigKeeper struct {
-	Cards    map[string]*CardConfig // Name => CardConfig
-	Mute     *MuteConfig            // 全局静音
-	file     string                 // 配置文件路径
-	muteFile string                 // 静音配置文件路径
+	Cards          map[string]*CardConfig // Name => CardConfig
+	Mute           *MuteConfig            // 全局静音
+	VirtualDevices []*VirtualDeviceConfig // 虚拟设备，登录时恢复
+	virtualMu      sync.Mutex             // 保护 VirtualDevices
+	file           string                 // 配置文件路径
+	muteFile       string                 // 静音配置文件路径
+	virtualFile    string                 // 虚拟设备配置文件路径
 }
 
 // 创建单例
-func createConfigKeeperSingleton(path string, mutePath string) func() *ConfigKeeper {
+func createConfigKeeperSingleton(path string, mutePath string, virtualPath string) func() *ConfigKeeper {
 	var ck *ConfigKeeper = nil
 	return func() *ConfigKeeper {
 		if ck == nil {
-			ck = NewConfigKeeper(path, mutePath)
+			ck = NewConfigKeeper(path, mutePath, virtualPath)
 		}
 		return ck
 	}
@@ -50,14 +56,16 @@ func createConfigKeeperSingleton(path string, mutePath string) func() *ConfigKee
 // 由于优先级管理需要在很多个对象中使用，放在Audio对象中需要添加额外参数传递到各个模块很不方便，因此在此创建一个全局的单例
 var globalConfigKeeperFile = filepath.Join(basedir.GetUserConfigDir(), "deepin/dde-daemon/audio-config-keeper.json")
 var globalConfigKeeperMuteFile = filepath.Join(basedir.GetUserConfigDir(), "deepin/dde-daemon/audio-config-keeper-mute.json")
-var GetConfigKeeper = createConfigKeeperSingleton(globalConfigKeeperFile, globalConfigKeeperMuteFile)
+var globalConfigKeeperVirtualFile = filepath.Join(basedir.GetUserConfigDir(), "deepin/dde-daemon/audio-config-keeper-virtual.json")
+var GetConfigKeeper = createConfigKeeperSingleton(globalConfigKeeperFile, globalConfigKeeperMuteFile, globalConfigKeeperVirtualFile)
 
-func NewConfigKeeper(path string, mutePath string) *ConfigKeeper {
+func NewConfigKeeper(path string, mutePath string, virtualPath string) *ConfigKeeper {
 	return &ConfigKeeper{
-		Cards:    make(map[string]*CardConfig),
-		Mute:     NewMuteConfig(),
-		file:     path,
-		muteFile: mutePath,
+		Cards:       make(map[string]*CardConfig),
+		Mute:        NewMuteConfig(),
+		file:        path,
+		muteFile:    mutePath,
+		virtualFile: virtualPath,
 	}
 }
 
@@ -112,10 +120,30 @@ func (ck *ConfigKeeper) Save() error {
 		return err
 	}
 
+	ck.virtualMu.Lock()
+	data, err = json.MarshalIndent(ck.VirtualDevices, "", "  ")
+	ck.virtualMu.Unlock()
+	if err != nil {
+		logger.Warning(err)
+		return err
+	}
+
+	err = ioutil.WriteFile(ck.virtualFile, data, 0644)
+	if err != nil {
+		logger.Warning(err)
+		return err
+	}
+
 	return nil
 }
 
 func (ck *ConfigKeeper) Load() error {
+	// 虚拟设备的配置单独保存，不受其他配置文件的影响
+	err := ck.loadVirtualDevices()
+	if err != nil && !os.IsNotExist(err) {
+		logger.Warning(err)
+	}
+
 	data, err := ioutil.ReadFile(ck.file)
 	if err != nil {
 		logger.Warning(err)
@@ -142,6 +170,37 @@ func (ck *ConfigKeeper) Load() error {
 	return nil
 }
 
+func (ck *ConfigKeeper) loadVirtualDevices() error {
+	data, err := ioutil.ReadFile(ck.virtualFile)
+	if err != nil {
+		return err
+	}
+
+	var devices []*VirtualDeviceConfig
+	err = json.Unmarshal(data, &devices)
+	if err != nil {
+		return err
+	}
+
+	var validDevices []*VirtualDeviceConfig
+	for _, device := range devices {
+		if device == nil {
+			continue
+		}
+		err = device.check()
+		if err != nil {
+			logger.Warningf("invalid virtual device %q: %v", device.Name, err)
+			continue
+		}
+		validDevices = append(validDevices, device)
+	}
+
+	ck.virtualMu.Lock()
+	ck.VirtualDevices = validDevices
+	ck.virtualMu.Unlock()
+	return nil
+}
+
 func (ck *ConfigKeeper) Print() {
 	data, err := json.MarshalIndent(ck.Cards, "", "  ")
 	if err != nil {
@@ -208,6 +267,12 @@ func (ck *ConfigKeeper) SetReduceNoise(cardName string, portName string, reduce
 	ck.Save()
 }
 
+func (ck *ConfigKeeper) SetEffect(cardName string, portName string, effect *EffectConfig) {
+	_, port := ck.GetCardAndPortConfig(cardName, portName)
+	port.Effect = effect
+	ck.Save()
+}
+
 func (ck *ConfigKeeper) SetMuteOutput(mute bool) {
 	ck.Mute.MuteOutput = mute
 	ck.Save()
@@ -227,6 +292,45 @@ func (ck *ConfigKeeper) SetMuteAll(mute bool) {
 	ck.Save()
 }
 
+// ListVirtualDevices 返回虚拟设备列表的副本
+func (ck *ConfigKeeper) ListVirtualDevices() []*VirtualDeviceConfig {
+	ck.virtualMu.Lock()
+	defer ck.virtualMu.Unlock()
+	devices := make([]*VirtualDeviceConfig, len(ck.VirtualDevices))
+	copy(devices, ck.VirtualDevices)
+	return devices
+}
+
+func (ck *ConfigKeeper) GetVirtualDevice(name string) *VirtualDeviceConfig {
+	ck.virtualMu.Lock()
+	defer ck.virtualMu.Unlock()
+	for _, device := range ck.VirtualDevices {
+		if device.Name == name {
+			return device
+		}
+	}
+	return nil
+}
+
+func (ck *ConfigKeeper) AddVirtualDevice(device *VirtualDeviceConfig) {
+	ck.virtualMu.Lock()
+	ck.VirtualDevices = append(ck.VirtualDevices, device)
+	ck.virtualMu.Unlock()
+	ck.Save()
+}
+
+func (ck *ConfigKeeper) RemoveVirtualDevice(name string) {
+	ck.virtualMu.Lock()
+	for idx, device := range ck.VirtualDevices {
+		if device.Name == name {
+			ck.VirtualDevices = append(ck.VirtualDevices[:idx], ck.VirtualDevices[idx+1:]...)
+			break
+		}
+	}
+	ck.virtualMu.Unlock()
+	ck.Save()
+}
+
 func (card *CardConfig) UpdatePortConfig(portConfig *PortConfig) {
 	card.Ports[portConfig.Name] = portConfig
 }
@@ -0,0 +1,385 @@
+package audio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	dbus "github.com/godbus/dbus"
+	"pkg.deepin.io/lib/dbusutil"
+)
+
+// 音效通过在输出设备上串联 filter sink 实现，从上到下依次是：
+// 均衡器(module-ladspa-sink, caps EqFA4p) -> 限幅器(module-ladspa-sink, fastLookaheadLimiter)
+// -> 单声道(module-remap-sink) -> 输出设备。
+// 最上面的 filter sink 会被设置为默认输出设备，和降噪的 echo-cancel 一样，
+// updateDefaultSink 通过 device.master_device 找到真正的输出设备。
+const (
+	effectSinkPrefix    = "dde-effect-"
+	effectSinkEqualizer = effectSinkPrefix + "equalizer"
+	effectSinkLimiter   = effectSinkPrefix + "limiter"
+	effectSinkMono      = effectSinkPrefix + "mono"
+
+	// 参数均衡器最多 10 个频段，每个频段可以设置中心频率、增益和 Q 值。
+	// caps EqFA4p 有 4 个参数均衡频段，串联 3 个实现。
+	equalizerBandCount = 10
+	eqFA4pBandCount    = 4
+	minBandGain        = -12.0
+	maxBandGain        = 12.0
+	minBandFreq        = 20.0
+	maxBandFreq        = 14000.0
+	minBandQ           = 0.1
+	maxBandQ           = 10.0
+	defaultBandQ       = 1.41 // 带宽约 1 个倍频程
+
+	presetCustom = "custom"
+
+	limiterThreshold = -1.0 // dB
+	limiterRelease   = 0.05 // 秒
+)
+
+// EqualizerBand 是参数均衡器的一个频段
+type EqualizerBand struct {
+	Freq float64 // 中心频率，单位 Hz
+	Gain float64 // 增益，单位 dB
+	Q    float64 // Q 值，越大影响的频率范围越窄，为 0 时使用默认值 1.41
+}
+
+type EqualizerPreset struct {
+	Name  string
+	Bands []EqualizerBand
+}
+
+// 预设使用的中心频率，间隔约 1 个倍频程
+var presetBandFreqs = []float64{31, 63, 125, 250, 500, 1000, 2000, 4000, 8000, 12000}
+
+func newPresetBands(gains ...float64) []EqualizerBand {
+	bands := make([]EqualizerBand, len(gains))
+	for idx, gain := range gains {
+		bands[idx] = EqualizerBand{Freq: presetBandFreqs[idx], Gain: gain, Q: defaultBandQ}
+	}
+	return bands
+}
+
+var equalizerPresets = []EqualizerPreset{
+	{Name: "flat", Bands: newPresetBands(0, 0, 0, 0, 0, 0, 0, 0, 0, 0)},
+	{Name: "bass-boost", Bands: newPresetBands(6, 5, 4, 2, 0, 0, 0, 0, 0, 0)},
+	{Name: "treble-boost", Bands: newPresetBands(0, 0, 0, 0, 0, 1, 2, 4, 5, 6)},
+	{Name: "vocal", Bands: newPresetBands(-3, -2, -1, 1, 3, 3, 2, 1, 0, -1)},
+	{Name: "laptop-speaker", Bands: newPresetBands(-12, -8, -2, 2, 3, 2, 1, 2, 3, 2)},
+	{Name: "headphone", Bands: newPresetBands(3, 2, 1, 0, -1, 0, 1, 2, 2, 1)},
+}
+
+func findEqualizerPreset(name string) *EqualizerPreset {
+	for idx := range equalizerPresets {
+		if equalizerPresets[idx].Name == name {
+			return &equalizerPresets[idx]
+		}
+	}
+	return nil
+}
+
+// EffectConfig 是一个输出端口的音效设置
+type EffectConfig struct {
+	Preset  string          // 均衡器预设，为空或者 custom 时使用 Bands
+	Bands   []EqualizerBand // 均衡器的频段，最多 10 个
+	Limiter bool            // 限制响度，避免破音
+	Mono    bool            // 混合为单声道
+}
+
+func (cfg *EffectConfig) check() error {
+	if cfg.Preset != "" && cfg.Preset != presetCustom {
+		if findEqualizerPreset(cfg.Preset) == nil {
+			return fmt.Errorf("invalid preset %q", cfg.Preset)
+		}
+		return nil
+	}
+	if len(cfg.Bands) > equalizerBandCount {
+		return fmt.Errorf("the number of bands must not be more than %d", equalizerBandCount)
+	}
+	for _, band := range cfg.Bands {
+		if band.Freq < minBandFreq || band.Freq > maxBandFreq {
+			return fmt.Errorf("band frequency must be in range %v~%v", minBandFreq, maxBandFreq)
+		}
+		if band.Gain < minBandGain || band.Gain > maxBandGain {
+			return fmt.Errorf("band gain must be in range %v~%v", minBandGain, maxBandGain)
+		}
+		if band.Q != 0 && (band.Q < minBandQ || band.Q > maxBandQ) {
+			return fmt.Errorf("band Q must be in range %v~%v", minBandQ, maxBandQ)
+		}
+	}
+	return nil
+}
+
+func (cfg *EffectConfig) getBands() []EqualizerBand {
+	if cfg.Preset != "" && cfg.Preset != presetCustom {
+		if preset := findEqualizerPreset(cfg.Preset); preset != nil {
+			return preset.Bands
+		}
+	}
+	return cfg.Bands
+}
+
+func (cfg *EffectConfig) equalizerEnabled() bool {
+	for _, band := range cfg.getBands() {
+		if band.Gain != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (cfg *EffectConfig) isEmpty() bool {
+	return cfg == nil || (!cfg.equalizerEnabled() && !cfg.Limiter && !cfg.Mono)
+}
+
+type effectModule struct {
+	sink string
+	name string
+	args []string
+}
+
+func formatControls(values []float64) string {
+	controls := make([]string, len(values))
+	for idx, v := range values {
+		controls[idx] = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return strings.Join(controls, ",")
+}
+
+// qToBandwidth 把 Q 值转换为 EqFA4p 使用的以倍频程为单位的带宽
+func qToBandwidth(q float64) float64 {
+	if q == 0 {
+		q = defaultBandQ
+	}
+	bw := 2 / math.Ln2 * math.Asinh(1/(2*q))
+	return math.Round(bw*1000) / 1000
+}
+
+// getEqFA4pControls 返回 EqFA4p 的控制参数：4 个频段的开关、频率、带宽、增益，最后是总增益。
+// 不足 4 个频段时，其余的频段关闭。
+func getEqFA4pControls(bands []EqualizerBand) []float64 {
+	controls := make([]float64, 0, eqFA4pBandCount*4+1)
+	for idx := 0; idx < eqFA4pBandCount; idx++ {
+		if idx < len(bands) {
+			band := bands[idx]
+			controls = append(controls, 1, band.Freq, qToBandwidth(band.Q), band.Gain)
+		} else {
+			controls = append(controls, 0, 1000, 1, 0)
+		}
+	}
+	return append(controls, 0)
+}
+
+// getEffectModules 返回 master 上的音效需要加载的模块，按加载的顺序排列，最后一个是最上面的 sink
+func getEffectModules(master string, cfg *EffectConfig) []effectModule {
+	if cfg.isEmpty() {
+		return nil
+	}
+	var modules []effectModule
+	add := func(sink, name string, args ...string) {
+		args = append([]string{
+			"sink_name=" + sink,
+			"master=" + master,
+		}, args...)
+		modules = append(modules, effectModule{sink: sink, name: name, args: args})
+		master = sink
+	}
+
+	if cfg.Mono {
+		add(effectSinkMono, "module-remap-sink", "channels=2", "channel_map=mono,mono")
+	}
+	if cfg.Limiter {
+		add(effectSinkLimiter, "module-ladspa-sink",
+			"plugin=fast_lookahead_limiter_1913", "label=fastLookaheadLimiter",
+			"control="+formatControls([]float64{0, limiterThreshold, limiterRelease}))
+	}
+	if cfg.equalizerEnabled() {
+		// 每 4 个频段一个 EqFA4p，最上面的是 effectSinkEqualizer
+		bands := cfg.getBands()
+		for idx := 0; idx < len(bands); idx += eqFA4pBandCount {
+			end := idx + eqFA4pBandCount
+			sink := effectSinkEqualizer
+			if end < len(bands) {
+				sink = fmt.Sprintf("%s-%d", effectSinkEqualizer, idx/eqFA4pBandCount+1)
+			} else {
+				end = len(bands)
+			}
+			add(sink, "module-ladspa-sink",
+				"plugin=caps", "label=EqFA4p",
+				"control="+formatControls(getEqFA4pControls(bands[idx:end])))
+		}
+	}
+	return modules
+}
+
+func isEffectSink(sinkName string) bool {
+	return strings.HasPrefix(sinkName, effectSinkPrefix)
+}
+
+// unloadEffectModules 卸载所有音效模块，包括上次运行时留下的
+func unloadEffectModules() error {
+	modules, err := listPulseModules()
+	if err != nil {
+		return err
+	}
+	// 先卸载上层的模块
+	for i := len(modules) - 1; i >= 0; i-- {
+		for _, arg := range modules[i].Args {
+			if strings.HasPrefix(arg, "sink_name="+effectSinkPrefix) {
+				err = unloadPulseModule(modules[i].Index)
+				if err != nil {
+					logger.Warning(err)
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (a *Audio) getEffectMaster() string {
+	a.effectMu.Lock()
+	v := a.effectMaster
+	a.effectMu.Unlock()
+	return v
+}
+
+// applySinkEffects 根据 sink 当前端口的设置重建音效
+func (a *Audio) applySinkEffects(sink *Sink) {
+	if sink == nil || isEffectSink(sink.Name) {
+		return
+	}
+	sink.PropsMu.RLock()
+	cardId := sink.Card
+	portName := sink.ActivePort.Name
+	sink.PropsMu.RUnlock()
+
+	var cfg *EffectConfig
+	if a.isCardIdValid(cardId) && portName != "" {
+		_, portConfig := GetConfigKeeper().GetCardAndPortConfig(a.getCardNameById(cardId), portName)
+		cfg = portConfig.Effect
+	}
+	err := a.setEffectChain(sink.Name, cfg)
+	if err != nil {
+		logger.Warning("failed to set effect chain:", err)
+	}
+}
+
+func (a *Audio) setEffectChain(master string, cfg *EffectConfig) error {
+	a.effectMu.Lock()
+	defer a.effectMu.Unlock()
+
+	if cfg.isEmpty() {
+		cfg = nil
+	} else {
+		cfgCopy := *cfg
+		cfg = &cfgCopy
+	}
+	if a.effectMaster == master && reflect.DeepEqual(a.effectConfig, cfg) {
+		return nil
+	}
+	ctx := a.context()
+	if ctx == nil {
+		return errors.New("pulse context is not ready")
+	}
+	logger.Debugf("set effect chain on %s: %+v", master, cfg)
+
+	// 卸载前把默认输出设备切换回 master，避免声音被移到其他设备上
+	if isEffectSink(ctx.GetDefaultSink()) {
+		ctx.SetDefaultSink(master)
+	}
+	err := unloadEffectModules()
+	if err != nil {
+		return err
+	}
+	// 加载失败时也记录下来，避免每次切换默认输出设备时重试
+	a.effectMaster = master
+	a.effectConfig = cfg
+
+	modules := getEffectModules(master, cfg)
+	for _, module := range modules {
+		err = loadPulseModule(module.name, module.args)
+		if err != nil {
+			// 可能没有安装 LADSPA 插件
+			unloadErr := unloadEffectModules()
+			if unloadErr != nil {
+				logger.Warning(unloadErr)
+			}
+			return err
+		}
+	}
+	if len(modules) > 0 {
+		ctx.SetDefaultSink(modules[len(modules)-1].sink)
+	}
+	return nil
+}
+
+func (s *Sink) getEffectConfig() (*EffectConfig, error) {
+	s.PropsMu.RLock()
+	cardId := s.Card
+	portName := s.ActivePort.Name
+	s.PropsMu.RUnlock()
+	if !s.audio.isCardIdValid(cardId) || portName == "" {
+		return nil, errors.New("sink has no port")
+	}
+	_, portConfig := GetConfigKeeper().GetCardAndPortConfig(s.audio.getCardNameById(cardId), portName)
+	if portConfig.Effect == nil {
+		return &EffectConfig{Preset: "flat"}, nil
+	}
+	return portConfig.Effect, nil
+}
+
+// GetEqualizer 获取当前端口的音效设置
+func (s *Sink) GetEqualizer() (equalizerJSON string, busErr *dbus.Error) {
+	cfg, err := s.getEffectConfig()
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+// SetEqualizer 设置当前端口的音效，equalizerJSON 是 EffectConfig 的 JSON 格式
+func (s *Sink) SetEqualizer(equalizerJSON string) *dbus.Error {
+	var cfg EffectConfig
+	err := json.Unmarshal([]byte(equalizerJSON), &cfg)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	err = cfg.check()
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	_, err = s.getEffectConfig()
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	s.PropsMu.RLock()
+	cardName := s.audio.getCardNameById(s.Card)
+	portName := s.ActivePort.Name
+	s.PropsMu.RUnlock()
+	GetConfigKeeper().SetEffect(cardName, portName, &cfg)
+
+	if s.audio.getDefaultSink() == s {
+		s.audio.applySinkEffects(s)
+	}
+	return nil
+}
+
+// ListPresets 列出均衡器的预设
+func (*Sink) ListPresets() (presetsJSON string, busErr *dbus.Error) {
+	data, err := json.Marshal(equalizerPresets)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
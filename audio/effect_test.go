@@ -0,0 +1,106 @@
+package audio
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEffectConfig(t *testing.T) {
+	var cfg *EffectConfig
+	assert.True(t, cfg.isEmpty())
+
+	cfg = &EffectConfig{Preset: "flat"}
+	assert.NoError(t, cfg.check())
+	assert.True(t, cfg.isEmpty())
+
+	cfg.Preset = "bass-boost"
+	assert.NoError(t, cfg.check())
+	assert.False(t, cfg.isEmpty())
+	assert.Equal(t, findEqualizerPreset("bass-boost").Bands, cfg.getBands())
+
+	cfg.Preset = "unknown"
+	assert.Error(t, cfg.check())
+
+	cfg = &EffectConfig{Preset: presetCustom, Bands: make([]EqualizerBand, 11)}
+	assert.Error(t, cfg.check())
+	cfg.Bands = []EqualizerBand{{Freq: 100, Gain: 13}}
+	assert.Error(t, cfg.check())
+	cfg.Bands = []EqualizerBand{{Freq: 10, Gain: 3}}
+	assert.Error(t, cfg.check())
+	cfg.Bands = []EqualizerBand{{Freq: 100, Gain: 3, Q: 20}}
+	assert.Error(t, cfg.check())
+	cfg.Bands = []EqualizerBand{{Freq: 100, Gain: 0}, {Freq: 3000, Gain: 0, Q: 4}}
+	assert.NoError(t, cfg.check())
+	assert.True(t, cfg.isEmpty())
+	cfg.Mono = true
+	assert.False(t, cfg.isEmpty())
+
+	for _, preset := range equalizerPresets {
+		assert.Len(t, preset.Bands, equalizerBandCount, preset.Name)
+		assert.NoError(t, (&EffectConfig{Preset: presetCustom, Bands: preset.Bands}).check(), preset.Name)
+	}
+}
+
+func Test_qToBandwidth(t *testing.T) {
+	assert.Equal(t, 1.003, qToBandwidth(0))
+	assert.Equal(t, 1.003, qToBandwidth(defaultBandQ))
+	assert.Equal(t, 1.917, qToBandwidth(0.7))
+	assert.Equal(t, 0.36, qToBandwidth(4))
+}
+
+func Test_getEffectModules(t *testing.T) {
+	assert.Nil(t, getEffectModules("alsa_output.speaker", &EffectConfig{Preset: "flat"}))
+
+	modules := getEffectModules("alsa_output.speaker", &EffectConfig{
+		Preset: presetCustom,
+		Bands: []EqualizerBand{
+			{Freq: 60, Gain: -1.5, Q: 0.7},
+			{Freq: 250, Gain: 0},
+			{Freq: 1000, Gain: 0},
+			{Freq: 4000, Gain: 2, Q: 4},
+			{Freq: 12000, Gain: 3},
+		},
+		Limiter: true,
+		Mono:    true,
+	})
+	assert.Equal(t, []effectModule{
+		{
+			sink: effectSinkMono,
+			name: "module-remap-sink",
+			args: []string{"sink_name=dde-effect-mono", "master=alsa_output.speaker",
+				"channels=2", "channel_map=mono,mono"},
+		},
+		{
+			sink: effectSinkLimiter,
+			name: "module-ladspa-sink",
+			args: []string{"sink_name=dde-effect-limiter", "master=dde-effect-mono",
+				"plugin=fast_lookahead_limiter_1913", "label=fastLookaheadLimiter", "control=0,-1,0.05"},
+		},
+		{
+			sink: effectSinkEqualizer + "-1",
+			name: "module-ladspa-sink",
+			args: []string{"sink_name=dde-effect-equalizer-1", "master=dde-effect-limiter",
+				"plugin=caps", "label=EqFA4p",
+				"control=1,60,1.917,-1.5,1,250,1.003,0,1,1000,1.003,0,1,4000,0.36,2,0"},
+		},
+		{
+			sink: effectSinkEqualizer,
+			name: "module-ladspa-sink",
+			args: []string{"sink_name=dde-effect-equalizer", "master=dde-effect-equalizer-1",
+				"plugin=caps", "label=EqFA4p",
+				"control=1,12000,1.003,3,0,1000,1,0,0,1000,1,0,0,1000,1,0,0"},
+		},
+	}, modules)
+
+	// 10 个频段需要 3 个 EqFA4p，最上面的是 effectSinkEqualizer
+	modules = getEffectModules("alsa_output.speaker", &EffectConfig{Preset: "vocal"})
+	assert.Len(t, modules, 3)
+	assert.Equal(t, effectSinkEqualizer, modules[2].sink)
+	assert.Equal(t, "master=dde-effect-equalizer-2", modules[2].args[1])
+
+	modules = getEffectModules("alsa_output.speaker", &EffectConfig{Limiter: true})
+	assert.Len(t, modules, 1)
+	assert.Equal(t, "master=alsa_output.speaker", modules[0].args[1])
+	assert.False(t, isPhysicalDevice(effectSinkLimiter))
+}
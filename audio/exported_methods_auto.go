@@ -8,6 +8,31 @@ import (
 
 func (v *Audio) GetExportedMethods() dbusutil.ExportedMethods {
 	return dbusutil.ExportedMethods{
+		{
+			Name:   "CreateCombinedSink",
+			Fn:     v.CreateCombinedSink,
+			InArgs: []string{"name", "description", "slaves"},
+		},
+		{
+			Name:   "CreateLoopback",
+			Fn:     v.CreateLoopback,
+			InArgs: []string{"name", "source", "sink", "latencyMsec"},
+		},
+		{
+			Name:   "CreateNullSink",
+			Fn:     v.CreateNullSink,
+			InArgs: []string{"name", "description"},
+		},
+		{
+			Name:    "GetAppRoutes",
+			Fn:      v.GetAppRoutes,
+			OutArgs: []string{"routesJSON"},
+		},
+		{
+			Name:    "GetVirtualDevices",
+			Fn:      v.GetVirtualDevices,
+			OutArgs: []string{"devicesJSON"},
+		},
 		{
 			Name:    "IsPortEnabled",
 			Fn:      v.IsPortEnabled,
@@ -18,10 +43,25 @@ func (v *Audio) GetExportedMethods() dbusutil.ExportedMethods {
 			Name: "NoRestartPulseAudio",
 			Fn:   v.NoRestartPulseAudio,
 		},
+		{
+			Name:   "RemoveVirtualDevice",
+			Fn:     v.RemoveVirtualDevice,
+			InArgs: []string{"name"},
+		},
 		{
 			Name: "Reset",
 			Fn:   v.Reset,
 		},
+		{
+			Name:   "ResetAppRoute",
+			Fn:     v.ResetAppRoute,
+			InArgs: []string{"app"},
+		},
+		{
+			Name:   "SetAppRoute",
+			Fn:     v.SetAppRoute,
+			InArgs: []string{"app", "sinkName", "volume"},
+		},
 		{
 			Name:   "SetBluetoothAudioMode",
 			Fn:     v.SetBluetoothAudioMode,
@@ -49,16 +89,31 @@ func (v *Meter) GetExportedMethods() dbusutil.ExportedMethods {
 }
 func (v *Sink) GetExportedMethods() dbusutil.ExportedMethods {
 	return dbusutil.ExportedMethods{
+		{
+			Name:    "GetEqualizer",
+			Fn:      v.GetEqualizer,
+			OutArgs: []string{"equalizerJSON"},
+		},
 		{
 			Name:    "GetMeter",
 			Fn:      v.GetMeter,
 			OutArgs: []string{"meter"},
 		},
+		{
+			Name:    "ListPresets",
+			Fn:      v.ListPresets,
+			OutArgs: []string{"presetsJSON"},
+		},
 		{
 			Name:   "SetBalance",
 			Fn:     v.SetBalance,
 			InArgs: []string{"value", "isPlay"},
 		},
+		{
+			Name:   "SetEqualizer",
+			Fn:     v.SetEqualizer,
+			InArgs: []string{"equalizerJSON"},
+		},
 		{
 			Name:   "SetFade",
 			Fn:     v.SetFade,
@@ -83,6 +138,11 @@ func (v *Sink) GetExportedMethods() dbusutil.ExportedMethods {
 }
 func (v *SinkInput) GetExportedMethods() dbusutil.ExportedMethods {
 	return dbusutil.ExportedMethods{
+		{
+			Name:   "MoveToSink",
+			Fn:     v.MoveToSink,
+			InArgs: []string{"sinkName"},
+		},
 		{
 			Name:   "SetBalance",
 			Fn:     v.SetBalance,
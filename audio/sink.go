@@ -265,7 +265,8 @@ func (s *Sink) update(sinkInfo *pulse.Sink) {
 	s.props = sinkInfo.PropList
 	s.PropsMu.Unlock()
 
-	if activePortChanged && s.audio.defaultSinkName == s.Name {
+	// 使用音效时默认输出设备是音效的虚拟设备
+	if activePortChanged && (s.audio.defaultSinkName == s.Name || s.audio.getEffectMaster() == s.Name) {
 		logger.Debugf("default sink update active port %s", sinkInfo.ActivePort.Name)
 		s.audio.resumeSinkConfig(s)
 	}
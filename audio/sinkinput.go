@@ -46,11 +46,13 @@ type SinkInput struct {
 	correctIconCalled bool
 	correctedIcon     string
 	visible           bool
+	filter            bool // 虚拟设备的输出
 	cVolume           pulse.CVolume
 	channelMap        pulse.ChannelMap
 	// Name process name
 	Name           string
 	Icon           string
+	AppId          string // 应用的标识，用于记录应用的输出设备和音量
 	Mute           bool
 	Volume         float64
 	Balance        float64
@@ -69,6 +71,7 @@ func newSinkInput(sinkInputInfo *pulse.SinkInput, audio *Audio) *SinkInput {
 		service: audio.service,
 		index:   sinkInputInfo.Index,
 		visible: getSinkInputVisible(sinkInputInfo),
+		filter:  sinkInputInfo.PropList[pulse.PA_PROP_MEDIA_ROLE] == "filter",
 	}
 	sinkInput.update(sinkInputInfo)
 	return sinkInput
@@ -81,6 +84,13 @@ func (s *SinkInput) getPropSinkIndex() uint32 {
 	return v
 }
 
+func (s *SinkInput) getAppId() string {
+	s.PropsMu.RLock()
+	v := s.AppId
+	s.PropsMu.RUnlock()
+	return v
+}
+
 func getSinkInputVisible(sinkInputInfo *pulse.SinkInput) bool {
 	appName := sinkInputInfo.PropList[pulse.PA_PROP_APPLICATION_NAME]
 	switch appName {
@@ -113,6 +123,7 @@ func (s *SinkInput) SetVolume(value float64, isPlay bool) *dbus.Error {
 	cv := s.cVolume.SetAvg(value)
 	s.PropsMu.RUnlock()
 	s.audio.context().SetSinkInputVolume(s.index, cv)
+	s.audio.rememberAppVolume(s, value)
 
 	if isPlay {
 		playFeedback()
@@ -158,6 +169,12 @@ func (s *SinkInput) SetMute(value bool) *dbus.Error {
 	return nil
 }
 
+// MoveToSink 把声音移到名为 sinkName 的输出设备，并记住应用使用的设备
+func (s *SinkInput) MoveToSink(sinkName string) *dbus.Error {
+	err := s.audio.moveSinkInputToSinkByName(s, sinkName)
+	return dbusutil.ToError(err)
+}
+
 func (s *SinkInput) getPath() dbus.ObjectPath {
 	return dbus.ObjectPath(dbusPath + "/SinkInput" + strconv.Itoa(int(s.index)))
 }
@@ -311,6 +328,7 @@ func (s *SinkInput) update(sinkInputInfo *pulse.SinkInput) {
 		icon = "media-player"
 	}
 	s.setPropIcon(icon)
+	s.setPropAppId(getSinkInputAppId(sinkInputInfo, correctedIcon))
 
 	s.setPropVolume(sinkInputInfo.Volume.Avg())
 	s.setPropMute(sinkInputInfo.Mute)
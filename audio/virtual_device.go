@@ -0,0 +1,306 @@
+package audio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	dbus "github.com/godbus/dbus"
+	"pkg.deepin.io/lib/dbusutil"
+)
+
+const (
+	virtualDeviceCombine  = "combine"  // 同时输出到多个设备
+	virtualDeviceNull     = "null"     // 空设备，可以通过它的 monitor 录制应用的声音
+	virtualDeviceLoopback = "loopback" // 把输入设备的声音输出到输出设备
+
+	defaultLoopbackLatencyMsec = 200
+)
+
+// VirtualDeviceConfig 是通过加载 pulseaudio 模块创建的虚拟设备
+type VirtualDeviceConfig struct {
+	Name        string   // combine 和 null 是 sink 的名字
+	Type        string   // combine, null 或 loopback
+	Description string   // 设备的描述，为空时使用 pulseaudio 的默认值
+	Slaves      []string // combine 的输出设备
+	Source      string   // loopback 的输入设备
+	Sink        string   // loopback 的输出设备，为空时使用默认输出设备
+	LatencyMsec uint32   // loopback 的延迟
+}
+
+var (
+	virtualDeviceNameReg = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	// 引用的设备可能是其他程序创建的，名字中可能有冒号
+	deviceNameReg = regexp.MustCompile(`^[A-Za-z0-9_.:-]+$`)
+)
+
+func (cfg *VirtualDeviceConfig) check() error {
+	if !virtualDeviceNameReg.MatchString(cfg.Name) {
+		return fmt.Errorf("invalid name %q", cfg.Name)
+	}
+	// 描述放在引号中传给 pulseaudio
+	if strings.ContainsAny(cfg.Description, "\"'\\\n") {
+		return fmt.Errorf("invalid description %q", cfg.Description)
+	}
+
+	switch cfg.Type {
+	case virtualDeviceCombine:
+		if len(cfg.Slaves) == 0 {
+			return errors.New("no slave sink")
+		}
+		for _, slave := range cfg.Slaves {
+			if !deviceNameReg.MatchString(slave) {
+				return fmt.Errorf("invalid slave sink %q", slave)
+			}
+		}
+	case virtualDeviceNull:
+	case virtualDeviceLoopback:
+		if !deviceNameReg.MatchString(cfg.Source) {
+			return fmt.Errorf("invalid source %q", cfg.Source)
+		}
+		if cfg.Sink != "" && !deviceNameReg.MatchString(cfg.Sink) {
+			return fmt.Errorf("invalid sink %q", cfg.Sink)
+		}
+	default:
+		return fmt.Errorf("invalid type %q", cfg.Type)
+	}
+	return nil
+}
+
+// moduleKey 是模块参数中标识这个设备的参数
+func (cfg *VirtualDeviceConfig) moduleKey() string {
+	if cfg.Type == virtualDeviceLoopback {
+		return "sink_input_properties=media.name=" + cfg.Name
+	}
+	return "sink_name=" + cfg.Name
+}
+
+func (cfg *VirtualDeviceConfig) getModuleName() string {
+	switch cfg.Type {
+	case virtualDeviceCombine:
+		return "module-combine-sink"
+	case virtualDeviceNull:
+		return "module-null-sink"
+	}
+	return "module-loopback"
+}
+
+func (cfg *VirtualDeviceConfig) getModuleArgs() []string {
+	args := []string{cfg.moduleKey()}
+	switch cfg.Type {
+	case virtualDeviceCombine:
+		args = append(args, "slaves="+strings.Join(cfg.Slaves, ","))
+	case virtualDeviceLoopback:
+		latency := cfg.LatencyMsec
+		if latency == 0 {
+			latency = defaultLoopbackLatencyMsec
+		}
+		args = append(args, "source="+cfg.Source, "source_dont_move=true",
+			"latency_msec="+strconv.FormatUint(uint64(latency), 10))
+		if cfg.Sink != "" {
+			args = append(args, "sink="+cfg.Sink, "sink_dont_move=true")
+		}
+		return args
+	}
+	if cfg.Description != "" {
+		args = append(args, fmt.Sprintf(`sink_properties='device.description="%s"'`, cfg.Description))
+	}
+	return args
+}
+
+type pulseModuleInfo struct {
+	Index uint32
+	Name  string
+	Args  []string
+}
+
+// parseModuleList 解析 pactl list short modules 的输出
+func parseModuleList(out string) []pulseModuleInfo {
+	var result []pulseModuleInfo
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) < 2 {
+			continue
+		}
+		idx, err := strconv.ParseUint(fields[0], 10, 32)
+		if err != nil {
+			continue
+		}
+		info := pulseModuleInfo{
+			Index: uint32(idx),
+			Name:  fields[1],
+		}
+		if len(fields) == 3 {
+			info.Args = strings.Fields(fields[2])
+		}
+		result = append(result, info)
+	}
+	return result
+}
+
+func findVirtualDeviceModule(modules []pulseModuleInfo, cfg *VirtualDeviceConfig) (uint32, bool) {
+	key := cfg.moduleKey()
+	moduleName := cfg.getModuleName()
+	for _, module := range modules {
+		if module.Name != moduleName {
+			continue
+		}
+		for _, arg := range module.Args {
+			if arg == key {
+				return module.Index, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func listPulseModules() ([]pulseModuleInfo, error) {
+	out, err := exec.Command("pactl", "list", "short", "modules").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseModuleList(string(out)), nil
+}
+
+func loadPulseModule(name string, args []string) error {
+	out, err := exec.Command("pactl", append([]string{"load-module", name}, args...)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %v %s", name, err, out)
+	}
+	return nil
+}
+
+func unloadPulseModule(idx uint32) error {
+	out, err := exec.Command("pactl", "unload-module", strconv.FormatUint(uint64(idx), 10)).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to unload module #%d: %v %s", idx, err, out)
+	}
+	return nil
+}
+
+func loadVirtualDevice(cfg *VirtualDeviceConfig) error {
+	logger.Debugf("load virtual device %q: %s %v", cfg.Name, cfg.getModuleName(), cfg.getModuleArgs())
+	return loadPulseModule(cfg.getModuleName(), cfg.getModuleArgs())
+}
+
+// restoreVirtualDevices 加载配置中还没有加载的虚拟设备
+func (a *Audio) restoreVirtualDevices() {
+	a.virtualDeviceMu.Lock()
+	defer a.virtualDeviceMu.Unlock()
+
+	devices := GetConfigKeeper().ListVirtualDevices()
+	if len(devices) == 0 {
+		return
+	}
+	modules, err := listPulseModules()
+	if err != nil {
+		logger.Warning("failed to list pulse modules:", err)
+		return
+	}
+	for _, cfg := range devices {
+		if _, ok := findVirtualDeviceModule(modules, cfg); ok {
+			continue
+		}
+		err = loadVirtualDevice(cfg)
+		if err != nil {
+			logger.Warning(err)
+		}
+	}
+}
+
+func (a *Audio) createVirtualDevice(cfg *VirtualDeviceConfig) error {
+	err := cfg.check()
+	if err != nil {
+		return err
+	}
+
+	a.virtualDeviceMu.Lock()
+	defer a.virtualDeviceMu.Unlock()
+
+	a.mu.Lock()
+	existed := a.getSinkByNameNoLock(cfg.Name) != nil
+	a.mu.Unlock()
+	if existed || GetConfigKeeper().GetVirtualDevice(cfg.Name) != nil {
+		return fmt.Errorf("device %q already exists", cfg.Name)
+	}
+
+	err = loadVirtualDevice(cfg)
+	if err != nil {
+		return err
+	}
+	GetConfigKeeper().AddVirtualDevice(cfg)
+	return nil
+}
+
+func (a *Audio) removeVirtualDevice(name string) error {
+	a.virtualDeviceMu.Lock()
+	defer a.virtualDeviceMu.Unlock()
+
+	cfg := GetConfigKeeper().GetVirtualDevice(name)
+	if cfg == nil {
+		return fmt.Errorf("not found virtual device %q", name)
+	}
+
+	modules, err := listPulseModules()
+	if err != nil {
+		return err
+	}
+	if idx, ok := findVirtualDeviceModule(modules, cfg); ok {
+		err = unloadPulseModule(idx)
+		if err != nil {
+			return err
+		}
+	}
+	GetConfigKeeper().RemoveVirtualDevice(name)
+	return nil
+}
+
+// CreateCombinedSink 创建一个同时输出到 slaves 中所有设备的 sink
+func (a *Audio) CreateCombinedSink(name, description string, slaves []string) *dbus.Error {
+	err := a.createVirtualDevice(&VirtualDeviceConfig{
+		Name:        name,
+		Type:        virtualDeviceCombine,
+		Description: description,
+		Slaves:      slaves,
+	})
+	return dbusutil.ToError(err)
+}
+
+// CreateNullSink 创建一个空的 sink，它的 monitor source 是 name.monitor
+func (a *Audio) CreateNullSink(name, description string) *dbus.Error {
+	err := a.createVirtualDevice(&VirtualDeviceConfig{
+		Name:        name,
+		Type:        virtualDeviceNull,
+		Description: description,
+	})
+	return dbusutil.ToError(err)
+}
+
+// CreateLoopback 把 source 的声音输出到 sink，sink 为空时使用默认输出设备，latencyMsec 为 0 时使用默认值
+func (a *Audio) CreateLoopback(name, source, sink string, latencyMsec uint32) *dbus.Error {
+	err := a.createVirtualDevice(&VirtualDeviceConfig{
+		Name:        name,
+		Type:        virtualDeviceLoopback,
+		Source:      source,
+		Sink:        sink,
+		LatencyMsec: latencyMsec,
+	})
+	return dbusutil.ToError(err)
+}
+
+func (a *Audio) RemoveVirtualDevice(name string) *dbus.Error {
+	err := a.removeVirtualDevice(name)
+	return dbusutil.ToError(err)
+}
+
+func (a *Audio) GetVirtualDevices() (devicesJSON string, busErr *dbus.Error) {
+	data, err := json.Marshal(GetConfigKeeper().ListVirtualDevices())
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,122 @@
+package audio
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVirtualDeviceConfig(t *testing.T) {
+	cfg := &VirtualDeviceConfig{
+		Name:        "combined",
+		Type:        virtualDeviceCombine,
+		Description: "Speaker and Headset",
+		Slaves:      []string{"alsa_output.pci-0000_00_1f.3.analog-stereo", "bluez_sink.00_11_22_33_44_55.a2dp_sink"},
+	}
+	require.NoError(t, cfg.check())
+	assert.Equal(t, "module-combine-sink", cfg.getModuleName())
+	assert.Equal(t, []string{
+		"sink_name=combined",
+		"slaves=alsa_output.pci-0000_00_1f.3.analog-stereo,bluez_sink.00_11_22_33_44_55.a2dp_sink",
+		`sink_properties='device.description="Speaker and Headset"'`,
+	}, cfg.getModuleArgs())
+
+	cfg.Description = `a"b`
+	assert.Error(t, cfg.check())
+	cfg.Description = ""
+	cfg.Slaves = []string{"a,b"}
+	assert.Error(t, cfg.check())
+
+	cfg = &VirtualDeviceConfig{Name: "record", Type: virtualDeviceNull}
+	require.NoError(t, cfg.check())
+	assert.Equal(t, []string{"sink_name=record"}, cfg.getModuleArgs())
+	cfg.Name = "a b"
+	assert.Error(t, cfg.check())
+
+	cfg = &VirtualDeviceConfig{Name: "mic-monitor", Type: virtualDeviceLoopback, Source: "alsa_input.usb"}
+	require.NoError(t, cfg.check())
+	assert.Equal(t, "module-loopback", cfg.getModuleName())
+	assert.Equal(t, []string{
+		"sink_input_properties=media.name=mic-monitor",
+		"source=alsa_input.usb", "source_dont_move=true", "latency_msec=200",
+	}, cfg.getModuleArgs())
+	cfg.Source = ""
+	assert.Error(t, cfg.check())
+
+	cfg.Type = "tunnel"
+	assert.Error(t, cfg.check())
+}
+
+func Test_findVirtualDeviceModule(t *testing.T) {
+	modules := parseModuleList("0\tmodule-device-restore\t\n" +
+		"25\tmodule-null-sink\tsink_name=record sink_properties='device.description=\"Record App\"'\t\n" +
+		"26\tmodule-loopback\tsink_input_properties=media.name=mic-monitor source=alsa_input.usb\t\n")
+	require.Len(t, modules, 3)
+
+	idx, ok := findVirtualDeviceModule(modules, &VirtualDeviceConfig{Name: "record", Type: virtualDeviceNull})
+	assert.True(t, ok)
+	assert.Equal(t, uint32(25), idx)
+
+	idx, ok = findVirtualDeviceModule(modules, &VirtualDeviceConfig{Name: "mic-monitor", Type: virtualDeviceLoopback})
+	assert.True(t, ok)
+	assert.Equal(t, uint32(26), idx)
+
+	_, ok = findVirtualDeviceModule(modules, &VirtualDeviceConfig{Name: "record", Type: virtualDeviceCombine})
+	assert.False(t, ok)
+	_, ok = findVirtualDeviceModule(modules, &VirtualDeviceConfig{Name: "rec", Type: virtualDeviceNull})
+	assert.False(t, ok)
+}
+
+func TestConfigKeeperVirtualDevices(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audio")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	newCk := func() *ConfigKeeper {
+		return NewConfigKeeper(filepath.Join(dir, "cards.json"), filepath.Join(dir, "mute.json"),
+			filepath.Join(dir, "virtual.json"))
+	}
+	ck := newCk()
+	ck.AddVirtualDevice(&VirtualDeviceConfig{Name: "record", Type: virtualDeviceNull})
+	ck.AddVirtualDevice(&VirtualDeviceConfig{Name: "mic-monitor", Type: virtualDeviceLoopback, Source: "alsa_input.usb"})
+
+	ck = newCk()
+	assert.NoError(t, ck.Load())
+	require.Len(t, ck.VirtualDevices, 2)
+	assert.NotNil(t, ck.GetVirtualDevice("record"))
+
+	ck.RemoveVirtualDevice("record")
+	ck = newCk()
+	assert.NoError(t, ck.Load())
+	assert.Nil(t, ck.GetVirtualDevice("record"))
+	assert.NotNil(t, ck.GetVirtualDevice("mic-monitor"))
+}
+
+func TestConfigKeeperVirtualDevicesConcurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audio")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ck := NewConfigKeeper(filepath.Join(dir, "cards.json"), filepath.Join(dir, "mute.json"),
+		filepath.Join(dir, "virtual.json"))
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("record%d", i)
+			ck.AddVirtualDevice(&VirtualDeviceConfig{Name: name, Type: virtualDeviceNull})
+			assert.NotNil(t, ck.GetVirtualDevice(name))
+			ck.ListVirtualDevices()
+			ck.RemoveVirtualDevice(name)
+		}(i)
+	}
+	wg.Wait()
+	assert.Empty(t, ck.ListVirtualDevices())
+}
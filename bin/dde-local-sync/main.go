@@ -0,0 +1,143 @@
+/*
+ * Copyright (C) 2014 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/godbus/dbus"
+	"pkg.deepin.io/dde/daemon/common/dsync"
+)
+
+var (
+	_modules string
+	_output  string
+	_dryRun  bool
+)
+
+func init() {
+	log.SetFlags(0)
+	flag.StringVar(&_modules, "m", "", "Comma separated modules, all modules if empty")
+	flag.StringVar(&_output, "o", "", "Output file of export, stdout if empty")
+	flag.BoolVar(&_dryRun, "n", false, "Show the differences of import only")
+}
+
+func usage() {
+	fmt.Println("Desc:")
+	fmt.Println("\tdde-local-sync - export and import the desktop configs of dde-session-daemon modules")
+	fmt.Println("Usage:")
+	fmt.Println("\tdde-local-sync list")
+	fmt.Println("\tdde-local-sync [-m modules] [-o file] export")
+	fmt.Println("\tdde-local-sync [-m modules] [-n] import <file>")
+	fmt.Println("Option:")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	sessionBus, err := dbus.SessionBus()
+	if err != nil {
+		log.Fatal(err)
+	}
+	obj := sessionBus.Object(dsync.LocalServiceName, dsync.LocalPath)
+
+	var modules []string
+	if _modules != "" {
+		modules = strings.Split(_modules, ",")
+	}
+
+	switch flag.Arg(0) {
+	case "list":
+		var names []string
+		err = obj.Call(dsync.LocalServiceName+".ListModules", 0).Store(&names)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+
+	case "export":
+		var bundle string
+		err = obj.Call(dsync.LocalServiceName+".Export", 0, modules).Store(&bundle)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if _output == "" {
+			fmt.Println(bundle)
+			return
+		}
+		err = ioutil.WriteFile(_output, []byte(bundle), 0600)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+	case "import":
+		if flag.NArg() != 2 {
+			usage()
+			os.Exit(2)
+		}
+		content, err := ioutil.ReadFile(flag.Arg(1))
+		if err != nil {
+			log.Fatal(err)
+		}
+		var result string
+		err = obj.Call(dsync.LocalServiceName+".Import", 0, string(content), modules, _dryRun).Store(&result)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printDiffs(result)
+
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+func printDiffs(result string) {
+	var diffs []dsync.ModuleDiff
+	err := json.Unmarshal([]byte(result), &diffs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, diff := range diffs {
+		if diff.Reason != "" {
+			fmt.Printf("%s: %s (%s)\n", diff.Module, diff.Status, diff.Reason)
+		} else {
+			fmt.Printf("%s: %s\n", diff.Module, diff.Status)
+		}
+		for _, change := range diff.Changes {
+			fmt.Printf("\t%s: %s -> %s\n", change.Key, orNone(change.Old), orNone(change.New))
+		}
+	}
+}
+
+func orNone(data json.RawMessage) string {
+	if len(data) == 0 {
+		return "<none>"
+	}
+	return string(data)
+}
@@ -20,6 +20,7 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -81,6 +82,15 @@ type SessionDaemon struct {
 	part1DisabledModules []string
 	part2EnabledModules  []string
 	part2DisabledModules []string
+	service              *dbusutil.Service
+
+	// nolint
+	signals *struct {
+		ModuleStateChanged struct {
+			name  string
+			state string
+		}
+	}
 }
 
 func (*SessionDaemon) GetInterfaceName() string {
@@ -112,9 +122,21 @@ func (s *SessionDaemon) register(service *dbusutil.Service) error {
 	if err != nil {
 		return err
 	}
+	s.service = service
+	loader.ConnectModuleStateChanged(s.handleModuleStateChanged)
 	return nil
 }
 
+func (s *SessionDaemon) handleModuleStateChanged(name string, state loader.ModuleState, err error) {
+	if err != nil {
+		s.log.Debugf("module %s state changed to %s: %v", name, state, err)
+	}
+	emitErr := s.service.Emit(s, "ModuleStateChanged", name, state.String())
+	if emitErr != nil {
+		s.log.Warning(emitErr)
+	}
+}
+
 func (s *SessionDaemon) initModules() {
 	part1ModuleNames := []string{
 		"dock",
@@ -259,6 +281,59 @@ func (s *SessionDaemon) StartPart2() *dbus.Error {
 	return dbusutil.ToError(err)
 }
 
+func (s *SessionDaemon) ListModules() (modules string, busErr *dbus.Error) {
+	data, err := json.Marshal(loader.ListModuleStatus())
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+func (s *SessionDaemon) GetModuleState(name string) (state string, busErr *dbus.Error) {
+	moduleState, err := loader.GetModuleState(name)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return moduleState.String(), nil
+}
+
+func (s *SessionDaemon) EnableModule(name string) *dbus.Error {
+	moduleLocker.Lock()
+	defer moduleLocker.Unlock()
+	err := loader.EnableModule(name)
+	return dbusutil.ToError(err)
+}
+
+func (s *SessionDaemon) DisableModule(name string) *dbus.Error {
+	moduleLocker.Lock()
+	defer moduleLocker.Unlock()
+	err := loader.DisableModule(name)
+	return dbusutil.ToError(err)
+}
+
+func (s *SessionDaemon) RestartModule(name string) *dbus.Error {
+	moduleLocker.Lock()
+	defer moduleLocker.Unlock()
+	err := loader.RestartModule(name)
+	return dbusutil.ToError(err)
+}
+
+func (s *SessionDaemon) GetStartupProfile() (profile string, busErr *dbus.Error) {
+	data, err := json.Marshal(loader.GetStartupProfiles())
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+func (s *SessionDaemon) ExportDependencyGraph(format string) (data string, busErr *dbus.Error) {
+	graph, err := loader.ExportDependencyGraph(format)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(graph), nil
+}
+
 func filterList(origin, condition []string) []string {
 	if len(condition) == 0 {
 		return origin
@@ -13,6 +13,43 @@ func (v *SessionDaemon) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:     v.CallTrace,
 			InArgs: []string{"times", "seconds"},
 		},
+		{
+			Name:   "DisableModule",
+			Fn:     v.DisableModule,
+			InArgs: []string{"name"},
+		},
+		{
+			Name:   "EnableModule",
+			Fn:     v.EnableModule,
+			InArgs: []string{"name"},
+		},
+		{
+			Name:    "ExportDependencyGraph",
+			Fn:      v.ExportDependencyGraph,
+			InArgs:  []string{"format"},
+			OutArgs: []string{"data"},
+		},
+		{
+			Name:    "GetModuleState",
+			Fn:      v.GetModuleState,
+			InArgs:  []string{"name"},
+			OutArgs: []string{"state"},
+		},
+		{
+			Name:    "GetStartupProfile",
+			Fn:      v.GetStartupProfile,
+			OutArgs: []string{"profile"},
+		},
+		{
+			Name:    "ListModules",
+			Fn:      v.ListModules,
+			OutArgs: []string{"modules"},
+		},
+		{
+			Name:   "RestartModule",
+			Fn:     v.RestartModule,
+			InArgs: []string{"name"},
+		},
 		{
 			Name: "StartPart2",
 			Fn:   v.StartPart2,
@@ -43,6 +43,7 @@ import (
 	soundthemeplayer "github.com/linuxdeepin/go-dbus-factory/com.deepin.api.soundthemeplayer"
 	"pkg.deepin.io/dde/api/soundutils"
 	"pkg.deepin.io/dde/api/userenv"
+	"pkg.deepin.io/dde/daemon/common/dsync"
 	"pkg.deepin.io/dde/daemon/loader"
 	"pkg.deepin.io/lib/dbusutil"
 	. "pkg.deepin.io/lib/gettext"
@@ -222,6 +223,11 @@ func main() {
 
 	loader.SetService(service)
 
+	_, err = dsync.ExportLocalProvider(service)
+	if err != nil {
+		logger.Warning("failed to export local sync provider:", err)
+	}
+
 	if _options.logLevel == "" &&
 		(utils.IsEnvExists(log.DebugLevelEnv) || utils.IsEnvExists(log.DebugMatchEnv)) {
 		logger.Info("Log level is none and debug env exists, so do not call loader.SetLogLevel")
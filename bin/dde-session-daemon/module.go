@@ -89,7 +89,11 @@ func listenDaemonSettings() {
 			return
 		}
 
-		err = module.Enable(enable)
+		if enable {
+			err = loader.EnableModule(name)
+		} else {
+			err = loader.DisableModule(name)
+		}
 		if err != nil {
 			logger.Warningf("Enable '%s' failed: %v", name, err)
 			return
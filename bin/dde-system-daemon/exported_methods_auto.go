@@ -23,6 +23,17 @@ func (v *Daemon) GetExportedMethods() dbusutil.ExportedMethods {
 			Name: "ClearTtys",
 			Fn:   v.ClearTtys,
 		},
+		{
+			Name:    "ExportDependencyGraph",
+			Fn:      v.ExportDependencyGraph,
+			InArgs:  []string{"format"},
+			OutArgs: []string{"data"},
+		},
+		{
+			Name:    "GetStartupProfile",
+			Fn:      v.GetStartupProfile,
+			OutArgs: []string{"profile"},
+		},
 		{
 			Name:    "IsPidVirtualMachine",
 			Fn:      v.IsPidVirtualMachine,
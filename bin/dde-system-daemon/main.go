@@ -65,6 +65,8 @@ type Daemon struct {
 		ClearTty                       func() `in:"number"`
 		IsPidVirtualMachine            func() `in:"pid" out:"ret"`
 		IsIgnoreCheckVirtual           func() `in:"pid" out:"ret"`
+		GetStartupProfile              func() `out:"profile"`
+		ExportDependencyGraph          func() `in:"format" out:"data"`
 	}
 	signals *struct { //nolint
 		HandleForSleep struct {
@@ -0,0 +1,42 @@
+/*
+ * Copyright (C) 2014 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/godbus/dbus"
+	"pkg.deepin.io/dde/daemon/loader"
+	"pkg.deepin.io/lib/dbusutil"
+)
+
+func (*Daemon) GetStartupProfile() (string, *dbus.Error) {
+	data, err := json.Marshal(loader.GetStartupProfiles())
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+func (*Daemon) ExportDependencyGraph(format string) (string, *dbus.Error) {
+	data, err := loader.ExportDependencyGraph(format)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,544 @@
+package calendar
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// CalDAV 同步，只使用 WebDAV 的基本方法：用 PROPFIND 列出日历集合中资源的 ETag，
+// 用 GET/PUT/DELETE 读写单个资源，每个资源保存一个日程。
+
+const (
+	conflictPolicyServer = "server" // 服务器的修改优先
+	conflictPolicyLocal  = "local"  // 本地的修改优先
+	conflictPolicyNewest = "newest" // 最后修改的优先
+
+	calDAVTimeout = 30 * time.Second
+)
+
+type CalDAVConfig struct {
+	URL            string // 日历集合的 URL
+	Username       string
+	Password       string
+	Interval       int // 自动同步的间隔，单位分钟，0 表示不自动同步
+	ConflictPolicy string
+}
+
+func (cfg *CalDAVConfig) check() error {
+	if cfg.URL != "" {
+		u, err := url.Parse(cfg.URL)
+		if err != nil {
+			return err
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+		}
+	}
+	if cfg.Interval < 0 {
+		return errors.New("invalid interval")
+	}
+	switch cfg.ConflictPolicy {
+	case "", conflictPolicyServer, conflictPolicyLocal, conflictPolicyNewest:
+	default:
+		return fmt.Errorf("invalid conflict policy %q", cfg.ConflictPolicy)
+	}
+	return nil
+}
+
+// CalDAVItem 记录上次同步时日程和远程资源的状态，用于判断两边是否有修改。
+type CalDAVItem struct {
+	gorm.Model
+
+	JobID uint
+	Href  string
+	ETag  string
+	Hash  string // 上次同步时日程的 hash
+}
+
+type CalDAVSyncResult struct {
+	Time          time.Time
+	Pulled        int
+	Pushed        int
+	DeletedLocal  int
+	DeletedRemote int
+	Conflicts     []string
+	Errors        []string
+
+	changedJobs []uint
+}
+
+func (r *CalDAVSyncResult) addError(href string, err error) {
+	logger.Warningf("sync %s: %v", href, err)
+	r.Errors = append(r.Errors, fmt.Sprintf("%s: %v", href, err))
+}
+
+var errPreconditionFailed = errors.New("precondition failed")
+
+type calDAVClient struct {
+	httpClient *http.Client
+	baseURL    *url.URL
+	username   string
+	password   string
+	// 资源的路径都使用解码后的形式，这里记录服务器返回的编码后的路径，
+	// 用来访问名字中有 %2F 之类的字符的资源
+	rawPaths map[string]string
+}
+
+func newCalDAVClient(cfg *CalDAVConfig) (*calDAVClient, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return &calDAVClient{
+		httpClient: &http.Client{Timeout: calDAVTimeout},
+		baseURL:    u,
+		username:   cfg.Username,
+		password:   cfg.Password,
+		rawPaths:   make(map[string]string),
+	}, nil
+}
+
+// getURL 返回资源的 URL，href 是解码后的路径，为空时表示日历集合
+func (c *calDAVClient) getURL(href string) *url.URL {
+	u := *c.baseURL
+	if href != "" {
+		u.Path = href
+		u.RawPath = c.rawPaths[href]
+	}
+	return &u
+}
+
+func (c *calDAVClient) do(method, href string, body io.Reader, header map[string]string) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.getURL(href).String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+	return c.httpClient.Do(req)
+}
+
+func checkResponse(resp *http.Response, codes ...int) error {
+	for _, code := range codes {
+		if resp.StatusCode == code {
+			return nil
+		}
+	}
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return errPreconditionFailed
+	}
+	return fmt.Errorf("%s %s: %s", resp.Request.Method, resp.Request.URL.Path, resp.Status)
+}
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:getetag/><d:resourcetype/></d:prop></d:propfind>`
+
+type davMultiStatus struct {
+	Responses []struct {
+		Href      string `xml:"DAV: href"`
+		PropStats []struct {
+			Prop struct {
+				ETag         string `xml:"DAV: getetag"`
+				ResourceType struct {
+					Collection *struct{} `xml:"DAV: collection"`
+				} `xml:"DAV: resourcetype"`
+			} `xml:"DAV: prop"`
+			Status string `xml:"DAV: status"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+// list 返回日历集合中的资源，key 是资源解码后的路径，value 是 ETag。
+func (c *calDAVClient) list() (map[string]string, error) {
+	resp, err := c.do("PROPFIND", "", strings.NewReader(propfindBody), map[string]string{
+		"Depth":        "1",
+		"Content-Type": "application/xml; charset=utf-8",
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	err = checkResponse(resp, http.StatusMultiStatus)
+	if err != nil {
+		return nil, err
+	}
+
+	var ms davMultiStatus
+	err = xml.NewDecoder(resp.Body).Decode(&ms)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string)
+	for _, r := range ms.Responses {
+		u, err := c.baseURL.Parse(r.Href)
+		if err != nil || u.Path == c.baseURL.Path {
+			continue
+		}
+		for _, ps := range r.PropStats {
+			if !strings.Contains(ps.Status, " 200 ") || ps.Prop.ResourceType.Collection != nil {
+				continue
+			}
+			result[u.Path] = ps.Prop.ETag
+			c.rawPaths[u.Path] = u.EscapedPath()
+		}
+	}
+	return result, nil
+}
+
+func (c *calDAVClient) get(href string) (data, etag string, err error) {
+	resp, err := c.do(http.MethodGet, href, nil, nil)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	err = checkResponse(resp, http.StatusOK)
+	if err != nil {
+		return "", "", err
+	}
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", "", err
+	}
+	return string(content), resp.Header.Get("ETag"), nil
+}
+
+// put 写入资源，etag 为空时表示创建资源，服务器上的资源已被修改时返回 errPreconditionFailed。
+func (c *calDAVClient) put(href, data, etag string) (string, error) {
+	header := map[string]string{
+		"Content-Type": "text/calendar; charset=utf-8",
+	}
+	if etag == "" {
+		header["If-None-Match"] = "*"
+	} else {
+		header["If-Match"] = etag
+	}
+	resp, err := c.do(http.MethodPut, href, strings.NewReader(data), header)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	err = checkResponse(resp, http.StatusOK, http.StatusCreated, http.StatusNoContent)
+	if err != nil {
+		return "", err
+	}
+
+	newETag := resp.Header.Get("ETag")
+	if newETag == "" || strings.HasPrefix(newETag, "W/") {
+		// 服务器可能会修改数据，没有返回强 ETag 时需要重新读取
+		_, newETag, err = c.get(href)
+		if err != nil {
+			return "", err
+		}
+	}
+	return newETag, nil
+}
+
+func (c *calDAVClient) delete(href, etag string) error {
+	var header map[string]string
+	if etag != "" {
+		header = map[string]string{"If-Match": etag}
+	}
+	resp, err := c.do(http.MethodDelete, href, nil, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp, http.StatusOK, http.StatusNoContent, http.StatusNotFound)
+}
+
+// calDAVStore 是同步时对本地日程的操作
+type calDAVStore interface {
+	getJobs() ([]*Job, error)
+	saveJob(job *Job) error
+	deleteJob(id uint) error
+	getItems() ([]*CalDAVItem, error)
+	saveItem(item *CalDAVItem) error
+	deleteItem(item *CalDAVItem) error
+}
+
+// getJobHash 计算日程中会同步的字段的 hash，用来判断日程是否在本地被修改过。
+func getJobHash(job *Job) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%d\n%q\n%q\n%v\n%d\n%d\n%q\n%q\n%q\n%q",
+		job.Type, job.Title, job.Description, job.AllDay,
+		job.Start.Unix(), job.End.Unix(), job.RRule, job.Remind, job.Ignore, job.UID)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// getJobHref 返回新建资源的路径，和 list 返回的一样是解码后的形式。
+// 资源名不需要和 UID 相同，去掉 / 避免产生子目录。
+func getJobHref(basePath, uid string) string {
+	return path.Join(basePath, strings.Replace(uid, "/", "_", -1)+".ics")
+}
+
+type calDAVSyncer struct {
+	client *calDAVClient
+	store  calDAVStore
+	policy string
+	result *CalDAVSyncResult
+
+	jobs map[uint]*Job
+}
+
+func syncCalDAV(client *calDAVClient, store calDAVStore, policy string, now time.Time) (*CalDAVSyncResult, error) {
+	s := &calDAVSyncer{
+		client: client,
+		store:  store,
+		policy: policy,
+		result: &CalDAVSyncResult{Time: now},
+		jobs:   make(map[uint]*Job),
+	}
+	if s.policy == "" {
+		s.policy = conflictPolicyNewest
+	}
+	err := s.sync()
+	if err != nil {
+		return nil, err
+	}
+	return s.result, nil
+}
+
+func (s *calDAVSyncer) sync() error {
+	remote, err := s.client.list()
+	if err != nil {
+		return err
+	}
+	items, err := s.store.getItems()
+	if err != nil {
+		return err
+	}
+	jobs, err := s.store.getJobs()
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		s.jobs[job.ID] = job
+	}
+
+	linkedHrefs := make(map[string]bool)
+	linkedJobs := make(map[uint]bool)
+	for _, item := range items {
+		linkedHrefs[item.Href] = true
+		linkedJobs[item.JobID] = true
+		remoteETag, remoteExists := remote[item.Href]
+		s.syncItem(item, remoteETag, remoteExists)
+	}
+
+	// 远程新建的日程，如果和本地日程的 UID 相同，说明是之前导入的同一个日程
+	uidJobs := make(map[string]*Job)
+	for _, job := range jobs {
+		if !linkedJobs[job.ID] {
+			uidJobs[getJobUID(job)] = job
+		}
+	}
+	for href := range remote {
+		if linkedHrefs[href] {
+			continue
+		}
+		job, err := s.pull(&CalDAVItem{Href: href}, 0, uidJobs)
+		if err != nil {
+			s.result.addError(href, err)
+			continue
+		}
+		linkedJobs[job.ID] = true
+	}
+
+	for _, job := range jobs {
+		if linkedJobs[job.ID] {
+			continue
+		}
+		if job.UID == "" {
+			job.UID = getJobUID(job)
+		}
+		href := getJobHref(s.client.baseURL.Path, job.UID)
+		err = s.push(&CalDAVItem{Href: href, JobID: job.ID}, job, "")
+		if err != nil {
+			s.result.addError(href, err)
+		}
+	}
+	return nil
+}
+
+func (s *calDAVSyncer) syncItem(item *CalDAVItem, remoteETag string, remoteExists bool) {
+	job := s.jobs[item.JobID]
+	localChanged := job != nil && getJobHash(job) != item.Hash
+	remoteChanged := remoteExists && remoteETag != item.ETag
+
+	var err error
+	switch {
+	case job == nil && !remoteExists:
+		err = s.store.deleteItem(item)
+
+	case job == nil:
+		if remoteChanged {
+			// 本地删除了，但是远程修改了，保留修改
+			s.addConflict(item.Href)
+			_, err = s.pull(item, 0, nil)
+			break
+		}
+		err = s.client.delete(item.Href, item.ETag)
+		if err == nil {
+			s.result.DeletedRemote++
+			err = s.store.deleteItem(item)
+		}
+
+	case !remoteExists:
+		if localChanged {
+			// 远程删除了，但是本地修改了，保留修改
+			s.addConflict(item.Href)
+			err = s.push(item, job, "")
+			break
+		}
+		err = s.store.deleteJob(job.ID)
+		if err == nil {
+			s.result.DeletedLocal++
+			s.result.changedJobs = append(s.result.changedJobs, job.ID)
+			err = s.store.deleteItem(item)
+		}
+
+	case localChanged && remoteChanged:
+		s.addConflict(item.Href)
+		err = s.resolveConflict(item, job, remoteETag)
+
+	case localChanged:
+		err = s.push(item, job, item.ETag)
+
+	case remoteChanged:
+		_, err = s.pull(item, job.ID, nil)
+	}
+
+	if err != nil {
+		s.result.addError(item.Href, err)
+	}
+}
+
+func (s *calDAVSyncer) addConflict(href string) {
+	logger.Debug("conflict:", href)
+	s.result.Conflicts = append(s.result.Conflicts, href)
+}
+
+func (s *calDAVSyncer) resolveConflict(item *CalDAVItem, job *Job, remoteETag string) error {
+	switch s.policy {
+	case conflictPolicyLocal:
+		return s.push(item, job, remoteETag)
+	case conflictPolicyServer:
+		_, err := s.pull(item, job.ID, nil)
+		return err
+	}
+
+	data, etag, err := s.client.get(item.Href)
+	if err != nil {
+		return err
+	}
+	event, err := decodeRemoteEvent(data)
+	if err != nil {
+		return err
+	}
+	if !event.lastModified.IsZero() && job.UpdatedAt.After(event.lastModified) {
+		return s.push(item, job, etag)
+	}
+	return s.applyRemoteEvent(item, job.ID, nil, event, etag)
+}
+
+// decodeRemoteEvent 解析资源中的日程，重复日程中被单独修改的那些次不同步。
+func decodeRemoteEvent(data string) (*icsEvent, error) {
+	events, err := decodeICS(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		if event.recurrenceID.IsZero() {
+			return event, nil
+		}
+	}
+	return nil, errors.New("no event found")
+}
+
+// pull 从服务器读取资源并保存到本地，jobId 为 0 时，在 uidJobs 中找 UID 相同的日程，找不到就新建日程。
+func (s *calDAVSyncer) pull(item *CalDAVItem, jobId uint, uidJobs map[string]*Job) (*Job, error) {
+	data, etag, err := s.client.get(item.Href)
+	if err != nil {
+		return nil, err
+	}
+	event, err := decodeRemoteEvent(data)
+	if err != nil {
+		return nil, err
+	}
+	err = s.applyRemoteEvent(item, jobId, uidJobs, event, etag)
+	if err != nil {
+		return nil, err
+	}
+	return event.job, nil
+}
+
+func (s *calDAVSyncer) applyRemoteEvent(item *CalDAVItem, jobId uint, uidJobs map[string]*Job,
+	event *icsEvent, etag string) error {
+	job := event.job
+	job.ID = jobId
+	if job.ID == 0 {
+		if job0, ok := uidJobs[job.UID]; ok {
+			job.ID = job0.ID
+		}
+	}
+
+	err := s.store.saveJob(job)
+	if err != nil {
+		return err
+	}
+	s.result.Pulled++
+	s.result.changedJobs = append(s.result.changedJobs, job.ID)
+
+	item.JobID = job.ID
+	item.ETag = etag
+	item.Hash = getJobHash(job)
+	return s.store.saveItem(item)
+}
+
+// push 把日程写到服务器，etag 为空时创建资源。
+func (s *calDAVSyncer) push(item *CalDAVItem, job *Job, etag string) error {
+	if job.UID == "" {
+		job.UID = getJobUID(job)
+	}
+	data := encodeICS([]*Job{job}, s.result.Time)
+	newETag, err := s.client.put(item.Href, data, etag)
+	if err != nil {
+		if err == errPreconditionFailed {
+			// 在同步的过程中服务器上的资源被修改了，下次同步时再处理
+			s.addConflict(item.Href)
+			return nil
+		}
+		return err
+	}
+
+	// 保存新生成的 UID
+	err = s.store.saveJob(job)
+	if err != nil {
+		return err
+	}
+	s.result.Pushed++
+
+	item.JobID = job.ID
+	item.ETag = newETag
+	item.Hash = getJobHash(job)
+	return s.store.saveItem(item)
+}
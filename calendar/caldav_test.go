@@ -0,0 +1,312 @@
+package calendar
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testCalDAVServer 是一个简单的 CalDAV 服务器，只支持同步用到的方法。
+type testCalDAVServer struct {
+	mu        sync.Mutex
+	resources map[string]string // key is path
+	etags     map[string]string
+	serial    int
+}
+
+func newTestCalDAVServer() *testCalDAVServer {
+	return &testCalDAVServer{
+		resources: make(map[string]string),
+		etags:     make(map[string]string),
+	}
+}
+
+func (srv *testCalDAVServer) setResource(p, data string) {
+	srv.serial++
+	srv.resources[p] = data
+	srv.etags[p] = fmt.Sprintf(`"%d"`, srv.serial)
+}
+
+// escapePath 和真实的服务器一样，返回编码后的 href
+func escapePath(p string) string {
+	u := url.URL{Path: p}
+	return u.EscapedPath()
+}
+
+func (srv *testCalDAVServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	p := r.URL.Path
+	etag, exists := srv.etags[p]
+	switch r.Method {
+	case "PROPFIND":
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprint(w, `<?xml version="1.0"?><d:multistatus xmlns:d="DAV:">`)
+		fmt.Fprintf(w, `<d:response><d:href>%s</d:href><d:propstat><d:prop>`+
+			`<d:resourcetype><d:collection/></d:resourcetype></d:prop>`+
+			`<d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, escapePath(p))
+		for href, etag := range srv.etags {
+			fmt.Fprintf(w, `<d:response><d:href>%s</d:href><d:propstat><d:prop>`+
+				`<d:getetag>%s</d:getetag><d:resourcetype/></d:prop>`+
+				`<d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, escapePath(href), etag)
+		}
+		fmt.Fprint(w, `</d:multistatus>`)
+
+	case http.MethodGet:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		fmt.Fprint(w, srv.resources[p])
+
+	case http.MethodPut:
+		if (r.Header.Get("If-None-Match") == "*" && exists) ||
+			(r.Header.Get("If-Match") != "" && r.Header.Get("If-Match") != etag) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		srv.setResource(p, string(data))
+		w.Header().Set("ETag", srv.etags[p])
+		w.WriteHeader(http.StatusCreated)
+
+	case http.MethodDelete:
+		if r.Header.Get("If-Match") != "" && r.Header.Get("If-Match") != etag {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		delete(srv.resources, p)
+		delete(srv.etags, p)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+type testCalDAVStore struct {
+	jobs      map[uint]*Job
+	items     map[uint]*CalDAVItem
+	nextID    uint
+	updatedAt time.Time
+}
+
+func newTestCalDAVStore() *testCalDAVStore {
+	return &testCalDAVStore{
+		jobs:   make(map[uint]*Job),
+		items:  make(map[uint]*CalDAVItem),
+		nextID: 1,
+	}
+}
+
+func (st *testCalDAVStore) getJobs() ([]*Job, error) {
+	var result []*Job
+	for _, job := range st.jobs {
+		j := *job
+		result = append(result, &j)
+	}
+	return result, nil
+}
+
+func (st *testCalDAVStore) saveJob(job *Job) error {
+	if job.ID == 0 {
+		job.ID = st.nextID
+		st.nextID++
+	}
+	job.UpdatedAt = st.updatedAt
+	j := *job
+	st.jobs[job.ID] = &j
+	return nil
+}
+
+func (st *testCalDAVStore) deleteJob(id uint) error {
+	delete(st.jobs, id)
+	return nil
+}
+
+func (st *testCalDAVStore) getItems() ([]*CalDAVItem, error) {
+	var result []*CalDAVItem
+	for _, item := range st.items {
+		i := *item
+		result = append(result, &i)
+	}
+	return result, nil
+}
+
+func (st *testCalDAVStore) saveItem(item *CalDAVItem) error {
+	if item.ID == 0 {
+		item.ID = st.nextID
+		st.nextID++
+	}
+	i := *item
+	st.items[item.ID] = &i
+	return nil
+}
+
+func (st *testCalDAVStore) deleteItem(item *CalDAVItem) error {
+	delete(st.items, item.ID)
+	return nil
+}
+
+func (st *testCalDAVStore) findJob(title string) *Job {
+	for _, job := range st.jobs {
+		if job.Title == title {
+			return job
+		}
+	}
+	return nil
+}
+
+func TestSyncCalDAV(t *testing.T) {
+	srv := newTestCalDAVServer()
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	client, err := newCalDAVClient(&CalDAVConfig{URL: ts.URL + "/cal"})
+	assert.NoError(t, err)
+	store := newTestCalDAVStore()
+	now := time.Now()
+	store.updatedAt = now
+	doSync := func(policy string) *CalDAVSyncResult {
+		result, err := syncCalDAV(client, store, policy, now)
+		assert.NoError(t, err)
+		assert.Empty(t, result.Errors)
+		return result
+	}
+
+	// 本地新建的日程推送到服务器，服务器上的日程拉取到本地
+	err = store.saveJob(&Job{
+		Title: "local",
+		Start: newTimeYMDHM(2020, 1, 1, 9, 0),
+		End:   newTimeYMDHM(2020, 1, 1, 10, 0),
+	})
+	assert.NoError(t, err)
+	remoteJob := &Job{
+		UID:   "remote@example.com",
+		Title: "remote",
+		Start: newTimeYMDHM(2020, 1, 2, 9, 0),
+		End:   newTimeYMDHM(2020, 1, 2, 10, 0),
+	}
+	srv.setResource("/cal/remote.ics", encodeICS([]*Job{remoteJob}, now))
+
+	result := doSync("")
+	assert.Equal(t, 1, result.Pushed)
+	assert.Equal(t, 1, result.Pulled)
+	assert.Len(t, srv.resources, 2)
+	assert.Len(t, store.jobs, 2)
+	assert.Len(t, store.items, 2)
+	assert.Equal(t, "remote@example.com", store.findJob("remote").UID)
+
+	// 没有修改时不做任何事
+	result = doSync("")
+	assert.Equal(t, 0, result.Pushed+result.Pulled+result.DeletedLocal+result.DeletedRemote)
+
+	// 服务器上修改了
+	remoteJob.Title = "remote changed"
+	srv.setResource("/cal/remote.ics", encodeICS([]*Job{remoteJob}, now))
+	result = doSync("")
+	assert.Equal(t, 1, result.Pulled)
+	assert.NotNil(t, store.findJob("remote changed"))
+	assert.Len(t, store.jobs, 2)
+
+	// 本地修改了
+	job := store.findJob("local")
+	job.Title = "local changed"
+	result = doSync("")
+	assert.Equal(t, 1, result.Pushed)
+	assert.Contains(t, srv.resources["/cal/"+job.UID+".ics"], "SUMMARY:local changed")
+
+	// 两边都修改了，按照策略处理冲突
+	job = store.jobs[job.ID]
+	job.Title = "local conflict"
+	setRemoteTitle := func(title string) {
+		remote := *job
+		remote.Title = title
+		srv.setResource("/cal/"+job.UID+".ics", encodeICS([]*Job{&remote}, now))
+	}
+	setRemoteTitle("server conflict")
+	result = doSync(conflictPolicyServer)
+	assert.Len(t, result.Conflicts, 1)
+	assert.Equal(t, "server conflict", store.jobs[job.ID].Title)
+
+	job = store.jobs[job.ID]
+	job.Title = "local wins"
+	setRemoteTitle("server loses")
+	result = doSync(conflictPolicyLocal)
+	assert.Len(t, result.Conflicts, 1)
+	assert.Contains(t, srv.resources["/cal/"+job.UID+".ics"], "SUMMARY:local wins")
+
+	// 本地删除了
+	delete(store.jobs, job.ID)
+	result = doSync("")
+	assert.Equal(t, 1, result.DeletedRemote)
+	assert.Len(t, srv.resources, 1)
+
+	// 服务器上删除了
+	delete(srv.resources, "/cal/remote.ics")
+	delete(srv.etags, "/cal/remote.ics")
+	result = doSync("")
+	assert.Equal(t, 1, result.DeletedLocal)
+	assert.Empty(t, store.jobs)
+	assert.Empty(t, store.items)
+}
+
+func TestSyncCalDAVEscapedHref(t *testing.T) {
+	srv := newTestCalDAVServer()
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	client, err := newCalDAVClient(&CalDAVConfig{URL: ts.URL + "/cal"})
+	assert.NoError(t, err)
+	store := newTestCalDAVStore()
+	now := time.Now()
+	store.updatedAt = now
+	doSync := func() *CalDAVSyncResult {
+		result, err := syncCalDAV(client, store, "", now)
+		assert.NoError(t, err)
+		assert.Empty(t, result.Errors)
+		return result
+	}
+
+	// UID 中有需要编码的字符
+	err = store.saveJob(&Job{
+		UID:   "会议 1/a@example.com",
+		Title: "local",
+		Start: newTimeYMDHM(2020, 1, 1, 9, 0),
+		End:   newTimeYMDHM(2020, 1, 1, 10, 0),
+	})
+	assert.NoError(t, err)
+	// 服务器上的资源名中有空格
+	remoteJob := &Job{
+		UID:   "remote",
+		Title: "remote",
+		Start: newTimeYMDHM(2020, 1, 2, 9, 0),
+		End:   newTimeYMDHM(2020, 1, 2, 10, 0),
+	}
+	srv.setResource("/cal/a b.ics", encodeICS([]*Job{remoteJob}, now))
+
+	result := doSync()
+	assert.Equal(t, 1, result.Pushed)
+	assert.Equal(t, 1, result.Pulled)
+	assert.Contains(t, srv.resources, "/cal/会议 1_a@example.com.ics")
+
+	// 再次同步时两边的路径能对应上，不会删除或者重复拉取
+	result = doSync()
+	assert.Equal(t, 0, result.Pushed+result.Pulled+result.DeletedLocal+result.DeletedRemote)
+	assert.Len(t, store.jobs, 2)
+	assert.Len(t, srv.resources, 2)
+
+	// 修改后推送到同一个资源
+	job := store.findJob("local")
+	job.Title = "local changed"
+	result = doSync()
+	assert.Equal(t, 1, result.Pushed)
+	assert.Len(t, srv.resources, 2)
+	assert.Contains(t, srv.resources["/cal/会议 1_a@example.com.ics"], "SUMMARY:local changed")
+}
@@ -35,6 +35,17 @@ func (v *Scheduler) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:     v.DeleteType,
 			InArgs: []string{"id"},
 		},
+		{
+			Name:    "ExportICS",
+			Fn:      v.ExportICS,
+			InArgs:  []string{"ids"},
+			OutArgs: []string{"ics"},
+		},
+		{
+			Name:    "GetCalDAVConfig",
+			Fn:      v.GetCalDAVConfig,
+			OutArgs: []string{"configJSON"},
+		},
 		{
 			Name:    "GetJob",
 			Fn:      v.GetJob,
@@ -70,6 +81,12 @@ func (v *Scheduler) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:      v.GetTypes,
 			OutArgs: []string{"typesJSON"},
 		},
+		{
+			Name:    "ImportICS",
+			Fn:      v.ImportICS,
+			InArgs:  []string{"ics"},
+			OutArgs: []string{"ids"},
+		},
 		{
 			Name:    "QueryJobs",
 			Fn:      v.QueryJobs,
@@ -88,6 +105,16 @@ func (v *Scheduler) GetExportedMethods() dbusutil.ExportedMethods {
 			InArgs:  []string{"params", "rule"},
 			OutArgs: []string{"jobsJSON"},
 		},
+		{
+			Name:   "SetCalDAVConfig",
+			Fn:     v.SetCalDAVConfig,
+			InArgs: []string{"configJSON"},
+		},
+		{
+			Name:    "SyncCalDAV",
+			Fn:      v.SyncCalDAV,
+			OutArgs: []string{"resultJSON"},
+		},
 		{
 			Name:   "UpdateJob",
 			Fn:     v.UpdateJob,
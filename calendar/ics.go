@@ -0,0 +1,698 @@
+package calendar
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 日程和 iCalendar(RFC 5545) 数据之间的转换
+
+const (
+	icsProdID      = "-//Deepin//dde-daemon calendar//EN"
+	icsLayoutUTC   = "20060102T150405Z"
+	icsLayoutLocal = "20060102T150405"
+	icsLayoutDate  = "20060102"
+	icsLineMaxLen  = 75
+
+	icsPropJobType = "X-DDE-JOB-TYPE"
+)
+
+type icsProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// icsComponent 是 BEGIN 和 END 之间的组件，比如 VCALENDAR, VEVENT, VALARM。
+type icsComponent struct {
+	name       string
+	props      []*icsProperty
+	components []*icsComponent
+}
+
+func (c *icsComponent) getProp(name string) *icsProperty {
+	for _, prop := range c.props {
+		if prop.name == name {
+			return prop
+		}
+	}
+	return nil
+}
+
+func (c *icsComponent) getPropValue(name string) string {
+	prop := c.getProp(name)
+	if prop == nil {
+		return ""
+	}
+	return prop.value
+}
+
+func (c *icsComponent) getProps(name string) []*icsProperty {
+	var result []*icsProperty
+	for _, prop := range c.props {
+		if prop.name == name {
+			result = append(result, prop)
+		}
+	}
+	return result
+}
+
+// addProp 添加属性，params 是参数名和参数值交替的列表。
+func (c *icsComponent) addProp(name, value string, params ...string) {
+	prop := &icsProperty{
+		name:  name,
+		value: value,
+	}
+	if len(params) > 0 {
+		prop.params = make(map[string]string)
+		for i := 0; i+1 < len(params); i += 2 {
+			prop.params[params[i]] = params[i+1]
+		}
+	}
+	c.props = append(c.props, prop)
+}
+
+func (c *icsComponent) getComponents(name string) []*icsComponent {
+	var result []*icsComponent
+	for _, sub := range c.components {
+		if sub.name == name {
+			result = append(result, sub)
+		}
+	}
+	return result
+}
+
+func escapeICSText(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, ";", `\;`, -1)
+	s = strings.Replace(s, ",", `\,`, -1)
+	s = strings.Replace(s, "\r\n", `\n`, -1)
+	s = strings.Replace(s, "\n", `\n`, -1)
+	return s
+}
+
+func unescapeICSText(s string) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			buf.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			buf.WriteByte('\n')
+		default:
+			buf.WriteByte(s[i])
+		}
+	}
+	return buf.String()
+}
+
+// foldICSLine 把超过 75 字节的行折叠，不拆开 UTF-8 字符。
+func foldICSLine(buf *bytes.Buffer, line string) {
+	lineLen := 0
+	for _, r := range line {
+		n := len(string(r))
+		if lineLen+n > icsLineMaxLen {
+			buf.WriteString("\r\n ")
+			lineLen = 1
+		}
+		buf.WriteRune(r)
+		lineLen += n
+	}
+	buf.WriteString("\r\n")
+}
+
+func (c *icsComponent) encode(buf *bytes.Buffer) {
+	foldICSLine(buf, "BEGIN:"+c.name)
+	for _, prop := range c.props {
+		var line bytes.Buffer
+		line.WriteString(prop.name)
+		for _, key := range sortedKeys(prop.params) {
+			value := prop.params[key]
+			if strings.ContainsAny(value, ":;,") {
+				value = `"` + value + `"`
+			}
+			line.WriteString(";" + key + "=" + value)
+		}
+		line.WriteString(":" + prop.value)
+		foldICSLine(buf, line.String())
+	}
+	for _, sub := range c.components {
+		sub.encode(buf)
+	}
+	foldICSLine(buf, "END:"+c.name)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// unfoldICSLines 把数据拆成行，并把折叠的行合并。
+func unfoldICSLines(data string) []string {
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 4096), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// parseICSLine 解析一行 NAME;PARAM=VALUE:VALUE，参数值可以用双引号括起来。
+func parseICSLine(line string) (*icsProperty, error) {
+	prop := &icsProperty{}
+	inQuote := false
+	var nameEnd, valueStart = -1, -1
+	for i, ch := range line {
+		if ch == '"' {
+			inQuote = !inQuote
+			continue
+		}
+		if inQuote {
+			continue
+		}
+		if ch == ';' && nameEnd == -1 {
+			nameEnd = i
+		} else if ch == ':' {
+			valueStart = i + 1
+			if nameEnd == -1 {
+				nameEnd = i
+			}
+			break
+		}
+	}
+	if valueStart == -1 {
+		return nil, fmt.Errorf("invalid line %q", line)
+	}
+
+	prop.name = strings.ToUpper(line[:nameEnd])
+	prop.value = line[valueStart:]
+	if nameEnd < valueStart-1 {
+		prop.params = make(map[string]string)
+		for _, param := range splitICSQuoted(line[nameEnd+1:valueStart-1], ';') {
+			kv := strings.SplitN(param, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			prop.params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+	return prop, nil
+}
+
+func splitICSQuoted(s string, sep rune) []string {
+	var result []string
+	inQuote := false
+	start := 0
+	for i, ch := range s {
+		if ch == '"' {
+			inQuote = !inQuote
+		} else if ch == sep && !inQuote {
+			result = append(result, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(result, s[start:])
+}
+
+// parseICS 解析 iCalendar 数据，返回 VCALENDAR 组件。
+func parseICS(data string) (*icsComponent, error) {
+	var stack []*icsComponent
+	var root *icsComponent
+	for _, line := range unfoldICSLines(data) {
+		prop, err := parseICSLine(line)
+		if err != nil {
+			return nil, err
+		}
+
+		switch prop.name {
+		case "BEGIN":
+			c := &icsComponent{name: strings.ToUpper(prop.value)}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.components = append(parent.components, c)
+			} else if root == nil {
+				root = c
+			} else {
+				return nil, errors.New("more than one root component")
+			}
+			stack = append(stack, c)
+
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].name != strings.ToUpper(prop.value) {
+				return nil, fmt.Errorf("unexpected END:%s", prop.value)
+			}
+			stack = stack[:len(stack)-1]
+
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("property %s out of component", prop.name)
+			}
+			c := stack[len(stack)-1]
+			c.props = append(c.props, prop)
+		}
+	}
+
+	if root == nil || len(stack) != 0 {
+		return nil, errors.New("incomplete calendar data")
+	}
+	if root.name != "VCALENDAR" {
+		return nil, fmt.Errorf("unexpected root component %s", root.name)
+	}
+	return root, nil
+}
+
+// parseICSTime 解析 DATE 或者 DATE-TIME 类型的值，返回本地时间。
+func parseICSTime(value string, params map[string]string) (t time.Time, isDate bool, err error) {
+	if params["VALUE"] == "DATE" || len(value) == len(icsLayoutDate) {
+		t, err = time.ParseInLocation(icsLayoutDate, value, time.Local)
+		return t, true, err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err = time.Parse(icsLayoutUTC, value)
+		return t.In(time.Local), false, err
+	}
+
+	loc := time.Local
+	if tzid := params["TZID"]; tzid != "" {
+		l, err := time.LoadLocation(strings.TrimPrefix(tzid, "/"))
+		if err != nil {
+			logger.Debugf("unknown TZID %q, use local time zone", tzid)
+		} else {
+			loc = l
+		}
+	}
+	t, err = time.ParseInLocation(icsLayoutLocal, value, loc)
+	return t.In(time.Local), false, err
+}
+
+func formatICSTime(t time.Time, isDate bool) string {
+	if isDate {
+		return t.Format(icsLayoutDate)
+	}
+	return t.UTC().Format(icsLayoutUTC)
+}
+
+var icsDurationRegexp = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+func parseICSDuration(s string) (time.Duration, error) {
+	match := icsDurationRegexp.FindStringSubmatch(s)
+	if match == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, unit := range units {
+		if match[i+2] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(match[i+2])
+		if err != nil {
+			return 0, err
+		}
+		d += time.Duration(n) * unit
+	}
+	if match[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
+
+func formatICSDuration(d time.Duration) string {
+	var buf bytes.Buffer
+	if d < 0 {
+		buf.WriteByte('-')
+		d = -d
+	}
+	buf.WriteByte('P')
+	if d == 0 {
+		buf.WriteString("T0S")
+		return buf.String()
+	}
+
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	if days > 0 {
+		fmt.Fprintf(&buf, "%dD", days)
+	}
+	if d > 0 {
+		buf.WriteByte('T')
+		hours := d / time.Hour
+		d -= hours * time.Hour
+		minutes := d / time.Minute
+		d -= minutes * time.Minute
+		seconds := d / time.Second
+		if hours > 0 {
+			fmt.Fprintf(&buf, "%dH", hours)
+		}
+		if minutes > 0 {
+			fmt.Fprintf(&buf, "%dM", minutes)
+		}
+		if seconds > 0 {
+			fmt.Fprintf(&buf, "%dS", seconds)
+		}
+	}
+	return buf.String()
+}
+
+// remindToTrigger 把提醒转换成 VALARM 的 TRIGGER，即相对于开始时间的偏移，
+// 全天日程的开始时间是当天的 00:00。
+func remindToTrigger(remind string) (time.Duration, error) {
+	if remindReg1.MatchString(remind) {
+		var nDays, hour, min int
+		_, err := fmt.Sscanf(remind, "%d;%d:%d", &nDays, &hour, &min)
+		if err != nil {
+			return 0, err
+		}
+		return -time.Duration(nDays)*24*time.Hour +
+			time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute, nil
+	}
+
+	nMinutes, err := strconv.Atoi(remind)
+	if err != nil {
+		return 0, err
+	}
+	return -time.Duration(nMinutes) * time.Minute, nil
+}
+
+// triggerToRemind 是 remindToTrigger 的逆转换，超出提醒范围的返回空字符串。
+func triggerToRemind(trigger time.Duration, allDay bool) string {
+	const day = 24 * time.Hour
+	if allDay {
+		nDays := 0
+		for trigger+time.Duration(nDays)*day < 0 {
+			nDays++
+		}
+		clock := trigger + time.Duration(nDays)*day
+		if nDays > 7 || clock >= day {
+			return ""
+		}
+		return fmt.Sprintf("%d;%02d:%02d", nDays,
+			int(clock/time.Hour), int(clock%time.Hour/time.Minute))
+	}
+
+	if trigger > 0 {
+		// 不支持在开始之后提醒，改为开始时提醒
+		trigger = 0
+	}
+	nMinutes := int(-trigger / time.Minute)
+	if nMinutes > 60*24*7 {
+		return ""
+	}
+	return strconv.Itoa(nMinutes)
+}
+
+func getJobTypeName(typeId int) string {
+	for _, jobType := range globalPredefinedTypes {
+		if jobType.ID == uint(typeId) {
+			return jobType.Name
+		}
+	}
+	return ""
+}
+
+var jobTypeNameMap = map[string]int{
+	"work":     jobTypeWork,
+	"life":     jobTypeLife,
+	"other":    jobTypeOther,
+	"festival": JobTypeFestival,
+}
+
+// getJobTypeByCategories 根据 CATEGORIES 找到日程类型，可以匹配当前语言的类型名和英文类型名。
+func getJobTypeByCategories(categories []string) int {
+	for _, category := range categories {
+		category = strings.ToLower(strings.TrimSpace(category))
+		for _, jobType := range globalPredefinedTypes {
+			if strings.ToLower(jobType.Name) == category {
+				return int(jobType.ID)
+			}
+		}
+		if typeId, ok := jobTypeNameMap[category]; ok {
+			return typeId
+		}
+	}
+	return jobTypeOther
+}
+
+// getJobUID 返回日程的 UID，没有 UID 的日程是在本地创建的，用 ID 生成一个，
+// 导出和同步时会保存生成的 UID。
+func getJobUID(job *Job) string {
+	if job.UID != "" {
+		return job.UID
+	}
+	return fmt.Sprintf("job-%d@dde-calendar", job.ID)
+}
+
+func jobToVEvent(job *Job, now time.Time) *icsComponent {
+	event := &icsComponent{name: "VEVENT"}
+	event.addProp("UID", getJobUID(job))
+	event.addProp("DTSTAMP", formatICSTime(now, false))
+	if !job.UpdatedAt.IsZero() {
+		event.addProp("LAST-MODIFIED", formatICSTime(job.UpdatedAt, false))
+	}
+	event.addProp("SUMMARY", escapeICSText(job.Title))
+	if job.Description != "" {
+		event.addProp("DESCRIPTION", escapeICSText(job.Description))
+	}
+
+	if job.AllDay {
+		// 全天日程的 End 是最后一天，而 DTEND 不包含在日程中
+		start := setClock(job.Start, Clock{})
+		end := setClock(job.End, Clock{}).AddDate(0, 0, 1)
+		event.addProp("DTSTART", formatICSTime(start, true), "VALUE", "DATE")
+		event.addProp("DTEND", formatICSTime(end, true), "VALUE", "DATE")
+	} else {
+		event.addProp("DTSTART", formatICSTime(job.Start, false))
+		event.addProp("DTEND", formatICSTime(job.End, false))
+	}
+
+	if job.RRule != "" {
+		event.addProp("RRULE", job.RRule)
+	}
+
+	ignore, err := job.getIgnore()
+	if err != nil {
+		logger.Warning(err)
+	}
+	for _, t := range ignore {
+		if job.AllDay {
+			event.addProp("EXDATE", formatICSTime(t, true), "VALUE", "DATE")
+		} else {
+			event.addProp("EXDATE", formatICSTime(t, false))
+		}
+	}
+
+	if typeName := getJobTypeName(job.Type); typeName != "" {
+		event.addProp("CATEGORIES", escapeICSText(typeName))
+	}
+	event.addProp(icsPropJobType, strconv.Itoa(job.Type))
+
+	if job.Remind != "" {
+		trigger, err := remindToTrigger(job.Remind)
+		if err != nil {
+			logger.Warning(err)
+		} else {
+			alarm := &icsComponent{name: "VALARM"}
+			alarm.addProp("ACTION", "DISPLAY")
+			alarm.addProp("DESCRIPTION", escapeICSText(job.Title))
+			alarm.addProp("TRIGGER", formatICSDuration(trigger))
+			event.components = append(event.components, alarm)
+		}
+	}
+	return event
+}
+
+func encodeICS(jobs []*Job, now time.Time) string {
+	cal := &icsComponent{name: "VCALENDAR"}
+	cal.addProp("VERSION", "2.0")
+	cal.addProp("PRODID", icsProdID)
+	cal.addProp("CALSCALE", "GREGORIAN")
+	for _, job := range jobs {
+		cal.components = append(cal.components, jobToVEvent(job, now))
+	}
+	var buf bytes.Buffer
+	cal.encode(&buf)
+	return buf.String()
+}
+
+// icsEvent 是从 VEVENT 转换得到的日程，recurrenceID 不为零时，是重复日程中被单独修改的一次。
+type icsEvent struct {
+	job          *Job
+	recurrenceID time.Time
+	lastModified time.Time
+}
+
+func vEventToJob(event *icsComponent) (*icsEvent, error) {
+	uid := event.getPropValue("UID")
+	if uid == "" {
+		return nil, errors.New("event without UID")
+	}
+
+	dtStart := event.getProp("DTSTART")
+	if dtStart == nil {
+		return nil, fmt.Errorf("event %s without DTSTART", uid)
+	}
+	start, allDay, err := parseICSTime(dtStart.value, dtStart.params)
+	if err != nil {
+		return nil, err
+	}
+
+	var end time.Time
+	if dtEnd := event.getProp("DTEND"); dtEnd != nil {
+		end, _, err = parseICSTime(dtEnd.value, dtEnd.params)
+		if err != nil {
+			return nil, err
+		}
+	} else if duration := event.getPropValue("DURATION"); duration != "" {
+		d, err := parseICSDuration(duration)
+		if err != nil {
+			return nil, err
+		}
+		end = start.Add(d)
+	} else if allDay {
+		end = start.AddDate(0, 0, 1)
+	} else {
+		end = start
+	}
+	if allDay {
+		// DTEND 不包含在日程中，全天日程的 End 是最后一天的 23:59
+		end = setClock(end.AddDate(0, 0, -1), Clock{Hour: 23, Minute: 59})
+		if end.Before(start) {
+			end = setClock(start, Clock{Hour: 23, Minute: 59})
+		}
+	}
+
+	title := unescapeICSText(event.getPropValue("SUMMARY"))
+	job := &Job{
+		UID:         uid,
+		Title:       title,
+		TitlePinyin: createPinyin(title),
+		Description: unescapeICSText(event.getPropValue("DESCRIPTION")),
+		AllDay:      allDay,
+		Start:       start,
+		End:         end,
+		RRule:       strings.TrimPrefix(event.getPropValue("RRULE"), "RRULE:"),
+	}
+
+	typeId, err := strconv.Atoi(event.getPropValue(icsPropJobType))
+	if err != nil || typeId <= 0 || typeId > JobTypeFestival {
+		var categories []string
+		for _, prop := range event.getProps("CATEGORIES") {
+			for _, category := range splitICSQuoted(prop.value, ',') {
+				categories = append(categories, unescapeICSText(category))
+			}
+		}
+		typeId = getJobTypeByCategories(categories)
+	}
+	job.Type = typeId
+
+	var ignore []time.Time
+	for _, prop := range event.getProps("EXDATE") {
+		for _, value := range strings.Split(prop.value, ",") {
+			t, _, err := parseICSTime(value, prop.params)
+			if err != nil {
+				return nil, err
+			}
+			ignore = append(ignore, t)
+		}
+	}
+	if len(ignore) > 0 {
+		err = job.setIgnore(ignore)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, alarm := range event.getComponents("VALARM") {
+		remind, ok := alarmToRemind(alarm, job)
+		if ok {
+			job.Remind = remind
+			break
+		}
+	}
+
+	result := &icsEvent{job: job}
+	if prop := event.getProp("RECURRENCE-ID"); prop != nil {
+		result.recurrenceID, _, err = parseICSTime(prop.value, prop.params)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if value := event.getPropValue("LAST-MODIFIED"); value != "" {
+		result.lastModified, _, _ = parseICSTime(value, nil)
+	}
+	return result, nil
+}
+
+func alarmToRemind(alarm *icsComponent, job *Job) (string, bool) {
+	prop := alarm.getProp("TRIGGER")
+	if prop == nil {
+		return "", false
+	}
+
+	base := job.Start
+	if job.AllDay {
+		base = setClock(job.Start, Clock{})
+	}
+
+	var trigger time.Duration
+	if prop.params["VALUE"] == "DATE-TIME" {
+		t, _, err := parseICSTime(prop.value, prop.params)
+		if err != nil {
+			return "", false
+		}
+		trigger = t.Sub(base)
+	} else {
+		d, err := parseICSDuration(prop.value)
+		if err != nil {
+			return "", false
+		}
+		trigger = d
+		if prop.params["RELATED"] == "END" {
+			trigger += job.End.Sub(job.Start)
+		}
+	}
+
+	remind := triggerToRemind(trigger, job.AllDay)
+	return remind, remind != ""
+}
+
+// decodeICS 解析 iCalendar 数据中的所有 VEVENT。
+func decodeICS(data string) ([]*icsEvent, error) {
+	cal, err := parseICS(data)
+	if err != nil {
+		return nil, err
+	}
+	var result []*icsEvent
+	for _, event := range cal.getComponents("VEVENT") {
+		ev, err := vEventToJob(event)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, ev)
+	}
+	return result, nil
+}
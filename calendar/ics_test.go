@@ -0,0 +1,191 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestICSDuration(t *testing.T) {
+	tests := []struct {
+		str string
+		d   time.Duration
+	}{
+		{"PT0S", 0},
+		{"-PT15M", -15 * time.Minute},
+		{"PT9H", 9 * time.Hour},
+		{"-P1DT15H", -39 * time.Hour},
+		{"P2D", 48 * time.Hour},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.str, formatICSDuration(test.d))
+		d, err := parseICSDuration(test.str)
+		assert.NoError(t, err)
+		assert.Equal(t, test.d, d)
+	}
+
+	d, err := parseICSDuration("-P1W")
+	assert.NoError(t, err)
+	assert.Equal(t, -7*24*time.Hour, d)
+	_, err = parseICSDuration("P")
+	assert.Error(t, err)
+	_, err = parseICSDuration("PT")
+	assert.Error(t, err)
+}
+
+func TestRemindTrigger(t *testing.T) {
+	tests := []struct {
+		remind  string
+		allDay  bool
+		trigger time.Duration
+	}{
+		{"0", false, 0},
+		{"15", false, -15 * time.Minute},
+		{"1440", false, -24 * time.Hour},
+		{"0;09:00", true, 9 * time.Hour},
+		{"1;09:00", true, -15 * time.Hour},
+		{"7;23:30", true, -6*24*time.Hour - 30*time.Minute},
+	}
+	for _, test := range tests {
+		trigger, err := remindToTrigger(test.remind)
+		assert.NoError(t, err)
+		assert.Equal(t, test.trigger, trigger)
+		assert.Equal(t, test.remind, triggerToRemind(test.trigger, test.allDay))
+	}
+
+	assert.Equal(t, "0", triggerToRemind(time.Hour, false))
+	assert.Equal(t, "", triggerToRemind(-8*24*time.Hour, false))
+	assert.Equal(t, "", triggerToRemind(-8*24*time.Hour, true))
+	assert.Equal(t, "", triggerToRemind(25*time.Hour, true))
+}
+
+func TestICSTextAndFold(t *testing.T) {
+	text := "a,b;c\\d\ne"
+	assert.Equal(t, `a\,b\;c\\d\ne`, escapeICSText(text))
+	assert.Equal(t, text, unescapeICSText(escapeICSText(text)))
+
+	job := &Job{
+		Title: strings.Repeat("日程", 40),
+		Start: newTimeYMDHM(2020, 1, 1, 9, 0),
+		End:   newTimeYMDHM(2020, 1, 1, 10, 0),
+	}
+	data := encodeICS([]*Job{job}, time.Now())
+	for _, line := range strings.Split(data, "\r\n") {
+		assert.True(t, len(line) <= icsLineMaxLen, line)
+	}
+	events, err := decodeICS(data)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, job.Title, events[0].job.Title)
+}
+
+func TestICSRoundTrip(t *testing.T) {
+	jobs := []*Job{
+		{
+			Type:        jobTypeWork,
+			Title:       "Weekly meeting",
+			Description: "room 1, floor 2",
+			Start:       newTimeYMDHM(2020, 3, 2, 10, 0),
+			End:         newTimeYMDHM(2020, 3, 2, 11, 30),
+			RRule:       "FREQ=WEEKLY;BYDAY=MO",
+			Remind:      "15",
+		},
+		{
+			Type:   jobTypeLife,
+			Title:  "Trip",
+			AllDay: true,
+			Start:  newTimeYMDHM(2020, 3, 6, 0, 0),
+			End:    newTimeYMDHM(2020, 3, 8, 23, 59),
+			RRule:  "FREQ=YEARLY",
+			Remind: "1;09:00",
+		},
+	}
+	jobs[0].ID = 1
+	jobs[1].ID = 2
+	err := jobs[0].setIgnore([]time.Time{newTimeYMDHM(2020, 3, 9, 10, 0)})
+	assert.NoError(t, err)
+	err = jobs[1].setIgnore([]time.Time{newTimeYMDHM(2021, 3, 6, 0, 0)})
+	assert.NoError(t, err)
+
+	data := encodeICS(jobs, time.Now())
+	assert.Contains(t, data, "DTSTART;VALUE=DATE:20200306\r\n")
+	assert.Contains(t, data, "DTEND;VALUE=DATE:20200309\r\n")
+	assert.Contains(t, data, "TRIGGER:-PT15M\r\n")
+
+	events, err := decodeICS(data)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	for i, event := range events {
+		job := event.job
+		assert.Equal(t, getJobUID(jobs[i]), job.UID)
+		assert.Equal(t, jobs[i].Type, job.Type)
+		assert.Equal(t, jobs[i].Title, job.Title)
+		assert.Equal(t, jobs[i].Description, job.Description)
+		assert.Equal(t, jobs[i].AllDay, job.AllDay)
+		assert.True(t, jobs[i].Start.Equal(job.Start))
+		assert.True(t, jobs[i].End.Equal(job.End))
+		assert.Equal(t, jobs[i].RRule, job.RRule)
+		assert.Equal(t, jobs[i].Remind, job.Remind)
+
+		ignore0, _ := jobs[i].getIgnore()
+		ignore, err := job.getIgnore()
+		assert.NoError(t, err)
+		assert.Len(t, ignore, 1)
+		assert.True(t, ignore0[0].Equal(ignore[0]))
+	}
+}
+
+func TestDecodeICS(t *testing.T) {
+	data := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//Example//EN",
+		"BEGIN:VEVENT",
+		"UID:abc@example.com",
+		"SUMMARY:Stand",
+		" up",
+		"DTSTART;TZID=UTC:20200301T090000",
+		"DURATION:PT30M",
+		"RRULE:FREQ=DAILY;COUNT=10",
+		"EXDATE:20200302T090000Z,20200303T090000Z",
+		"CATEGORIES:Meeting,Work",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"TRIGGER;RELATED=END:-PT40M",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:abc@example.com",
+		"RECURRENCE-ID:20200305T090000Z",
+		"SUMMARY:Stand up (moved)",
+		"DTSTART:20200305T100000Z",
+		"DTEND:20200305T103000Z",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+
+	events, err := decodeICS(data)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+
+	job := events[0].job
+	assert.Equal(t, "Standup", job.Title)
+	assert.True(t, time.Date(2020, 3, 1, 9, 0, 0, 0, time.UTC).Equal(job.Start))
+	assert.Equal(t, 30*time.Minute, job.End.Sub(job.Start))
+	assert.Equal(t, jobTypeWork, job.Type)
+	assert.Equal(t, "10", job.Remind)
+	ignore, err := job.getIgnore()
+	assert.NoError(t, err)
+	assert.Len(t, ignore, 2)
+	assert.True(t, events[0].recurrenceID.IsZero())
+
+	assert.True(t, time.Date(2020, 3, 5, 9, 0, 0, 0, time.UTC).Equal(events[1].recurrenceID))
+
+	_, err = decodeICS("BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nEND:VCALENDAR\r\n")
+	assert.Error(t, err)
+	_, err = decodeICS("BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nSUMMARY:a\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n")
+	assert.Error(t, err)
+}
@@ -38,6 +38,8 @@ type Job struct {
 	RecurID int    `gorm:"-"`
 	Ignore  string // 忽略，JSON
 
+	UID string // iCalendar 中的 UID，导入的日程才有
+
 	remindTime time.Time
 }
 
@@ -76,6 +76,11 @@ func (m *Module) start() error {
 		logger.Warning(err)
 	}
 
+	err = db.AutoMigrate(&CalDAVItem{}).Error
+	if err != nil {
+		logger.Warning(err)
+	}
+
 	hasJobTypeTable := db.HasTable(&JobType{})
 
 	err = db.AutoMigrate(&JobType{}).Error
@@ -105,6 +110,7 @@ func (m *Module) start() error {
 	}
 
 	m.scheduler.startRemindLoop()
+	m.scheduler.initCalDAV()
 	return nil
 }
 
@@ -30,6 +30,12 @@ type Scheduler struct {
 	remindLaterTimersMu sync.Mutex
 	festivalJobEnabled  bool
 
+	calDAVMu     sync.Mutex
+	calDAVConfig *CalDAVConfig
+	calDAVTimer  *time.Timer
+	calDAVClosed bool
+	calDAVSyncMu sync.Mutex
+
 	changeChan chan []uint
 	quitChan   chan struct{}
 
@@ -62,6 +68,7 @@ func newScheduler(db *gorm.DB, service *dbusutil.Service) *Scheduler {
 }
 
 func (s *Scheduler) destroy() {
+	s.destroyCalDAV()
 	s.notifications.RemoveAllHandlers()
 	s.signalLoop.Stop()
 	close(s.quitChan)
@@ -0,0 +1,299 @@
+package calendar
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"pkg.deepin.io/lib/xdg/basedir"
+)
+
+var calDAVConfigFile = filepath.Join(basedir.GetUserConfigDir(), "deepin/dde-daemon/calendar/caldav.json")
+
+func (s *Scheduler) findJobByUID(db *gorm.DB, uid string) (*Job, error) {
+	var job Job
+	err := db.Where("uid = ?", uid).First(&job).Error
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// importICS 导入 iCalendar 数据中的日程，UID 相同的日程会被更新。
+// 重复日程中被单独修改的一次，和 setJobRemind 中的处理一样，加入主日程的忽略列表，再创建一个新的日程。
+func (s *Scheduler) importICS(data string) ([]uint, error) {
+	events, err := decodeICS(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []uint
+	var overrides []*icsEvent
+	for _, event := range events {
+		if !event.recurrenceID.IsZero() {
+			overrides = append(overrides, event)
+			continue
+		}
+		id, err := s.saveImportedJob(event.job)
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+
+	for _, event := range overrides {
+		master, err := s.findJobByUID(s.db, event.job.UID)
+		if err != nil {
+			return ids, err
+		}
+		if master != nil {
+			ignore, err := master.getIgnore()
+			if err != nil {
+				return ids, err
+			}
+			if !timeSliceContains(ignore, event.recurrenceID) {
+				err = master.setIgnore(append(ignore, event.recurrenceID))
+				if err != nil {
+					return ids, err
+				}
+				err = s.db.Model(master).Update("Ignore", master.Ignore).Error
+				if err != nil {
+					return ids, err
+				}
+			}
+		}
+
+		event.job.UID += "/" + formatICSTime(event.recurrenceID, event.job.AllDay)
+		event.job.RRule = ""
+		id, err := s.saveImportedJob(event.job)
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (s *Scheduler) saveImportedJob(job *Job) (uint, error) {
+	job0, err := s.findJobByUID(s.db, job.UID)
+	if err != nil {
+		return 0, err
+	}
+	if job0 == nil {
+		err = s.createJob(job)
+		return job.ID, err
+	}
+	job.ID = job0.ID
+	err = s.updateJob(job)
+	return job.ID, err
+}
+
+func (s *Scheduler) exportICS(ids []uint) (string, error) {
+	var jobs []*Job
+	db := s.db
+	if len(ids) > 0 {
+		db = db.Where("id IN (?)", ids)
+	}
+	err := db.Find(&jobs).Error
+	if err != nil {
+		return "", err
+	}
+
+	// 保存为本地日程生成的 UID，再次导入导出的文件时可以找到对应的日程，
+	// 用 UpdateColumn 不修改 UpdatedAt
+	for _, job := range jobs {
+		if job.UID != "" {
+			continue
+		}
+		uid := getJobUID(job)
+		err = s.db.Model(job).UpdateColumn("UID", uid).Error
+		if err != nil {
+			return "", err
+		}
+		job.UID = uid
+	}
+	return encodeICS(jobs, time.Now()), nil
+}
+
+type dbCalDAVStore struct {
+	s *Scheduler
+}
+
+func (st dbCalDAVStore) getJobs() ([]*Job, error) {
+	var jobs []*Job
+	err := st.s.db.Find(&jobs).Error
+	return jobs, err
+}
+
+func (st dbCalDAVStore) saveJob(job *Job) error {
+	if job.ID == 0 {
+		return st.s.createJob(job)
+	}
+	err := st.s.updateJob(job)
+	if err != nil {
+		return err
+	}
+	// updateJob 不修改 UID
+	return st.s.db.Model(job).Update("UID", job.UID).Error
+}
+
+func (st dbCalDAVStore) deleteJob(id uint) error {
+	return st.s.deleteJob(id)
+}
+
+func (st dbCalDAVStore) getItems() ([]*CalDAVItem, error) {
+	var items []*CalDAVItem
+	err := st.s.db.Find(&items).Error
+	return items, err
+}
+
+func (st dbCalDAVStore) saveItem(item *CalDAVItem) error {
+	return st.s.db.Save(item).Error
+}
+
+func (st dbCalDAVStore) deleteItem(item *CalDAVItem) error {
+	return st.s.db.Unscoped().Delete(item).Error
+}
+
+func loadCalDAVConfig(filename string) (*CalDAVConfig, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var cfg CalDAVConfig
+	err = fromJson(string(data), &cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func saveCalDAVConfig(filename string, cfg *CalDAVConfig) error {
+	data, err := toJson(cfg)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		return err
+	}
+	// 包含密码
+	return ioutil.WriteFile(filename, []byte(data), 0600)
+}
+
+func (s *Scheduler) initCalDAV() {
+	cfg, err := loadCalDAVConfig(calDAVConfigFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warning("failed to load CalDAV config:", err)
+		}
+		cfg = &CalDAVConfig{}
+	}
+	s.calDAVMu.Lock()
+	s.calDAVConfig = cfg
+	s.scheduleCalDAVSyncNoLock()
+	s.calDAVMu.Unlock()
+}
+
+func (s *Scheduler) setCalDAVConfig(cfg *CalDAVConfig) error {
+	err := cfg.check()
+	if err != nil {
+		return err
+	}
+
+	s.calDAVMu.Lock()
+	defer s.calDAVMu.Unlock()
+	if cfg.Password == "" && cfg.URL == s.calDAVConfig.URL && cfg.Username == s.calDAVConfig.Username {
+		// GetCalDAVConfig 不返回密码，密码为空时保持原来的密码
+		cfg.Password = s.calDAVConfig.Password
+	}
+	if cfg.URL != s.calDAVConfig.URL {
+		// 换了日历集合，之前的同步状态没有用了
+		err = s.db.Unscoped().Delete(&CalDAVItem{}).Error
+		if err != nil {
+			return err
+		}
+	}
+	err = saveCalDAVConfig(calDAVConfigFile, cfg)
+	if err != nil {
+		return err
+	}
+	s.calDAVConfig = cfg
+	s.scheduleCalDAVSyncNoLock()
+	return nil
+}
+
+func (s *Scheduler) scheduleCalDAVSyncNoLock() {
+	if s.calDAVTimer != nil {
+		s.calDAVTimer.Stop()
+		s.calDAVTimer = nil
+	}
+	cfg := s.calDAVConfig
+	if s.calDAVClosed || cfg.URL == "" || cfg.Interval <= 0 {
+		return
+	}
+	s.calDAVTimer = time.AfterFunc(time.Duration(cfg.Interval)*time.Minute, func() {
+		_, err := s.syncCalDAV()
+		if err != nil {
+			logger.Warning("failed to sync CalDAV:", err)
+		}
+	})
+}
+
+func (s *Scheduler) syncCalDAV() (*CalDAVSyncResult, error) {
+	// 同一时间只进行一次同步；网络请求时不持有 calDAVMu，避免阻塞读写配置
+	s.calDAVSyncMu.Lock()
+	defer s.calDAVSyncMu.Unlock()
+
+	s.calDAVMu.Lock()
+	cfg := *s.calDAVConfig
+	s.calDAVMu.Unlock()
+	defer func() {
+		s.calDAVMu.Lock()
+		s.scheduleCalDAVSyncNoLock()
+		s.calDAVMu.Unlock()
+	}()
+
+	if cfg.URL == "" {
+		return nil, errors.New("CalDAV is not configured")
+	}
+	client, err := newCalDAVClient(&cfg)
+	if err != nil {
+		return nil, err
+	}
+	result, err := syncCalDAV(client, dbCalDAVStore{s}, cfg.ConflictPolicy, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	s.calDAVMu.Lock()
+	if s.calDAVConfig.URL != cfg.URL {
+		// 同步的过程中换了日历集合，这次同步保存的状态属于原来的日历集合
+		err = s.db.Unscoped().Delete(&CalDAVItem{}).Error
+		if err != nil {
+			logger.Warning(err)
+		}
+	}
+	s.calDAVMu.Unlock()
+	logger.Debugf("CalDAV sync result: %+v", result)
+	if len(result.changedJobs) > 0 {
+		s.notifyJobsChange(result.changedJobs...)
+	}
+	return result, nil
+}
+
+func (s *Scheduler) destroyCalDAV() {
+	s.calDAVMu.Lock()
+	s.calDAVClosed = true
+	if s.calDAVTimer != nil {
+		s.calDAVTimer.Stop()
+		s.calDAVTimer = nil
+	}
+	s.calDAVMu.Unlock()
+}
@@ -237,3 +237,56 @@ func (s *Scheduler) UpdateType(typeJSON string) *dbus.Error {
 	err = s.updateType(jt)
 	return dbusutil.ToError(err)
 }
+
+func (s *Scheduler) ImportICS(ics string) (ids []int64, busErr *dbus.Error) {
+	jobIds, err := s.importICS(ics)
+	if len(jobIds) > 0 {
+		s.notifyJobsChange(jobIds...)
+	}
+	if err != nil {
+		return nil, dbusutil.ToError(err)
+	}
+	ids = make([]int64, len(jobIds))
+	for idx, id := range jobIds {
+		ids[idx] = int64(id)
+	}
+	return ids, nil
+}
+
+func (s *Scheduler) ExportICS(ids []int64) (ics string, busErr *dbus.Error) {
+	jobIds := make([]uint, len(ids))
+	for idx, id := range ids {
+		jobIds[idx] = uint(id)
+	}
+	ics, err := s.exportICS(jobIds)
+	return ics, dbusutil.ToError(err)
+}
+
+func (s *Scheduler) GetCalDAVConfig() (configJSON string, busErr *dbus.Error) {
+	s.calDAVMu.Lock()
+	cfg := *s.calDAVConfig
+	s.calDAVMu.Unlock()
+
+	cfg.Password = ""
+	configJSON, err := toJson(cfg)
+	return configJSON, dbusutil.ToError(err)
+}
+
+func (s *Scheduler) SetCalDAVConfig(configJSON string) *dbus.Error {
+	var cfg CalDAVConfig
+	err := fromJson(configJSON, &cfg)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	err = s.setCalDAVConfig(&cfg)
+	return dbusutil.ToError(err)
+}
+
+func (s *Scheduler) SyncCalDAV() (resultJSON string, busErr *dbus.Error) {
+	result, err := s.syncCalDAV()
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	resultJSON, err = toJson(result)
+	return resultJSON, dbusutil.ToError(err)
+}
@@ -12,15 +12,40 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			Name: "BecomeClipboardOwner",
 			Fn:   v.BecomeClipboardOwner,
 		},
+		{
+			Name: "Clear",
+			Fn:   v.Clear,
+		},
+		{
+			Name:    "GetHistory",
+			Fn:      v.GetHistory,
+			OutArgs: []string{"historyJSON"},
+		},
+		{
+			Name:   "Remove",
+			Fn:     v.Remove,
+			InArgs: []string{"id"},
+		},
 		{
 			Name:   "RemoveTarget",
 			Fn:     v.RemoveTarget,
 			InArgs: []string{"target"},
 		},
+		{
+			Name:   "Restore",
+			Fn:     v.Restore,
+			InArgs: []string{"id"},
+		},
 		{
 			Name: "SaveClipboard",
 			Fn:   v.SaveClipboard,
 		},
+		{
+			Name:    "Search",
+			Fn:      v.Search,
+			InArgs:  []string{"keyword"},
+			OutArgs: []string{"historyJSON"},
+		},
 		{
 			Name: "WriteContent",
 			Fn:   v.WriteContent,
@@ -0,0 +1,386 @@
+package clipboard
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"pkg.deepin.io/lib/strv"
+	"pkg.deepin.io/lib/xdg/basedir"
+)
+
+const (
+	historyTypeText    = "text"
+	historyTypeImage   = "image"
+	historyTypeURIList = "uri-list"
+
+	historyPreviewMaxLen = 200
+)
+
+// 剪贴板连续变化时历史记录延迟保存，只写一次文件
+var historySaveDelay = 5 * time.Second
+
+var (
+	historyConfigFile = filepath.Join(basedir.GetUserConfigDir(), "deepin/dde-daemon/clipboard.json")
+	historyDataFile   = filepath.Join(basedir.GetUserCacheDir(), "deepin/dde-daemon/clipboard-history.json")
+)
+
+var (
+	historyTextTargets = []string{"UTF8_STRING", "text/plain;charset=utf-8", "text/plain", "STRING", "TEXT"}
+	historyFileTargets = []string{"text/uri-list", "x-special/gnome-copied-files"}
+)
+
+// 按顺序匹配，提供了 keys 中的任意一个 target 就是这种类型，比如文件管理器复制文件时也会提供 text/plain
+var historyTypeTargets = []struct {
+	typ     string
+	keys    []string
+	targets []string
+	single  bool // 只保存第一个提供了的 target
+}{
+	{historyTypeURIList, historyFileTargets,
+		append(append([]string{}, historyFileTargets...), historyTextTargets[:3]...), false},
+	{historyTypeImage, []string{"image/png", "image/jpeg", "image/bmp"},
+		[]string{"image/png", "image/jpeg", "image/bmp"}, true},
+	{historyTypeText, historyTextTargets, historyTextTargets, false},
+}
+
+// 密码管理器通过这些 target 表明剪贴板中是敏感内容
+var sensitiveTargets = []string{
+	"x-kde-passwordManagerHint",
+	"application/x-nspasteboard-concealed-type",
+}
+
+type HistoryConfig struct {
+	MaxEntries       int
+	MaxEntrySize     int
+	MaxTotalSize     int // 所有记录的总大小，超过时删除最旧的记录
+	Persistent       bool
+	BlacklistWMClass []string
+}
+
+func getDefaultHistoryConfig() *HistoryConfig {
+	return &HistoryConfig{
+		MaxEntries:       50,
+		MaxEntrySize:     10 * 1024 * 1024,
+		MaxTotalSize:     20 * 1024 * 1024,
+		BlacklistWMClass: []string{"keepassxc", "keepass2", "bitwarden", "1password"},
+	}
+}
+
+func loadHistoryConfig(filename string) (*HistoryConfig, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	cfg := getDefaultHistoryConfig()
+	err = json.Unmarshal(data, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxEntries <= 0 {
+		return nil, errors.New("invalid MaxEntries")
+	}
+	return cfg, nil
+}
+
+func (cfg *HistoryConfig) isBlacklisted(wmClass []string) bool {
+	for _, name := range wmClass {
+		for _, b := range cfg.BlacklistWMClass {
+			if name != "" && strings.EqualFold(name, b) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HistoryTarget 是保存下来的一个 target 的数据，因为要保存到文件里，target 和类型都用名字表示
+type HistoryTarget struct {
+	Target string
+	Type   string
+	Format uint8
+	Data   []byte
+}
+
+type HistoryEntry struct {
+	Id      uint64
+	Type    string
+	Time    int64
+	Preview string
+	Size    int
+
+	hash    string
+	targets []*HistoryTarget
+}
+
+// 保存到文件中的格式
+type historyEntryFile struct {
+	HistoryEntry
+	Hash    string
+	Targets []*HistoryTarget
+}
+
+func isSensitiveTargets(targetNames []string) bool {
+	return hasAnyTarget(targetNames, sensitiveTargets)
+}
+
+// getHistoryTargets 返回内容的类型和需要保存的 targets
+func getHistoryTargets(targetNames []string) (string, []string) {
+	for _, tt := range historyTypeTargets {
+		if !hasAnyTarget(targetNames, tt.keys) {
+			continue
+		}
+		var result []string
+		for _, t := range tt.targets {
+			if strv.Strv(targetNames).Contains(t) {
+				result = append(result, t)
+				if tt.single {
+					break
+				}
+			}
+		}
+		return tt.typ, result
+	}
+	return "", nil
+}
+
+func hasAnyTarget(targetNames, targets []string) bool {
+	for _, t := range targets {
+		if strv.Strv(targetNames).Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func getHistoryPreview(typ string, targets []*HistoryTarget) string {
+	if typ == historyTypeImage || len(targets) == 0 {
+		return ""
+	}
+	// text 和 uri-list 的第一个 target 都是文本
+	runes := []rune(string(targets[0].Data))
+	if len(runes) > historyPreviewMaxLen {
+		runes = runes[:historyPreviewMaxLen]
+	}
+	return string(runes)
+}
+
+func newHistoryEntry(typ string, targets []*HistoryTarget, t int64) *HistoryEntry {
+	entry := &HistoryEntry{
+		Type:    typ,
+		Time:    t,
+		targets: targets,
+	}
+	for _, target := range targets {
+		entry.Size += len(target.Data)
+	}
+	entry.Preview = getHistoryPreview(typ, targets)
+	if len(targets) > 0 {
+		entry.hash = getBytesMd5sum(targets[0].Data)
+	}
+	return entry
+}
+
+type history struct {
+	mu       sync.Mutex
+	cfg      *HistoryConfig
+	entries  []*HistoryEntry // 最新的在前面
+	nextId   uint64
+	isSaving bool
+}
+
+func newHistory(cfg *HistoryConfig) *history {
+	return &history{
+		cfg:    cfg,
+		nextId: 1,
+	}
+}
+
+// add 添加记录，内容相同的旧记录会被移除，返回 false 表示没有添加。
+func (h *history) add(entry *HistoryEntry) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if entry.Size == 0 || (h.cfg.MaxEntrySize > 0 && entry.Size > h.cfg.MaxEntrySize) ||
+		(h.cfg.MaxTotalSize > 0 && entry.Size > h.cfg.MaxTotalSize) {
+		return false
+	}
+	for idx, e := range h.entries {
+		if e.Type == entry.Type && e.hash == entry.hash {
+			h.entries = append(h.entries[:idx], h.entries[idx+1:]...)
+			break
+		}
+	}
+	entry.Id = h.nextId
+	h.nextId++
+	h.entries = append([]*HistoryEntry{entry}, h.entries...)
+	h.trimNoLock()
+	return true
+}
+
+// trimNoLock 删除超过 MaxEntries 和 MaxTotalSize 的最旧的记录
+func (h *history) trimNoLock() {
+	if len(h.entries) > h.cfg.MaxEntries {
+		h.entries = h.entries[:h.cfg.MaxEntries]
+	}
+	if h.cfg.MaxTotalSize <= 0 {
+		return
+	}
+	var total int
+	for idx, e := range h.entries {
+		total += e.Size
+		if total > h.cfg.MaxTotalSize {
+			h.entries = h.entries[:idx]
+			return
+		}
+	}
+}
+
+func (h *history) get(id uint64) *HistoryEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, e := range h.entries {
+		if e.Id == id {
+			return e
+		}
+	}
+	return nil
+}
+
+// moveToFront 把记录移到最前面，用于恢复某条记录之后
+func (h *history) moveToFront(id uint64, t int64) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for idx, e := range h.entries {
+		if e.Id == id {
+			e.Time = t
+			copy(h.entries[1:idx+1], h.entries[:idx])
+			h.entries[0] = e
+			return true
+		}
+	}
+	return false
+}
+
+func (h *history) remove(id uint64) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for idx, e := range h.entries {
+		if e.Id == id {
+			h.entries = append(h.entries[:idx], h.entries[idx+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (h *history) clear() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.entries) == 0 {
+		return false
+	}
+	h.entries = nil
+	return true
+}
+
+func (h *history) list() []HistoryEntry {
+	return h.search("")
+}
+
+// search 返回文本内容中包含关键字的记录，忽略大小写，图片不能被搜索到。
+func (h *history) search(keyword string) []HistoryEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	keyword = strings.ToLower(keyword)
+	result := make([]HistoryEntry, 0, len(h.entries))
+	for _, e := range h.entries {
+		if keyword != "" && (e.Type == historyTypeImage ||
+			!strings.Contains(strings.ToLower(string(e.targets[0].Data)), keyword)) {
+			continue
+		}
+		result = append(result, *e)
+	}
+	return result
+}
+
+func (h *history) save(filename string) error {
+	h.mu.Lock()
+	entries := make([]*historyEntryFile, len(h.entries))
+	for idx, e := range h.entries {
+		entries[idx] = &historyEntryFile{
+			HistoryEntry: *e,
+			Hash:         e.hash,
+			Targets:      e.targets,
+		}
+	}
+	h.mu.Unlock()
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(filename), 0700)
+	if err != nil {
+		return err
+	}
+	// 可能包含隐私内容
+	return ioutil.WriteFile(filename, data, 0600)
+}
+
+// saveLater 在 historySaveDelay 之后保存，这期间的变化一起保存
+func (h *history) saveLater(filename string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.isSaving {
+		return
+	}
+	h.isSaving = true
+
+	time.AfterFunc(historySaveDelay, func() {
+		h.mu.Lock()
+		h.isSaving = false
+		h.mu.Unlock()
+
+		err := h.save(filename)
+		if err != nil {
+			logger.Warning("failed to save history:", err)
+		}
+	})
+}
+
+func (h *history) load(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var entries []*historyEntryFile
+	err = json.Unmarshal(data, &entries)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = nil
+	for _, ef := range entries {
+		if len(ef.Targets) == 0 {
+			continue
+		}
+		e := ef.HistoryEntry
+		e.hash = ef.Hash
+		e.targets = ef.Targets
+		if e.Id >= h.nextId {
+			h.nextId = e.Id + 1
+		}
+		h.entries = append(h.entries, &e)
+	}
+	h.trimNoLock()
+	return nil
+}
@@ -0,0 +1,164 @@
+package clipboard
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTextEntry(text string) *HistoryEntry {
+	return newHistoryEntry(historyTypeText, []*HistoryTarget{
+		{Target: "UTF8_STRING", Type: "UTF8_STRING", Format: 8, Data: []byte(text)},
+	}, 1)
+}
+
+func Test_getHistoryTargets(t *testing.T) {
+	typ, targets := getHistoryTargets([]string{"TARGETS", "text/plain", "UTF8_STRING", "TIMESTAMP"})
+	assert.Equal(t, historyTypeText, typ)
+	assert.Equal(t, []string{"UTF8_STRING", "text/plain"}, targets)
+
+	typ, targets = getHistoryTargets([]string{"text/plain", "text/uri-list", "x-special/gnome-copied-files"})
+	assert.Equal(t, historyTypeURIList, typ)
+	assert.Equal(t, []string{"text/uri-list", "x-special/gnome-copied-files", "text/plain"}, targets)
+
+	typ, targets = getHistoryTargets([]string{"image/bmp", "image/png", "application/x-qt-image"})
+	assert.Equal(t, historyTypeImage, typ)
+	assert.Equal(t, []string{"image/png"}, targets)
+
+	typ, targets = getHistoryTargets([]string{"TARGETS", "application/x-qt-image"})
+	assert.Equal(t, "", typ)
+	assert.Nil(t, targets)
+}
+
+func Test_isSensitive(t *testing.T) {
+	assert.True(t, isSensitiveTargets([]string{"UTF8_STRING", "x-kde-passwordManagerHint"}))
+	assert.False(t, isSensitiveTargets([]string{"UTF8_STRING", "text/plain"}))
+
+	cfg := getDefaultHistoryConfig()
+	assert.True(t, cfg.isBlacklisted([]string{"keepassxc", "KeePassXC"}))
+	assert.False(t, cfg.isBlacklisted([]string{"deepin-editor", "deepin-editor"}))
+	assert.False(t, cfg.isBlacklisted(nil))
+}
+
+func TestHistory(t *testing.T) {
+	cfg := getDefaultHistoryConfig()
+	cfg.MaxEntries = 3
+	cfg.MaxEntrySize = 10
+	h := newHistory(cfg)
+
+	assert.True(t, h.add(newTextEntry("a")))
+	assert.True(t, h.add(newTextEntry("Hello")))
+	assert.True(t, h.add(newTextEntry("b")))
+	assert.False(t, h.add(newTextEntry("")))
+	assert.False(t, h.add(newTextEntry("too long text")))
+
+	// 相同的内容只保留最新的
+	assert.True(t, h.add(newTextEntry("a")))
+	entries := h.list()
+	require.Len(t, entries, 3)
+	assert.Equal(t, "a", entries[0].Preview)
+	assert.Equal(t, uint64(4), entries[0].Id)
+	assert.Equal(t, "b", entries[1].Preview)
+
+	// 超过最大数量时丢弃最旧的
+	assert.True(t, h.add(newTextEntry("c")))
+	entries = h.list()
+	require.Len(t, entries, 3)
+	assert.Equal(t, "b", entries[2].Preview)
+	assert.Nil(t, h.get(2))
+
+	assert.True(t, h.moveToFront(3, 2))
+	entries = h.list()
+	assert.Equal(t, "b", entries[0].Preview)
+	assert.Equal(t, int64(2), entries[0].Time)
+	assert.Equal(t, "c", entries[1].Preview)
+
+	assert.Len(t, h.search("B"), 1)
+	assert.Empty(t, h.search("x"))
+
+	assert.True(t, h.remove(3))
+	assert.False(t, h.remove(3))
+	assert.Len(t, h.list(), 2)
+
+	assert.True(t, h.clear())
+	assert.False(t, h.clear())
+	assert.Empty(t, h.list())
+}
+
+func TestHistoryMaxTotalSize(t *testing.T) {
+	cfg := getDefaultHistoryConfig()
+	cfg.MaxEntrySize = 0
+	cfg.MaxTotalSize = 10
+	h := newHistory(cfg)
+
+	assert.True(t, h.add(newTextEntry("aaaa")))
+	assert.True(t, h.add(newTextEntry("bbbb")))
+	assert.False(t, h.add(newTextEntry("too long text")))
+	// 总大小超过限制时删除最旧的记录
+	assert.True(t, h.add(newTextEntry("cccc")))
+	entries := h.list()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "cccc", entries[0].Preview)
+	assert.Equal(t, "bbbb", entries[1].Preview)
+}
+
+func TestHistorySaveLater(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clipboard-history")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "history.json")
+
+	defer func(delay time.Duration) {
+		historySaveDelay = delay
+	}(historySaveDelay)
+	historySaveDelay = 50 * time.Millisecond
+
+	h := newHistory(getDefaultHistoryConfig())
+	for _, text := range []string{"a", "b", "c"} {
+		h.add(newTextEntry(text))
+		h.saveLater(filename)
+	}
+	_, err = os.Stat(filename)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Eventually(t, func() bool {
+		h1 := newHistory(getDefaultHistoryConfig())
+		return h1.load(filename) == nil && len(h1.list()) == 3
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestHistorySaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clipboard-history")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "history.json")
+
+	cfg := getDefaultHistoryConfig()
+	h := newHistory(cfg)
+	h.add(newTextEntry("a"))
+	h.add(newHistoryEntry(historyTypeImage, []*HistoryTarget{
+		{Target: "image/png", Type: "image/png", Format: 8, Data: []byte{0x89, 'P', 'N', 'G'}},
+	}, 1))
+	err = h.save(filename)
+	require.NoError(t, err)
+	fi, err := os.Stat(filename)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	h1 := newHistory(cfg)
+	err = h1.load(filename)
+	require.NoError(t, err)
+	assert.Equal(t, h.list(), h1.list())
+	assert.Equal(t, []byte{0x89, 'P', 'N', 'G'}, h1.get(2).targets[0].Data)
+
+	// 编号接着之前的，相同的内容被去重
+	assert.True(t, h1.add(newTextEntry("a")))
+	entries := h1.list()
+	assert.Len(t, entries, 2)
+	assert.Equal(t, uint64(3), entries[0].Id)
+}
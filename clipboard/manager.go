@@ -10,6 +10,7 @@ import (
 
 	x "github.com/linuxdeepin/go-x11-client"
 	"github.com/linuxdeepin/go-x11-client/ext/xfixes"
+	"pkg.deepin.io/lib/dbusutil"
 	"pkg.deepin.io/lib/log"
 )
 
@@ -72,6 +73,17 @@ type Manager struct {
 
 	contentMu sync.Mutex
 	content   []*TargetData
+
+	// 同时只转换一个 target，它们都使用 m.window 上的属性
+	convertMu sync.Mutex
+
+	service *dbusutil.Service
+	history *history
+
+	//nolint
+	signals *struct {
+		HistoryChanged struct{}
+	}
 }
 
 func (m *Manager) getTargetData(target x.Atom) *TargetData {
@@ -202,6 +214,7 @@ func (m *Manager) handleEvent(ev x.GenericEvent) {
 					logger.Debug("i have become the owner of CLIPBOARD")
 				} else {
 					logger.Debug("other app have become the owner of CLIPBOARD")
+					go m.recordHistory(event.Owner, event.Timestamp)
 				}
 			}
 
@@ -236,6 +249,9 @@ func (m *Manager) becomeClipboardOwner(ts x.Timestamp) error {
 }
 
 func (m *Manager) getClipboardTargets(ts x.Timestamp) ([]x.Atom, error) {
+	m.convertMu.Lock()
+	defer m.convertMu.Unlock()
+
 	selNotifyEvent, err := m.ec.captureSelectionNotifyEvent(func() error {
 		m.xc.ConvertSelection(m.window, atomClipboard,
 			atomTargets, atomTargets, ts)
@@ -519,6 +535,18 @@ func shouldIgnoreSaveTarget(target x.Atom, targetName string) bool {
 }
 
 func (m *Manager) saveTarget(target x.Atom, ts x.Timestamp) error {
+	targetData, err := m.convertTarget(target, ts)
+	if err != nil {
+		return err
+	}
+	m.addTargetData(targetData)
+	return nil
+}
+
+func (m *Manager) convertTarget(target x.Atom, ts x.Timestamp) (*TargetData, error) {
+	m.convertMu.Lock()
+	defer m.convertMu.Unlock()
+
 	selNotifyEvent, err := m.ec.captureSelectionNotifyEvent(func() error {
 		m.xc.ConvertSelection(m.window, atomClipboard, target, target, ts)
 		return m.xc.Flush()
@@ -528,36 +556,32 @@ func (m *Manager) saveTarget(target x.Atom, ts x.Timestamp) error {
 			event.Target == target
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if selNotifyEvent.Property == x.None {
-		return errors.New("failed to convert target")
+		return nil, errors.New("failed to convert target")
 	}
 
 	propReply, err := m.getProperty(m.window, selNotifyEvent.Property, false)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if propReply.Type == atomIncr {
-		err := m.recvTargetIncr(target, selNotifyEvent.Property)
-		if err != nil {
-			return err
-		}
-	} else {
-		err = m.xc.DeletePropertyE(m.window, selNotifyEvent.Property)
-		if err != nil {
-			return err
-		}
-		logger.Debug("data len:", len(propReply.Value))
-		m.addTargetData(&TargetData{
-			Target: target,
-			Type:   propReply.Type,
-			Format: propReply.Format,
-			Data:   propReply.Value,
-		})
+		return m.recvTargetIncr(target, selNotifyEvent.Property)
 	}
-	return nil
+
+	err = m.xc.DeletePropertyE(m.window, selNotifyEvent.Property)
+	if err != nil {
+		return nil, err
+	}
+	logger.Debug("data len:", len(propReply.Value))
+	return &TargetData{
+		Target: target,
+		Type:   propReply.Type,
+		Format: propReply.Format,
+		Data:   propReply.Value,
+	}, nil
 }
 
 func (m *Manager) getProperty(win x.Window, propertyAtom x.Atom, delete bool) (*x.GetPropertyReply, error) {
@@ -578,7 +602,7 @@ func (m *Manager) getProperty(win x.Window, propertyAtom x.Atom, delete bool) (*
 	return propReply, nil
 }
 
-func (m *Manager) recvTargetIncr(target, prop x.Atom) error {
+func (m *Manager) recvTargetIncr(target, prop x.Atom) (*TargetData, error) {
 	logger.Debug("start recvTargetIncr", target)
 	var data [][]byte
 	t0 := time.Now()
@@ -597,7 +621,7 @@ func (m *Manager) recvTargetIncr(target, prop x.Atom) error {
 		})
 		if err != nil {
 			logger.Warning(err)
-			return err
+			return nil, err
 		}
 
 		propReply, err := m.xc.GetProperty(false, propNotifyEvent.Window, propNotifyEvent.Atom,
@@ -605,7 +629,7 @@ func (m *Manager) recvTargetIncr(target, prop x.Atom) error {
 			0, 0)
 		if err != nil {
 			logger.Warning(err)
-			return err
+			return nil, err
 		}
 		propReply, err = m.xc.GetProperty(false, propNotifyEvent.Window, propNotifyEvent.Atom,
 			x.GetPropertyTypeAny, 0,
@@ -613,7 +637,7 @@ func (m *Manager) recvTargetIncr(target, prop x.Atom) error {
 		)
 		if err != nil {
 			logger.Warning(err)
-			return err
+			return nil, err
 		}
 
 		if propReply.ValueLen == 0 {
@@ -623,16 +647,15 @@ func (m *Manager) recvTargetIncr(target, prop x.Atom) error {
 			err = m.xc.DeletePropertyE(propNotifyEvent.Window, propNotifyEvent.Atom)
 			if err != nil {
 				logger.Warning(err)
-				return err
+				return nil, err
 			}
 
-			m.addTargetData(&TargetData{
+			return &TargetData{
 				Target: target,
 				Type:   propReply.Type,
 				Format: propReply.Format,
 				Data:   bytes.Join(data, nil),
-			})
-			return nil
+			}, nil
 		}
 		if logger.GetLogLevel() == log.LevelDebug {
 			logger.Debugf("recv data size: %d, md5sum: %s", len(propReply.Value), getBytesMd5sum(propReply.Value))
@@ -0,0 +1,231 @@
+package clipboard
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus"
+	x "github.com/linuxdeepin/go-x11-client"
+	"pkg.deepin.io/lib/dbusutil"
+)
+
+var errHistoryEntryNotFound = errors.New("history entry not found")
+
+func (m *Manager) initHistory() {
+	cfg, err := loadHistoryConfig(historyConfigFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warning("failed to load history config:", err)
+		}
+		cfg = getDefaultHistoryConfig()
+	}
+	m.history = newHistory(cfg)
+
+	if cfg.Persistent {
+		err = m.history.load(historyDataFile)
+		if err != nil && !os.IsNotExist(err) {
+			logger.Warning("failed to load history:", err)
+		}
+	} else {
+		// 关闭持久化之后，删除之前保存的记录
+		err = os.Remove(historyDataFile)
+		if err != nil && !os.IsNotExist(err) {
+			logger.Warning(err)
+		}
+	}
+}
+
+func (m *Manager) getWindowWMClass(win x.Window) []string {
+	reply, err := m.xc.GetProperty(false, win, x.AtomWMClass, x.AtomString, 0, 256)
+	if err != nil || reply.Format != 8 || len(reply.Value) == 0 {
+		return nil
+	}
+	// WM_CLASS 是 instance 和 class 两个以 \0 结尾的字符串
+	return strings.Split(strings.TrimRight(string(reply.Value), "\x00"), "\x00")
+}
+
+func (m *Manager) getOwnerWMClass(owner x.Window) []string {
+	wmClass := m.getWindowWMClass(owner)
+	if len(wmClass) > 0 {
+		return wmClass
+	}
+
+	// 剪贴板的所有者一般是不可见的窗口，没有 WM_CLASS，从 client leader 窗口获取
+	atomClientLeader, err := m.xc.GetAtom("WM_CLIENT_LEADER")
+	if err != nil {
+		return nil
+	}
+	reply, err := m.xc.GetProperty(false, owner, atomClientLeader, x.AtomWindow, 0, 1)
+	if err != nil || reply.Format != 32 || len(reply.Value) < 4 {
+		return nil
+	}
+	leader := x.Window(x.Get32(reply.Value))
+	if leader == 0 || leader == owner {
+		return nil
+	}
+	return m.getWindowWMClass(leader)
+}
+
+// recordHistory 在其他程序成为剪贴板所有者之后，获取剪贴板的内容加入到历史记录中。
+func (m *Manager) recordHistory(owner x.Window, ts x.Timestamp) {
+	if m.history == nil {
+		return
+	}
+
+	wmClass := m.getOwnerWMClass(owner)
+	if m.history.cfg.isBlacklisted(wmClass) {
+		logger.Debugf("ignore clipboard of %v", wmClass)
+		return
+	}
+
+	targets, err := m.getClipboardTargets(ts)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+
+	targetNames := make([]string, 0, len(targets))
+	nameAtomMap := make(map[string]x.Atom, len(targets))
+	for _, target := range targets {
+		targetName, err := m.xc.GetAtomName(target)
+		if err != nil {
+			logger.Warning(err)
+			continue
+		}
+		targetNames = append(targetNames, targetName)
+		nameAtomMap[targetName] = target
+	}
+	if isSensitiveTargets(targetNames) {
+		logger.Debug("ignore sensitive clipboard content")
+		return
+	}
+
+	typ, historyTargets := getHistoryTargets(targetNames)
+	if typ == "" {
+		return
+	}
+
+	var data []*HistoryTarget
+	for _, targetName := range historyTargets {
+		targetData, err := m.convertTarget(nameAtomMap[targetName], ts)
+		if err != nil {
+			logger.Warning(err)
+			continue
+		}
+		typeName, err := m.xc.GetAtomName(targetData.Type)
+		if err != nil {
+			logger.Warning(err)
+			continue
+		}
+		data = append(data, &HistoryTarget{
+			Target: targetName,
+			Type:   typeName,
+			Format: targetData.Format,
+			Data:   targetData.Data,
+		})
+	}
+	if len(data) == 0 {
+		return
+	}
+
+	if m.history.add(newHistoryEntry(typ, data, time.Now().Unix())) {
+		m.onHistoryChanged()
+	}
+}
+
+func (m *Manager) onHistoryChanged() {
+	if m.service != nil {
+		err := m.service.Emit(m, "HistoryChanged")
+		if err != nil {
+			logger.Warning(err)
+		}
+	}
+
+	if m.history.cfg.Persistent {
+		m.history.saveLater(historyDataFile)
+	}
+}
+
+// restoreHistory 把历史记录中的内容放回剪贴板
+func (m *Manager) restoreHistory(id uint64) error {
+	entry := m.history.get(id)
+	if entry == nil {
+		return errHistoryEntryNotFound
+	}
+
+	content := make([]*TargetData, 0, len(entry.targets))
+	for _, ht := range entry.targets {
+		target, err := m.xc.GetAtom(ht.Target)
+		if err != nil {
+			return err
+		}
+		typ, err := m.xc.GetAtom(ht.Type)
+		if err != nil {
+			return err
+		}
+		content = append(content, &TargetData{
+			Target: target,
+			Type:   typ,
+			Format: ht.Format,
+			Data:   ht.Data,
+		})
+	}
+
+	ts, err := m.getTimestamp()
+	if err != nil {
+		return err
+	}
+	m.contentMu.Lock()
+	m.content = content
+	m.contentMu.Unlock()
+	err = m.becomeClipboardOwner(ts)
+	if err != nil {
+		return err
+	}
+
+	if m.history.moveToFront(id, time.Now().Unix()) {
+		m.onHistoryChanged()
+	}
+	return nil
+}
+
+func historyEntriesToJSON(entries []HistoryEntry) (string, error) {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (m *Manager) GetHistory() (historyJSON string, busErr *dbus.Error) {
+	historyJSON, err := historyEntriesToJSON(m.history.list())
+	return historyJSON, dbusutil.ToError(err)
+}
+
+func (m *Manager) Search(keyword string) (historyJSON string, busErr *dbus.Error) {
+	historyJSON, err := historyEntriesToJSON(m.history.search(keyword))
+	return historyJSON, dbusutil.ToError(err)
+}
+
+func (m *Manager) Restore(id uint64) *dbus.Error {
+	err := m.restoreHistory(id)
+	return dbusutil.ToError(err)
+}
+
+func (m *Manager) Remove(id uint64) *dbus.Error {
+	if !m.history.remove(id) {
+		return dbusutil.ToError(errHistoryEntryNotFound)
+	}
+	m.onHistoryChanged()
+	return nil
+}
+
+func (m *Manager) Clear() *dbus.Error {
+	if m.history.clear() {
+		m.onHistoryChanged()
+	}
+	return nil
+}
@@ -45,17 +45,20 @@ func (mo *Module) Start() error {
 		logger.Warning(err)
 	}
 
-	m := &Manager{}
+	service := loader.GetService()
+	m := &Manager{
+		service: service,
+	}
 	m.xc = &xClient{
 		conn: xConn,
 	}
+	m.initHistory()
 
 	err = m.start()
 	if err != nil {
 		return err
 	}
 
-	service := loader.GetService()
 	err = service.Export("/com/deepin/daemon/ClipboardManager", m)
 	if err != nil {
 		return err
@@ -11,7 +11,7 @@ import (
 	"pkg.deepin.io/lib/strv"
 )
 
-//go:generate dbusutil-gen em -type Config
+//go:generate dbusutil-gen em -type Config,LocalProvider
 
 type Interface interface {
 	Get() (interface{}, error)
@@ -56,6 +56,7 @@ func NewConfig(name string, core Interface, sessionSigLoop *dbusutil.SignalLoop,
 	if err != nil {
 		logger.Warning(err)
 	}
+	registerConfig(c)
 	return c
 }
 
@@ -80,6 +81,7 @@ func (c *Config) Register() error {
 }
 
 func (c *Config) Destroy() {
+	unregisterConfig(c)
 	c.dbusDaemon.RemoveHandler(proxy.RemoveAllHandlers)
 }
 
@@ -0,0 +1,352 @@
+package dsync
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dbus "github.com/godbus/dbus"
+	"pkg.deepin.io/lib/dbusutil"
+)
+
+// LocalProvider works like the sync daemon, but it exports the configs of all
+// registered modules into a local json bundle and imports them back, no cloud
+// service is needed.
+type LocalProvider struct {
+	service *dbusutil.Service
+}
+
+const (
+	LocalServiceName = "com.deepin.daemon.LocalSync"
+	LocalPath        = "/com/deepin/daemon/LocalSync"
+	localInterface   = LocalServiceName
+
+	// BundleSchemaVersion is the version of the bundle format, increase it if
+	// the bundle format is changed incompatibly.
+	BundleSchemaVersion = 1
+)
+
+var registry struct {
+	mu      sync.Mutex
+	configs map[string]*Config
+}
+
+func registerConfig(c *Config) {
+	registry.mu.Lock()
+	if registry.configs == nil {
+		registry.configs = make(map[string]*Config)
+	}
+	registry.configs[c.name] = c
+	registry.mu.Unlock()
+}
+
+func unregisterConfig(c *Config) {
+	registry.mu.Lock()
+	if registry.configs[c.name] == c {
+		delete(registry.configs, c.name)
+	}
+	registry.mu.Unlock()
+}
+
+func getConfig(name string) *Config {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	return registry.configs[name]
+}
+
+func getConfigNames() []string {
+	registry.mu.Lock()
+	names := make([]string, 0, len(registry.configs))
+	for name := range registry.configs {
+		names = append(names, name)
+	}
+	registry.mu.Unlock()
+	sort.Strings(names)
+	return names
+}
+
+type Bundle struct {
+	SchemaVersion int
+	Time          time.Time
+	Hostname      string
+	Modules       map[string]json.RawMessage
+}
+
+const (
+	DiffStatusUnchanged = "unchanged"
+	DiffStatusChanged   = "changed"
+	DiffStatusSkipped   = "skipped"
+	DiffStatusFailed    = "failed"
+)
+
+type ModuleDiff struct {
+	Module  string
+	Status  string
+	Reason  string `json:",omitempty"`
+	Changes []KeyChange
+}
+
+type KeyChange struct {
+	Key string
+	Old json.RawMessage `json:",omitempty"`
+	New json.RawMessage `json:",omitempty"`
+}
+
+func ExportLocalProvider(service *dbusutil.Service) (*LocalProvider, error) {
+	p := &LocalProvider{
+		service: service,
+	}
+	err := service.Export(LocalPath, p)
+	if err != nil {
+		return nil, err
+	}
+	err = service.RequestName(LocalServiceName)
+	if err != nil {
+		_ = service.StopExport(p)
+		return nil, err
+	}
+	return p, nil
+}
+
+func (*LocalProvider) GetInterfaceName() string {
+	return localInterface
+}
+
+func getModuleData(name string) (json.RawMessage, error) {
+	c := getConfig(name)
+	if c == nil {
+		return nil, fmt.Errorf("module %q is not registered", name)
+	}
+	v, err := c.core.Get()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+func filterModules(modules []string) ([]string, error) {
+	if len(modules) == 0 {
+		return getConfigNames(), nil
+	}
+	for _, name := range modules {
+		if getConfig(name) == nil {
+			return nil, fmt.Errorf("module %q is not registered", name)
+		}
+	}
+	return modules, nil
+}
+
+// ExportBundle gets the configs of modules, all registered modules if
+// modules is empty.
+func ExportBundle(modules []string) (*Bundle, error) {
+	modules, err := filterModules(modules)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname()
+	bundle := &Bundle{
+		SchemaVersion: BundleSchemaVersion,
+		Time:          time.Now(),
+		Hostname:      hostname,
+		Modules:       make(map[string]json.RawMessage),
+	}
+	for _, name := range modules {
+		data, err := getModuleData(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get config of module %q: %v", name, err)
+		}
+		bundle.Modules[name] = data
+	}
+	return bundle, nil
+}
+
+// ImportBundle sets the configs of modules in bundle, all modules in bundle
+// if modules is empty. Nothing is set if dryRun is true, the result shows the
+// differences only.
+func ImportBundle(bundle *Bundle, modules []string, dryRun bool) ([]*ModuleDiff, error) {
+	if bundle.SchemaVersion <= 0 || bundle.SchemaVersion > BundleSchemaVersion {
+		return nil, fmt.Errorf("unsupported bundle schema version %d", bundle.SchemaVersion)
+	}
+
+	if len(modules) == 0 {
+		for name := range bundle.Modules {
+			modules = append(modules, name)
+		}
+		sort.Strings(modules)
+	}
+
+	var result []*ModuleDiff
+	for _, name := range modules {
+		diff := &ModuleDiff{Module: name}
+		result = append(result, diff)
+
+		newData, ok := bundle.Modules[name]
+		if !ok {
+			diff.Status = DiffStatusSkipped
+			diff.Reason = "not in bundle"
+			continue
+		}
+		c := getConfig(name)
+		if c == nil {
+			diff.Status = DiffStatusSkipped
+			diff.Reason = "module is not registered"
+			continue
+		}
+		oldData, err := getModuleData(name)
+		if err != nil {
+			diff.Status = DiffStatusFailed
+			diff.Reason = err.Error()
+			continue
+		}
+
+		err = checkDataVersion(oldData, newData)
+		if err != nil {
+			diff.Status = DiffStatusSkipped
+			diff.Reason = err.Error()
+			continue
+		}
+
+		diff.Changes, err = diffModuleData(oldData, newData)
+		if err != nil {
+			diff.Status = DiffStatusFailed
+			diff.Reason = err.Error()
+			continue
+		}
+		if len(diff.Changes) == 0 {
+			diff.Status = DiffStatusUnchanged
+			continue
+		}
+		diff.Status = DiffStatusChanged
+
+		if dryRun {
+			continue
+		}
+		err = c.core.Set(newData)
+		if err != nil {
+			diff.Status = DiffStatusFailed
+			diff.Reason = err.Error()
+		}
+	}
+	return result, nil
+}
+
+func getDataVersion(data json.RawMessage) string {
+	var v struct {
+		Version string `json:"version"`
+	}
+	_ = json.Unmarshal(data, &v)
+	return v.Version
+}
+
+func getMajorVersion(version string) int {
+	major, _ := strconv.Atoi(strings.SplitN(version, ".", 2)[0])
+	return major
+}
+
+// checkDataVersion refuses the data whose major version is newer than the
+// current one, the module does not know how to set it.
+func checkDataVersion(current, data json.RawMessage) error {
+	curVersion := getDataVersion(current)
+	version := getDataVersion(data)
+	if getMajorVersion(version) > getMajorVersion(curVersion) {
+		return fmt.Errorf("data version %q is newer than %q", version, curVersion)
+	}
+	return nil
+}
+
+// diffModuleData compares the top level keys of two json objects.
+func diffModuleData(oldData, newData json.RawMessage) ([]KeyChange, error) {
+	var oldMap, newMap map[string]json.RawMessage
+	err := json.Unmarshal(oldData, &oldMap)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(newData, &newMap)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]struct{})
+	for key := range oldMap {
+		keys[key] = struct{}{}
+	}
+	for key := range newMap {
+		keys[key] = struct{}{}
+	}
+
+	var changes []KeyChange
+	for key := range keys {
+		oldValue, newValue := oldMap[key], newMap[key]
+		if isJSONEqual(oldValue, newValue) {
+			continue
+		}
+		changes = append(changes, KeyChange{
+			Key: key,
+			Old: oldValue,
+			New: newValue,
+		})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes, nil
+}
+
+func isJSONEqual(a, b json.RawMessage) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == len(b)
+	}
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return bytes.Equal(a, b)
+	}
+	// compare the normalized form, the order of keys is ignored
+	na, _ := json.Marshal(va)
+	nb, _ := json.Marshal(vb)
+	return bytes.Equal(na, nb)
+}
+
+func (*LocalProvider) ListModules() (modules []string, busErr *dbus.Error) {
+	return getConfigNames(), nil
+}
+
+func (*LocalProvider) Export(modules []string) (bundle string, busErr *dbus.Error) {
+	b, err := ExportBundle(modules)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+func (*LocalProvider) Import(bundle string, modules []string, dryRun bool) (result string, busErr *dbus.Error) {
+	var b Bundle
+	err := json.Unmarshal([]byte(bundle), &b)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	if b.Modules == nil {
+		return "", dbusutil.ToError(errors.New("invalid bundle, no modules"))
+	}
+
+	diffs, err := ImportBundle(&b, modules, dryRun)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	data, err := json.Marshal(diffs)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
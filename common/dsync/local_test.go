@@ -0,0 +1,108 @@
+package dsync
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testCore struct {
+	data    map[string]interface{}
+	setData []byte
+}
+
+func (c *testCore) Get() (interface{}, error) {
+	return c.data, nil
+}
+
+func (c *testCore) Set(data []byte) error {
+	if c.data["readonly"] == true {
+		return errors.New("readonly")
+	}
+	c.setData = data
+	return nil
+}
+
+func Test_diffModuleData(t *testing.T) {
+	changes, err := diffModuleData(json.RawMessage(`{"version":"1.0","a":1,"b":{"x":1,"y":2},"c":"c"}`),
+		json.RawMessage(`{"version":"1.0","a":2,"b":{"y":2,"x":1},"d":true}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []KeyChange{
+		{Key: "a", Old: json.RawMessage(`1`), New: json.RawMessage(`2`)},
+		{Key: "c", Old: json.RawMessage(`"c"`)},
+		{Key: "d", New: json.RawMessage(`true`)},
+	}, changes)
+
+	_, err = diffModuleData(json.RawMessage(`{}`), json.RawMessage(`[]`))
+	assert.Error(t, err)
+}
+
+func Test_checkDataVersion(t *testing.T) {
+	assert.NoError(t, checkDataVersion(json.RawMessage(`{"version":"2.0"}`),
+		json.RawMessage(`{"version":"1.0"}`)))
+	assert.NoError(t, checkDataVersion(json.RawMessage(`{"version":"1.0"}`),
+		json.RawMessage(`{"version":"1.1"}`)))
+	assert.Error(t, checkDataVersion(json.RawMessage(`{"version":"1.0"}`),
+		json.RawMessage(`{"version":"2.0"}`)))
+	assert.NoError(t, checkDataVersion(json.RawMessage(`{}`), json.RawMessage(`{}`)))
+}
+
+func Test_ImportBundle(t *testing.T) {
+	dock := &testCore{data: map[string]interface{}{"version": "1.0", "mode": 0}}
+	power := &testCore{data: map[string]interface{}{"version": "1.0"}}
+	audio := &testCore{data: map[string]interface{}{"version": "1.0", "readonly": true}}
+	configs := []*Config{
+		{name: "dock", core: dock},
+		{name: "power", core: power},
+		{name: "audio", core: audio},
+	}
+	for _, c := range configs {
+		registerConfig(c)
+		defer unregisterConfig(c)
+	}
+	assert.Equal(t, []string{"audio", "dock", "power"}, getConfigNames())
+
+	bundle, err := ExportBundle([]string{"dock"})
+	assert.NoError(t, err)
+	assert.Equal(t, BundleSchemaVersion, bundle.SchemaVersion)
+	assert.Len(t, bundle.Modules, 1)
+	_, err = ExportBundle([]string{"unknown"})
+	assert.Error(t, err)
+
+	bundle = &Bundle{
+		SchemaVersion: BundleSchemaVersion,
+		Modules: map[string]json.RawMessage{
+			"dock":     json.RawMessage(`{"version":"1.0","mode":1}`),
+			"power":    json.RawMessage(`{"version":"2.0"}`),
+			"audio":    json.RawMessage(`{"version":"1.0","readonly":false}`),
+			"launcher": json.RawMessage(`{"version":"1.0"}`),
+		},
+	}
+
+	diffs, err := ImportBundle(bundle, nil, true)
+	assert.NoError(t, err)
+	statuses := make(map[string]string)
+	for _, diff := range diffs {
+		statuses[diff.Module] = diff.Status
+	}
+	assert.Equal(t, map[string]string{
+		"audio":    DiffStatusChanged,
+		"dock":     DiffStatusChanged,
+		"launcher": DiffStatusSkipped,
+		"power":    DiffStatusSkipped,
+	}, statuses)
+	assert.Nil(t, dock.setData)
+
+	diffs, err = ImportBundle(bundle, []string{"dock", "audio"}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, DiffStatusChanged, diffs[0].Status)
+	assert.Equal(t, DiffStatusFailed, diffs[1].Status)
+	assert.JSONEq(t, `{"version":"1.0","mode":1}`, string(dock.setData))
+	assert.Nil(t, power.setData)
+
+	bundle.SchemaVersion = BundleSchemaVersion + 1
+	_, err = ImportBundle(bundle, nil, true)
+	assert.Error(t, err)
+}
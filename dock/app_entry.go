@@ -50,6 +50,10 @@ type AppEntry struct {
 	IsDocked      bool
 	// dbusutil-gen: equal=method:Equal
 	WindowInfos windowInfosType
+	// dbusutil-gen: equal=method:Equal
+	WindowInfosV2 windowInfosV2Type
+	// 根据 Manager.EntryFilterMode 决定是否显示
+	Visible bool
 
 	service          *dbusutil.Service
 	manager          *Manager
@@ -67,6 +71,7 @@ func newAppEntry(dockManager *Manager, innerId string, appInfo *AppInfo) *AppEnt
 		Id:      dockManager.allocEntryId(),
 		innerId: innerId,
 		windows: make(map[x.Window]*WindowInfo),
+		Visible: true,
 	}
 	entry.Menu.manager = dockManager
 	entry.setAppInfo(appInfo)
@@ -213,6 +218,7 @@ func (entry *AppEntry) attachWindow(winInfo *WindowInfo) bool {
 		return false
 	}
 
+	winInfo.updateMonitor(entry.manager.getMonitors())
 	entry.windows[win] = winInfo
 	entry.updateWindowInfos()
 	entry.updateIsActive()
@@ -331,13 +337,23 @@ func (entry *AppEntry) getIcon() string {
 
 func (e *AppEntry) updateWindowInfos() {
 	windowInfos := newWindowInfos()
+	windowInfosV2 := newWindowInfosV2()
 	for win, winInfo := range e.windows {
+		flash := winInfo.hasWmStateDemandsAttention()
 		windowInfos[win] = ExportWindowInfo{
 			Title: winInfo.Title,
-			Flash: winInfo.hasWmStateDemandsAttention(),
+			Flash: flash,
+		}
+		windowInfosV2[win] = ExportWindowInfoV2{
+			Title:     winInfo.Title,
+			Flash:     flash,
+			Workspace: winInfo.workspace,
+			Monitor:   winInfo.monitor,
 		}
 	}
 	e.setPropWindowInfos(windowInfos)
+	e.setPropWindowInfosV2(windowInfosV2)
+	e.updateVisible()
 }
 
 func (e *AppEntry) updateIsActive() {
@@ -43,8 +43,11 @@ func (entry *AppEntry) Activate(timestamp uint32) *dbus.Error {
 		m.updateHideState(true)
 	}
 
+	filter := m.getEntryFilter()
 	entry.PropsMu.RLock()
 	hasWindow := entry.hasWindow()
+	// 优先激活当前工作区上的窗口，避免切换到其他工作区
+	winInfo := entry.getPreferredWindow(&filter)
 	entry.PropsMu.RUnlock()
 
 	if !hasWindow {
@@ -52,12 +55,12 @@ func (entry *AppEntry) Activate(timestamp uint32) *dbus.Error {
 		return nil
 	}
 
-	if entry.current == nil {
+	if winInfo == nil {
 		err := errors.New("entry.current is nil")
 		logger.Warning(err)
 		return dbusutil.ToError(err)
 	}
-	win := entry.current.window
+	win := winInfo.window
 	state, err := ewmh.GetWMState(globalXConn, win).Reply(globalXConn)
 	if err != nil {
 		logger.Warningf("failed to get ewmh WMState for win %d: %v", win, err)
@@ -130,8 +133,9 @@ func (entry *AppEntry) RequestUndock() *dbus.Error {
 }
 
 func (entry *AppEntry) PresentWindows() *dbus.Error {
+	filter := entry.manager.getEntryFilter()
 	entry.PropsMu.RLock()
-	windowIds := entry.getWindowIds()
+	windowIds := entry.getFilteredWindowIds(&filter)
 	entry.PropsMu.RUnlock()
 	if len(windowIds) > 0 {
 		err := entry.manager.wm.PresentWindows(dbus.FlagNoAutoStart, windowIds)
@@ -109,3 +109,29 @@ func (v *AppEntry) setPropWindowInfos(value windowInfosType) (changed bool) {
 func (v *AppEntry) emitPropChangedWindowInfos(value windowInfosType) error {
 	return v.service.EmitPropertyChanged(v, "WindowInfos", value)
 }
+
+func (v *AppEntry) setPropWindowInfosV2(value windowInfosV2Type) (changed bool) {
+	if !v.WindowInfosV2.Equal(value) {
+		v.WindowInfosV2 = value
+		v.emitPropChangedWindowInfosV2(value)
+		return true
+	}
+	return false
+}
+
+func (v *AppEntry) emitPropChangedWindowInfosV2(value windowInfosV2Type) error {
+	return v.service.EmitPropertyChanged(v, "WindowInfosV2", value)
+}
+
+func (v *AppEntry) setPropVisible(value bool) (changed bool) {
+	if v.Visible != value {
+		v.Visible = value
+		v.emitPropChangedVisible(value)
+		return true
+	}
+	return false
+}
+
+func (v *AppEntry) emitPropChangedVisible(value bool) error {
+	return v.service.EmitPropertyChanged(v, "Visible", value)
+}
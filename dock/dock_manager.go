@@ -26,6 +26,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/godbus/dbus"
 	libApps "github.com/linuxdeepin/go-dbus-factory/com.deepin.daemon.apps"
 	launcher "github.com/linuxdeepin/go-dbus-factory/com.deepin.dde.daemon.launcher"
@@ -56,6 +57,7 @@ type Manager struct {
 	Opacity             gsprop.Double
 	HideState           HideStateType
 	FrontendWindowRect  *Rect
+	EntryFilterMode     int32
 
 	service            *dbusutil.Service
 	sessionSigLoop     *dbusutil.SignalLoop
@@ -82,13 +84,24 @@ type Manager struct {
 
 	entryCount         uint
 	identifyWindowFuns []*IdentifyWindowFunc
-	windowPatterns     WindowPatterns
+	windowPatterns     WindowPatterns // 系统的规则
+	// 用户的规则
+	userWindowPatterns    WindowPatterns
+	windowPatternsMu      sync.RWMutex
+	windowPatternsWatcher *fsnotify.Watcher
+
+	workspaceMu      sync.Mutex
+	currentWorkspace uint32
+	monitors         []*monitorInfo
+	entryFilterMode  EntryFilterModeType
+	rrFirstEvent     uint8
 
 	// dbus objects:
 	launcher     launcher.Launcher
 	ddeLauncher  libDDELauncher.Launcher
 	wm           wm.Wm
 	appsObj      libApps.Apps
+	swapSched    *swapSchedHelper
 	startManager sessionmanager.StartManager
 	wmSwitcher   wmswitcher.WMSwitcher
 	wmName       string
@@ -162,6 +175,11 @@ func (m *Manager) destroy() {
 	m.sessionSigLoop.Stop()
 	m.syncConfig.Destroy()
 
+	if m.windowPatternsWatcher != nil {
+		_ = m.windowPatternsWatcher.Close()
+		m.windowPatternsWatcher = nil
+	}
+
 	err := m.service.StopExport(m)
 	if err != nil {
 		logger.Warning(err)
@@ -290,6 +308,9 @@ func (m *Manager) SetFrontendWindowRect(x, y int32, width, height uint32) *dbus.
 		logger.Warning("EmitPropertyChanged error:", err)
 	}
 	m.updateHideState(false)
+	if filter := m.getEntryFilter(); filter.mode == EntryFilterModeCurrentMonitor {
+		m.updateEntriesVisible()
+	}
 	return nil
 }
 
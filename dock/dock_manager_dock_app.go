@@ -178,6 +178,7 @@ func (m *Manager) dockEntry(entry *AppEntry) (bool, error) {
 	}
 
 	entry.setPropIsDocked(true)
+	entry.updateVisible()
 	entry.updateMenu()
 	entry.PropsMu.Unlock()
 	return true, nil
@@ -233,6 +234,7 @@ func (m *Manager) undockEntry(entry *AppEntry) {
 		}
 		entry.updateIcon()
 		entry.setPropIsDocked(false)
+		entry.updateVisible()
 		entry.updateName()
 		entry.updateMenu()
 
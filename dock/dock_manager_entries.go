@@ -154,6 +154,10 @@ func (m *Manager) removeAppEntry(e *AppEntry) {
 }
 
 func (m *Manager) attachWindow(winInfo *WindowInfo) {
+	if winInfo.appInfo != nil && winInfo.pid != 0 {
+		go m.swapSched.moveProcess(winInfo.appInfo.GetId(), winInfo.pid)
+	}
+
 	entry := m.Entries.GetByInnerId(winInfo.entryInnerId)
 
 	if entry != nil {
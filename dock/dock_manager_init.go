@@ -225,10 +225,8 @@ func (m *Manager) init() error {
 	m.listenSettingsChanged()
 
 	m.windowInfoMap = make(map[x.Window]*WindowInfo)
-	m.windowPatterns, err = loadWindowPatterns(windowPatternsFile)
-	if err != nil {
-		logger.Warning("loadWindowPatterns failed:", err)
-	}
+	m.reloadWindowPatterns()
+	m.watchUserWindowPatterns()
 
 	sessionBus := m.service.Conn()
 	m.wm = wm.NewWm(sessionBus)
@@ -238,6 +236,7 @@ func (m *Manager) init() error {
 		return err
 	}
 	m.appsObj = libApps.NewApps(systemBus)
+	m.swapSched = newSwapSchedHelper(systemBus)
 	m.launcher = launcher.NewLauncher(sessionBus)
 	m.ddeLauncher = libDDELauncher.NewLauncher(sessionBus)
 	m.startManager = sessionmanager.NewStartManager(sessionBus)
@@ -248,6 +247,7 @@ func (m *Manager) init() error {
 	m.listenWMSwitcherSignal()
 
 	m.registerIdentifyWindowFuncs()
+	m.initWorkspace()
 	m.initEntries()
 	m.pluginSettings = newPluginSettingsStorage(m)
 
@@ -25,6 +25,7 @@ import (
 	"time"
 
 	x "github.com/linuxdeepin/go-x11-client"
+	"github.com/linuxdeepin/go-x11-client/ext/randr"
 	"github.com/linuxdeepin/go-x11-client/util/wm/ewmh"
 )
 
@@ -152,12 +153,14 @@ func (m *Manager) handleActiveWindowChanged() {
 
 	logger.Debug("Active window changed", activeWindow)
 
+	var activePid uint
 	m.Entries.mu.RLock()
 	for _, entry := range m.Entries.items {
 		entry.PropsMu.Lock()
 
 		winInfo, ok := entry.windows[activeWindow]
 		if ok {
+			activePid = winInfo.pid
 			entry.setPropIsActive(true)
 			entry.setCurrentWindowInfo(winInfo)
 			entry.updateName()
@@ -170,6 +173,10 @@ func (m *Manager) handleActiveWindowChanged() {
 	}
 	m.Entries.mu.RUnlock()
 
+	if activePid != 0 {
+		go m.swapSched.setForegroundApp(activePid)
+	}
+
 	m.updateHideState(true)
 }
 
@@ -224,6 +231,7 @@ func (m *Manager) handleConfigureNotifyEvent(ev *x.ConfigureNotifyEvent) {
 	if winInfo == nil {
 		return
 	}
+	m.handleWindowMoved(winInfo)
 
 	if HideModeType(m.HideMode.Get()) != HideModeSmartHide {
 		return
@@ -285,6 +293,8 @@ func (m *Manager) handleRootWindowPropertyNotifyEvent(ev *x.PropertyNotifyEvent)
 		m.handleActiveWindowChanged()
 	case atomNetShowingDesktop:
 		m.updateHideState(false)
+	case atomNetCurrentDesktop:
+		m.handleCurrentWorkspaceChanged()
 	}
 }
 
@@ -349,6 +359,9 @@ func (m *Manager) handlePropertyNotifyEvent(ev *x.PropertyNotifyEvent) {
 	case x.AtomWMTransientFor:
 		winInfo.updateHasWmTransientFor()
 		needAttachOrDetach = true
+
+	case atomNetWmDesktop:
+		winInfo.updateWorkspace()
 	}
 
 	if winInfo.updateCalled && newInnerId != "" && winInfo.innerId != newInnerId {
@@ -373,7 +386,7 @@ func (m *Manager) handlePropertyNotifyEvent(ev *x.PropertyNotifyEvent) {
 	defer entry.PropsMu.Unlock()
 
 	switch ev.Atom {
-	case atomNetWMState:
+	case atomNetWMState, atomNetWmDesktop:
 		entry.updateWindowInfos()
 
 	case atomNetWMIcon:
@@ -397,6 +410,10 @@ func (m *Manager) eventHandleLoop() {
 	globalXConn.AddEventChan(eventChan)
 
 	for ev := range eventChan {
+		if m.rrFirstEvent != 0 && ev.GetEventCode() == randr.ScreenChangeNotifyEventCode+m.rrFirstEvent {
+			m.handleScreenChanged()
+			continue
+		}
 		switch ev.GetEventCode() {
 		case x.MapNotifyEventCode:
 			event, _ := x.NewMapNotifyEvent(ev)
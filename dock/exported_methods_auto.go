@@ -62,6 +62,12 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:     v.ActivateWindow,
 			InArgs: []string{"win"},
 		},
+		{
+			Name:    "AddWindowRule",
+			Fn:      v.AddWindowRule,
+			InArgs:  []string{"ruleJSON"},
+			OutArgs: []string{"index"},
+		},
 		{
 			Name: "CancelPreviewWindow",
 			Fn:   v.CancelPreviewWindow,
@@ -76,6 +82,12 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:      v.GetDockedAppsDesktopFiles,
 			OutArgs: []string{"desktopFiles"},
 		},
+		{
+			Name:    "GetEntriesForWorkspace",
+			Fn:      v.GetEntriesForWorkspace,
+			InArgs:  []string{"workspace"},
+			OutArgs: []string{"entryIDs"},
+		},
 		{
 			Name:    "GetEntryIDs",
 			Fn:      v.GetEntryIDs,
@@ -86,6 +98,11 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:      v.GetPluginSettings,
 			OutArgs: []string{"jsonStr"},
 		},
+		{
+			Name:    "GetWindowRules",
+			Fn:      v.GetWindowRules,
+			OutArgs: []string{"rulesJSON"},
+		},
 		{
 			Name:    "IsDocked",
 			Fn:      v.IsDocked,
@@ -144,6 +161,11 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:     v.RemovePluginSettings,
 			InArgs: []string{"key1", "key2List"},
 		},
+		{
+			Name:   "RemoveWindowRule",
+			Fn:     v.RemoveWindowRule,
+			InArgs: []string{"index"},
+		},
 		{
 			Name:    "RequestDock",
 			Fn:      v.RequestDock,
@@ -156,6 +178,11 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			InArgs:  []string{"desktopFile"},
 			OutArgs: []string{"undocked"},
 		},
+		{
+			Name:   "SetEntryFilterMode",
+			Fn:     v.SetEntryFilterMode,
+			InArgs: []string{"mode"},
+		},
 		{
 			Name:   "SetFrontendWindowRect",
 			Fn:     v.SetFrontendWindowRect,
@@ -166,5 +193,11 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:     v.SetPluginSettings,
 			InArgs: []string{"jsonStr"},
 		},
+		{
+			Name:    "TestRule",
+			Fn:      v.TestRule,
+			InArgs:  []string{"win"},
+			OutArgs: []string{"resultJSON"},
+		},
 	}
 }
@@ -310,11 +310,12 @@ func identifyWindowByPidEnv(m *Manager, winInfo *WindowInfo) (string, *AppInfo)
 
 func identifyWindowByRule(m *Manager, winInfo *WindowInfo) (string, *AppInfo) {
 	msgPrefix := fmt.Sprintf("identifyWindowByRule win: %d ", winInfo.window)
-	ret := m.windowPatterns.Match(winInfo)
-	if ret == "" {
+	pattern := m.matchWindowPattern(winInfo)
+	if pattern == nil {
 		return "", nil
 	}
-	logger.Debug(msgPrefix, "patterns match result:", ret)
+	ret := pattern.Ret
+	logger.Debugf("%s patterns match result: %q (%s pattern %d)", msgPrefix, ret, pattern.Source, pattern.Index)
 	// parse ret
 	// id=$appId or env
 	var appInfo *AppInfo
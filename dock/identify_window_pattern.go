@@ -33,9 +33,9 @@ import (
 type WindowPatterns []WindowPattern
 
 type WindowPattern struct {
-	Rules       []WindowRule `json:"rules"`
-	Result      string       `json:"ret"`
-	ParsedRules []*WindowRuleParsed
+	Rules       []WindowRule        `json:"rules"`
+	Result      string              `json:"ret"`
+	ParsedRules []*WindowRuleParsed `json:"-"`
 }
 
 type WindowRule [2]string
@@ -57,29 +57,48 @@ func loadWindowPatterns(file string) (WindowPatterns, error) {
 	if err != nil {
 		return nil, err
 	}
-	var patterns WindowPatterns
-	err = json.Unmarshal(content, &patterns)
+	patterns, err := parseWindowPatterns(content)
 	if err != nil {
 		return nil, err
 	}
 	logger.Debugf("loadWindowPatterns: ok count %d", len(patterns))
+	return patterns, nil
+}
+
+func parseWindowPatterns(content []byte) (WindowPatterns, error) {
+	var patterns WindowPatterns
+	err := json.Unmarshal(content, &patterns)
+	if err != nil {
+		return nil, err
+	}
 
 	// parse pattterns
 	for i := range patterns {
-		pattern := &patterns[i]
-		rules := pattern.Rules
-		// parse rules in pattern
-		pattern.ParsedRules = make([]*WindowRuleParsed, len(rules))
-		for j := range rules {
-			rule := &rules[j]
-			pattern.ParsedRules[j] = rule.Parse()
-		}
+		patterns[i].parse()
 	}
-
 	return patterns, nil
 }
 
+func (pattern *WindowPattern) parse() {
+	rules := pattern.Rules
+	// parse rules in pattern
+	pattern.ParsedRules = make([]*WindowRuleParsed, len(rules))
+	for j := range rules {
+		rule := &rules[j]
+		pattern.ParsedRules[j] = rule.Parse()
+	}
+}
+
 func (patterns WindowPatterns) Match(winInfo *WindowInfo) string {
+	idx := patterns.MatchIndex(winInfo)
+	if idx < 0 {
+		return ""
+	}
+	return patterns[idx].Result
+}
+
+// MatchIndex 返回第一个匹配的 pattern 的位置，都不匹配时返回 -1
+func (patterns WindowPatterns) MatchIndex(winInfo *WindowInfo) int {
 	for i := range patterns {
 		pattern := &patterns[i]
 		rules := pattern.ParsedRules
@@ -99,11 +118,11 @@ func (patterns WindowPatterns) Match(winInfo *WindowInfo) string {
 		if patternOk {
 			// pattern match success
 			logger.Debugf("pattern match success")
-			return pattern.Result
+			return i
 		}
 	}
 	// fail
-	return ""
+	return -1
 }
 
 func parseRuleKey(winInfo *WindowInfo, key string) string {
@@ -0,0 +1,352 @@
+/*
+ * Copyright (C) 2017 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package dock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	dbus "github.com/godbus/dbus"
+	x "github.com/linuxdeepin/go-x11-client"
+	"pkg.deepin.io/lib/dbusutil"
+	"pkg.deepin.io/lib/xdg/basedir"
+)
+
+const (
+	windowPatternSourceUser   = "user"
+	windowPatternSourceSystem = "system"
+)
+
+// 用户的规则优先于系统的规则
+var userWindowPatternsFile = filepath.Join(basedir.GetUserConfigDir(),
+	"deepin/dde-daemon/dock/window_patterns.json")
+
+// WindowPatternInfo 是通过 DBus 返回的规则
+type WindowPatternInfo struct {
+	Source string       // user 或者 system
+	Index  int          // 在所属文件中的位置
+	Rules  []WindowRule // 规则的格式和 window_patterns.json 相同
+	Ret    string
+}
+
+func newWindowPatternInfo(source string, index int, pattern *WindowPattern) *WindowPatternInfo {
+	return &WindowPatternInfo{
+		Source: source,
+		Index:  index,
+		Rules:  pattern.Rules,
+		Ret:    pattern.Result,
+	}
+}
+
+var validRuleKeys = []string{"hasPid", "exec", "arg", "wmi", "wmc", "wmn", "wmrole"}
+
+func isValidRuleKey(key string) bool {
+	const envPrefix = "env."
+	if strings.HasPrefix(key, envPrefix) {
+		return len(key) > len(envPrefix)
+	}
+	for _, k := range validRuleKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// check 检查用户添加的规则，需要先调用 parse
+func (pattern *WindowPattern) check() error {
+	if len(pattern.Rules) == 0 {
+		return errors.New("rules is empty")
+	}
+	for idx, rule := range pattern.ParsedRules {
+		if !isValidRuleKey(rule.Key) {
+			return fmt.Errorf("rule %d: invalid key %q", idx, rule.Key)
+		}
+		value := rule.ValueParsed
+		if value.Fn == nil {
+			return fmt.Errorf("rule %d: invalid value %q", idx, value.Original)
+		}
+		if value.Type == 'r' || value.Type == 'R' {
+			_, err := regexp.Compile(value.Value)
+			if err != nil {
+				return fmt.Errorf("rule %d: %v", idx, err)
+			}
+		}
+	}
+	if pattern.Result != "env" &&
+		!(len(pattern.Result) > 4 && strings.HasPrefix(pattern.Result, "id=")) {
+		return fmt.Errorf("invalid ret %q", pattern.Result)
+	}
+	return nil
+}
+
+func loadUserWindowPatterns(file string) (WindowPatterns, error) {
+	patterns, err := loadWindowPatterns(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	// 跳过手动编辑时写错的规则
+	result := patterns[:0]
+	for idx := range patterns {
+		err = patterns[idx].check()
+		if err != nil {
+			logger.Warningf("ignore user window pattern %d: %v", idx, err)
+			continue
+		}
+		result = append(result, patterns[idx])
+	}
+	return result, nil
+}
+
+func saveUserWindowPatterns(file string, patterns WindowPatterns) error {
+	if patterns == nil {
+		patterns = WindowPatterns{}
+	}
+	content, err := json.MarshalIndent(patterns, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(file), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, content, 0644)
+}
+
+func (m *Manager) reloadWindowPatterns() {
+	patterns, err := loadWindowPatterns(windowPatternsFile)
+	if err != nil {
+		logger.Warning("loadWindowPatterns failed:", err)
+	}
+	userPatterns, err := loadUserWindowPatterns(userWindowPatternsFile)
+	if err != nil {
+		logger.Warning("loadUserWindowPatterns failed:", err)
+	}
+
+	m.windowPatternsMu.Lock()
+	m.windowPatterns = patterns
+	m.userWindowPatterns = userPatterns
+	m.windowPatternsMu.Unlock()
+}
+
+// matchWindowPattern 先匹配用户的规则，再匹配系统的规则，都不匹配时返回 nil
+func (m *Manager) matchWindowPattern(winInfo *WindowInfo) *WindowPatternInfo {
+	m.windowPatternsMu.RLock()
+	defer m.windowPatternsMu.RUnlock()
+
+	if idx := m.userWindowPatterns.MatchIndex(winInfo); idx >= 0 {
+		return newWindowPatternInfo(windowPatternSourceUser, idx, &m.userWindowPatterns[idx])
+	}
+	if idx := m.windowPatterns.MatchIndex(winInfo); idx >= 0 {
+		return newWindowPatternInfo(windowPatternSourceSystem, idx, &m.windowPatterns[idx])
+	}
+	return nil
+}
+
+// watchUserWindowPatterns 监视用户的规则文件，文件改变后重新加载规则
+func (m *Manager) watchUserWindowPatterns() {
+	dir := filepath.Dir(userWindowPatternsFile)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	// 编辑器保存文件时可能会先删除再创建，所以监视目录
+	err = watcher.Add(dir)
+	if err != nil {
+		logger.Warning(err)
+		_ = watcher.Close()
+		return
+	}
+	m.windowPatternsWatcher = watcher
+
+	go func() {
+		var timer *time.Timer
+		for {
+			select {
+			case ev, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if ev.Name != userWindowPatternsFile || ev.Op&fsnotify.Chmod != 0 {
+					continue
+				}
+				logger.Debug("user window patterns file changed:", ev)
+				// 合并短时间内的多次改变
+				if timer == nil {
+					timer = time.AfterFunc(500*time.Millisecond, m.reloadWindowPatterns)
+				} else {
+					timer.Reset(500 * time.Millisecond)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Warning("window patterns watcher error:", err)
+			}
+		}
+	}()
+}
+
+func (m *Manager) addUserWindowPattern(pattern WindowPattern) (int, error) {
+	pattern.parse()
+	err := pattern.check()
+	if err != nil {
+		return 0, err
+	}
+
+	m.windowPatternsMu.Lock()
+	defer m.windowPatternsMu.Unlock()
+	patterns := append(m.userWindowPatterns[:len(m.userWindowPatterns):len(m.userWindowPatterns)], pattern)
+	err = saveUserWindowPatterns(userWindowPatternsFile, patterns)
+	if err != nil {
+		return 0, err
+	}
+	m.userWindowPatterns = patterns
+	return len(patterns) - 1, nil
+}
+
+func (m *Manager) removeUserWindowPattern(index int) error {
+	m.windowPatternsMu.Lock()
+	defer m.windowPatternsMu.Unlock()
+	if index < 0 || index >= len(m.userWindowPatterns) {
+		return fmt.Errorf("invalid index %d", index)
+	}
+	patterns := make(WindowPatterns, 0, len(m.userWindowPatterns)-1)
+	patterns = append(patterns, m.userWindowPatterns[:index]...)
+	patterns = append(patterns, m.userWindowPatterns[index+1:]...)
+	err := saveUserWindowPatterns(userWindowPatternsFile, patterns)
+	if err != nil {
+		return err
+	}
+	m.userWindowPatterns = patterns
+	return nil
+}
+
+func (m *Manager) listWindowPatterns() []*WindowPatternInfo {
+	m.windowPatternsMu.RLock()
+	defer m.windowPatternsMu.RUnlock()
+	result := make([]*WindowPatternInfo, 0, len(m.userWindowPatterns)+len(m.windowPatterns))
+	for idx := range m.userWindowPatterns {
+		result = append(result, newWindowPatternInfo(windowPatternSourceUser, idx, &m.userWindowPatterns[idx]))
+	}
+	for idx := range m.windowPatterns {
+		result = append(result, newWindowPatternInfo(windowPatternSourceSystem, idx, &m.windowPatterns[idx]))
+	}
+	return result
+}
+
+// IdentifyTestResult 是 TestRule 的结果
+type IdentifyTestResult struct {
+	Method      string             // 第一个识别成功的方法，都失败时为空
+	InnerId     string             // 识别的结果
+	DesktopFile string             // 识别出的应用的 desktop 文件，Pid 等方法可能没有
+	Rule        *WindowPatternInfo // 窗口匹配的规则，没有匹配的规则时为 null
+	Current     string             // 窗口当前使用的识别方法，和 QueryWindowIdentifyMethod 的结果相同
+}
+
+// testIdentifyWindow 按照 identifyWindow 的顺序尝试识别窗口，但是不改变窗口的状态
+func (m *Manager) testIdentifyWindow(winInfo *WindowInfo) *IdentifyTestResult {
+	result := &IdentifyTestResult{
+		Rule: m.matchWindowPattern(winInfo),
+	}
+	if winInfo.innerId == "" {
+		return result
+	}
+	for _, item := range m.identifyWindowFuns {
+		innerId, appInfo := item.Fn(m, winInfo)
+		if innerId == "" {
+			continue
+		}
+		result.Method = item.Name
+		result.InnerId = innerId
+		if appInfo != nil {
+			if fixedAppInfo := fixAutostartAppInfo(appInfo); fixedAppInfo != nil {
+				result.Method = item.Name + "+FixAutostart"
+				appInfo = fixedAppInfo
+				result.InnerId = appInfo.innerId
+			}
+			result.DesktopFile = appInfo.GetFileName()
+		}
+		break
+	}
+	return result
+}
+
+func (m *Manager) GetWindowRules() (rulesJSON string, busErr *dbus.Error) {
+	data, err := json.Marshal(m.listWindowPatterns())
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+// AddWindowRule 添加一条用户规则，ruleJSON 和 window_patterns.json 中的一项格式相同，
+// 例如 {"rules":[["wmc","=:Foo"]],"ret":"id=foo"}，返回规则在用户规则中的位置。
+func (m *Manager) AddWindowRule(ruleJSON string) (index int32, busErr *dbus.Error) {
+	var pattern WindowPattern
+	err := json.Unmarshal([]byte(ruleJSON), &pattern)
+	if err != nil {
+		return 0, dbusutil.ToError(err)
+	}
+	idx, err := m.addUserWindowPattern(pattern)
+	if err != nil {
+		return 0, dbusutil.ToError(err)
+	}
+	return int32(idx), nil
+}
+
+// RemoveWindowRule 删除一条用户规则，系统的规则不能删除
+func (m *Manager) RemoveWindowRule(index int32) *dbus.Error {
+	err := m.removeUserWindowPattern(int(index))
+	return dbusutil.ToError(err)
+}
+
+// TestRule 返回窗口 win 现在会被哪个方法识别，以及匹配的规则
+func (m *Manager) TestRule(win uint32) (resultJSON string, busErr *dbus.Error) {
+	winInfo := m.getWindowInfo(x.Window(win))
+	if winInfo == nil {
+		return "", dbusutil.ToError(fmt.Errorf("window %d not found", win))
+	}
+	result := m.testIdentifyWindow(winInfo)
+	current, busErr := m.QueryWindowIdentifyMethod(win)
+	if busErr == nil {
+		result.Current = current
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright (C) 2017 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package dock
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/linuxdeepin/go-x11-client/util/wm/icccm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestWindowPattern(rules []WindowRule, ret string) WindowPattern {
+	pattern := WindowPattern{Rules: rules, Result: ret}
+	pattern.parse()
+	return pattern
+}
+
+func TestWindowPatternCheck(t *testing.T) {
+	pattern := newTestWindowPattern([]WindowRule{{"wmc", "=:Foo"}, {"env.FOO", "c!bar"}}, "id=foo")
+	assert.NoError(t, pattern.check())
+	pattern = newTestWindowPattern([]WindowRule{{"wmc", "=:Foo"}}, "env")
+	assert.NoError(t, pattern.check())
+
+	pattern = newTestWindowPattern(nil, "id=foo")
+	assert.Error(t, pattern.check())
+	pattern = newTestWindowPattern([]WindowRule{{"foo", "=:Foo"}}, "id=foo")
+	assert.Error(t, pattern.check())
+	pattern = newTestWindowPattern([]WindowRule{{"env.", "=:Foo"}}, "id=foo")
+	assert.Error(t, pattern.check())
+	pattern = newTestWindowPattern([]WindowRule{{"wmc", "x:Foo"}}, "id=foo")
+	assert.Error(t, pattern.check())
+	pattern = newTestWindowPattern([]WindowRule{{"wmc", "r:("}}, "id=foo")
+	assert.Error(t, pattern.check())
+	pattern = newTestWindowPattern([]WindowRule{{"wmc", "=:Foo"}}, "id=")
+	assert.Error(t, pattern.check())
+	pattern = newTestWindowPattern([]WindowRule{{"wmc", "=:Foo"}}, "foo")
+	assert.Error(t, pattern.check())
+}
+
+func TestMatchWindowPattern(t *testing.T) {
+	m := &Manager{
+		windowPatterns: WindowPatterns{
+			newTestWindowPattern([]WindowRule{{"wmi", "=:bar"}}, "id=bar"),
+			newTestWindowPattern([]WindowRule{{"wmc", "=:Foo"}}, "id=system-foo"),
+		},
+		userWindowPatterns: WindowPatterns{
+			newTestWindowPattern([]WindowRule{{"wmc", "e:foo"}}, "id=user-foo"),
+		},
+	}
+
+	winInfo := &WindowInfo{wmClass: &icccm.WMClass{Instance: "foo", Class: "Foo"}}
+	info := m.matchWindowPattern(winInfo)
+	require.NotNil(t, info)
+	assert.Equal(t, windowPatternSourceUser, info.Source)
+	assert.Equal(t, 0, info.Index)
+	assert.Equal(t, "id=user-foo", info.Ret)
+
+	winInfo = &WindowInfo{wmClass: &icccm.WMClass{Instance: "bar", Class: "Bar"}}
+	info = m.matchWindowPattern(winInfo)
+	require.NotNil(t, info)
+	assert.Equal(t, windowPatternSourceSystem, info.Source)
+	assert.Equal(t, 0, info.Index)
+
+	winInfo = &WindowInfo{wmClass: &icccm.WMClass{Instance: "baz", Class: "Baz"}}
+	assert.Nil(t, m.matchWindowPattern(winInfo))
+
+	list := m.listWindowPatterns()
+	require.Len(t, list, 3)
+	assert.Equal(t, windowPatternSourceUser, list[0].Source)
+	assert.Equal(t, windowPatternSourceSystem, list[2].Source)
+	assert.Equal(t, 1, list[2].Index)
+}
+
+func TestUserWindowPatterns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dock-window-patterns")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	oldFile := userWindowPatternsFile
+	userWindowPatternsFile = filepath.Join(dir, "dock/window_patterns.json")
+	defer func() {
+		userWindowPatternsFile = oldFile
+	}()
+
+	patterns, err := loadUserWindowPatterns(userWindowPatternsFile)
+	assert.NoError(t, err)
+	assert.Empty(t, patterns)
+
+	m := &Manager{}
+	idx, err := m.addUserWindowPattern(WindowPattern{Rules: []WindowRule{{"wmc", "=:Foo"}}, Result: "id=foo"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, idx)
+	idx, err = m.addUserWindowPattern(WindowPattern{Rules: []WindowRule{{"wmc", "=:Bar"}}, Result: "id=bar"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, idx)
+	_, err = m.addUserWindowPattern(WindowPattern{Rules: []WindowRule{{"wmc", "=:Baz"}}, Result: "baz"})
+	assert.Error(t, err)
+
+	patterns, err = loadUserWindowPatterns(userWindowPatternsFile)
+	require.NoError(t, err)
+	require.Len(t, patterns, 2)
+	assert.Equal(t, "id=bar", patterns[1].Result)
+	assert.Len(t, patterns[1].ParsedRules, 1)
+
+	assert.Error(t, m.removeUserWindowPattern(2))
+	require.NoError(t, m.removeUserWindowPattern(0))
+	patterns, err = loadUserWindowPatterns(userWindowPatternsFile)
+	require.NoError(t, err)
+	require.Len(t, patterns, 1)
+	assert.Equal(t, "id=bar", patterns[0].Result)
+
+	// 手动编辑时写错的规则被忽略
+	err = ioutil.WriteFile(userWindowPatternsFile,
+		[]byte(`[{"rules":[["wmc","=:Foo"]],"ret":"foo"},{"rules":[["wmc","=:Bar"]],"ret":"id=bar"}]`), 0644)
+	require.NoError(t, err)
+	patterns, err = loadUserWindowPatterns(userWindowPatternsFile)
+	require.NoError(t, err)
+	require.Len(t, patterns, 1)
+	assert.Equal(t, "id=bar", patterns[0].Result)
+}
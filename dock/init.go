@@ -73,6 +73,8 @@ var (
 	atomNetWmAllowedActions     x.Atom
 	atomNetWmPid                x.Atom
 	atomMotifWmHints            x.Atom
+	atomNetWmDesktop            x.Atom
+	atomNetCurrentDesktop       x.Atom
 )
 
 func initDir() {
@@ -114,4 +116,6 @@ func initAtom() {
 	atomNetWmAllowedActions, _ = getAtom("_NET_WM_ALLOWED_ACTIONS")
 	atomNetWmPid, _ = getAtom("_NET_WM_PID")
 	atomMotifWmHints, _ = getAtom("_MOTIF_WM_HINTS")
+	atomNetWmDesktop, _ = getAtom("_NET_WM_DESKTOP")
+	atomNetCurrentDesktop, _ = getAtom("_NET_CURRENT_DESKTOP")
 }
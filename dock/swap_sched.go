@@ -0,0 +1,56 @@
+/*
+ * Copyright (C) 2014 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package dock
+
+import (
+	"github.com/godbus/dbus"
+)
+
+const (
+	swapSchedServiceName = "com.deepin.daemon.SwapSchedHelper"
+	swapSchedPath        = "/com/deepin/daemon/SwapSchedHelper"
+	swapSchedInterface   = swapSchedServiceName
+)
+
+// swapSchedHelper 通知 dde-system-daemon 的 swapsched 模块应用的进程和前台应用，
+// 它据此把应用的进程放到单独的 cgroup 中，并在内存紧张时冻结后台应用。
+type swapSchedHelper struct {
+	obj dbus.BusObject
+}
+
+func newSwapSchedHelper(systemBus *dbus.Conn) *swapSchedHelper {
+	return &swapSchedHelper{
+		obj: systemBus.Object(swapSchedServiceName, swapSchedPath),
+	}
+}
+
+func (h *swapSchedHelper) moveProcess(appId string, pid uint) {
+	err := h.obj.Call(swapSchedInterface+".MoveProcess", dbus.FlagNoAutoStart,
+		appId, uint32(pid)).Err
+	if err != nil {
+		logger.Debugf("failed to move process %d of app %s: %v", pid, appId, err)
+	}
+}
+
+func (h *swapSchedHelper) setForegroundApp(pid uint) {
+	err := h.obj.Call(swapSchedInterface+".SetForegroundApp", dbus.FlagNoAutoStart,
+		uint32(pid)).Err
+	if err != nil {
+		logger.Debugf("failed to set foreground app, pid %d: %v", pid, err)
+	}
+}
@@ -83,6 +83,28 @@ func (t DisplayModeType) String() string {
 	}
 }
 
+// EntryFilterModeType 决定没有驻留的应用在什么时候显示在任务栏上
+type EntryFilterModeType int32
+
+const (
+	EntryFilterModeNone             EntryFilterModeType = iota // 显示所有应用
+	EntryFilterModeCurrentWorkspace                            // 只显示在当前工作区有窗口的应用
+	EntryFilterModeCurrentMonitor                              // 只显示在任务栏所在显示器上有窗口的应用
+)
+
+func (t EntryFilterModeType) String() string {
+	switch t {
+	case EntryFilterModeNone:
+		return "None"
+	case EntryFilterModeCurrentWorkspace:
+		return "Current workspace"
+	case EntryFilterModeCurrentMonitor:
+		return "Current monitor"
+	default:
+		return "Unknown mode"
+	}
+}
+
 type positionType int32
 
 const (
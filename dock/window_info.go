@@ -49,6 +49,7 @@ type WindowInfo struct {
 	lastConfigureNotifyEvent *x.ConfigureNotifyEvent
 	mu                       sync.Mutex
 	updateConfigureTimer     *time.Timer
+	updateMonitorTimer       *time.Timer
 
 	wmState           []x.Atom
 	wmWindowType      []x.Atom
@@ -65,6 +66,8 @@ type WindowInfo struct {
 	pid          uint
 	process      *ProcessInfo
 	entry        *AppEntry
+	workspace    uint32 // _NET_WM_DESKTOP
+	monitor      string // 窗口所在的显示器
 
 	updateCalled bool
 
@@ -350,6 +353,7 @@ func (winInfo *WindowInfo) update() {
 	winInfo.gtkAppId = getWindowGtkApplicationId(win)
 	winInfo.flatpakAppID = getWindowFlatpakAppID(win)
 	winInfo.updateWmName()
+	winInfo.updateWorkspace()
 	winInfo.innerId = genInnerId(winInfo)
 }
 
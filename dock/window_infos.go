@@ -50,3 +50,31 @@ func (a windowInfosType) Equal(b windowInfosType) bool {
 	}
 	return true
 }
+
+// ExportWindowInfoV2 在 ExportWindowInfo 的基础上增加了工作区和显示器，
+// 为了兼容解析 (sb) 的旧前端，通过单独的 WindowInfosV2 属性导出
+type ExportWindowInfoV2 struct {
+	Title     string
+	Flash     bool
+	Workspace uint32 // 窗口所在的工作区，0xFFFFFFFF 表示在所有工作区上
+	Monitor   string // 窗口所在的显示器
+}
+
+type windowInfosV2Type map[x.Window]ExportWindowInfoV2
+
+func newWindowInfosV2() windowInfosV2Type {
+	return make(windowInfosV2Type)
+}
+
+func (a windowInfosV2Type) Equal(b windowInfosV2Type) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for keyA, valA := range a {
+		valB, okB := b[keyA]
+		if !okB || valA != valB {
+			return false
+		}
+	}
+	return true
+}
@@ -65,3 +65,32 @@ func Test_windowInfosTypeEqual(t *testing.T) {
 	}
 	assert.False(t, wf.Equal(wa))
 }
+
+func Test_windowInfosV2TypeEqual(t *testing.T) {
+	wa := windowInfosV2Type{
+		0: {"a", false, 0, ""},
+		1: {"b", true, 0xFFFFFFFF, "eDP-1"},
+	}
+	wb := windowInfosV2Type{
+		1: {"b", true, 0xFFFFFFFF, "eDP-1"},
+		0: {"a", false, 0, ""},
+	}
+	assert.True(t, wa.Equal(wb))
+
+	wc := windowInfosV2Type{
+		0: {"a", false, 1, ""},
+		1: {"b", true, 0xFFFFFFFF, "eDP-1"},
+	}
+	assert.False(t, wc.Equal(wa))
+
+	wd := windowInfosV2Type{
+		0: {"a", false, 0, "HDMI-1"},
+		1: {"b", true, 0xFFFFFFFF, "eDP-1"},
+	}
+	assert.False(t, wd.Equal(wa))
+
+	we := windowInfosV2Type{
+		0: {"a", false, 0, ""},
+	}
+	assert.False(t, we.Equal(wa))
+}
@@ -0,0 +1,459 @@
+/*
+ * Copyright (C) 2017 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package dock
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	dbus "github.com/godbus/dbus"
+	x "github.com/linuxdeepin/go-x11-client"
+	"github.com/linuxdeepin/go-x11-client/ext/randr"
+	"github.com/linuxdeepin/go-x11-client/util/wm/ewmh"
+	"pkg.deepin.io/lib/dbusutil"
+	"pkg.deepin.io/lib/xdg/basedir"
+)
+
+// 窗口的 _NET_WM_DESKTOP 为这个值时显示在所有工作区上
+const allWorkspaces = 0xFFFFFFFF
+
+var dockConfigFile = filepath.Join(basedir.GetUserConfigDir(), "deepin/dde-daemon/dock/config.json")
+
+// dockConfig 保存不在 gsettings 中的设置
+type dockConfig struct {
+	EntryFilterMode EntryFilterModeType
+}
+
+func loadDockConfig(file string) (*dockConfig, error) {
+	var cfg dockConfig
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &cfg, nil
+		}
+		return nil, err
+	}
+	err = json.Unmarshal(content, &cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func saveDockConfig(file string, cfg *dockConfig) error {
+	content, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(file), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, content, 0644)
+}
+
+type monitorInfo struct {
+	Name string
+	Rect
+}
+
+func (mi *monitorInfo) contains(px, py int32) bool {
+	return mi.X <= px && px < mi.X+int32(mi.Width) &&
+		mi.Y <= py && py < mi.Y+int32(mi.Height)
+}
+
+func (mi *monitorInfo) overlapArea(rect *Rect) int64 {
+	x0 := maxInt32(mi.X, rect.X)
+	y0 := maxInt32(mi.Y, rect.Y)
+	x1 := minInt32(mi.X+int32(mi.Width), rect.X+int32(rect.Width))
+	y1 := minInt32(mi.Y+int32(mi.Height), rect.Y+int32(rect.Height))
+	if x1 <= x0 || y1 <= y0 {
+		return 0
+	}
+	return int64(x1-x0) * int64(y1-y0)
+}
+
+func maxInt32(a, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt32(a, b int32) int32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// findMonitorName 返回 rect 中心所在的显示器，中心不在任何显示器上时返回重叠面积最大的显示器
+func findMonitorName(monitors []*monitorInfo, rect *Rect) string {
+	if rect == nil {
+		return ""
+	}
+	cx := rect.X + int32(rect.Width/2)
+	cy := rect.Y + int32(rect.Height/2)
+	for _, mi := range monitors {
+		if mi.contains(cx, cy) {
+			return mi.Name
+		}
+	}
+	var name string
+	var maxArea int64
+	for _, mi := range monitors {
+		area := mi.overlapArea(rect)
+		if area > maxArea {
+			maxArea = area
+			name = mi.Name
+		}
+	}
+	return name
+}
+
+func getMonitors(conn *x.Conn) ([]*monitorInfo, error) {
+	root := conn.GetDefaultScreen().Root
+	resources, err := randr.GetScreenResources(conn, root).Reply(conn)
+	if err != nil {
+		return nil, err
+	}
+	var monitors []*monitorInfo
+	for _, output := range resources.Outputs {
+		outputInfo, err := randr.GetOutputInfo(conn, output, resources.ConfigTimestamp).Reply(conn)
+		if err != nil {
+			logger.Warningf("failed to get output %d info: %v", output, err)
+			continue
+		}
+		if outputInfo.Crtc == 0 {
+			continue
+		}
+		crtcInfo, err := randr.GetCrtcInfo(conn, outputInfo.Crtc, resources.ConfigTimestamp).Reply(conn)
+		if err != nil {
+			logger.Warningf("failed to get crtc %d info: %v", outputInfo.Crtc, err)
+			continue
+		}
+		monitors = append(monitors, &monitorInfo{
+			Name: string(outputInfo.Name),
+			Rect: Rect{
+				X:      int32(crtcInfo.X),
+				Y:      int32(crtcInfo.Y),
+				Width:  uint32(crtcInfo.Width),
+				Height: uint32(crtcInfo.Height),
+			},
+		})
+	}
+	return monitors, nil
+}
+
+func (winInfo *WindowInfo) updateWorkspace() {
+	workspace, err := ewmh.GetWMDesktop(globalXConn, winInfo.window).Reply(globalXConn)
+	if err != nil {
+		// 跳过窗口管理器的窗口没有这个属性，当作在所有工作区上
+		logger.Debugf("failed to get _NET_WM_DESKTOP for window %d: %v", winInfo.window, err)
+		workspace = allWorkspaces
+	}
+	winInfo.workspace = workspace
+}
+
+func (winInfo *WindowInfo) updateMonitor(monitors []*monitorInfo) bool {
+	rect, err := getWindowGeometry(globalXConn, winInfo.window)
+	if err != nil {
+		logger.Debugf("failed to get geometry of window %d: %v", winInfo.window, err)
+		return false
+	}
+	monitor := findMonitorName(monitors, rect)
+	if winInfo.monitor == monitor {
+		return false
+	}
+	winInfo.monitor = monitor
+	return true
+}
+
+func (winInfo *WindowInfo) isOnWorkspace(workspace uint32) bool {
+	return winInfo.workspace == allWorkspaces || winInfo.workspace == workspace
+}
+
+// entryFilter 是判断应用是否显示时需要的状态
+type entryFilter struct {
+	mode      EntryFilterModeType
+	workspace uint32 // 当前工作区
+	monitor   string // 任务栏所在的显示器
+}
+
+func (f *entryFilter) matchWindow(winInfo *WindowInfo) bool {
+	switch f.mode {
+	case EntryFilterModeCurrentWorkspace:
+		return winInfo.isOnWorkspace(f.workspace)
+	case EntryFilterModeCurrentMonitor:
+		// 还不知道任务栏在哪个显示器上时不过滤
+		return f.monitor == "" || winInfo.monitor == f.monitor
+	}
+	return true
+}
+
+func (m *Manager) getEntryFilter() entryFilter {
+	frontendRect := m.getFrontendWindowRect()
+	m.workspaceMu.Lock()
+	defer m.workspaceMu.Unlock()
+	return entryFilter{
+		mode:      m.entryFilterMode,
+		workspace: m.currentWorkspace,
+		monitor:   findMonitorName(m.monitors, frontendRect),
+	}
+}
+
+func (m *Manager) getFrontendWindowRect() *Rect {
+	m.PropsMu.RLock()
+	defer m.PropsMu.RUnlock()
+	if m.FrontendWindowRect == nil || m.FrontendWindowRect.Width == 0 {
+		return nil
+	}
+	rect := *m.FrontendWindowRect
+	return &rect
+}
+
+func (m *Manager) getMonitors() []*monitorInfo {
+	m.workspaceMu.Lock()
+	defer m.workspaceMu.Unlock()
+	return m.monitors
+}
+
+// 调用者需要持有 entry.PropsMu
+func (entry *AppEntry) updateVisible() {
+	filter := entry.manager.getEntryFilter()
+	entry.setPropVisible(entry.isVisible(&filter))
+}
+
+// 调用者需要持有 entry.PropsMu
+func (entry *AppEntry) isVisible(filter *entryFilter) bool {
+	if entry.IsDocked || filter.mode == EntryFilterModeNone {
+		return true
+	}
+	for _, winInfo := range entry.windows {
+		if filter.matchWindow(winInfo) {
+			return true
+		}
+	}
+	return false
+}
+
+// getPreferredWindow 返回点击任务栏图标时要激活的窗口，
+// 优先选择 entry.current，如果它不在当前工作区上，选择在当前工作区上的其他窗口。
+// 调用者需要持有 entry.PropsMu
+func (entry *AppEntry) getPreferredWindow(filter *entryFilter) *WindowInfo {
+	current := entry.current
+	if current == nil || (current.isOnWorkspace(filter.workspace) && filter.matchWindow(current)) {
+		return current
+	}
+
+	winSlice := make(windowSlice, 0, len(entry.windows))
+	for win := range entry.windows {
+		winSlice = append(winSlice, win)
+	}
+	sort.Sort(winSlice)
+	var onWorkspace *WindowInfo
+	for _, win := range winSlice {
+		winInfo := entry.windows[win]
+		if !winInfo.isOnWorkspace(filter.workspace) {
+			continue
+		}
+		if filter.matchWindow(winInfo) {
+			return winInfo
+		}
+		if onWorkspace == nil {
+			onWorkspace = winInfo
+		}
+	}
+	if onWorkspace != nil {
+		return onWorkspace
+	}
+	return current
+}
+
+// getFilteredWindowIds 返回需要显示的窗口，过滤后没有窗口时返回所有窗口。
+// 调用者需要持有 entry.PropsMu
+func (entry *AppEntry) getFilteredWindowIds(filter *entryFilter) []uint32 {
+	list := make([]uint32, 0, len(entry.windows))
+	for _, winInfo := range entry.windows {
+		if filter.matchWindow(winInfo) {
+			list = append(list, uint32(winInfo.window))
+		}
+	}
+	if len(list) == 0 {
+		return entry.getWindowIds()
+	}
+	return list
+}
+
+func (m *Manager) updateEntriesVisible() {
+	m.Entries.mu.RLock()
+	defer m.Entries.mu.RUnlock()
+	for _, entry := range m.Entries.items {
+		entry.PropsMu.Lock()
+		entry.updateVisible()
+		entry.PropsMu.Unlock()
+	}
+}
+
+func (m *Manager) initWorkspace() {
+	cfg, err := loadDockConfig(dockConfigFile)
+	if err != nil {
+		logger.Warning("failed to load dock config:", err)
+		cfg = &dockConfig{}
+	}
+	m.entryFilterMode = cfg.EntryFilterMode
+	m.EntryFilterMode = int32(cfg.EntryFilterMode)
+
+	m.currentWorkspace, err = ewmh.GetCurrentDesktop(globalXConn).Reply(globalXConn)
+	if err != nil {
+		logger.Warning("failed to get current workspace:", err)
+	}
+
+	_, err = randr.QueryVersion(globalXConn, randr.MajorVersion, randr.MinorVersion).Reply(globalXConn)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	err = randr.SelectInputChecked(globalXConn, m.rootWindow, randr.NotifyMaskScreenChange).Check(globalXConn)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	m.rrFirstEvent = globalXConn.GetExtensionData(randr.Ext()).FirstEvent
+	m.monitors, err = getMonitors(globalXConn)
+	if err != nil {
+		logger.Warning("failed to get monitors:", err)
+	}
+}
+
+func (m *Manager) handleCurrentWorkspaceChanged() {
+	workspace, err := ewmh.GetCurrentDesktop(globalXConn).Reply(globalXConn)
+	if err != nil {
+		logger.Warning("failed to get current workspace:", err)
+		return
+	}
+	m.workspaceMu.Lock()
+	changed := m.currentWorkspace != workspace
+	m.currentWorkspace = workspace
+	mode := m.entryFilterMode
+	m.workspaceMu.Unlock()
+
+	if changed && mode == EntryFilterModeCurrentWorkspace {
+		m.updateEntriesVisible()
+	}
+}
+
+func (m *Manager) handleScreenChanged() {
+	monitors, err := getMonitors(globalXConn)
+	if err != nil {
+		logger.Warning("failed to get monitors:", err)
+		return
+	}
+	m.workspaceMu.Lock()
+	m.monitors = monitors
+	m.workspaceMu.Unlock()
+
+	m.Entries.mu.RLock()
+	defer m.Entries.mu.RUnlock()
+	for _, entry := range m.Entries.items {
+		entry.PropsMu.Lock()
+		for _, winInfo := range entry.windows {
+			winInfo.updateMonitor(monitors)
+		}
+		entry.updateWindowInfos()
+		entry.PropsMu.Unlock()
+	}
+}
+
+// handleWindowMoved 在窗口移动后更新窗口所在的显示器
+func (m *Manager) handleWindowMoved(winInfo *WindowInfo) {
+	const delay = 200 * time.Millisecond
+	winInfo.mu.Lock()
+	defer winInfo.mu.Unlock()
+	if winInfo.updateMonitorTimer != nil {
+		winInfo.updateMonitorTimer.Reset(delay)
+		return
+	}
+	winInfo.updateMonitorTimer = time.AfterFunc(delay, func() {
+		entry := m.Entries.getByWindowId(winInfo.window)
+		if entry == nil {
+			winInfo.updateMonitor(m.getMonitors())
+			return
+		}
+		entry.PropsMu.Lock()
+		if winInfo.updateMonitor(m.getMonitors()) {
+			entry.updateWindowInfos()
+		}
+		entry.PropsMu.Unlock()
+	})
+}
+
+func (m *Manager) setPropEntryFilterMode(mode int32) {
+	m.PropsMu.Lock()
+	if m.EntryFilterMode != mode {
+		m.EntryFilterMode = mode
+		_ = m.service.EmitPropertyChanged(m, "EntryFilterMode", mode)
+	}
+	m.PropsMu.Unlock()
+}
+
+// SetEntryFilterMode 设置没有驻留的应用在什么时候显示，
+// 0 显示所有应用，1 只显示在当前工作区有窗口的应用，2 只显示在任务栏所在显示器上有窗口的应用。
+func (m *Manager) SetEntryFilterMode(mode int32) *dbus.Error {
+	filterMode := EntryFilterModeType(mode)
+	switch filterMode {
+	case EntryFilterModeNone, EntryFilterModeCurrentWorkspace, EntryFilterModeCurrentMonitor:
+	default:
+		return dbusutil.ToError(fmt.Errorf("invalid mode %d", mode))
+	}
+	err := saveDockConfig(dockConfigFile, &dockConfig{EntryFilterMode: filterMode})
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	logger.Debug("set entry filter mode:", filterMode)
+
+	m.workspaceMu.Lock()
+	m.entryFilterMode = filterMode
+	m.workspaceMu.Unlock()
+	m.setPropEntryFilterMode(mode)
+	m.updateEntriesVisible()
+	return nil
+}
+
+// GetEntriesForWorkspace 返回在工作区 workspace 上有窗口的应用的 Id
+func (m *Manager) GetEntriesForWorkspace(workspace uint32) (entryIDs []string, busErr *dbus.Error) {
+	m.Entries.mu.RLock()
+	defer m.Entries.mu.RUnlock()
+	entryIDs = make([]string, 0, len(m.Entries.items))
+	for _, entry := range m.Entries.items {
+		entry.PropsMu.RLock()
+		for _, winInfo := range entry.windows {
+			if winInfo.isOnWorkspace(workspace) {
+				entryIDs = append(entryIDs, entry.Id)
+				break
+			}
+		}
+		entry.PropsMu.RUnlock()
+	}
+	return entryIDs, nil
+}
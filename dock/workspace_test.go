@@ -0,0 +1,132 @@
+/*
+ * Copyright (C) 2017 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package dock
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	x "github.com/linuxdeepin/go-x11-client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindMonitorName(t *testing.T) {
+	monitors := []*monitorInfo{
+		{Name: "eDP-1", Rect: Rect{X: 0, Y: 0, Width: 1920, Height: 1080}},
+		{Name: "HDMI-1", Rect: Rect{X: 1920, Y: 0, Width: 2560, Height: 1440}},
+	}
+	assert.Equal(t, "eDP-1", findMonitorName(monitors, &Rect{X: 100, Y: 100, Width: 800, Height: 600}))
+	assert.Equal(t, "HDMI-1", findMonitorName(monitors, &Rect{X: 1800, Y: 100, Width: 800, Height: 600}))
+	// 中心在两个显示器之外，选择重叠面积最大的
+	assert.Equal(t, "HDMI-1", findMonitorName(monitors, &Rect{X: 2000, Y: 1200, Width: 400, Height: 600}))
+	assert.Equal(t, "", findMonitorName(monitors, &Rect{X: -2000, Y: 0, Width: 100, Height: 100}))
+	assert.Equal(t, "", findMonitorName(monitors, nil))
+	assert.Equal(t, "", findMonitorName(nil, &Rect{X: 0, Y: 0, Width: 100, Height: 100}))
+}
+
+func newTestEntry(docked bool, windows ...*WindowInfo) *AppEntry {
+	entry := &AppEntry{
+		IsDocked: docked,
+		windows:  make(map[x.Window]*WindowInfo),
+	}
+	for _, winInfo := range windows {
+		entry.windows[winInfo.window] = winInfo
+	}
+	if len(windows) > 0 {
+		entry.current = windows[0]
+	}
+	return entry
+}
+
+func TestEntryFilter(t *testing.T) {
+	win1 := &WindowInfo{window: 1, workspace: 0, monitor: "eDP-1"}
+	win2 := &WindowInfo{window: 2, workspace: 1, monitor: "HDMI-1"}
+	sticky := &WindowInfo{window: 3, workspace: allWorkspaces, monitor: "HDMI-1"}
+
+	filter := &entryFilter{mode: EntryFilterModeNone, workspace: 1}
+	assert.True(t, newTestEntry(false, win1).isVisible(filter))
+
+	filter = &entryFilter{mode: EntryFilterModeCurrentWorkspace, workspace: 1}
+	assert.False(t, newTestEntry(false, win1).isVisible(filter))
+	assert.True(t, newTestEntry(false, win1, win2).isVisible(filter))
+	assert.True(t, newTestEntry(false, sticky).isVisible(filter))
+	assert.True(t, newTestEntry(true).isVisible(filter))
+	assert.True(t, newTestEntry(true, win1).isVisible(filter))
+
+	filter = &entryFilter{mode: EntryFilterModeCurrentMonitor, monitor: "eDP-1"}
+	assert.True(t, newTestEntry(false, win1).isVisible(filter))
+	assert.False(t, newTestEntry(false, win2, sticky).isVisible(filter))
+
+	// 还不知道任务栏在哪个显示器上
+	filter = &entryFilter{mode: EntryFilterModeCurrentMonitor}
+	assert.True(t, newTestEntry(false, win2).isVisible(filter))
+}
+
+func TestGetPreferredWindow(t *testing.T) {
+	win1 := &WindowInfo{window: 1, workspace: 0, monitor: "eDP-1"}
+	win2 := &WindowInfo{window: 2, workspace: 1, monitor: "HDMI-1"}
+	win3 := &WindowInfo{window: 3, workspace: 1, monitor: "eDP-1"}
+
+	filter := &entryFilter{mode: EntryFilterModeNone, workspace: 0}
+	assert.Equal(t, win1, newTestEntry(false, win1, win2).getPreferredWindow(filter))
+
+	// entry.current 不在当前工作区上
+	filter = &entryFilter{mode: EntryFilterModeNone, workspace: 1}
+	assert.Equal(t, win2, newTestEntry(false, win1, win2, win3).getPreferredWindow(filter))
+
+	filter = &entryFilter{mode: EntryFilterModeCurrentMonitor, workspace: 1, monitor: "eDP-1"}
+	assert.Equal(t, win3, newTestEntry(false, win1, win2, win3).getPreferredWindow(filter))
+
+	// 当前工作区上没有窗口
+	filter = &entryFilter{mode: EntryFilterModeNone, workspace: 2}
+	assert.Equal(t, win1, newTestEntry(false, win1, win2).getPreferredWindow(filter))
+
+	assert.Nil(t, newTestEntry(true).getPreferredWindow(filter))
+}
+
+func TestGetFilteredWindowIds(t *testing.T) {
+	win1 := &WindowInfo{window: 1, workspace: 0}
+	win2 := &WindowInfo{window: 2, workspace: 1}
+	entry := newTestEntry(false, win1, win2)
+
+	filter := &entryFilter{mode: EntryFilterModeCurrentWorkspace, workspace: 1}
+	assert.Equal(t, []uint32{2}, entry.getFilteredWindowIds(filter))
+
+	filter = &entryFilter{mode: EntryFilterModeCurrentWorkspace, workspace: 2}
+	assert.ElementsMatch(t, []uint32{1, 2}, entry.getFilteredWindowIds(filter))
+}
+
+func TestDockConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dock-config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "dock/config.json")
+	cfg, err := loadDockConfig(file)
+	require.NoError(t, err)
+	assert.Equal(t, EntryFilterModeNone, cfg.EntryFilterMode)
+
+	err = saveDockConfig(file, &dockConfig{EntryFilterMode: EntryFilterModeCurrentMonitor})
+	require.NoError(t, err)
+	cfg, err = loadDockConfig(file)
+	require.NoError(t, err)
+	assert.Equal(t, EntryFilterModeCurrentMonitor, cfg.EntryFilterMode)
+}
@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"strings"
 
 	"pkg.deepin.io/lib/xdg/basedir"
 )
@@ -56,6 +57,8 @@ type EventInfo struct {
 type gestureInfo struct {
 	Event 	EventInfo
 	Action  ActionInfo
+	// 应用的 desktop ID 或者 WM_CLASS 到动作的映射，这些应用是活动应用时代替 Action
+	AppActions map[string]ActionInfo `json:",omitempty"`
 }
 type gestureInfos []*gestureInfo
 
@@ -86,6 +89,103 @@ func (infos gestureInfos) Set (evInfo EventInfo, action ActionInfo) error {
 	return nil
 }
 
+// getAction 返回活动应用 appIds 对应的动作，没有应用的动作时返回 Action
+func (info *gestureInfo) getAction(appIds []string) ActionInfo {
+	for _, id := range appIds {
+		for app, action := range info.AppActions {
+			if normalizeAppId(app) == normalizeAppId(id) {
+				return action
+			}
+		}
+	}
+	return info.Action
+}
+
+func (info *gestureInfo) setAppAction(app string, action ActionInfo) {
+	info.deleteAppAction(app)
+	if info.AppActions == nil {
+		info.AppActions = make(map[string]ActionInfo)
+	}
+	info.AppActions[app] = action
+}
+
+func (info *gestureInfo) deleteAppAction(app string) bool {
+	var found bool
+	for app0 := range info.AppActions {
+		if normalizeAppId(app0) == normalizeAppId(app) {
+			delete(info.AppActions, app0)
+			found = true
+		}
+	}
+	if len(info.AppActions) == 0 {
+		info.AppActions = nil
+	}
+	return found
+}
+
+func normalizeAppId(app string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(app), ".desktop"))
+}
+
+// Delete 删除事件 evInfo 的手势信息
+func (infos gestureInfos) Delete(evInfo EventInfo) gestureInfos {
+	result := infos[:0]
+	for _, info := range infos {
+		if info.Event != evInfo {
+			result = append(result, info)
+		}
+	}
+	return result
+}
+
+// 可以绑定动作的手势事件，键是事件名，值是可用的方向
+var bindableEvents = map[string][]string{
+	"swipe": {"up", "down", "left", "right"},
+	"tap":   {"none"},
+	"pinch": {"in", "out"},
+	"hold":  {"none"},
+}
+
+// getHoldFallbackEvent 返回长按没有动作时代替它的点按事件。
+// 长按是按住超过长按时间后抬起的点按，所以没有绑定长按时应该当作点按。
+func getHoldFallbackEvent(evInfo EventInfo) (EventInfo, bool) {
+	if evInfo.Name != "hold" {
+		return EventInfo{}, false
+	}
+	return EventInfo{
+		Name:      "tap",
+		Direction: "none",
+		Fingers:   evInfo.Fingers,
+	}, true
+}
+
+func checkEventInfo(evInfo EventInfo) error {
+	directions, ok := bindableEvents[evInfo.Name]
+	if !ok {
+		return fmt.Errorf("invalid gesture name: %q", evInfo.Name)
+	}
+	var validDirection bool
+	for _, direction := range directions {
+		if direction == evInfo.Direction {
+			validDirection = true
+			break
+		}
+	}
+	if !validDirection {
+		return fmt.Errorf("invalid direction %q for gesture %q", evInfo.Direction, evInfo.Name)
+	}
+
+	// 双指手势只有 pinch，双指滑动和点按是滚动和右键
+	minFingers := int32(3)
+	if evInfo.Name == "pinch" {
+		minFingers = 2
+	}
+	if evInfo.Fingers < minFingers || evInfo.Fingers > 5 {
+		return fmt.Errorf("invalid fingers %d for gesture %q", evInfo.Fingers, evInfo.Name)
+	}
+	return nil
+}
+
 func newGestureInfosFromFile(filename string) (gestureInfos, error) {
 	content, err := ioutil.ReadFile(filepath.Clean(filename))
 	if err != nil {
@@ -122,3 +122,64 @@ func Test_Set(t *testing.T) {
 	assert.Nil(t, infos.Set(EventInfo{Name:"swipe", Direction:"up", Fingers:3}, action2))
 	assert.Nil(t, infos.Set(EventInfo{Name:"swipe", Direction:"down", Fingers:3}, action2))
 }
+
+// 测试：应用的动作
+func Test_AppAction(t *testing.T) {
+	infos, err := newGestureInfosFromFile(configPath)
+	assert.Nil(t, err)
+
+	evInfo := EventInfo{Name: "swipe", Direction: "left", Fingers: 4}
+	info := infos.Get(evInfo)
+	assert.NotNil(t, info)
+	defaultAction := info.Action
+
+	tabAction := ActionInfo{
+		Type:   ActionTypeShortcut,
+		Action: "ctrl+Tab",
+	}
+	info.setAppAction("Firefox.desktop", tabAction)
+	assert.Equal(t, tabAction, info.getAction([]string{"firefox", "Navigator"}))
+	assert.Equal(t, defaultAction, info.getAction([]string{"deepin-terminal"}))
+	assert.Equal(t, defaultAction, info.getAction(nil))
+
+	// 同一个应用的不同写法只保留一个
+	info.setAppAction("firefox", tabAction)
+	assert.Len(t, info.AppActions, 1)
+
+	assert.True(t, info.deleteAppAction("FIREFOX"))
+	assert.False(t, info.deleteAppAction("firefox"))
+	assert.Nil(t, info.AppActions)
+}
+
+// 测试：Delete接口
+func Test_Delete(t *testing.T) {
+	infos, err := newGestureInfosFromFile(configPath)
+	assert.Nil(t, err)
+
+	evInfo := EventInfo{Name: "swipe", Direction: "up", Fingers: 3}
+	n := len(infos)
+	infos = infos.Delete(evInfo)
+	assert.Len(t, infos, n-1)
+	assert.Nil(t, infos.Get(evInfo))
+}
+
+// 测试：检查手势事件
+func Test_checkEventInfo(t *testing.T) {
+	assert.Nil(t, checkEventInfo(EventInfo{Name: "swipe", Direction: "up", Fingers: 3}))
+	assert.Nil(t, checkEventInfo(EventInfo{Name: "pinch", Direction: "in", Fingers: 2}))
+	assert.Nil(t, checkEventInfo(EventInfo{Name: "hold", Direction: "none", Fingers: 4}))
+	assert.NotNil(t, checkEventInfo(EventInfo{Name: "swipe", Direction: "in", Fingers: 3}))
+	assert.NotNil(t, checkEventInfo(EventInfo{Name: "swipe", Direction: "up", Fingers: 2}))
+	assert.NotNil(t, checkEventInfo(EventInfo{Name: "tap", Direction: "none", Fingers: 6}))
+	assert.NotNil(t, checkEventInfo(EventInfo{Name: "touch right button", Direction: "down", Fingers: 0}))
+}
+
+// 测试：没有绑定长按时当作点按
+func Test_getHoldFallbackEvent(t *testing.T) {
+	tapInfo, ok := getHoldFallbackEvent(EventInfo{Name: "hold", Direction: "none", Fingers: 3})
+	assert.True(t, ok)
+	assert.Equal(t, EventInfo{Name: "tap", Direction: "none", Fingers: 3}, tapInfo)
+
+	_, ok = getHoldFallbackEvent(EventInfo{Name: "tap", Direction: "none", Fingers: 3})
+	assert.False(t, ok)
+}
@@ -59,13 +59,13 @@ func (d *Daemon) Start() error {
 	}
 
 	var err error
-	d.manager, err = newManager()
+	service := loader.GetService()
+	d.manager, err = newManager(service)
 	if err != nil {
 		logger.Error("failed to initialize gesture manager:", err)
 		return err
 	}
 
-	service := loader.GetService()
 	err = service.Export(dbusServicePath, d.manager)
 	if err != nil {
 		logger.Error("failed to export gesture:", err)
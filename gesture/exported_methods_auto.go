@@ -23,11 +23,36 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:      v.GetShortPressDuration,
 			OutArgs: []string{"duration"},
 		},
+		{
+			Name:    "ListGestures",
+			Fn:      v.ListGestures,
+			OutArgs: []string{"gestures"},
+		},
+		{
+			Name:   "ResetAppGesture",
+			Fn:     v.ResetAppGesture,
+			InArgs: []string{"app", "name", "direction", "fingers"},
+		},
+		{
+			Name:   "ResetGesture",
+			Fn:     v.ResetGesture,
+			InArgs: []string{"name", "direction", "fingers"},
+		},
+		{
+			Name:   "SetAppGesture",
+			Fn:     v.SetAppGesture,
+			InArgs: []string{"app", "name", "direction", "fingers", "actionType", "action"},
+		},
 		{
 			Name:   "SetEdgeMoveStopDuration",
 			Fn:     v.SetEdgeMoveStopDuration,
 			InArgs: []string{"duration"},
 		},
+		{
+			Name:   "SetGesture",
+			Fn:     v.SetGesture,
+			InArgs: []string{"name", "direction", "fingers", "actionType", "action"},
+		},
 		{
 			Name:   "SetLongPressDuration",
 			Fn:     v.SetLongPressDuration,
@@ -38,6 +38,7 @@ const (
 )
 
 type Manager struct {
+	service        *dbusutil.Service
 	wm             wm.Wm
 	sysDaemon      daemon.Daemon
 	systemSigLoop  *dbusutil.SignalLoop
@@ -54,9 +55,18 @@ type Manager struct {
 	sessionmanager sessionmanager.SessionManager
 	clipboard      clipboard.Clipboard
 	notification   notification.Notification
+
+	// nolint
+	signals *struct {
+		GestureChanged struct {
+			name      string
+			direction string
+			fingers   int32
+		}
+	}
 }
 
-func newManager() (*Manager, error) {
+func newManager(service *dbusutil.Service) (*Manager, error) {
 	sessionConn, err := dbus.SessionBus()
 	if err != nil {
 		return nil, err
@@ -111,6 +121,7 @@ func newManager() (*Manager, error) {
 	}
 
 	m := &Manager{
+		service:        service,
 		userFile:       configUserPath,
 		Infos:          infos,
 		setting:        setting,
@@ -279,18 +290,43 @@ func (m *Manager) shouldIgnoreGesture(info *gestureInfo) bool {
 }
 
 func (m *Manager) Exec(evInfo EventInfo) error {
+	m.mu.RLock()
 	info := m.Infos.Get(evInfo)
+	var hasAppActions bool
+	if info != nil {
+		hasAppActions = len(info.AppActions) > 0
+	}
+	m.mu.RUnlock()
 	if info == nil {
+		if tapInfo, ok := getHoldFallbackEvent(evInfo); ok {
+			// 长按没有绑定动作，当作点按，避免慢速的多指点按失效
+			return m.Exec(tapInfo)
+		}
 		return fmt.Errorf("not found event info: %s", evInfo.toString())
 	}
 
-	logger.Debugf("[Exec]: event info:%s  action info:%s", info.Event.toString(), info.Action.toString())
+	// 获取活动应用需要访问 X，不在锁内进行
+	var appIds []string
+	if hasAppActions {
+		appIds = getActiveAppIds()
+	}
+	m.mu.RLock()
+	action := info.getAction(appIds)
+	m.mu.RUnlock()
+
+	logger.Debugf("[Exec]: event info:%s  action info:%s", info.Event.toString(), action.toString())
 	if m.shouldIgnoreGesture(info) {
 		return nil
 	}
 
-	var cmd = info.Action.Action
-	switch info.Action.Type {
+	var cmd = action.Action
+	switch action.Type {
+	case "":
+		// 只设置了应用的动作
+		if tapInfo, ok := getHoldFallbackEvent(evInfo); ok {
+			return m.Exec(tapInfo)
+		}
+		return nil
 	case ActionTypeCommandline:
 		break
 	case ActionTypeShortcut:
@@ -298,7 +334,7 @@ func (m *Manager) Exec(evInfo EventInfo) error {
 	case ActionTypeBuiltin:
 		return m.handleBuiltinAction(cmd)
 	default:
-		return fmt.Errorf("invalid action type: %s", info.Action.Type)
+		return fmt.Errorf("invalid action type: %s", action.Type)
 	}
 
 	out, err := exec.Command("/bin/sh", "-c", cmd).CombinedOutput()
@@ -1,6 +1,11 @@
 package gesture
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
 	"github.com/godbus/dbus"
 	"pkg.deepin.io/lib/dbusutil"
 )
@@ -52,3 +57,153 @@ func (m *Manager) SetEdgeMoveStopDuration(duration uint32) *dbus.Error {
 func (m *Manager) GetEdgeMoveStopDuration() (duration uint32, busErr *dbus.Error) {
 	return uint32(m.tsSetting.GetInt(tsSchemaKeyEdgeMoveStop)), nil
 }
+
+const gestureSignalChanged = "GestureChanged"
+
+func (m *Manager) checkActionInfo(action ActionInfo) error {
+	switch action.Type {
+	case ActionTypeShortcut, ActionTypeCommandline:
+		if action.Action == "" {
+			return errors.New("action is empty")
+		}
+	case ActionTypeBuiltin:
+		if m.builtinSets[action.Action] == nil {
+			return fmt.Errorf("invalid built-in action %q", action.Action)
+		}
+	default:
+		return fmt.Errorf("invalid action type: %q", action.Type)
+	}
+	return nil
+}
+
+// ListGestures 返回可以绑定的手势和它们的动作，JSON 格式
+func (m *Manager) ListGestures() (gestures string, busErr *dbus.Error) {
+	m.mu.RLock()
+	infos := make(gestureInfos, 0, len(m.Infos))
+	for _, info := range m.Infos {
+		if checkEventInfo(info.Event) == nil {
+			infos = append(infos, info)
+		}
+	}
+	data, err := json.Marshal(infos)
+	m.mu.RUnlock()
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+// SetGesture 设置手势的动作，actionType 是 shortcut、commandline 或者 built-in
+func (m *Manager) SetGesture(name, direction string, fingers int32, actionType, action string) *dbus.Error {
+	return m.setGesture("", name, direction, fingers, actionType, action)
+}
+
+// SetAppGesture 设置手势在应用 app 中的动作，app 是 desktop ID 或者 WM_CLASS
+func (m *Manager) SetAppGesture(app, name, direction string, fingers int32, actionType, action string) *dbus.Error {
+	if strings.TrimSpace(app) == "" {
+		return dbusutil.ToError(errors.New("app is empty"))
+	}
+	return m.setGesture(strings.TrimSpace(app), name, direction, fingers, actionType, action)
+}
+
+func (m *Manager) setGesture(app, name, direction string, fingers int32, actionType, action string) *dbus.Error {
+	logger.Debugf("setGesture app: %q, event: %s %s %d, action: %s %q",
+		app, name, direction, fingers, actionType, action)
+	evInfo := EventInfo{
+		Name:      name,
+		Direction: direction,
+		Fingers:   fingers,
+	}
+	err := checkEventInfo(evInfo)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	actionInfo := ActionInfo{
+		Type:   actionType,
+		Action: action,
+	}
+	err = m.checkActionInfo(actionInfo)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	m.mu.Lock()
+	info := m.Infos.Get(evInfo)
+	if info == nil {
+		// 默认配置中没有的手势，比如 pinch 和 hold，只设置应用的动作时 Action 为空，默认什么都不做
+		info = &gestureInfo{
+			Event: evInfo,
+		}
+		m.Infos = append(m.Infos, info)
+	}
+	if app == "" {
+		info.Action = actionInfo
+	} else {
+		info.setAppAction(app, actionInfo)
+	}
+	m.mu.Unlock()
+
+	return m.saveAndEmitChanged(evInfo)
+}
+
+// ResetGesture 把手势恢复成系统默认的设置，同时删除应用的动作
+func (m *Manager) ResetGesture(name, direction string, fingers int32) *dbus.Error {
+	evInfo := EventInfo{
+		Name:      name,
+		Direction: direction,
+		Fingers:   fingers,
+	}
+	err := checkEventInfo(evInfo)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	defaultInfos, err := newGestureInfosFromFile(configSystemPath)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	m.mu.Lock()
+	if defaultInfo := defaultInfos.Get(evInfo); defaultInfo != nil {
+		if info := m.Infos.Get(evInfo); info != nil {
+			info.Action = defaultInfo.Action
+			info.AppActions = defaultInfo.AppActions
+		} else {
+			m.Infos = append(m.Infos, defaultInfo)
+		}
+	} else {
+		m.Infos = m.Infos.Delete(evInfo)
+	}
+	m.mu.Unlock()
+
+	return m.saveAndEmitChanged(evInfo)
+}
+
+// ResetAppGesture 删除手势在应用 app 中的动作
+func (m *Manager) ResetAppGesture(app, name, direction string, fingers int32) *dbus.Error {
+	evInfo := EventInfo{
+		Name:      name,
+		Direction: direction,
+		Fingers:   fingers,
+	}
+	m.mu.Lock()
+	info := m.Infos.Get(evInfo)
+	found := info != nil && info.deleteAppAction(app)
+	m.mu.Unlock()
+	if !found {
+		return dbusutil.ToError(fmt.Errorf("not found action of app %q for: %s", app, evInfo.toString()))
+	}
+
+	return m.saveAndEmitChanged(evInfo)
+}
+
+func (m *Manager) saveAndEmitChanged(evInfo EventInfo) *dbus.Error {
+	err := m.Write()
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	err = m.service.Emit(m, gestureSignalChanged, evInfo.Name, evInfo.Direction, evInfo.Fingers)
+	if err != nil {
+		logger.Warning(err)
+	}
+	return nil
+}
@@ -22,6 +22,7 @@ package gesture
 import (
 	"fmt"
 	"io/ioutil"
+	"path/filepath"
 	"strings"
 
 	"github.com/godbus/dbus"
@@ -29,6 +30,8 @@ import (
 	x "github.com/linuxdeepin/go-x11-client"
 	"github.com/linuxdeepin/go-x11-client/util/keybind"
 	"github.com/linuxdeepin/go-x11-client/util/wm/ewmh"
+	"github.com/linuxdeepin/go-x11-client/util/wm/icccm"
+	"pkg.deepin.io/lib/procfs"
 )
 
 var (
@@ -108,6 +111,33 @@ func getCurrentActionWindowCmd() string {
 	return string(data)
 }
 
+// getActiveAppIds 返回活动窗口所属应用的 desktop ID 和 WM_CLASS，desktop ID 在前
+func getActiveAppIds() []string {
+	if getX11Conn() == nil {
+		return nil
+	}
+	win, err := ewmh.GetActiveWindow(xconn).Reply(xconn)
+	if err != nil || win == 0 {
+		return nil
+	}
+
+	var ids []string
+	pid, err := ewmh.GetWMPid(xconn, win).Reply(xconn)
+	if err == nil && pid != 0 {
+		environ, err := procfs.Process(pid).Environ()
+		if err == nil {
+			if desktopFile := environ.Get("GIO_LAUNCHED_DESKTOP_FILE"); desktopFile != "" {
+				ids = append(ids, filepath.Base(desktopFile))
+			}
+		}
+	}
+	wmClass, err := icccm.GetWMClass(xconn, win).Reply(xconn)
+	if err == nil {
+		ids = append(ids, wmClass.Instance, wmClass.Class)
+	}
+	return ids
+}
+
 func isSessionActive(sessionPath dbus.ObjectPath) bool {
 	if _dconn == nil {
 		conn, err := dbus.SystemBus()
@@ -0,0 +1,220 @@
+/*
+ * Copyright (C) 2017 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package grub2
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const (
+	grubEnvFile = "/boot/grub/grubenv"
+
+	grubRebootCmd     = "grub-reboot"
+	grubSetDefaultCmd = "grub-set-default"
+	grubEditEnvCmd    = "grub-editenv"
+
+	// GRUB_DEFAULT 为 saved 时从 grubenv 的 saved_entry 中读取默认启动项
+	grubDefaultSaved = "saved"
+
+	entryTypeMenuEntry = "menuentry"
+	entryTypeSubMenu   = "submenu"
+
+	// os-prober 生成的启动项的 id 前缀，比如 osprober-efi-XXXX
+	osProberIdPrefix = "osprober-"
+)
+
+// entryNode 是 GetEntries 返回的启动项树的节点
+type entryNode struct {
+	Title string
+	Type  string
+	// grub.cfg 中的 id，可能为空
+	Id string `json:",omitempty"`
+	// 可以用于 GRUB_DEFAULT 和 grub-reboot 的路径，各级之间用 > 分隔，
+	// 优先使用 id，没有 id 时使用序号
+	Path     string
+	Classes  []string `json:",omitempty"`
+	Kernel   string   `json:",omitempty"`
+	OSProber bool
+	Children []*entryNode `json:",omitempty"`
+}
+
+func (entry *Entry) getPathItem() string {
+	if entry.id != "" {
+		return entry.id
+	}
+	return strconv.Itoa(entry.num)
+}
+
+// buildEntryTree 把 parseEntries 返回的列表转换成树，entries 按照在 grub.cfg 中的顺序排列
+func buildEntryTree(entries []Entry) []*entryNode {
+	var roots []*entryNode
+	// stack[i] 是第 i 层当前的 submenu
+	var stack []*entryNode
+	for i := range entries {
+		entry := &entries[i]
+		depth := entry.getDepth()
+		if depth > len(stack) {
+			logger.Warningf("invalid depth %d of entry %q", depth, entry.title)
+			continue
+		}
+		stack = stack[:depth]
+
+		node := &entryNode{
+			Title:    entry.title,
+			Type:     entryTypeMenuEntry,
+			Id:       entry.id,
+			Path:     entry.getPathItem(),
+			Classes:  entry.classes,
+			Kernel:   entry.kernel,
+			OSProber: strings.HasPrefix(entry.id, osProberIdPrefix),
+		}
+		if depth == 0 {
+			roots = append(roots, node)
+		} else {
+			parent := stack[depth-1]
+			node.Path = parent.Path + ">" + node.Path
+			parent.Children = append(parent.Children, node)
+		}
+
+		if entry.entryType == SUBMENU {
+			node.Type = entryTypeSubMenu
+			stack = append(stack, node)
+		}
+	}
+	return roots
+}
+
+// findEntryNode 按照 id 或者路径查找 menuentry
+func findEntryNode(nodes []*entryNode, id string) *entryNode {
+	for _, node := range nodes {
+		if node.Type == entryTypeMenuEntry && (node.Id == id || node.Path == id) {
+			return node
+		}
+		result := findEntryNode(node.Children, id)
+		if result != nil {
+			return result
+		}
+	}
+	return nil
+}
+
+func loadEntryTree() ([]*entryNode, error) {
+	content, err := ioutil.ReadFile(grubScriptFile)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := parseEntries(string(content))
+	if err != nil {
+		return nil, err
+	}
+	return buildEntryTree(entries), nil
+}
+
+// parseGrubEnv 解析 grubenv 文件，文件用 # 填充到 1024 字节
+func parseGrubEnv(content string) map[string]string {
+	env := make(map[string]string)
+	sc := bufio.NewScanner(strings.NewReader(content))
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		idx := strings.Index(line, "=")
+		if idx <= 0 {
+			continue
+		}
+		env[line[:idx]] = line[idx+1:]
+	}
+	return env
+}
+
+func loadGrubEnv() (map[string]string, error) {
+	content, err := ioutil.ReadFile(grubEnvFile)
+	if err != nil {
+		return nil, err
+	}
+	return parseGrubEnv(string(content)), nil
+}
+
+// resolveSavedEntry 把 saved_entry 转换成第一层启动项的标题，saved_entry 可以是序号、标题或者 id，
+// 也可以是用 > 分隔的路径，这时只看第一级。找不到时返回空字符串。
+func resolveSavedEntry(entries []Entry, saved string) string {
+	first := strings.SplitN(saved, ">", 2)[0]
+	idx, err := strconv.Atoi(first)
+	isNum := err == nil
+	for i := range entries {
+		entry := &entries[i]
+		if entry.parentSubMenu != nil {
+			continue
+		}
+		if (isNum && entry.num == idx) || (!isNum && (entry.title == first || entry.id == first)) {
+			return entry.title
+		}
+	}
+	return ""
+}
+
+// isSavedDefault 判断是否记住上次启动的启动项
+func isSavedDefault(params map[string]string) bool {
+	return getDefaultEntry(params) == grubDefaultSaved &&
+		decodeShellValue(params[grubSaveDefault]) == "true"
+}
+
+// getModifyTaskSavedDefault 开启或关闭记住上次启动项，关闭时默认启动项设置为 idx
+func getModifyTaskSavedDefault(enabled bool, idx int) modifyTask {
+	f := func(params map[string]string) {
+		if enabled {
+			params[grubDefault] = grubDefaultSaved
+			params[grubSaveDefault] = "true"
+		} else {
+			params[grubDefault] = strconv.Itoa(idx)
+			delete(params, grubSaveDefault)
+		}
+	}
+	return modifyTask{
+		paramsModifyFunc: f,
+	}
+}
+
+func runGrubCmd(name string, args ...string) error {
+	logger.Debugf("$ %s %s", name, strings.Join(args, " "))
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %v: %s", name, err, out)
+	}
+	return nil
+}
+
+// setNextEntry 只在下次启动时使用 entry，不修改默认启动项
+func setNextEntry(entry string) error {
+	return runGrubCmd(grubRebootCmd, entry)
+}
+
+func clearNextEntry() error {
+	return runGrubCmd(grubEditEnvCmd, grubEnvFile, "unset", "next_entry")
+}
+
+// setSavedEntry 修改 grubenv 中的 saved_entry，在 GRUB_DEFAULT=saved 时作为默认启动项
+func setSavedEntry(entry string) error {
+	return runGrubCmd(grubSetDefaultCmd, entry)
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright (C) 2017 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package grub2
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testGrubCfg = `
+function gfxmode {
+	set gfxpayload="${1}"
+}
+menuentry 'Deepin 20 GNU/Linux' --class deepin --class gnu-linux --class gnu --class os $menuentry_id_option 'gnulinux-simple-8a1b' {
+	load_video
+	insmod gzio
+	linux	/boot/vmlinuz-5.4.70-amd64-desktop root=UUID=8a1b ro  splash quiet
+	initrd	/boot/initrd.img-5.4.70-amd64-desktop
+}
+submenu 'Advanced options for Deepin 20 GNU/Linux' $menuentry_id_option 'gnulinux-advanced-8a1b' {
+	menuentry 'Deepin 20 GNU/Linux, with Linux 5.4.70-amd64-desktop' --class deepin --class os $menuentry_id_option 'gnulinux-5.4.70-amd64-desktop-advanced-8a1b' {
+		linux	/boot/vmlinuz-5.4.70-amd64-desktop root=UUID=8a1b ro  splash quiet
+	}
+	menuentry 'Deepin 20 GNU/Linux, with Linux 5.4.50-amd64-desktop (recovery mode)' --class deepin --class os {
+		linux	/vmlinuz-5.4.50-amd64-desktop root=UUID=8a1b ro single
+	}
+}
+menuentry 'Windows Boot Manager (on /dev/nvme0n1p1)' --class windows --class os $menuentry_id_option 'osprober-efi-6C2B-1A4D' {
+	chainloader /efi/Microsoft/Boot/bootmgfw.efi
+}
+menuentry "System setup" --id=uefi-firmware {
+	fwsetup
+}
+`
+
+func Test_parseEntries(t *testing.T) {
+	entries, err := parseEntries(testGrubCfg)
+	require.Nil(t, err)
+	require.Len(t, entries, 6)
+
+	assert.Equal(t, "gnulinux-simple-8a1b", entries[0].id)
+	assert.Equal(t, []string{"deepin", "gnu-linux", "gnu", "os"}, entries[0].classes)
+	assert.Equal(t, "5.4.70-amd64-desktop", entries[0].kernel)
+
+	assert.Equal(t, SUBMENU, entries[1].entryType)
+	assert.Equal(t, "gnulinux-advanced-8a1b", entries[1].id)
+	assert.Equal(t, "", entries[1].kernel)
+	assert.Equal(t, 1, entries[2].getDepth())
+	assert.Equal(t, "", entries[3].id)
+	assert.Equal(t, "5.4.50-amd64-desktop", entries[3].kernel)
+
+	assert.Equal(t, 0, entries[4].getDepth())
+	assert.Equal(t, 2, entries[4].num)
+	assert.Equal(t, "uefi-firmware", entries[5].id)
+}
+
+func Test_buildEntryTree(t *testing.T) {
+	entries, err := parseEntries(testGrubCfg)
+	require.Nil(t, err)
+	nodes := buildEntryTree(entries)
+	require.Len(t, nodes, 4)
+
+	assert.Equal(t, entryTypeMenuEntry, nodes[0].Type)
+	assert.Equal(t, "gnulinux-simple-8a1b", nodes[0].Path)
+	assert.False(t, nodes[0].OSProber)
+
+	sub := nodes[1]
+	assert.Equal(t, entryTypeSubMenu, sub.Type)
+	require.Len(t, sub.Children, 2)
+	assert.Equal(t, "gnulinux-advanced-8a1b>gnulinux-5.4.70-amd64-desktop-advanced-8a1b", sub.Children[0].Path)
+	assert.Equal(t, "gnulinux-advanced-8a1b>1", sub.Children[1].Path)
+	assert.Equal(t, "5.4.50-amd64-desktop", sub.Children[1].Kernel)
+
+	assert.True(t, nodes[2].OSProber)
+	assert.Equal(t, "osprober-efi-6C2B-1A4D", nodes[2].Path)
+
+	assert.Equal(t, nodes[2], findEntryNode(nodes, "osprober-efi-6C2B-1A4D"))
+	assert.Equal(t, sub.Children[1], findEntryNode(nodes, "gnulinux-advanced-8a1b>1"))
+	assert.Equal(t, sub.Children[0], findEntryNode(nodes, "gnulinux-5.4.70-amd64-desktop-advanced-8a1b"))
+	// 不能进入子菜单
+	assert.Nil(t, findEntryNode(nodes, "gnulinux-advanced-8a1b"))
+	assert.Nil(t, findEntryNode(nodes, "no-such-entry"))
+
+	data, err := json.Marshal(nodes[2])
+	require.Nil(t, err)
+	assert.Equal(t, `{"Title":"Windows Boot Manager (on /dev/nvme0n1p1)","Type":"menuentry",`+
+		`"Id":"osprober-efi-6C2B-1A4D","Path":"osprober-efi-6C2B-1A4D","Classes":["windows","os"],"OSProber":true}`,
+		string(data))
+}
+
+func Test_parseGrubEnv(t *testing.T) {
+	env := parseGrubEnv("# GRUB Environment Block\nsaved_entry=gnulinux-advanced-8a1b>1\nnext_entry=\n" +
+		"##########################################")
+	assert.Equal(t, map[string]string{
+		"saved_entry": "gnulinux-advanced-8a1b>1",
+		"next_entry":  "",
+	}, env)
+}
+
+func Test_resolveSavedEntry(t *testing.T) {
+	entries, err := parseEntries(testGrubCfg)
+	require.Nil(t, err)
+
+	assert.Equal(t, "Windows Boot Manager (on /dev/nvme0n1p1)", resolveSavedEntry(entries, "2"))
+	assert.Equal(t, "Windows Boot Manager (on /dev/nvme0n1p1)", resolveSavedEntry(entries, "osprober-efi-6C2B-1A4D"))
+	assert.Equal(t, "Advanced options for Deepin 20 GNU/Linux", resolveSavedEntry(entries, "gnulinux-advanced-8a1b>1"))
+	assert.Equal(t, "Deepin 20 GNU/Linux", resolveSavedEntry(entries, "Deepin 20 GNU/Linux"))
+	assert.Equal(t, "", resolveSavedEntry(entries, "9"))
+	assert.Equal(t, "", resolveSavedEntry(entries, ""))
+}
+
+func Test_getModifyTaskSavedDefault(t *testing.T) {
+	params := map[string]string{grubDefault: "0"}
+	getModifyTaskSavedDefault(true, 0).paramsModifyFunc(params)
+	assert.True(t, isSavedDefault(params))
+
+	getModifyTaskSavedDefault(false, 2).paramsModifyFunc(params)
+	assert.False(t, isSavedDefault(params))
+	assert.Equal(t, map[string]string{grubDefault: "2"}, params)
+
+	getModifyTaskSavedDefault(true, 0).paramsModifyFunc(params)
+	getModifyTaskDefaultEntry(1).paramsModifyFunc(params)
+	assert.Equal(t, map[string]string{grubDefault: "1"}, params)
+}
@@ -33,6 +33,11 @@ type Entry struct {
 	title         string
 	num           int
 	parentSubMenu *Entry
+	// $menuentry_id_option 或 --id 指定的 id，可能为空
+	id      string
+	classes []string
+	// linux 命令加载的内核版本，只有 menuentry 有
+	kernel string
 }
 
 func (entry *Entry) getFullTitle() string {
@@ -41,3 +46,12 @@ func (entry *Entry) getFullTitle() string {
 	}
 	return entry.title
 }
+
+// getDepth 返回 entry 所在的层级，第一层为 0
+func (entry *Entry) getDepth() int {
+	depth := 0
+	for p := entry.parentSubMenu; p != nil; p = p.parentSubMenu {
+		depth++
+	}
+	return depth
+}
@@ -8,11 +8,26 @@ import (
 
 func (v *Grub2) GetExportedMethods() dbusutil.ExportedMethods {
 	return dbusutil.ExportedMethods{
+		{
+			Name:   "AddKernelParam",
+			Fn:     v.AddKernelParam,
+			InArgs: []string{"param"},
+		},
 		{
 			Name:    "GetAvailableGfxmodes",
 			Fn:      v.GetAvailableGfxmodes,
 			OutArgs: []string{"gfxModes"},
 		},
+		{
+			Name:    "GetEntries",
+			Fn:      v.GetEntries,
+			OutArgs: []string{"entriesJSON"},
+		},
+		{
+			Name:    "GetKernelParams",
+			Fn:      v.GetKernelParams,
+			OutArgs: []string{"params"},
+		},
 		{
 			Name:    "GetSimpleEntryTitles",
 			Fn:      v.GetSimpleEntryTitles,
@@ -22,10 +37,24 @@ func (v *Grub2) GetExportedMethods() dbusutil.ExportedMethods {
 			Name: "PrepareGfxmodeDetect",
 			Fn:   v.PrepareGfxmodeDetect,
 		},
+		{
+			Name:   "RebootToEntry",
+			Fn:     v.RebootToEntry,
+			InArgs: []string{"id"},
+		},
+		{
+			Name:   "RemoveKernelParam",
+			Fn:     v.RemoveKernelParam,
+			InArgs: []string{"param"},
+		},
 		{
 			Name: "Reset",
 			Fn:   v.Reset,
 		},
+		{
+			Name: "RollbackKernelParams",
+			Fn:   v.RollbackKernelParams,
+		},
 		{
 			Name:   "SetDefaultEntry",
 			Fn:     v.SetDefaultEntry,
@@ -41,6 +70,16 @@ func (v *Grub2) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:     v.SetGfxmode,
 			InArgs: []string{"gfxmode"},
 		},
+		{
+			Name:   "SetKernelParams",
+			Fn:     v.SetKernelParams,
+			InArgs: []string{"params"},
+		},
+		{
+			Name:   "SetSavedDefault",
+			Fn:     v.SetSavedDefault,
+			InArgs: []string{"enabled"},
+		},
 		{
 			Name:   "SetTimeout",
 			Fn:     v.SetTimeout,
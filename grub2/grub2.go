@@ -65,10 +65,14 @@ type Grub2 struct {
 	theme              *Theme
 	gfxmodeDetectState gfxmodeDetectState
 	inhibitFd          dbus.UnixFD
-	PropsMu            sync.RWMutex
+	// GRUB_CMDLINE_LINUX_DEFAULT 中的参数，由 PropsMu 保护
+	kernelCmdline kernelCmdline
+	PropsMu       sync.RWMutex
 	// props:
 	ThemeFile    string
 	DefaultEntry string
+	// 是否记住上次启动的启动项
+	SavedDefault bool
 	EnableTheme  bool
 	Gfxmode      string
 	Timeout      uint32
@@ -94,6 +98,19 @@ func (g *Grub2) defaultEntryIdx2Str(idx int) (string, error) {
 	}
 }
 
+// getSavedEntry 返回 grubenv 中 saved_entry 对应的第一层启动项，没有时使用第一个启动项
+func (g *Grub2) getSavedEntry() string {
+	env, err := loadGrubEnv()
+	if err != nil {
+		logger.Warning("failed to load grubenv:", err)
+	}
+	entry := resolveSavedEntry(g.entries, env["saved_entry"])
+	if entry == "" {
+		entry, _ = g.defaultEntryIdx2Str(0)
+	}
+	return entry
+}
+
 func (g *Grub2) applyParams(params map[string]string) {
 	if grub_common.InGfxmodeDetectionMode(params) {
 		g.gfxmodeDetectState = gfxmodeDetectStateDetecting
@@ -118,6 +135,9 @@ func (g *Grub2) applyParams(params map[string]string) {
 
 	g.Gfxmode = getGfxMode(params)
 
+	g.kernelCmdline = parseKernelCmdline(params[grubCmdlineLinuxDefault])
+	g.SavedDefault = isSavedDefault(params)
+
 	// default entry
 	defaultEntry := getDefaultEntry(params)
 
@@ -127,9 +147,8 @@ func (g *Grub2) applyParams(params map[string]string) {
 		g.DefaultEntry, _ = g.defaultEntryIdx2Str(defaultEntryIdx)
 	} else {
 		// not a num
-		if defaultEntry == "saved" {
-			// TODO saved
-			g.DefaultEntry, _ = g.defaultEntryIdx2Str(0)
+		if defaultEntry == grubDefaultSaved {
+			g.DefaultEntry = g.getSavedEntry()
 		} else {
 			g.DefaultEntry = defaultEntry
 		}
@@ -140,6 +159,8 @@ type modifyTask struct {
 	paramsModifyFunc func(map[string]string)
 	adjustTheme      bool
 	adjustThemeLang  string
+	// 修改之前是否备份 /etc/default/grub
+	backup bool
 }
 
 func getModifyTaskEnableTheme(enable bool, lang string, gfxmodeDetectState gfxmodeDetectState) modifyTask {
@@ -194,6 +215,8 @@ func getModifyTaskGfxmode(val string, lang string) modifyTask {
 func getModifyTaskDefaultEntry(idx int) modifyTask {
 	f := func(params map[string]string) {
 		params[grubDefault] = strconv.Itoa(idx)
+		// 指定了默认启动项，不再记住上次启动的启动项
+		delete(params, grubSaveDefault)
 	}
 	return modifyTask{
 		paramsModifyFunc: f,
@@ -419,7 +442,14 @@ func parseEntries(fileContent string) ([]Entry, error) {
 			}
 			title, ok := parseTitle(line)
 			if ok {
-				entry := Entry{MENUENTRY, title, numCount[level], parentMenus[len(parentMenus)-1]}
+				entry := Entry{
+					entryType:     MENUENTRY,
+					title:         title,
+					num:           numCount[level],
+					parentSubMenu: parentMenus[len(parentMenus)-1],
+					id:            parseEntryId(line),
+					classes:       parseEntryClasses(line),
+				}
 				entries = append(entries, entry)
 				logger.Debugf("found entry: [%d] %s %s", level, strings.Repeat(" ", level*2), title)
 
@@ -437,11 +467,20 @@ func parseEntries(fileContent string) ([]Entry, error) {
 			}
 			title, ok := parseTitle(line)
 			if ok {
-				entry := Entry{SUBMENU, title, numCount[level], parentMenus[len(parentMenus)-1]}
+				entry := Entry{
+					entryType:     SUBMENU,
+					title:         title,
+					num:           numCount[level],
+					parentSubMenu: parentMenus[len(parentMenus)-1],
+					id:            parseEntryId(line),
+					classes:       parseEntryClasses(line),
+				}
 				entries = append(entries, entry)
 				parentMenus = append(parentMenus, &entry)
 				logger.Debugf("found entry: [%d] %s %s", level, strings.Repeat(" ", level*2), title)
 
+				// 子菜单和 menuentry 一起编号，和 grub 的序号一致
+				numCount[level]++
 				level++
 				numCount[level] = 0
 				continue
@@ -461,6 +500,11 @@ func parseEntries(fileContent string) ([]Entry, error) {
 				parentMenus[len(parentMenus)-1] = nil
 				parentMenus = parentMenus[:len(parentMenus)-1]
 			}
+		} else if inMenuEntry {
+			kernel, ok := parseKernelVersion(line)
+			if ok {
+				entries[len(entries)-1].kernel = kernel
+			}
 		}
 	}
 	err := sl.Err()
@@ -473,8 +517,36 @@ func parseEntries(fileContent string) ([]Entry, error) {
 var (
 	entryRegexpSingleQuote = regexp.MustCompile(`^ *(menuentry|submenu) +'(.*?)'.*$`)
 	entryRegexpDoubleQuote = regexp.MustCompile(`^ *(menuentry|submenu) +"(.*?)".*$`)
+	entryRegexpId          = regexp.MustCompile(`(?:\$menuentry_id_option|--id)[ =]+(?:'([^']*)'|"([^"]*)"|([^ '"{]+))`)
+	entryRegexpClass       = regexp.MustCompile(`--class +([^ {]+)`)
+	entryRegexpKernel      = regexp.MustCompile(`^linux(?:16|efi)?\s+\S*/vmlinuz-(\S+)`)
 )
 
+func parseEntryId(line string) string {
+	match := entryRegexpId.FindStringSubmatch(line)
+	if match == nil {
+		return ""
+	}
+	return match[1] + match[2] + match[3]
+}
+
+func parseEntryClasses(line string) []string {
+	var classes []string
+	for _, match := range entryRegexpClass.FindAllStringSubmatch(line, -1) {
+		classes = append(classes, match[1])
+	}
+	return classes
+}
+
+// parseKernelVersion 从 linux 命令中取出内核版本，比如 linux /boot/vmlinuz-5.4.0-generic root=...
+func parseKernelVersion(line string) (string, bool) {
+	match := entryRegexpKernel.FindStringSubmatch(line)
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
+
 func parseTitle(line string) (string, bool) {
 	line = strings.TrimLeftFunc(line, unicode.IsSpace)
 	if entryRegexpSingleQuote.MatchString(line) {
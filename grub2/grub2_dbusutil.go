@@ -28,6 +28,19 @@ func (v *Grub2) emitPropChangedDefaultEntry(value string) error {
 	return v.service.EmitPropertyChanged(v, "DefaultEntry", value)
 }
 
+func (v *Grub2) setPropSavedDefault(value bool) (changed bool) {
+	if v.SavedDefault != value {
+		v.SavedDefault = value
+		v.emitPropChangedSavedDefault(value)
+		return true
+	}
+	return false
+}
+
+func (v *Grub2) emitPropChangedSavedDefault(value bool) error {
+	return v.service.EmitPropertyChanged(v, "SavedDefault", value)
+}
+
 func (v *Grub2) setPropEnableTheme(value bool) (changed bool) {
 	if v.EnableTheme != value {
 		v.EnableTheme = value
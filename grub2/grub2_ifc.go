@@ -20,8 +20,11 @@
 package grub2
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
+	"os"
 	"strings"
 
 	dbus "github.com/godbus/dbus"
@@ -92,7 +95,11 @@ func (g *Grub2) SetDefaultEntry(sender dbus.Sender, entry string) *dbus.Error {
 	}
 
 	g.PropsMu.Lock()
-	if g.setPropDefaultEntry(entry) {
+	changed := g.setPropDefaultEntry(entry)
+	if g.setPropSavedDefault(false) {
+		changed = true
+	}
+	if changed {
 		g.addModifyTask(getModifyTaskDefaultEntry(idx))
 	}
 	g.PropsMu.Unlock()
@@ -219,7 +226,8 @@ func (g *Grub2) Reset(sender dbus.Sender) *dbus.Error {
 	g.setPropThemeFile(defaultGrubTheme)
 
 	cfgDefaultEntry, _ := g.defaultEntryIdx2Str(defaultGrubDefaultInt)
-	if g.setPropDefaultEntry(cfgDefaultEntry) {
+	savedDefaultChanged := g.setPropSavedDefault(false)
+	if g.setPropDefaultEntry(cfgDefaultEntry) || savedDefaultChanged {
 		modifyTasks = append(modifyTasks, getModifyTaskDefaultEntry(defaultGrubDefaultInt))
 	}
 	g.PropsMu.Unlock()
@@ -295,3 +303,220 @@ func (g *Grub2) PrepareGfxmodeDetect(sender dbus.Sender) *dbus.Error {
 	}
 	return nil
 }
+
+// GetKernelParams 返回 GRUB_CMDLINE_LINUX_DEFAULT 中的内核参数
+func (g *Grub2) GetKernelParams() (params []string, busErr *dbus.Error) {
+	g.service.DelayAutoQuit()
+
+	g.PropsMu.RLock()
+	params = make([]string, len(g.kernelCmdline.params))
+	copy(params, g.kernelCmdline.params)
+	g.PropsMu.RUnlock()
+	return params, nil
+}
+
+// setKernelParams 修改内核参数，调用者需要持有 PropsMu 的写锁
+func (g *Grub2) setKernelParams(params []string) {
+	if isKernelParamsEqual(g.kernelCmdline.params, params) {
+		return
+	}
+	g.kernelCmdline = g.kernelCmdline.withParams(params)
+	g.addModifyTask(getModifyTaskKernelParams(g.kernelCmdline, true))
+}
+
+// AddKernelParam 添加内核参数，已经有相同 key 的参数时替换它，比如 nomodeset 或 mitigations=off
+func (g *Grub2) AddKernelParam(sender dbus.Sender, param string) *dbus.Error {
+	g.service.DelayAutoQuit()
+
+	err := g.checkAuth(sender, polikitActionIdCommon)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	err = checkKernelParam(param)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	g.PropsMu.Lock()
+	g.setKernelParams(addKernelParam(g.kernelCmdline.params, param))
+	g.PropsMu.Unlock()
+	return nil
+}
+
+// RemoveKernelParam 删除内核参数，param 不带值时删除所有 key 相同的参数
+func (g *Grub2) RemoveKernelParam(sender dbus.Sender, param string) *dbus.Error {
+	g.service.DelayAutoQuit()
+
+	err := g.checkAuth(sender, polikitActionIdCommon)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	g.PropsMu.Lock()
+	params, removed := removeKernelParam(g.kernelCmdline.params, param)
+	if !removed {
+		g.PropsMu.Unlock()
+		return dbusutil.ToError(fmt.Errorf("kernel parameter %q not found", param))
+	}
+	g.setKernelParams(params)
+	g.PropsMu.Unlock()
+	return nil
+}
+
+// SetKernelParams 替换全部内核参数
+func (g *Grub2) SetKernelParams(sender dbus.Sender, params []string) *dbus.Error {
+	g.service.DelayAutoQuit()
+
+	err := g.checkAuth(sender, polikitActionIdCommon)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	g.PropsMu.Lock()
+	params, err = normalizeKernelParams(params, g.kernelCmdline)
+	if err != nil {
+		g.PropsMu.Unlock()
+		return dbusutil.ToError(err)
+	}
+	g.setKernelParams(params)
+	g.PropsMu.Unlock()
+	return nil
+}
+
+// RollbackKernelParams 从最近的备份恢复内核参数，并删除这个备份，多次调用可以逐步回退。
+func (g *Grub2) RollbackKernelParams(sender dbus.Sender) *dbus.Error {
+	g.service.DelayAutoQuit()
+
+	err := g.checkAuth(sender, polikitActionIdCommon)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	backups, err := listGrubParamsBackups(grubParamsBackupDir)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	if len(backups) == 0 {
+		return dbusutil.ToError(errors.New("no backup to rollback"))
+	}
+	backupFile := backups[len(backups)-1]
+	params, err := grub_common.LoadGrubParamsFile(backupFile)
+	if err != nil {
+		logger.Warning("failed to load backup:", err)
+		return dbusutil.ToError(err)
+	}
+	kernelCmdline := parseKernelCmdline(params[grubCmdlineLinuxDefault])
+	logger.Infof("rollback kernel params to %v from %s", kernelCmdline.params, backupFile)
+
+	g.PropsMu.Lock()
+	// 队列中的任务按顺序执行，这个任务会覆盖之前还没有写入的修改
+	g.kernelCmdline = kernelCmdline
+	g.addModifyTask(getModifyTaskKernelParams(kernelCmdline, false))
+	g.PropsMu.Unlock()
+
+	err = os.Remove(backupFile)
+	if err != nil {
+		logger.Warning("failed to remove backup:", err)
+	}
+	return nil
+}
+
+// GetEntries 返回 JSON 格式的启动项树，包括子菜单、内核版本和 os-prober 找到的其他系统
+func (g *Grub2) GetEntries() (entriesJSON string, busErr *dbus.Error) {
+	g.service.DelayAutoQuit()
+
+	nodes, err := loadEntryTree()
+	if err != nil {
+		logger.Warning("failed to load entries:", err)
+		return "", dbusutil.ToError(err)
+	}
+	if nodes == nil {
+		nodes = []*entryNode{}
+	}
+	data, err := json.Marshal(nodes)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+// RebootToEntry 只在下次启动时使用 id 对应的启动项，然后立即重启，不修改默认启动项。
+// id 是 GetEntries 返回的 Id 或者 Path。
+func (g *Grub2) RebootToEntry(sender dbus.Sender, id string) *dbus.Error {
+	g.service.DelayAutoQuit()
+
+	err := g.checkAuth(sender, polikitActionIdCommon)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	g.PropsMu.RLock()
+	updating := g.Updating
+	g.PropsMu.RUnlock()
+	if updating {
+		// grub.cfg 正在重新生成，这时重启可能无法启动
+		return dbusutil.ToError(errors.New("grub is updating"))
+	}
+
+	nodes, err := loadEntryTree()
+	if err != nil {
+		logger.Warning("failed to load entries:", err)
+		return dbusutil.ToError(err)
+	}
+	node := findEntryNode(nodes, id)
+	if node == nil {
+		return dbusutil.ToError(fmt.Errorf("invalid entry %q", id))
+	}
+
+	logger.Infof("reboot to entry %q (%s)", node.Title, node.Path)
+	err = setNextEntry(node.Path)
+	if err != nil {
+		logger.Warning(err)
+		return dbusutil.ToError(err)
+	}
+
+	err = reboot()
+	if err != nil {
+		logger.Warning("failed to reboot:", err)
+		// 没有重启时取消，避免以后的某次启动意外进入这个启动项
+		err1 := clearNextEntry()
+		if err1 != nil {
+			logger.Warning(err1)
+		}
+		return dbusutil.ToError(err)
+	}
+	return nil
+}
+
+// SetSavedDefault 设置是否记住上次启动的启动项，开启时以当前的默认启动项作为初始值
+func (g *Grub2) SetSavedDefault(sender dbus.Sender, enabled bool) *dbus.Error {
+	g.service.DelayAutoQuit()
+
+	err := g.checkAuth(sender, polikitActionIdCommon)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	g.PropsMu.Lock()
+	defer g.PropsMu.Unlock()
+
+	if g.SavedDefault == enabled {
+		return nil
+	}
+
+	idx := g.defaultEntryStr2Idx(g.DefaultEntry)
+	if idx == -1 {
+		idx = defaultGrubDefaultInt
+	}
+	if enabled && g.DefaultEntry != "" {
+		err = setSavedEntry(g.DefaultEntry)
+		if err != nil {
+			logger.Warning(err)
+			return dbusutil.ToError(err)
+		}
+	}
+	g.setPropSavedDefault(enabled)
+	g.addModifyTask(getModifyTaskSavedDefault(enabled, idx))
+	return nil
+}
@@ -15,11 +15,13 @@ const (
 	defaultThemeDir  = themesDir + "/deepin"
 	fallbackThemeDir = defaultThemeDir + "-fallback"
 
-	grubBackground = "GRUB_BACKGROUND"
-	grubDefault    = "GRUB_DEFAULT"
-	grubGfxmode    = "GRUB_GFXMODE"
-	grubTheme      = "GRUB_THEME"
-	grubTimeout    = "GRUB_TIMEOUT"
+	grubBackground          = "GRUB_BACKGROUND"
+	grubCmdlineLinuxDefault = "GRUB_CMDLINE_LINUX_DEFAULT"
+	grubDefault             = "GRUB_DEFAULT"
+	grubGfxmode             = "GRUB_GFXMODE"
+	grubSaveDefault         = "GRUB_SAVEDEFAULT"
+	grubTheme               = "GRUB_THEME"
+	grubTimeout             = "GRUB_TIMEOUT"
 
 	defaultGrubTheme       = defaultThemeDir + "/theme.txt"
 	fallbackGrubTheme      = fallbackThemeDir + "/theme.txt"
@@ -40,3 +40,12 @@ func inhibit(what, who, why string) (dbus.UnixFD, error) {
 	m := login1.NewManager(systemConn)
 	return m.Inhibit(0, what, who, why, "block")
 }
+
+func reboot() error {
+	systemConn, err := dbus.SystemBus()
+	if err != nil {
+		return err
+	}
+	m := login1.NewManager(systemConn)
+	return m.Reboot(0, false)
+}
@@ -0,0 +1,351 @@
+/*
+ * Copyright (C) 2017 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package grub2
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	grubParamsBackupDir    = "/var/lib/dde-daemon/grub2/backups"
+	grubParamsBackupPrefix = "grub."
+	// 最多保留的备份数量
+	grubParamsBackupMax = 10
+)
+
+var (
+	// 参数中不能有空白和 shell 的特殊字符，否则写入 /etc/default/grub 后会被 shell 解释
+	kernelParamKeyRegexp   = regexp.MustCompile(`^[A-Za-z0-9_.\-]+$`)
+	kernelParamValueRegexp = regexp.MustCompile(`^[A-Za-z0-9_.,:/=+@\-]*$`)
+)
+
+type kernelParamChecker func(value string, hasValue bool) error
+
+func checkNoValue(value string, hasValue bool) error {
+	if hasValue {
+		return fmt.Errorf("unexpected value %q", value)
+	}
+	return nil
+}
+
+func checkValueIn(values ...string) kernelParamChecker {
+	return func(value string, hasValue bool) error {
+		if hasValue {
+			for _, v := range values {
+				if v == value {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("invalid value %q, must be one of %s", value, strings.Join(values, ", "))
+	}
+}
+
+func checkIntRange(min, max int) kernelParamChecker {
+	return func(value string, hasValue bool) error {
+		v, err := strconv.Atoi(value)
+		if !hasValue || err != nil || v < min || v > max {
+			return fmt.Errorf("invalid value %q, must be an integer between %d and %d", value, min, max)
+		}
+		return nil
+	}
+}
+
+func checkResumeDevice(value string, hasValue bool) error {
+	for _, prefix := range []string{"UUID=", "PARTUUID=", "LABEL=", "/dev/"} {
+		if hasValue && strings.HasPrefix(value, prefix) && len(value) > len(prefix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid resume device %q", value)
+}
+
+// 常用的内核参数，会检查它们的值；其他参数只检查格式
+var knownKernelParams = map[string]kernelParamChecker{
+	"quiet":              checkNoValue,
+	"splash":             checkNoValue,
+	"nosplash":           checkNoValue,
+	"nomodeset":          checkNoValue,
+	"noapic":             checkNoValue,
+	"nolapic":            checkNoValue,
+	"noresume":           checkNoValue,
+	"mitigations":        checkValueIn("off", "auto", "auto,nosmt"),
+	"resume":             checkResumeDevice,
+	"acpi":               checkValueIn("off", "force", "strict", "noirq", "rsdt", "copy_dsdt"),
+	"acpi_backlight":     checkValueIn("vendor", "video", "native", "none"),
+	"loglevel":           checkIntRange(0, 7),
+	"i915.modeset":       checkValueIn("0", "1"),
+	"nouveau.modeset":    checkValueIn("0", "1"),
+	"radeon.modeset":     checkValueIn("0", "1"),
+	"amdgpu.modeset":     checkValueIn("0", "1"),
+	"nvidia-drm.modeset": checkValueIn("0", "1"),
+}
+
+// parseKernelParam 把 key=value 格式的参数分成 key 和 value
+func parseKernelParam(param string) (key, value string, hasValue bool) {
+	idx := strings.Index(param, "=")
+	if idx == -1 {
+		return param, "", false
+	}
+	return param[:idx], param[idx+1:], true
+}
+
+func checkKernelParam(param string) error {
+	key, value, hasValue := parseKernelParam(param)
+	if !kernelParamKeyRegexp.MatchString(key) {
+		return fmt.Errorf("invalid kernel parameter %q", param)
+	}
+	if hasValue && !kernelParamValueRegexp.MatchString(value) {
+		return fmt.Errorf("invalid kernel parameter %q", param)
+	}
+
+	checker, ok := knownKernelParams[key]
+	if !ok {
+		return nil
+	}
+	err := checker(value, hasValue)
+	if err != nil {
+		return fmt.Errorf("kernel parameter %s: %v", key, err)
+	}
+	return nil
+}
+
+// kernelCmdline 是 GRUB_CMDLINE_LINUX_DEFAULT 的值。文件中已有的参数保留原来的写法，
+// 写回时使用原来的引号，避免转义被重复，或者单引号中的 $ 在双引号中被展开。
+type kernelCmdline struct {
+	// 外层的引号，'"' 或者 '\''
+	quote byte
+	// 去掉 shell 转义后的参数
+	params []string
+	// 文件中已有的参数到原始写法的映射
+	raws map[string]string
+}
+
+// parseKernelCmdline 按照内核的规则分割参数，双引号中的空白不分割，比如 acpi_osi="Windows 2013"。
+// 不用 decodeShellValue，避免 $vt_handoff 之类的变量被展开后丢失。
+func parseKernelCmdline(value string) kernelCmdline {
+	cmdline := kernelCmdline{
+		quote: '"',
+		raws:  make(map[string]string),
+	}
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		cmdline.quote = value[0]
+		value = value[1 : len(value)-1]
+	}
+	// 没有引号的值按照双引号处理，写回时加上双引号
+
+	var raw, param []byte
+	var inQuote bool
+	flush := func() {
+		if len(raw) == 0 {
+			return
+		}
+		cmdline.params = append(cmdline.params, string(param))
+		cmdline.raws[string(param)] = string(raw)
+		raw = raw[:0]
+		param = param[:0]
+	}
+	for i := 0; i < len(value); i++ {
+		ch := value[i]
+		if cmdline.quote == '"' && ch == '\\' && i+1 < len(value) {
+			next := value[i+1]
+			raw = append(raw, ch, next)
+			switch next {
+			case '$', '`', '"', '\\':
+				param = append(param, next)
+			default:
+				param = append(param, ch, next)
+			}
+			if next == '"' {
+				inQuote = !inQuote
+			}
+			i++
+			continue
+		}
+		if cmdline.quote == '\'' && ch == '"' {
+			inQuote = !inQuote
+		}
+		if !inQuote && (ch == ' ' || ch == '\t' || ch == '\n') {
+			flush()
+			continue
+		}
+		raw = append(raw, ch)
+		param = append(param, ch)
+	}
+	flush()
+	return cmdline
+}
+
+// getKernelParams 返回 GRUB_CMDLINE_LINUX_DEFAULT 中的参数
+func getKernelParams(params map[string]string) []string {
+	return parseKernelCmdline(params[grubCmdlineLinuxDefault]).params
+}
+
+// isInFile 判断 param 是否是文件中已有的参数，这些参数不需要检查
+func (c kernelCmdline) isInFile(param string) bool {
+	_, ok := c.raws[param]
+	return ok
+}
+
+// withParams 返回参数为 params 的 kernelCmdline，文件中已有的参数使用原来的写法
+func (c kernelCmdline) withParams(params []string) kernelCmdline {
+	c.params = params
+	return c
+}
+
+// String 返回写入文件的值，新参数已经检查过，不需要转义
+func (c kernelCmdline) String() string {
+	raws := make([]string, len(c.params))
+	for i, param := range c.params {
+		raw, ok := c.raws[param]
+		if !ok {
+			raw = param
+		}
+		raws[i] = raw
+	}
+	return string(c.quote) + strings.Join(raws, " ") + string(c.quote)
+}
+
+// addKernelParam 添加参数，已经有相同 key 的参数时替换它
+func addKernelParam(list []string, param string) []string {
+	key, _, _ := parseKernelParam(param)
+	var result []string
+	var added bool
+	for _, p := range list {
+		k, _, _ := parseKernelParam(p)
+		if k != key {
+			result = append(result, p)
+		} else if !added {
+			result = append(result, param)
+			added = true
+		}
+	}
+	if !added {
+		result = append(result, param)
+	}
+	return result
+}
+
+// removeKernelParam 删除参数，param 有值时只删除完全相同的参数，没有值时删除所有 key 相同的参数
+func removeKernelParam(list []string, param string) ([]string, bool) {
+	_, _, hasValue := parseKernelParam(param)
+	var result []string
+	var removed bool
+	for _, p := range list {
+		var match bool
+		if hasValue {
+			match = p == param
+		} else {
+			k, _, _ := parseKernelParam(p)
+			match = k == param
+		}
+		if match {
+			removed = true
+			continue
+		}
+		result = append(result, p)
+	}
+	return result, removed
+}
+
+// normalizeKernelParams 检查参数，并去掉 key 重复的参数，后面的参数优先。
+// cmdline 中已有的参数原样保留，不检查，比如 $vt_handoff。
+func normalizeKernelParams(list []string, cmdline kernelCmdline) ([]string, error) {
+	var result []string
+	for _, param := range list {
+		if !cmdline.isInFile(param) {
+			err := checkKernelParam(param)
+			if err != nil {
+				return nil, err
+			}
+		}
+		result = addKernelParam(result, param)
+	}
+	return result, nil
+}
+
+func isKernelParamsEqual(l1, l2 []string) bool {
+	if len(l1) != len(l2) {
+		return false
+	}
+	for i := range l1 {
+		if l1[i] != l2[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func getModifyTaskKernelParams(cmdline kernelCmdline, backup bool) modifyTask {
+	value := cmdline.String()
+	f := func(params map[string]string) {
+		params[grubCmdlineLinuxDefault] = value
+	}
+	return modifyTask{
+		paramsModifyFunc: f,
+		backup:           backup,
+	}
+}
+
+// listGrubParamsBackups 返回所有备份文件，按照时间从旧到新排序
+func listGrubParamsBackups(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, grubParamsBackupPrefix+"*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// backupGrubParams 把 /etc/default/grub 复制到备份目录，只保留最近的 grubParamsBackupMax 个备份
+func backupGrubParams(filename, dir string, now time.Time) error {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	backupFile := filepath.Join(dir, grubParamsBackupPrefix+now.Format("20060102-150405.000000000"))
+	err = ioutil.WriteFile(backupFile, content, 0644)
+	if err != nil {
+		return err
+	}
+
+	backups, err := listGrubParamsBackups(dir)
+	if err != nil {
+		return err
+	}
+	for len(backups) > grubParamsBackupMax {
+		err = os.Remove(backups[0])
+		if err != nil {
+			logger.Warning("failed to remove old backup:", err)
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+/*
+ * Copyright (C) 2017 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package grub2
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_checkKernelParam(t *testing.T) {
+	for _, param := range []string{
+		"quiet", "nomodeset", "mitigations=off", "mitigations=auto,nosmt",
+		"resume=UUID=0b0c6a3e-1c2f-4f0e-9d43-3f2d1a2b3c4d", "resume=/dev/sda2",
+		"loglevel=3", "i915.modeset=0", "acpi_backlight=vendor", "intel_iommu=on",
+	} {
+		assert.Nil(t, checkKernelParam(param), param)
+	}
+
+	for _, param := range []string{
+		"", "quiet=1", "mitigations=none", "resume=", "resume=sda2", "loglevel=8",
+		"loglevel=x", "i915.modeset=2", "a b", "x=$(reboot)", "x=`id`", "x=\"1\"", "x;y",
+	} {
+		assert.NotNil(t, checkKernelParam(param), param)
+	}
+}
+
+func Test_getKernelParams(t *testing.T) {
+	assert.Equal(t, []string{"quiet", "splash", "$vt_handoff"},
+		getKernelParams(map[string]string{grubCmdlineLinuxDefault: `"quiet  splash $vt_handoff"`}))
+	assert.Equal(t, []string{"quiet"},
+		getKernelParams(map[string]string{grubCmdlineLinuxDefault: `'quiet'`}))
+	assert.Len(t, getKernelParams(map[string]string{grubCmdlineLinuxDefault: `""`}), 0)
+	assert.Len(t, getKernelParams(map[string]string{}), 0)
+	assert.Equal(t, []string{"quiet", `acpi_osi="Windows 2013"`, `a\b`},
+		getKernelParams(map[string]string{grubCmdlineLinuxDefault: `"quiet acpi_osi=\"Windows 2013\" a\\b"`}))
+	assert.Equal(t, []string{`acpi_osi="Windows 2013"`, "$x"},
+		getKernelParams(map[string]string{grubCmdlineLinuxDefault: `'acpi_osi="Windows 2013" $x'`}))
+}
+
+// 测试：修改参数后文件中已有的参数保持不变
+func Test_kernelCmdlineRoundTrip(t *testing.T) {
+	for _, value := range []string{
+		`"quiet splash acpi_osi=\"Windows 2013\" $vt_handoff"`,
+		`"quiet acpi_osi=! acpi_osi=\"Windows 2013\" x=a\\b"`,
+		`'quiet acpi_osi="Windows 2013" $vt_handoff'`,
+		`""`,
+	} {
+		cmdline := parseKernelCmdline(value)
+		assert.Equal(t, value, cmdline.String())
+
+		// 多次修改不会重复转义
+		for i := 0; i < 3; i++ {
+			params := addKernelParam(cmdline.params, "nomodeset")
+			params, _ = removeKernelParam(params, "nomodeset")
+			task := getModifyTaskKernelParams(cmdline.withParams(params), true)
+			grubParams := make(map[string]string)
+			task.paramsModifyFunc(grubParams)
+			assert.Equal(t, value, grubParams[grubCmdlineLinuxDefault])
+			cmdline = parseKernelCmdline(grubParams[grubCmdlineLinuxDefault])
+		}
+	}
+
+	cmdline := parseKernelCmdline(`'quiet $vt_handoff'`)
+	cmdline = cmdline.withParams(addKernelParam(cmdline.params, "nomodeset"))
+	assert.Equal(t, `'quiet $vt_handoff nomodeset'`, cmdline.String())
+
+	cmdline = parseKernelCmdline(`quiet`)
+	cmdline = cmdline.withParams(addKernelParam(cmdline.params, "splash"))
+	assert.Equal(t, `"quiet splash"`, cmdline.String())
+}
+
+func Test_addRemoveKernelParam(t *testing.T) {
+	list := []string{"quiet", "splash", "mitigations=auto"}
+	assert.Equal(t, []string{"quiet", "splash", "mitigations=auto", "nomodeset"},
+		addKernelParam(list, "nomodeset"))
+	assert.Equal(t, []string{"quiet", "splash", "mitigations=off"},
+		addKernelParam(list, "mitigations=off"))
+	assert.Equal(t, []string{"quiet", "splash", "mitigations=auto"}, list)
+
+	result, removed := removeKernelParam(list, "mitigations")
+	assert.True(t, removed)
+	assert.Equal(t, []string{"quiet", "splash"}, result)
+
+	_, removed = removeKernelParam(list, "mitigations=off")
+	assert.False(t, removed)
+
+	result, removed = removeKernelParam(list, "quiet")
+	assert.True(t, removed)
+	assert.Equal(t, []string{"splash", "mitigations=auto"}, result)
+}
+
+func Test_normalizeKernelParams(t *testing.T) {
+	result, err := normalizeKernelParams([]string{"quiet", "loglevel=3", "splash", "loglevel=4"}, kernelCmdline{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"quiet", "loglevel=4", "splash"}, result)
+
+	_, err = normalizeKernelParams([]string{"quiet", "loglevel=9"}, kernelCmdline{})
+	assert.NotNil(t, err)
+
+	// 文件中已有的参数不检查
+	cmdline := parseKernelCmdline(`"quiet $vt_handoff acpi_osi=\"Windows 2013\""`)
+	result, err = normalizeKernelParams(append(cmdline.params, "nomodeset"), cmdline)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"quiet", "$vt_handoff", `acpi_osi="Windows 2013"`, "nomodeset"}, result)
+	_, err = normalizeKernelParams([]string{"$vt_handoff"}, kernelCmdline{})
+	assert.NotNil(t, err)
+
+	result, err = normalizeKernelParams(nil, kernelCmdline{})
+	assert.Nil(t, err)
+	assert.Len(t, result, 0)
+
+	assert.True(t, isKernelParamsEqual(nil, []string{}))
+	assert.False(t, isKernelParamsEqual([]string{"quiet"}, []string{"splash"}))
+}
+
+func Test_getModifyTaskKernelParams(t *testing.T) {
+	task := getModifyTaskKernelParams(kernelCmdline{quote: '"'}.withParams([]string{"quiet", "nomodeset"}), true)
+	assert.True(t, task.backup)
+	params := make(map[string]string)
+	task.paramsModifyFunc(params)
+	assert.Equal(t, `"quiet nomodeset"`, params[grubCmdlineLinuxDefault])
+	assert.Equal(t, []string{"quiet", "nomodeset"}, getKernelParams(params))
+}
+
+func Test_backupGrubParams(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grub2-backup")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "grub")
+	backupDir := filepath.Join(dir, "backups")
+	now := time.Date(2020, 3, 2, 8, 0, 0, 0, time.Local)
+	for i := 0; i < grubParamsBackupMax+2; i++ {
+		err = ioutil.WriteFile(filename, []byte{byte('a' + i)}, 0644)
+		require.Nil(t, err)
+		err = backupGrubParams(filename, backupDir, now.Add(time.Duration(i)*time.Second))
+		require.Nil(t, err)
+	}
+
+	backups, err := listGrubParamsBackups(backupDir)
+	require.Nil(t, err)
+	require.Len(t, backups, grubParamsBackupMax)
+	content, err := ioutil.ReadFile(backups[0])
+	require.Nil(t, err)
+	assert.Equal(t, "c", string(content))
+	content, err = ioutil.ReadFile(backups[len(backups)-1])
+	require.Nil(t, err)
+	assert.Equal(t, string(byte('a'+grubParamsBackupMax+1)), string(content))
+}
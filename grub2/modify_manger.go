@@ -24,6 +24,7 @@ import (
 	"os/exec"
 	"strings"
 	"sync"
+	"time"
 
 	"pkg.deepin.io/dde/daemon/grub_common"
 )
@@ -89,6 +90,7 @@ func (m *modifyManager) start(tasks ...modifyTask) {
 	logger.Debug("modifyManager.start len(tasks):", len(tasks))
 	var adjustTheme bool
 	var adjustThemeLang string
+	var backup bool
 	for _, task := range tasks {
 		f := task.paramsModifyFunc
 		if f != nil {
@@ -98,6 +100,15 @@ func (m *modifyManager) start(tasks ...modifyTask) {
 			adjustTheme = true
 			adjustThemeLang = task.adjustThemeLang
 		}
+		if task.backup {
+			backup = true
+		}
+	}
+	if backup {
+		err := backupGrubParams(grubParamsFile, grubParamsBackupDir, time.Now())
+		if err != nil {
+			logger.Warning("failed to backup grub params:", err)
+		}
 	}
 	err := writeGrubParams(params)
 	if err != nil {
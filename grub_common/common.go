@@ -25,8 +25,13 @@ const (
 )
 
 func LoadGrubParams() (map[string]string, error) {
+	return LoadGrubParamsFile(GrubParamsFile)
+}
+
+// LoadGrubParamsFile 读取和 /etc/default/grub 格式相同的文件，比如它的备份
+func LoadGrubParamsFile(filename string) (map[string]string, error) {
 	params := make(map[string]string)
-	f, err := os.Open(GrubParamsFile)
+	f, err := os.Open(filename)
 	if err != nil {
 		return params, err
 	}
@@ -0,0 +1,330 @@
+/*
+ * Copyright (C) 2017 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package housekeeping
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"syscall"
+	"time"
+
+	"pkg.deepin.io/lib/xdg/basedir"
+)
+
+const (
+	actionEmptyTrash      = "empty-trash"
+	actionPruneThumbnails = "prune-thumbnails"
+	actionPruneCaches     = "prune-caches"
+	actionRotateLogs      = "rotate-logs"
+
+	thumbnailMaxAge  = 30 * 24 * time.Hour
+	cacheMaxAge      = 30 * 24 * time.Hour
+	rotatedLogMaxAge = 7 * 24 * time.Hour
+
+	// the log larger than logMaxSize is cut, only the last logKeepSize is kept
+	logMaxSize  = 10 * 1024 * 1024
+	logKeepSize = 1024 * 1024
+)
+
+// cacheDirsToPrune are the directories under ~/.cache which only contain the
+// data that can be downloaded or generated again, other applications may keep
+// important data in ~/.cache, so they are not touched.
+var cacheDirsToPrune = []string{
+	"pip",
+	"go-build",
+	"yarn",
+	"mozilla/firefox",
+	"google-chrome",
+	"chromium",
+	"deepin/deepin-browser",
+	"fontconfig",
+}
+
+type cleanupFunc func(now time.Time) (freed uint64, err error)
+
+var cleanupActions = map[string]cleanupFunc{
+	actionEmptyTrash:      emptyTrash,
+	actionPruneThumbnails: pruneThumbnails,
+	actionPruneCaches:     pruneCaches,
+	actionRotateLogs:      rotateLogs,
+}
+
+func getCleanupActionNames() []string {
+	var names []string
+	for name := range cleanupActions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func runCleanupAction(name string) (uint64, error) {
+	fn, ok := cleanupActions[name]
+	if !ok {
+		return 0, fmt.Errorf("invalid action %q", name)
+	}
+	return fn(time.Now())
+}
+
+func getTrashDir() string {
+	return filepath.Join(basedir.GetUserDataDir(), "Trash")
+}
+
+func getThumbnailsDir() string {
+	return filepath.Join(basedir.GetUserCacheDir(), "thumbnails")
+}
+
+func emptyTrash(now time.Time) (uint64, error) {
+	return emptyTrashDir(getTrashDir())
+}
+
+func emptyTrashDir(trashDir string) (uint64, error) {
+	var freed uint64
+	for _, sub := range []string{"files", "info", "expunged"} {
+		dir := filepath.Join(trashDir, sub)
+		fileInfos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return freed, err
+		}
+		for _, fileInfo := range fileInfos {
+			filename := filepath.Join(dir, fileInfo.Name())
+			size := getDirSize(filename)
+			err = os.RemoveAll(filename)
+			if err != nil {
+				logger.Warning(err)
+				continue
+			}
+			freed += size
+		}
+	}
+	return freed, nil
+}
+
+func pruneThumbnails(now time.Time) (uint64, error) {
+	return removeOldFiles(getThumbnailsDir(), now.Add(-thumbnailMaxAge), nil)
+}
+
+// pruneCaches removes the cache files in cacheDirsToPrune which are not
+// modified for cacheMaxAge.
+func pruneCaches(now time.Time) (uint64, error) {
+	return pruneCacheDirs(basedir.GetUserCacheDir(), cacheDirsToPrune, now)
+}
+
+func pruneCacheDirs(cacheDir string, dirs []string, now time.Time) (uint64, error) {
+	var freed uint64
+	for _, dir := range dirs {
+		size, err := removeOldFiles(filepath.Join(cacheDir, dir), now.Add(-cacheMaxAge), nil)
+		freed += size
+		if err != nil {
+			return freed, err
+		}
+	}
+	return freed, nil
+}
+
+func rotateLogs(now time.Time) (uint64, error) {
+	return rotateLogsInDir(basedir.GetUserCacheDir(), now)
+}
+
+var rotatedLogRegexp = regexp.MustCompile(`\.log(\.\d+|\.old)(\.gz|\.xz)?$`)
+
+// rotateLogsInDir removes the rotated logs which are older than
+// rotatedLogMaxAge, and cuts the head of the large logs in place, so that the
+// programs writing them are not affected.
+func rotateLogsInDir(dir string, now time.Time) (uint64, error) {
+	var freed uint64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) || os.IsPermission(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		name := info.Name()
+		if rotatedLogRegexp.MatchString(name) {
+			if info.ModTime().After(now.Add(-rotatedLogMaxAge)) {
+				return nil
+			}
+			err = os.Remove(path)
+			if err != nil {
+				logger.Warning(err)
+				return nil
+			}
+			freed += uint64(info.Size())
+		} else if filepath.Ext(name) == ".log" && info.Size() > logMaxSize {
+			size, err := cutLogHead(path, logKeepSize)
+			if err != nil {
+				logger.Warning(err)
+				return nil
+			}
+			freed += size
+		}
+		return nil
+	})
+	return freed, err
+}
+
+// cutLogHead keeps the last keepSize bytes of the log, from the start of a
+// line, and returns the disk space freed. The size of the file is not used,
+// because the program which does not open the log with O_APPEND keeps writing
+// at its old offset, and the file becomes a sparse file which is large but
+// does not use so much space.
+func cutLogHead(filename string, keepSize int64) (uint64, error) {
+	f, err := os.OpenFile(filename, os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	usedBefore, err := getFileDiskUsage(f)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = f.Seek(-keepSize, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	tail, err := ioutil.ReadAll(f)
+	if err != nil {
+		return 0, err
+	}
+	if idx := bytes.IndexByte(tail, '\n'); idx != -1 {
+		tail = tail[idx+1:]
+	}
+
+	err = f.Truncate(0)
+	if err != nil {
+		return 0, err
+	}
+	_, err = f.WriteAt(tail, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	usedAfter, err := getFileDiskUsage(f)
+	if err != nil || usedAfter > usedBefore {
+		return 0, err
+	}
+	return usedBefore - usedAfter, nil
+}
+
+// getFileDiskUsage returns the disk space used by the file in bytes.
+func getFileDiskUsage(f *os.File) (uint64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return uint64(info.Size()), nil
+	}
+	// st_blocks is in 512-byte units
+	return uint64(stat.Blocks) * 512, nil
+}
+
+// removeOldFiles removes the regular files under dir which are modified
+// before the time, the directories that skip returns true are not entered.
+func removeOldFiles(dir string, before time.Time, skip func(path string) bool) (uint64, error) {
+	var freed uint64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) || os.IsPermission(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			if skip != nil && skip(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() || !info.ModTime().Before(before) {
+			return nil
+		}
+		err = os.Remove(path)
+		if err != nil {
+			logger.Warning(err)
+			return nil
+		}
+		freed += uint64(info.Size())
+		return nil
+	})
+	return freed, err
+}
+
+func getDirSize(dir string) uint64 {
+	var size uint64
+	_ = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+	return size
+}
+
+type DirSize struct {
+	Path string
+	Size uint64
+}
+
+// getLargestDirs returns the count largest sub directories of dir, the
+// symbolic links are not followed.
+func getLargestDirs(dir string, count int) ([]DirSize, error) {
+	fileInfos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []DirSize
+	for _, fileInfo := range fileInfos {
+		if !fileInfo.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, fileInfo.Name())
+		result = append(result, DirSize{
+			Path: path,
+			Size: getDirSize(path),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Size > result[j].Size
+	})
+	if count > 0 && len(result) > count {
+		result = result[:count]
+	}
+	return result, nil
+}
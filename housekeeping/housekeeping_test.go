@@ -0,0 +1,187 @@
+/*
+ * Copyright (C) 2017 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package housekeeping
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_PolicyIsLow(t *testing.T) {
+	p := &Policy{MinFreeBytes: 100}
+	assert.True(t, p.isLow(1000, 99))
+	assert.False(t, p.isLow(1000, 100))
+
+	p = &Policy{MinFreePercent: 10}
+	assert.True(t, p.isLow(1000, 99))
+	assert.False(t, p.isLow(1000, 100))
+	assert.False(t, p.isLow(0, 0))
+
+	p = &Policy{MinFreePercent: 10, MinFreeBytes: 200}
+	assert.True(t, p.isLow(1000, 150))
+}
+
+func Test_ConfigCheck(t *testing.T) {
+	assert.NoError(t, getDefaultConfig().check())
+	assert.Error(t, (&Config{Policies: []*Policy{{Path: ""}}}).check())
+	assert.Error(t, (&Config{Policies: []*Policy{{Path: "/"}}}).check())
+	assert.Error(t, (&Config{Policies: []*Policy{{Path: "/", MinFreePercent: 100}}}).check())
+	assert.NoError(t, (&Config{Policies: []*Policy{{Path: "/", MinFreePercent: 5}}}).check())
+}
+
+func writeTestFile(t *testing.T, filename string, size int, modTime time.Time) {
+	err := os.MkdirAll(filepath.Dir(filename), 0755)
+	assert.NoError(t, err)
+	err = ioutil.WriteFile(filename, make([]byte, size), 0644)
+	assert.NoError(t, err)
+	err = os.Chtimes(filename, modTime, modTime)
+	assert.NoError(t, err)
+}
+
+func Test_removeOldFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "housekeeping")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	old := now.Add(-48 * time.Hour)
+	writeTestFile(t, filepath.Join(dir, "a/old"), 10, old)
+	writeTestFile(t, filepath.Join(dir, "a/new"), 20, now)
+	writeTestFile(t, filepath.Join(dir, "skip/old"), 30, old)
+
+	freed, err := removeOldFiles(dir, now.Add(-24*time.Hour), func(path string) bool {
+		return path == filepath.Join(dir, "skip")
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), freed)
+	_, err = os.Stat(filepath.Join(dir, "a/old"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dir, "a/new"))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, "skip/old"))
+	assert.NoError(t, err)
+}
+
+func Test_pruneCacheDirs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "housekeeping")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	old := now.Add(-cacheMaxAge - time.Hour)
+	writeTestFile(t, filepath.Join(dir, "pip/http/old"), 10, old)
+	writeTestFile(t, filepath.Join(dir, "pip/http/new"), 20, now)
+	writeTestFile(t, filepath.Join(dir, "app/data"), 30, old)
+
+	freed, err := pruneCacheDirs(dir, []string{"pip", "not-exist"}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), freed)
+	_, err = os.Stat(filepath.Join(dir, "pip/http/old"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dir, "pip/http/new"))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, "app/data"))
+	assert.NoError(t, err)
+}
+
+func Test_emptyTrashDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "housekeeping")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	writeTestFile(t, filepath.Join(dir, "files/a"), 10, now)
+	writeTestFile(t, filepath.Join(dir, "files/b/c"), 20, now)
+	writeTestFile(t, filepath.Join(dir, "info/a.trashinfo"), 5, now)
+
+	freed, err := emptyTrashDir(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(35), freed)
+	fileInfos, err := ioutil.ReadDir(filepath.Join(dir, "files"))
+	assert.NoError(t, err)
+	assert.Empty(t, fileInfos)
+}
+
+func Test_rotateLogsInDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "housekeeping")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	old := now.Add(-rotatedLogMaxAge - time.Hour)
+	writeTestFile(t, filepath.Join(dir, "app/app.log.1"), 10, old)
+	writeTestFile(t, filepath.Join(dir, "app/app.log.2.gz"), 20, now)
+	writeTestFile(t, filepath.Join(dir, "app/small.log"), 30, old)
+
+	bigLog := filepath.Join(dir, "app/big.log")
+	line := strings.Repeat("x", 99) + "\n"
+	content := strings.Repeat(line, logMaxSize/len(line)+1)
+	err = ioutil.WriteFile(bigLog, []byte(content), 0644)
+	assert.NoError(t, err)
+
+	bigLogUsage := getTestFileDiskUsage(t, bigLog)
+	freed, err := rotateLogsInDir(dir, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 10+bigLogUsage-getTestFileDiskUsage(t, bigLog), freed)
+	_, err = os.Stat(filepath.Join(dir, "app/app.log.1"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dir, "app/app.log.2.gz"))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, "app/small.log"))
+	assert.NoError(t, err)
+
+	data, err := ioutil.ReadFile(bigLog)
+	assert.NoError(t, err)
+	assert.True(t, len(data) <= logKeepSize)
+	assert.True(t, strings.HasPrefix(string(data), line))
+}
+
+func getTestFileDiskUsage(t *testing.T, filename string) uint64 {
+	f, err := os.Open(filename)
+	assert.NoError(t, err)
+	defer f.Close()
+	usage, err := getFileDiskUsage(f)
+	assert.NoError(t, err)
+	return usage
+}
+
+func Test_getLargestDirs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "housekeeping")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	writeTestFile(t, filepath.Join(dir, "a/1"), 10, now)
+	writeTestFile(t, filepath.Join(dir, "b/1"), 20, now)
+	writeTestFile(t, filepath.Join(dir, "b/c/1"), 20, now)
+	writeTestFile(t, filepath.Join(dir, "c/1"), 30, now)
+	writeTestFile(t, filepath.Join(dir, "file"), 100, now)
+
+	result, err := getLargestDirs(dir, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []DirSize{
+		{Path: filepath.Join(dir, "b"), Size: 40},
+		{Path: filepath.Join(dir, "c"), Size: 30},
+	}, result)
+}
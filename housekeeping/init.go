@@ -20,20 +20,12 @@
 package housekeeping
 
 import (
-	"os"
 	"time"
 
 	"github.com/godbus/dbus"
 	notifications "github.com/linuxdeepin/go-dbus-factory/org.freedesktop.notifications"
 	"pkg.deepin.io/dde/daemon/loader"
-	. "pkg.deepin.io/lib/gettext"
 	"pkg.deepin.io/lib/log"
-	"pkg.deepin.io/lib/utils"
-)
-
-const (
-	// 500MB
-	fsMinLeftSpace = 1024 * 1024 * 500
 )
 
 func init() {
@@ -42,6 +34,7 @@ func init() {
 
 type Daemon struct {
 	*loader.ModuleBase
+	manager  *Manager
 	ticker   *time.Ticker
 	stopChan chan struct{}
 }
@@ -65,6 +58,19 @@ func (d *Daemon) Start() error {
 		return nil
 	}
 
+	service := loader.GetService()
+	manager := newManager(service)
+	err := service.Export(dbusPath, manager)
+	if err != nil {
+		return err
+	}
+	err = service.RequestName(dbusServiceName)
+	if err != nil {
+		_ = service.StopExport(manager)
+		return err
+	}
+	d.manager = manager
+
 	d.ticker = time.NewTicker(time.Minute * 1)
 	d.stopChan = make(chan struct{})
 	go func() {
@@ -75,22 +81,7 @@ func (d *Daemon) Start() error {
 					logger.Error("Invalid ticker event")
 					return
 				}
-
-				fs, err := utils.QueryFilesytemInfo(os.Getenv("HOME"))
-				if err != nil {
-					logger.Error("Failed to get filesystem info:", err)
-					break
-				}
-				logger.Debug("Home filesystem info(total, free, avail):",
-					fs.TotalSize, fs.FreeSize, fs.AvailSize)
-				if fs.AvailSize > fsMinLeftSpace {
-					break
-				}
-				err = sendNotify("dialog-warning", "",
-					Tr("Insufficient disk space, please clean up in time!"))
-				if err != nil {
-					logger.Warning(err)
-				}
+				manager.check()
 			case <-d.stopChan:
 				logger.Debug("Stop housekeeping")
 				if d.ticker != nil {
@@ -109,6 +100,18 @@ func (d *Daemon) Stop() error {
 		close(d.stopChan)
 		d.stopChan = nil
 	}
+	if d.manager != nil {
+		service := loader.GetService()
+		err := service.StopExport(d.manager)
+		if err != nil {
+			logger.Warning(err)
+		}
+		err = service.ReleaseName(dbusServiceName)
+		if err != nil {
+			logger.Warning(err)
+		}
+		d.manager = nil
+	}
 	return nil
 }
 
@@ -0,0 +1,258 @@
+/*
+ * Copyright (C) 2017 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package housekeeping
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus"
+	"pkg.deepin.io/dde/daemon/session/common"
+	"pkg.deepin.io/lib/dbusutil"
+	. "pkg.deepin.io/lib/gettext"
+	"pkg.deepin.io/lib/utils"
+)
+
+//go:generate dbusutil-gen em -type Manager
+
+const (
+	dbusServiceName = "com.deepin.daemon.Housekeeping"
+	dbusPath        = "/com/deepin/daemon/Housekeeping"
+	dbusInterface   = dbusServiceName
+)
+
+type Manager struct {
+	service *dbusutil.Service
+
+	mu      sync.Mutex
+	config  *Config
+	snoozed map[string]time.Time // key is the expanded policy path
+
+	actionMu sync.Mutex
+
+	// nolint
+	signals *struct {
+		LowDiskSpace struct {
+			path  string
+			avail uint64
+		}
+	}
+}
+
+type Usage struct {
+	Path         string
+	Total        uint64
+	Free         uint64
+	Avail        uint64
+	UsedPercent  float64
+	Low          bool
+	SnoozedUntil int64 // unix time in seconds, 0 if not snoozed
+}
+
+func newManager(service *dbusutil.Service) *Manager {
+	m := &Manager{
+		service: service,
+		snoozed: make(map[string]time.Time),
+	}
+	cfg, err := loadConfig(configFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warning("failed to load config:", err)
+		}
+		cfg = getDefaultConfig()
+	}
+	m.config = cfg
+	return m
+}
+
+func (*Manager) GetInterfaceName() string {
+	return dbusInterface
+}
+
+func (m *Manager) getPolicies() []*Policy {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.config.Policies
+}
+
+func (m *Manager) getSnoozedUntil(path string, now time.Time) time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	until, ok := m.snoozed[path]
+	if ok && !now.Before(until) {
+		delete(m.snoozed, path)
+		return time.Time{}
+	}
+	return until
+}
+
+func (m *Manager) getUsages(now time.Time) []*Usage {
+	var result []*Usage
+	for _, p := range m.getPolicies() {
+		path := common.ExpandPath(p.Path)
+		fs, err := utils.QueryFilesytemInfo(path)
+		if err != nil {
+			logger.Warningf("failed to get filesystem info of %q: %v", path, err)
+			continue
+		}
+		total, free, avail := uint64(fs.TotalSize), uint64(fs.FreeSize), uint64(fs.AvailSize)
+		usage := &Usage{
+			Path:  path,
+			Total: total,
+			Free:  free,
+			Avail: avail,
+			Low:   p.isLow(total, avail),
+		}
+		if total > 0 {
+			usage.UsedPercent = float64(total-free) * 100 / float64(total)
+		}
+		if until := m.getSnoozedUntil(path, now); !until.IsZero() {
+			usage.SnoozedUntil = until.Unix()
+		}
+		result = append(result, usage)
+	}
+	return result
+}
+
+// check warns every filesystem which is low on space and not snoozed.
+func (m *Manager) check() {
+	for _, usage := range m.getUsages(time.Now()) {
+		logger.Debugf("filesystem info of %q(total, free, avail): %d %d %d",
+			usage.Path, usage.Total, usage.Free, usage.Avail)
+		if !usage.Low || usage.SnoozedUntil != 0 {
+			continue
+		}
+
+		err := m.service.Emit(m, "LowDiskSpace", usage.Path, usage.Avail)
+		if err != nil {
+			logger.Warning(err)
+		}
+		// the path is shown as the summary, so that the translations of the
+		// body are kept
+		err = sendNotify("dialog-warning", usage.Path,
+			Tr("Insufficient disk space, please clean up in time!"))
+		if err != nil {
+			logger.Warning(err)
+		}
+	}
+}
+
+func (m *Manager) GetUsage() (usage string, busErr *dbus.Error) {
+	data, err := json.Marshal(m.getUsages(time.Now()))
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+func (m *Manager) GetPolicies() (policies string, busErr *dbus.Error) {
+	m.mu.Lock()
+	data, err := json.Marshal(m.config.Policies)
+	m.mu.Unlock()
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+func (m *Manager) SetPolicies(policies string) *dbus.Error {
+	var cfg Config
+	err := json.Unmarshal([]byte(policies), &cfg.Policies)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	err = cfg.check()
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	err = saveConfig(configFile, &cfg)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	m.config = &cfg
+	return nil
+}
+
+// Snooze stops warning the filesystem of path for seconds, all policies if
+// path is empty.
+func (m *Manager) Snooze(path string, seconds uint32) *dbus.Error {
+	until := time.Now().Add(time.Duration(seconds) * time.Second)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if path != "" {
+		path = common.ExpandPath(path)
+		found := false
+		for _, p := range m.config.Policies {
+			if common.ExpandPath(p.Path) == path {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return dbusutil.ToError(fmt.Errorf("no policy for %q", path))
+		}
+		m.snoozed[path] = until
+		return nil
+	}
+
+	for _, p := range m.config.Policies {
+		m.snoozed[common.ExpandPath(p.Path)] = until
+	}
+	return nil
+}
+
+func (*Manager) ListActions() (actions []string, busErr *dbus.Error) {
+	return getCleanupActionNames(), nil
+}
+
+func (m *Manager) RunAction(action string) (freed uint64, busErr *dbus.Error) {
+	// the actions walk through the cache directory, do not run them at the
+	// same time.
+	m.actionMu.Lock()
+	defer m.actionMu.Unlock()
+
+	freed, err := runCleanupAction(action)
+	logger.Infof("run action %s, freed %d bytes", action, freed)
+	if err != nil {
+		return freed, dbusutil.ToError(err)
+	}
+	return freed, nil
+}
+
+func (m *Manager) ListLargestDirs(path string, count int32) (dirs string, busErr *dbus.Error) {
+	if path == "" {
+		return "", dbusutil.ToError(errors.New("empty path"))
+	}
+	result, err := getLargestDirs(common.ExpandPath(path), int(count))
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,112 @@
+/*
+ * Copyright (C) 2017 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package housekeeping
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"pkg.deepin.io/lib/xdg/basedir"
+)
+
+const (
+	// 500MB
+	fsMinLeftSpace = 1024 * 1024 * 500
+)
+
+var configFile = filepath.Join(basedir.GetUserConfigDir(), "deepin/dde-daemon/housekeeping.json")
+
+// Policy watches the filesystem which Path is on, it is low on space if the
+// available space is less than MinFreePercent of the total size or less than
+// MinFreeBytes, the zero threshold is not checked.
+type Policy struct {
+	Path           string
+	MinFreePercent float64
+	MinFreeBytes   uint64
+}
+
+type Config struct {
+	Policies []*Policy
+}
+
+func getDefaultConfig() *Config {
+	return &Config{
+		Policies: []*Policy{
+			{Path: "~", MinFreeBytes: fsMinLeftSpace},
+		},
+	}
+}
+
+func loadConfig(filename string) (*Config, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	err = json.Unmarshal(data, &cfg)
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.check()
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func saveConfig(filename string, cfg *Config) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0644)
+}
+
+func (cfg *Config) check() error {
+	for _, p := range cfg.Policies {
+		if p == nil || p.Path == "" {
+			return errors.New("empty policy path")
+		}
+		if p.MinFreePercent < 0 || p.MinFreePercent >= 100 {
+			return fmt.Errorf("policy %q: invalid percent %v", p.Path, p.MinFreePercent)
+		}
+		if p.MinFreePercent == 0 && p.MinFreeBytes == 0 {
+			return fmt.Errorf("policy %q: no threshold", p.Path)
+		}
+	}
+	return nil
+}
+
+func (p *Policy) isLow(total, avail uint64) bool {
+	if p.MinFreeBytes > 0 && avail < p.MinFreeBytes {
+		return true
+	}
+	if p.MinFreePercent > 0 && total > 0 &&
+		float64(avail)*100/float64(total) < p.MinFreePercent {
+		return true
+	}
+	return false
+}
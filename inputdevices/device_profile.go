@@ -0,0 +1,406 @@
+/*
+ * Copyright (C) 2016 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package inputdevices
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	maxButtonMapLen = 32
+	minScrollSpeed  = 1
+	maxScrollSpeed  = 100
+)
+
+// deviceProfile 是单个设备的配置，字段为空时使用设备类型（鼠标、触摸板等）的配置
+type deviceProfile struct {
+	LeftHanded         *bool    `json:",omitempty"`
+	NaturalScroll      *bool    `json:",omitempty"`
+	MotionAcceleration *float64 `json:",omitempty"`
+	// 滚动速度，只对触摸板有效，对应触摸板的 DeltaScroll
+	ScrollSpeed *int32 `json:",omitempty"`
+	// 按钮映射，第 i 个元素是物理按钮 i+1 映射到的逻辑按钮，0 表示禁用
+	ButtonMap []uint8 `json:",omitempty"`
+}
+
+func (p *deviceProfile) isEmpty() bool {
+	return p.LeftHanded == nil && p.NaturalScroll == nil &&
+		p.MotionAcceleration == nil && p.ScrollSpeed == nil &&
+		len(p.ButtonMap) == 0
+}
+
+func (p *deviceProfile) check() error {
+	if p.MotionAcceleration != nil {
+		v := *p.MotionAcceleration
+		if v < -1 || v > 1 {
+			return fmt.Errorf("invalid motion acceleration %v", v)
+		}
+	}
+	if p.ScrollSpeed != nil {
+		v := *p.ScrollSpeed
+		if v < minScrollSpeed || v > maxScrollSpeed {
+			return fmt.Errorf("invalid scroll speed %v", v)
+		}
+	}
+	if len(p.ButtonMap) > maxButtonMapLen {
+		return fmt.Errorf("button map is too long: %d", len(p.ButtonMap))
+	}
+	return nil
+}
+
+// 下面的方法允许 p 为 nil，这时返回设备类型的配置 def
+
+func (p *deviceProfile) leftHanded(def bool) bool {
+	if p == nil || p.LeftHanded == nil {
+		return def
+	}
+	return *p.LeftHanded
+}
+
+func (p *deviceProfile) naturalScroll(def bool) bool {
+	if p == nil || p.NaturalScroll == nil {
+		return def
+	}
+	return *p.NaturalScroll
+}
+
+func (p *deviceProfile) motionAcceleration(def float32) float32 {
+	if p == nil || p.MotionAcceleration == nil {
+		return def
+	}
+	return float32(*p.MotionAcceleration)
+}
+
+func (p *deviceProfile) scrollSpeed(def int32) int32 {
+	if p == nil || p.ScrollSpeed == nil {
+		return def
+	}
+	return *p.ScrollSpeed
+}
+
+// 配置的键是 "vendor:product" 或者 "vendor:product:name"，vendor 和 product 是 4 位小写十六进制数，
+// 同一型号的多个设备名称不同时可以用后者区分，匹配时优先使用带名称的配置。
+var deviceKeyRegexp = regexp.MustCompile(`^[0-9a-f]{4}:[0-9a-f]{4}(:.+)?$`)
+
+func checkDeviceKey(key string) error {
+	if !deviceKeyRegexp.MatchString(key) {
+		return fmt.Errorf("invalid device key %q", key)
+	}
+	return nil
+}
+
+func getDeviceKey(vendor, product uint16) string {
+	return fmt.Sprintf("%04x:%04x", vendor, product)
+}
+
+// parseProductId 解析 X 输入设备属性 "Device Product ID"，它是两个 32 位整数：vendor 和 product
+func parseProductId(data []byte) (vendor, product uint16, ok bool) {
+	if len(data) < 8 {
+		return 0, 0, false
+	}
+	vendor = uint16(binary.LittleEndian.Uint32(data[0:4]))
+	product = uint16(binary.LittleEndian.Uint32(data[4:8]))
+	return vendor, product, true
+}
+
+type deviceProfiles struct {
+	mu       sync.Mutex
+	filename string
+	profiles map[string]*deviceProfile
+	// 设备 id 到 "vendor:product" 的缓存，设备变化时清空
+	idKeyCache map[int32]string
+	// 根据设备 id 获取 "vendor:product"，测试时可以替换
+	queryDeviceKey func(id int32) (string, bool)
+}
+
+func newDeviceProfiles(filename string) *deviceProfiles {
+	return &deviceProfiles{
+		filename:       filename,
+		profiles:       make(map[string]*deviceProfile),
+		idKeyCache:     make(map[int32]string),
+		queryDeviceKey: queryDeviceKey,
+	}
+}
+
+func (dp *deviceProfiles) load() error {
+	data, err := ioutil.ReadFile(dp.filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var profiles map[string]*deviceProfile
+	err = json.Unmarshal(data, &profiles)
+	if err != nil {
+		return err
+	}
+
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+	for key, profile := range profiles {
+		if profile == nil || checkDeviceKey(key) != nil || profile.check() != nil {
+			logger.Warningf("ignore invalid device profile %q", key)
+			continue
+		}
+		dp.profiles[key] = profile
+	}
+	return nil
+}
+
+// 调用者需要持有 mu
+func (dp *deviceProfiles) saveLocked() error {
+	data, err := json.MarshalIndent(dp.profiles, "", "    ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(dp.filename), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dp.filename, data, 0644)
+}
+
+func (dp *deviceProfiles) get(key string) *deviceProfile {
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+	return dp.profiles[key]
+}
+
+// set 设置并保存配置，profile 为空时删除配置，返回原来的配置
+func (dp *deviceProfiles) set(key string, profile *deviceProfile) (*deviceProfile, error) {
+	err := checkDeviceKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if profile != nil {
+		err = profile.check()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+	old := dp.profiles[key]
+	if profile == nil || profile.isEmpty() {
+		delete(dp.profiles, key)
+	} else {
+		dp.profiles[key] = profile
+	}
+	return old, dp.saveLocked()
+}
+
+func (dp *deviceProfiles) clearCache() {
+	dp.mu.Lock()
+	dp.idKeyCache = make(map[int32]string)
+	dp.mu.Unlock()
+}
+
+// 调用者需要持有 mu
+func (dp *deviceProfiles) getDeviceKeyLocked(id int32) string {
+	key, ok := dp.idKeyCache[id]
+	if ok {
+		return key
+	}
+	key, _ = dp.queryDeviceKey(id)
+	dp.idKeyCache[id] = key
+	return key
+}
+
+// getDeviceKey 返回设备的 "vendor:product"，获取不到时返回空字符串
+func (dp *deviceProfiles) getDeviceKey(id int32) string {
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+	return dp.getDeviceKeyLocked(id)
+}
+
+// lookup 查找设备使用的配置，返回配置的键和配置，没有时返回空字符串和 nil
+func (dp *deviceProfiles) lookup(id int32, name string) (string, *deviceProfile) {
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+	key := dp.getDeviceKeyLocked(id)
+	if key == "" {
+		return "", nil
+	}
+	if profile, ok := dp.profiles[key+":"+name]; ok {
+		return key + ":" + name, profile
+	}
+	if profile, ok := dp.profiles[key]; ok {
+		return key, profile
+	}
+	return "", nil
+}
+
+var _deviceProfiles *deviceProfiles
+
+// getDeviceProfile 返回设备的配置，没有时返回 nil
+func getDeviceProfile(id int32, name string) *deviceProfile {
+	if _deviceProfiles == nil {
+		return nil
+	}
+	_, profile := _deviceProfiles.lookup(id, name)
+	return profile
+}
+
+var errInvalidButtonMap = errors.New("invalid button map")
+
+func setButtonMap(id int32, buttonMap []uint8) error {
+	if len(buttonMap) == 0 {
+		return errInvalidButtonMap
+	}
+	args := make([]string, len(buttonMap))
+	for i, button := range buttonMap {
+		args[i] = fmt.Sprint(button)
+	}
+	return doAction(fmt.Sprintf("xinput set-button-map %d %s", id, strings.Join(args, " ")))
+}
+
+// resetButtonMap 恢复设备前 n 个按钮的默认映射
+func resetButtonMap(id int32, n int) error {
+	buttonMap := make([]uint8, n)
+	for i := range buttonMap {
+		buttonMap[i] = uint8(i + 1)
+	}
+	return setButtonMap(id, buttonMap)
+}
+
+type inputDeviceInfo struct {
+	Id   int32
+	Name string
+	// mouse, trackpoint, touchpad 或者 wacom
+	Type string
+	// "vendor:product"，获取不到时为空
+	Key string
+	// 设备使用的配置的键，没有配置时为空
+	ProfileKey string
+}
+
+func (m *Manager) listDevices() []inputDeviceInfo {
+	var result []inputDeviceInfo
+	add := func(id int32, name, typ string) {
+		info := inputDeviceInfo{
+			Id:   id,
+			Name: name,
+			Type: typ,
+		}
+		if _deviceProfiles != nil {
+			info.Key = _deviceProfiles.getDeviceKey(id)
+			info.ProfileKey, _ = _deviceProfiles.lookup(id, name)
+		}
+		result = append(result, info)
+	}
+
+	for _, v := range m.mouse.devInfos {
+		add(v.Id, v.Name, "mouse")
+	}
+	for _, v := range m.trackPoint.devInfos {
+		add(v.Id, v.Name, "trackpoint")
+	}
+	for _, v := range m.tpad.devInfos {
+		add(v.Id, v.Name, "touchpad")
+	}
+	for _, v := range m.wacom.devInfos {
+		add(v.Id, v.Name, "wacom")
+	}
+	return result
+}
+
+// applyDeviceProfiles 重新应用和设备配置有关的设置，设备类型的配置作为默认值
+func (m *Manager) applyDeviceProfiles() {
+	if m.mouse.Exist {
+		m.mouse.enableLeftHanded()
+		m.mouse.enableNaturalScroll()
+		m.mouse.motionAcceleration()
+	}
+	if m.trackPoint.Exist {
+		m.trackPoint.enableLeftHanded()
+		m.trackPoint.motionAcceleration()
+	}
+	if m.tpad.Exist {
+		m.tpad.enableLeftHanded()
+		m.tpad.enableNaturalScroll()
+		m.tpad.motionAcceleration()
+		m.tpad.setScrollDistance()
+	}
+	if m.wacom.Exist {
+		m.wacom.enableLeftHanded()
+	}
+	m.applyButtonMaps()
+}
+
+func (m *Manager) applyButtonMaps() {
+	if globalWayland {
+		return
+	}
+	for _, dev := range m.listDevices() {
+		profile := getDeviceProfile(dev.Id, dev.Name)
+		if profile == nil || len(profile.ButtonMap) == 0 {
+			continue
+		}
+		err := setButtonMap(dev.Id, profile.ButtonMap)
+		if err != nil {
+			logger.Warningf("Set button map for '%v - %v' failed: %v",
+				dev.Id, dev.Name, err)
+		}
+	}
+}
+
+// resetButtonMaps 删除按钮映射后恢复使用配置 key 的设备的默认映射
+func (m *Manager) resetButtonMaps(key string, n int) {
+	if globalWayland {
+		return
+	}
+	for _, dev := range m.listDevices() {
+		if dev.Key == "" || (key != dev.Key && key != dev.Key+":"+dev.Name) {
+			continue
+		}
+		err := resetButtonMap(dev.Id, n)
+		if err != nil {
+			logger.Warningf("Reset button map for '%v - %v' failed: %v",
+				dev.Id, dev.Name, err)
+		}
+	}
+}
+
+func (m *Manager) setDeviceProfile(key string, profile *deviceProfile) error {
+	if _deviceProfiles == nil {
+		return errors.New("device profiles not loaded")
+	}
+	old, err := _deviceProfiles.set(key, profile)
+	if err != nil {
+		return err
+	}
+
+	if old != nil && len(old.ButtonMap) > 0 &&
+		(profile == nil || len(profile.ButtonMap) == 0) {
+		m.resetButtonMaps(key, len(old.ButtonMap))
+	}
+	m.applyDeviceProfiles()
+	return nil
+}
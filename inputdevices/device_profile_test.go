@@ -0,0 +1,106 @@
+/*
+ * Copyright (C) 2016 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package inputdevices
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseProductId(t *testing.T) {
+	vendor, product, ok := parseProductId([]byte{0x6d, 0x04, 0, 0, 0x2b, 0xc5, 0, 0})
+	assert.True(t, ok)
+	assert.Equal(t, "046d:c52b", getDeviceKey(vendor, product))
+
+	_, _, ok = parseProductId([]byte{0x6d, 0x04, 0, 0})
+	assert.False(t, ok)
+}
+
+func Test_checkDeviceKey(t *testing.T) {
+	assert.Nil(t, checkDeviceKey("046d:c52b"))
+	assert.Nil(t, checkDeviceKey("046d:c52b:Logitech USB Receiver"))
+	assert.NotNil(t, checkDeviceKey("046D:C52B"))
+	assert.NotNil(t, checkDeviceKey("46d:c52b"))
+	assert.NotNil(t, checkDeviceKey("046d:c52b:"))
+	assert.NotNil(t, checkDeviceKey(""))
+}
+
+func Test_deviceProfileDefault(t *testing.T) {
+	var p *deviceProfile
+	assert.True(t, p.leftHanded(true))
+	assert.Equal(t, float32(0.5), p.motionAcceleration(0.5))
+
+	leftHanded := false
+	speed := int32(20)
+	p = &deviceProfile{LeftHanded: &leftHanded, ScrollSpeed: &speed}
+	assert.False(t, p.leftHanded(true))
+	assert.True(t, p.naturalScroll(true))
+	assert.Equal(t, int32(20), p.scrollSpeed(10))
+}
+
+func Test_deviceProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "device-profiles")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "profiles.json")
+
+	dp := newDeviceProfiles(filename)
+	dp.queryDeviceKey = func(id int32) (string, bool) {
+		switch id {
+		case 10, 11:
+			return "046d:c52b", true
+		}
+		return "", false
+	}
+	require.Nil(t, dp.load())
+
+	leftHanded := true
+	_, err = dp.set("046d:c52b", &deviceProfile{LeftHanded: &leftHanded})
+	require.Nil(t, err)
+	_, err = dp.set("046d:c52b:Vertical Mouse", &deviceProfile{ButtonMap: []uint8{3, 2, 1}})
+	require.Nil(t, err)
+
+	key, p := dp.lookup(10, "Trackball")
+	assert.Equal(t, "046d:c52b", key)
+	assert.True(t, p.leftHanded(false))
+	key, p = dp.lookup(11, "Vertical Mouse")
+	assert.Equal(t, "046d:c52b:Vertical Mouse", key)
+	assert.Equal(t, []uint8{3, 2, 1}, p.ButtonMap)
+	_, p = dp.lookup(12, "Trackball")
+	assert.Nil(t, p)
+
+	accel := 2.0
+	_, err = dp.set("046d:c52b", &deviceProfile{MotionAcceleration: &accel})
+	assert.NotNil(t, err)
+	_, err = dp.set("bad", &deviceProfile{LeftHanded: &leftHanded})
+	assert.NotNil(t, err)
+
+	dp1 := newDeviceProfiles(filename)
+	require.Nil(t, dp1.load())
+	assert.Equal(t, dp.profiles, dp1.profiles)
+
+	old, err := dp1.set("046d:c52b:Vertical Mouse", &deviceProfile{})
+	require.Nil(t, err)
+	assert.NotNil(t, old)
+	assert.Nil(t, dp1.get("046d:c52b:Vertical Mouse"))
+}
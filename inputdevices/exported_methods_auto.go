@@ -50,7 +50,24 @@ func (v *Keyboard) GetExportedMethods() dbusutil.ExportedMethods {
 	}
 }
 func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
-	return nil
+	return dbusutil.ExportedMethods{
+		{
+			Name:    "GetDeviceProfile",
+			Fn:      v.GetDeviceProfile,
+			InArgs:  []string{"key"},
+			OutArgs: []string{"profileJSON"},
+		},
+		{
+			Name:    "ListDevices",
+			Fn:      v.ListDevices,
+			OutArgs: []string{"devicesJSON"},
+		},
+		{
+			Name:   "SetDeviceProfile",
+			Fn:     v.SetDeviceProfile,
+			InArgs: []string{"key", "profileJSON"},
+		},
+	}
 }
 func (v *Mouse) GetExportedMethods() dbusutil.ExportedMethods {
 	return dbusutil.ExportedMethods{
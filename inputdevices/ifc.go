@@ -20,6 +20,8 @@
 package inputdevices
 
 import (
+	"encoding/json"
+
 	"github.com/godbus/dbus"
 	"pkg.deepin.io/dde/daemon/langselector"
 	"pkg.deepin.io/lib/dbusutil"
@@ -59,6 +61,39 @@ func (w *Wacom) Reset() *dbus.Error {
 	return nil
 }
 
+// ListDevices 返回鼠标、指点杆、触摸板和数位板设备的列表，包括设备的 "vendor:product" 和使用的配置
+func (m *Manager) ListDevices() (devicesJSON string, busErr *dbus.Error) {
+	return toJSON(m.listDevices()), nil
+}
+
+// GetDeviceProfile 返回配置 key 的内容，没有配置时返回 "{}"
+func (m *Manager) GetDeviceProfile(key string) (profileJSON string, busErr *dbus.Error) {
+	err := checkDeviceKey(key)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	profile := _deviceProfiles.get(key)
+	if profile == nil {
+		return "{}", nil
+	}
+	return toJSON(profile), nil
+}
+
+// SetDeviceProfile 设置配置 key 并立即应用到匹配的设备，profileJSON 为空或者 "{}" 时删除配置
+func (m *Manager) SetDeviceProfile(key string, profileJSON string) *dbus.Error {
+	var profile *deviceProfile
+	if profileJSON != "" {
+		profile = &deviceProfile{}
+		err := json.Unmarshal([]byte(profileJSON), profile)
+		if err != nil {
+			return dbusutil.ToError(err)
+		}
+	}
+
+	err := m.setDeviceProfile(key, profile)
+	return dbusutil.ToError(err)
+}
+
 func (kbd *Keyboard) Reset() *dbus.Error {
 	for _, key := range kbd.setting.ListKeys() {
 		kbd.setting.Reset(key)
@@ -85,6 +85,13 @@ func NewManager(service *dbusutil.Service) *Manager {
 		},
 	}
 
+	_deviceProfiles = newDeviceProfiles(filepath.Join(basedir.GetUserConfigDir(),
+		"deepin/dde-daemon/input-device-profiles.json"))
+	err := _deviceProfiles.load()
+	if err != nil {
+		logger.Warning("failed to load device profiles:", err)
+	}
+
 	m.settings = gio.NewSettings(gsSchemaInputDevices)
 	m.WheelSpeed.Bind(m.settings, gsKeyWheelSpeed)
 
@@ -198,6 +205,7 @@ func (m *Manager) init() {
 	m.mouse.handleGSettings()
 	m.trackPoint.init()
 	m.trackPoint.handleGSettings()
+	m.applyButtonMaps()
 
 	m.setWheelSpeed(true)
 	m.handleGSettings()
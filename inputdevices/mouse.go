@@ -172,7 +172,7 @@ func (m *Mouse) disableTouchPad() {
 func (m *Mouse) enableLeftHanded() {
 	enabled := m.LeftHanded.Get()
 	for _, v := range m.devInfos {
-		err := v.EnableLeftHanded(enabled)
+		err := v.EnableLeftHanded(getDeviceProfile(v.Id, v.Name).leftHanded(enabled))
 		if err != nil {
 			logger.Debugf("Enable left handed for '%d - %v' failed: %v",
 				v.Id, v.Name, err)
@@ -183,7 +183,7 @@ func (m *Mouse) enableLeftHanded() {
 func (m *Mouse) enableNaturalScroll() {
 	enabled := m.NaturalScroll.Get()
 	for _, v := range m.devInfos {
-		err := v.EnableNaturalScroll(enabled)
+		err := v.EnableNaturalScroll(getDeviceProfile(v.Id, v.Name).naturalScroll(enabled))
 		if err != nil {
 			logger.Debugf("Enable natural scroll for '%d - %v' failed: %v",
 				v.Id, v.Name, err)
@@ -228,7 +228,7 @@ func (m *Mouse) motionAcceleration() {
 			continue
 		}
 
-		err := v.SetMotionAcceleration(accel)
+		err := v.SetMotionAcceleration(getDeviceProfile(v.Id, v.Name).motionAcceleration(accel))
 		if err != nil {
 			logger.Debugf("Set acceleration for '%d - %v' failed: %v",
 				v.Id, v.Name, err)
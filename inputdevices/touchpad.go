@@ -185,7 +185,7 @@ func (tpad *Touchpad) enable(enabled bool) {
 func (tpad *Touchpad) enableLeftHanded() {
 	enabled := tpad.LeftHanded.Get()
 	for _, v := range tpad.devInfos {
-		err := v.EnableLeftHanded(enabled)
+		err := v.EnableLeftHanded(getDeviceProfile(v.Id, v.Name).leftHanded(enabled))
 		if err != nil {
 			logger.Debugf("Enable left handed '%v - %v' failed: %v",
 				v.Id, v.Name, err)
@@ -197,7 +197,7 @@ func (tpad *Touchpad) enableLeftHanded() {
 func (tpad *Touchpad) enableNaturalScroll() {
 	enabled := tpad.NaturalScroll.Get()
 	for _, v := range tpad.devInfos {
-		err := v.EnableNaturalScroll(enabled)
+		err := v.EnableNaturalScroll(getDeviceProfile(v.Id, v.Name).naturalScroll(enabled))
 		if err != nil {
 			logger.Debugf("Enable natural scroll '%v - %v' failed: %v",
 				v.Id, v.Name, err)
@@ -209,7 +209,8 @@ func (tpad *Touchpad) enableNaturalScroll() {
 func (tpad *Touchpad) setScrollDistance() {
 	delta := tpad.DeltaScroll.Get()
 	for _, v := range tpad.devInfos {
-		err := v.SetScrollDistance(delta, delta)
+		d := getDeviceProfile(v.Id, v.Name).scrollSpeed(delta)
+		err := v.SetScrollDistance(d, d)
 		if err != nil {
 			logger.Debugf("Set natural scroll distance '%v - %v' failed: %v",
 				v.Id, v.Name, err)
@@ -256,7 +257,7 @@ func (tpad *Touchpad) enableTapToClick() {
 func (tpad *Touchpad) motionAcceleration() {
 	accel := float32(tpad.MotionAcceleration.Get())
 	for _, v := range tpad.devInfos {
-		err := v.SetMotionAcceleration(accel)
+		err := v.SetMotionAcceleration(getDeviceProfile(v.Id, v.Name).motionAcceleration(accel))
 		if err != nil {
 			logger.Debugf("Set acceleration for '%d - %v' failed: %v",
 				v.Id, v.Name, err)
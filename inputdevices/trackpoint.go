@@ -166,7 +166,7 @@ func (tp *TrackPoint) enableWheelHorizScroll() {
 func (tp *TrackPoint) enableLeftHanded() {
 	enabled := tp.LeftHanded.Get()
 	for _, info := range tp.devInfos {
-		err := info.EnableLeftHanded(enabled)
+		err := info.EnableLeftHanded(getDeviceProfile(info.Id, info.Name).leftHanded(enabled))
 		if err != nil {
 			logger.Warningf("Enable left-handed for '%v %s' failed: %v",
 				info.Id, info.Name, err)
@@ -210,7 +210,7 @@ func (tp *TrackPoint) wheelEmulationTimeout() {
 func (tp *TrackPoint) motionAcceleration() {
 	accel := float32(tp.MotionAcceleration.Get())
 	for _, v := range tp.devInfos {
-		err := v.SetMotionAcceleration(accel)
+		err := v.SetMotionAcceleration(getDeviceProfile(v.Id, v.Name).motionAcceleration(accel))
 		if err != nil {
 			logger.Debugf("Set acceleration for '%d - %v' failed: %v",
 				v.Id, v.Name, err)
@@ -80,6 +80,10 @@ func handleDeviceChanged() {
 	_wacomInfos = dxWacoms{}
 	getWacomInfos(false)
 
+	if _deviceProfiles != nil {
+		_deviceProfiles.clearCache()
+	}
+
 	if _manager == nil {
 		logger.Warning("_manager is nil")
 		return
@@ -89,6 +93,7 @@ func handleDeviceChanged() {
 	_manager.mouse.handleDeviceChanged()
 	_manager.wacom.handleDeviceChanged()
 	_manager.kbd.handleDeviceChanged()
+	_manager.applyButtonMaps()
 }
 
 func getDeviceInfos(force bool) common.DeviceInfos {
@@ -140,6 +145,16 @@ func getExtraInfo(id int32) (devNode string, phys string) {
 	return
 }
 
+// queryDeviceKey 通过设备属性 "Device Product ID" 获取设备的 "vendor:product"
+func queryDeviceKey(id int32) (string, bool) {
+	data, _ := dxutils.GetProperty(id, "Device Product ID")
+	vendor, product, ok := parseProductId(data)
+	if !ok {
+		return "", false
+	}
+	return getDeviceKey(vendor, product), true
+}
+
 func getTouchpadInfoByDxTouchpad(tmp *dxinput.Touchpad) *touchpadInfo {
 	m := &touchpadInfo{
 		Touchpad: tmp,
@@ -20,6 +20,17 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			InArgs:  []string{"name", "action", "keystroke"},
 			OutArgs: []string{"id", "type0"},
 		},
+		{
+			Name:   "AddMode",
+			Fn:     v.AddMode,
+			InArgs: []string{"name", "enterKeystroke", "exitKeystroke", "oneShot"},
+		},
+		{
+			Name:    "AddScopedShortcut",
+			Fn:      v.AddScopedShortcut,
+			InArgs:  []string{"name", "action", "keystroke", "apps", "mode"},
+			OutArgs: []string{"id", "type0"},
+		},
 		{
 			Name:   "AddShortcutKeystroke",
 			Fn:     v.AddShortcutKeystroke,
@@ -46,6 +57,11 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:     v.DeleteCustomShortcut,
 			InArgs: []string{"id"},
 		},
+		{
+			Name:   "DeleteMode",
+			Fn:     v.DeleteMode,
+			InArgs: []string{"name"},
+		},
 		{
 			Name:   "DeleteShortcutKeystroke",
 			Fn:     v.DeleteShortcutKeystroke,
@@ -61,6 +77,11 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:      v.GetCapsLockState,
 			OutArgs: []string{"state"},
 		},
+		{
+			Name:    "GetCurrentMode",
+			Fn:      v.GetCurrentMode,
+			OutArgs: []string{"name"},
+		},
 		{
 			Name:    "GetShortcut",
 			Fn:      v.GetShortcut,
@@ -81,6 +102,11 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:      v.ListAllShortcuts,
 			OutArgs: []string{"shortcuts"},
 		},
+		{
+			Name:    "ListModes",
+			Fn:      v.ListModes,
+			OutArgs: []string{"modes"},
+		},
 		{
 			Name:    "ListShortcutsByType",
 			Fn:      v.ListShortcutsByType,
@@ -129,10 +155,20 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			Fn:     v.SetCapsLockState,
 			InArgs: []string{"state"},
 		},
+		{
+			Name:   "SetCurrentMode",
+			Fn:     v.SetCurrentMode,
+			InArgs: []string{"name"},
+		},
 		{
 			Name:   "SetNumLockState",
 			Fn:     v.SetNumLockState,
 			InArgs: []string{"state"},
 		},
+		{
+			Name:   "SetShortcutScope",
+			Fn:     v.SetShortcutScope,
+			InArgs: []string{"id", "apps", "mode"},
+		},
 	}
 }
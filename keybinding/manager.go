@@ -66,6 +66,7 @@ const (
 	gsSchemaSessionPower = "com.deepin.dde.power"
 
 	customConfigFile = "deepin/dde-daemon/keybinding/custom.ini"
+	modeConfigFile   = "deepin/dde-daemon/keybinding/modes.ini"
 )
 
 const ( // power按键事件的响应
@@ -94,6 +95,7 @@ type Manager struct {
 	enableListenGSettings bool
 
 	customShortcutManager *shortcuts.CustomShortcutManager
+	shortcutModeManager   *shortcuts.ShortcutModeManager
 
 	lockFront     lockfront.LockFront
 	shutdownFront shutdownfront.ShutdownFront
@@ -139,6 +141,10 @@ type Manager struct {
 			pressed   bool
 			keystroke string
 		}
+
+		ModeChanged struct {
+			mode string
+		}
 	}
 }
 
@@ -189,6 +195,7 @@ func newManager(service *dbusutil.Service) (*Manager, error) {
 	m.keyEvent = keyevent.NewKeyEvent(sysBus)
 
 	m.shortcutManager = shortcuts.NewShortcutManager(m.conn, m.keySymbols, m.handleKeyEvent)
+	m.shortcutManager.SetKeystrokeEventCallback(m.emitSignalKeyEvent)
 	m.shortcutManager.AddSystem(m.gsSystem, m.wm)
 	m.shortcutManager.AddMedia(m.gsMediaKey)
 
@@ -220,6 +227,12 @@ func newManager(service *dbusutil.Service) (*Manager, error) {
 		m.shortcutManager.AddWM(m.gsGnomeWM)
 	}
 
+	// 模式要在自定义快捷键之前添加，自定义快捷键可能属于某个模式
+	modeConfigFilePath := filepath.Join(basedir.GetUserConfigDir(), modeConfigFile)
+	m.shortcutModeManager = shortcuts.NewShortcutModeManager(modeConfigFilePath)
+	m.shortcutManager.AddModes(m.shortcutModeManager)
+	m.shortcutManager.SetModeChangedCallback(m.handleModeChanged)
+
 	customConfigFilePath := filepath.Join(basedir.GetUserConfigDir(), customConfigFile)
 	m.customShortcutManager = shortcuts.NewCustomShortcutManager(customConfigFilePath)
 	m.shortcutManager.AddCustom(m.customShortcutManager)
@@ -58,6 +58,7 @@ var errTypeAssertionFail = errors.New("type assertion failed")
 var errShortcutKeystrokesUnmodifiable = errors.New("keystrokes of this shortcut is unmodifiable")
 var errKeystrokeUsed = errors.New("keystroke had been used")
 var errNameUsed = errors.New("name had been used")
+var errKeystrokeNotSimple = errors.New("key sequence, <Double> and <Hold> can not be used by this shortcut")
 
 func (*Manager) GetInterfaceName() string {
 	return dbusInterface
@@ -116,7 +117,8 @@ func (m *Manager) Reset() *dbus.Error {
 		var newKeystrokes []*shortcuts.Keystroke
 		modifyFlag := false
 		for _, keystroke := range keystrokes {
-			conflictKeystroke, err := m.shortcutManager.FindConflictingKeystroke(keystroke)
+			conflictKeystroke, err := m.shortcutManager.FindConflictingKeystroke(keystroke,
+				shortcuts.GetShortcutScope(cs))
 			if err != nil {
 				logger.Warning(err)
 				modifyFlag = true
@@ -175,12 +177,24 @@ func (m *Manager) AddCustomShortcut(name, action, keystroke string) (id string,
 	type0 int32, busErr *dbus.Error) {
 
 	logger.Debugf("Add custom key: %q %q %q", name, action, keystroke)
+	return m.addCustomShortcut(name, action, keystroke, nil)
+}
+
+func (m *Manager) addCustomShortcut(name, action, keystroke string, scope *shortcuts.ShortcutScope) (id string,
+	type0 int32, busErr *dbus.Error) {
+
 	ks, err := shortcuts.ParseKeystroke(keystroke)
 	if err != nil {
 		logger.Warning(err)
 		busErr = dbusutil.ToError(err)
 		return
 	}
+	err = checkScopeKeystroke(ks, scope)
+	if err != nil {
+		logger.Warning(err)
+		busErr = dbusutil.ToError(err)
+		return
+	}
 
 	exist := m.shortcutManager.GetByIdType(name, shortcuts.ShortcutTypeCustom)
 	if exist != nil {
@@ -190,7 +204,7 @@ func (m *Manager) AddCustomShortcut(name, action, keystroke string) (id string,
 		return
 	}
 
-	conflictKeystroke, err := m.shortcutManager.FindConflictingKeystroke(ks)
+	conflictKeystroke, err := m.shortcutManager.FindConflictingKeystroke(ks, scope)
 	if err != nil {
 		logger.Warning(err)
 		busErr = dbusutil.ToError(err)
@@ -203,7 +217,7 @@ func (m *Manager) AddCustomShortcut(name, action, keystroke string) (id string,
 		return
 	}
 
-	shortcut, err := m.customShortcutManager.Add(name, action, []*shortcuts.Keystroke{ks})
+	shortcut, err := m.customShortcutManager.Add(name, action, []*shortcuts.Keystroke{ks}, scope)
 	if err != nil {
 		logger.Warning(err)
 		busErr = dbusutil.ToError(err)
@@ -250,7 +264,7 @@ func (m *Manager) LookupConflictingShortcut(keystroke string) (shortcut string,
 		return "", dbusutil.ToError(err)
 	}
 
-	conflictKeystroke, err := m.shortcutManager.FindConflictingKeystroke(ks)
+	conflictKeystroke, err := m.shortcutManager.FindConflictingKeystroke(ks, nil)
 	if err != nil {
 		return "", dbusutil.ToError(err)
 	}
@@ -289,8 +303,12 @@ func (m *Manager) ModifyCustomShortcut(id, name, cmd, keystroke string) *dbus.Er
 		if err != nil {
 			return dbusutil.ToError(err)
 		}
+		err = checkScopeKeystroke(ks, customShortcut.GetScope())
+		if err != nil {
+			return dbusutil.ToError(err)
+		}
 		// check conflicting
-		conflictKeystroke, err := m.shortcutManager.FindConflictingKeystroke(ks)
+		conflictKeystroke, err := m.shortcutManager.FindConflictingKeystroke(ks, customShortcut.GetScope())
 		if err != nil {
 			return dbusutil.ToError(err)
 		}
@@ -336,8 +354,16 @@ func (m *Manager) AddShortcutKeystroke(id string, type0 int32, keystroke string)
 				"keystroke of shortcut which type is wm can not be set to the Super key"))
 		}
 	}
+	// wm 类型的快捷键由窗口管理器处理，不支持按键序列、双击和长按
+	if type0 == shortcuts.ShortcutTypeWM && !ks.IsSimple() {
+		return dbusutil.ToError(errKeystrokeNotSimple)
+	}
+	err = checkScopeKeystroke(ks, shortcuts.GetShortcutScope(shortcut))
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
 
-	conflictKeystroke, err := m.shortcutManager.FindConflictingKeystroke(ks)
+	conflictKeystroke, err := m.shortcutManager.FindConflictingKeystroke(ks, shortcuts.GetShortcutScope(shortcut))
 	if err != nil {
 		return dbusutil.ToError(err)
 	}
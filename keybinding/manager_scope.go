@@ -0,0 +1,200 @@
+/*
+ * Copyright (C) 2016 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package keybinding
+
+import (
+	"fmt"
+
+	"github.com/godbus/dbus"
+	"pkg.deepin.io/dde/daemon/keybinding/shortcuts"
+	"pkg.deepin.io/dde/daemon/keybinding/util"
+	"pkg.deepin.io/lib/dbusutil"
+)
+
+const modeSignalChanged = "ModeChanged"
+
+func (m *Manager) newShortcutScope(apps []string, mode string) (*shortcuts.ShortcutScope, error) {
+	scope := &shortcuts.ShortcutScope{
+		Apps: apps,
+		Mode: mode,
+	}
+	scope.Normalize()
+	if scope.Mode != "" && m.shortcutManager.GetMode(scope.Mode) == nil {
+		return nil, fmt.Errorf("mode %q not found", scope.Mode)
+	}
+	return scope, nil
+}
+
+// 有作用范围的快捷键只在需要时抓取按键，不支持按键序列、双击和长按
+func checkScopeKeystroke(ks *shortcuts.Keystroke, scope *shortcuts.ShortcutScope) error {
+	if !scope.IsGlobal() && !ks.IsSimple() {
+		return errKeystrokeNotSimple
+	}
+	return nil
+}
+
+// AddScopedShortcut 添加有作用范围的自定义快捷键，
+// apps 是 WM_CLASS 或者 desktop ID 的列表，只在这些应用的窗口是活动窗口时生效，为空时不限制应用；
+// mode 是快捷键所属的模式，为空时属于默认模式。
+func (m *Manager) AddScopedShortcut(name, action, keystroke string, apps []string,
+	mode string) (id string, type0 int32, busErr *dbus.Error) {
+
+	logger.Debugf("Add scoped custom key: %q %q %q %v %q", name, action, keystroke, apps, mode)
+	scope, err := m.newShortcutScope(apps, mode)
+	if err != nil {
+		return "", 0, dbusutil.ToError(err)
+	}
+	return m.addCustomShortcut(name, action, keystroke, scope)
+}
+
+// SetShortcutScope 修改自定义快捷键的作用范围，apps 和 mode 都为空时变成全局快捷键
+func (m *Manager) SetShortcutScope(id string, apps []string, mode string) *dbus.Error {
+	logger.Debugf("SetShortcutScope id: %q, apps: %v, mode: %q", id, apps, mode)
+	const ty = shortcuts.ShortcutTypeCustom
+	shortcut := m.shortcutManager.GetByIdType(id, ty)
+	if shortcut == nil {
+		return dbusutil.ToError(ErrShortcutNotFound{id, ty})
+	}
+	customShortcut, ok := shortcut.(*shortcuts.CustomShortcut)
+	if !ok {
+		return dbusutil.ToError(errTypeAssertionFail)
+	}
+
+	scope, err := m.newShortcutScope(apps, mode)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	for _, ks := range shortcut.GetKeystrokes() {
+		err = checkScopeKeystroke(ks, scope)
+		if err != nil {
+			return dbusutil.ToError(err)
+		}
+		conflictKeystroke, err := m.shortcutManager.FindConflictingKeystroke(ks, scope)
+		if err != nil {
+			return dbusutil.ToError(err)
+		}
+		if conflictKeystroke != nil && conflictKeystroke.Shortcut != shortcut {
+			return dbusutil.ToError(errKeystrokeUsed)
+		}
+	}
+
+	m.shortcutManager.ModifyShortcutScope(customShortcut, scope)
+	err = customShortcut.Save()
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	m.emitShortcutSignal(shortcutSignalChanged, shortcut)
+	return nil
+}
+
+// AddMode 添加快捷键模式，按下 enterKeystroke 进入模式，按下 exitKeystroke 回到默认模式，
+// exitKeystroke 为空时使用 Escape。oneShot 为 true 时执行模式中的一个快捷键后就回到默认模式。
+func (m *Manager) AddMode(name, enterKeystroke, exitKeystroke string, oneShot bool) *dbus.Error {
+	logger.Debugf("AddMode name: %q, enter: %q, exit: %q, oneShot: %v",
+		name, enterKeystroke, exitKeystroke, oneShot)
+	err := shortcuts.CheckModeName(name)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	if m.shortcutManager.GetMode(name) != nil {
+		return dbusutil.ToError(errNameUsed)
+	}
+
+	enterKs, err := shortcuts.ParseKeystroke(enterKeystroke)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	conflictKeystroke, err := m.shortcutManager.FindConflictingKeystroke(enterKs, nil)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	if conflictKeystroke != nil {
+		return dbusutil.ToError(errKeystrokeUsed)
+	}
+
+	if exitKeystroke == "" {
+		exitKeystroke = shortcuts.DefaultModeExitKeystroke
+	}
+	exitKs, err := shortcuts.ParseKeystroke(exitKeystroke)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	if !exitKs.IsSimple() {
+		return dbusutil.ToError(errKeystrokeNotSimple)
+	}
+
+	mode := &shortcuts.ShortcutMode{
+		Name:            name,
+		EnterKeystrokes: []*shortcuts.Keystroke{enterKs},
+		ExitKeystrokes:  []*shortcuts.Keystroke{exitKs},
+		OneShot:         oneShot,
+	}
+	err = m.shortcutModeManager.Add(mode)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	m.shortcutManager.AddMode(mode)
+	return nil
+}
+
+// DeleteMode 删除快捷键模式，模式中还有快捷键时不能删除
+func (m *Manager) DeleteMode(name string) *dbus.Error {
+	logger.Debug("DeleteMode", name)
+	if m.shortcutManager.GetMode(name) == nil {
+		return dbusutil.ToError(shortcuts.ErrModeNotFound)
+	}
+	for _, shortcut := range m.shortcutManager.ListByType(shortcuts.ShortcutTypeCustom) {
+		scope := shortcuts.GetShortcutScope(shortcut)
+		if scope != nil && scope.Mode == name {
+			return dbusutil.ToError(fmt.Errorf("mode %q is used by shortcut %q", name, shortcut.GetId()))
+		}
+	}
+
+	err := m.shortcutModeManager.Delete(name)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	err = m.shortcutManager.DeleteMode(name)
+	return dbusutil.ToError(err)
+}
+
+func (m *Manager) ListModes() (modes string, busErr *dbus.Error) {
+	ret, err := util.MarshalJSON(m.shortcutManager.ListModes())
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return ret, nil
+}
+
+// SetCurrentMode 切换到模式 name，name 为空时回到默认模式
+func (m *Manager) SetCurrentMode(name string) *dbus.Error {
+	err := m.shortcutManager.SetMode(name)
+	return dbusutil.ToError(err)
+}
+
+func (m *Manager) GetCurrentMode() (name string, busErr *dbus.Error) {
+	return m.shortcutManager.CurrentMode(), nil
+}
+
+func (m *Manager) handleModeChanged(mode string) {
+	logger.Debug("emit DBus signal", modeSignalChanged, mode)
+	err := m.service.Emit(m, modeSignalChanged, mode)
+	if err != nil {
+		logger.Warning(err)
+	}
+}
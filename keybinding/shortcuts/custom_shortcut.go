@@ -32,12 +32,38 @@ const (
 	kfKeyName       = "Name"
 	kfKeyKeystrokes = "Accels"
 	kfKeyAction     = "Action"
+	kfKeyApps       = "Apps"
+	kfKeyMode       = "Mode"
 )
 
 type CustomShortcut struct {
 	BaseShortcut
 	manager *CustomShortcutManager
 	Cmd     string `json:"Exec"`
+	// 作用范围，见 ShortcutScope
+	Apps []string
+	Mode string
+}
+
+func (cs *CustomShortcut) GetScope() *ShortcutScope {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return &ShortcutScope{
+		Apps: cs.Apps,
+		Mode: cs.Mode,
+	}
+}
+
+func (cs *CustomShortcut) SetScope(scope *ShortcutScope) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if scope == nil {
+		cs.Apps = nil
+		cs.Mode = ""
+		return
+	}
+	cs.Apps = scope.Apps
+	cs.Mode = scope.Mode
 }
 
 func (cs *CustomShortcut) Marshal() (string, error) {
@@ -70,9 +96,23 @@ func (cs *CustomShortcut) Save() error {
 	kfile.SetString(section, kfKeyName, cs.Name)
 	kfile.SetString(section, kfKeyAction, cs.Cmd)
 	kfile.SetStringList(section, kfKeyKeystrokes, cs.getKeystrokesStrv())
+	setKeyFileScope(kfile, section, cs.GetScope())
 	return cs.manager.Save()
 }
 
+func setKeyFileScope(kfile *keyfile.KeyFile, section string, scope *ShortcutScope) {
+	if scope == nil || len(scope.Apps) == 0 {
+		kfile.DeleteKey(section, kfKeyApps)
+	} else {
+		kfile.SetStringList(section, kfKeyApps, scope.Apps)
+	}
+	if scope == nil || scope.Mode == "" {
+		kfile.DeleteKey(section, kfKeyMode)
+	} else {
+		kfile.SetString(section, kfKeyMode, scope.Mode)
+	}
+}
+
 func (cs *CustomShortcut) GetAction() *Action {
 	_, err := os.Stat(cs.Cmd)
 	if !os.IsNotExist(err) {
@@ -130,6 +170,8 @@ func (csm *CustomShortcutManager) List() []Shortcut {
 		name, _ := kfile.GetString(section, kfKeyName)
 		cmd, _ := kfile.GetString(section, kfKeyAction)
 		keystrokes, _ := kfile.GetStringList(section, kfKeyKeystrokes)
+		apps, _ := kfile.GetStringList(section, kfKeyApps)
+		mode, _ := kfile.GetString(section, kfKeyMode)
 
 		shortcut := &CustomShortcut{
 			BaseShortcut: BaseShortcut{
@@ -141,6 +183,9 @@ func (csm *CustomShortcutManager) List() []Shortcut {
 			manager: csm,
 			Cmd:     cmd,
 		}
+		scope := &ShortcutScope{Apps: apps, Mode: mode}
+		scope.Normalize()
+		shortcut.SetScope(scope)
 
 		ret = append(ret, shortcut)
 	}
@@ -155,7 +200,9 @@ func (csm *CustomShortcutManager) Save() error {
 	return csm.kfile.SaveToFile(csm.file)
 }
 
-func (csm *CustomShortcutManager) Add(name, action string, keystrokes []*Keystroke) (Shortcut, error) {
+// Add 添加自定义快捷键，scope 为 nil 时是全局快捷键
+func (csm *CustomShortcutManager) Add(name, action string, keystrokes []*Keystroke,
+	scope *ShortcutScope) (Shortcut, error) {
 	id := name
 	csm.kfile.SetString(id, kfKeyName, name)
 	csm.kfile.SetString(id, kfKeyAction, action)
@@ -165,6 +212,7 @@ func (csm *CustomShortcutManager) Add(name, action string, keystrokes []*Keystro
 		keystrokesStrv = append(keystrokesStrv, ks.String())
 	}
 	csm.kfile.SetStringList(id, kfKeyKeystrokes, keystrokesStrv)
+	setKeyFileScope(csm.kfile, id, scope)
 
 	shortcut := &CustomShortcut{
 		BaseShortcut: BaseShortcut{
@@ -176,6 +224,7 @@ func (csm *CustomShortcutManager) Add(name, action string, keystrokes []*Keystro
 		manager: csm,
 		Cmd:     action,
 	}
+	shortcut.SetScope(scope)
 	return shortcut, csm.Save()
 }
 
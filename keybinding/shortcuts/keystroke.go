@@ -29,6 +29,18 @@ import (
 	"github.com/linuxdeepin/go-x11-client/util/keysyms"
 )
 
+// Trigger 快捷键的触发方式
+type Trigger uint8
+
+const (
+	TriggerPress     Trigger = iota // 按下时触发
+	TriggerDoubleTap                // 双击，例如 <Double>Shift_L
+	TriggerHold                     // 长按，例如 <Hold>Super_L
+)
+
+// 按键序列最多包含的按键数量
+const maxKeySequenceLength = 4
+
 // Keystroke
 // field Mods ignore mod2(Num_Lock) and lock(Caps_Lock)
 type Keystroke struct {
@@ -36,10 +48,19 @@ type Keystroke struct {
 	Keystr   string
 	Keysym   x.Keysym
 	Shortcut Shortcut
+	Trigger  Trigger
+	// 按键序列中第一个按键之后的按键，例如 "<Super>w t" 中的 t，
+	// 只抓取第一个按键，后面的按键在超时前依次按下才会触发快捷键。
+	Sequence []*Keystroke
 
 	isKeystrAboveTab bool
 }
 
+// IsSimple 返回 ks 是否是按下时触发的单个按键
+func (ks *Keystroke) IsSimple() bool {
+	return ks.Trigger == TriggerPress && len(ks.Sequence) == 0
+}
+
 func (ks *Keystroke) DebugString() string {
 	str := ks.String()
 	if ks.Shortcut == nil {
@@ -51,6 +72,15 @@ func (ks *Keystroke) DebugString() string {
 
 func (a *Keystroke) Equal(keySymbols *keysyms.KeySymbols, b *Keystroke) bool {
 	logger.Debug(a, " equal? ", b)
+	if a.Trigger != b.Trigger || len(a.Sequence) != len(b.Sequence) {
+		logger.Debug("Trigger or Sequence no equal, return false")
+		return false
+	}
+	for i := range a.Sequence {
+		if !a.Sequence[i].Equal(keySymbols, b.Sequence[i]) {
+			return false
+		}
+	}
 	if a.Mods != b.Mods {
 		logger.Debug("Mods no equal, return false")
 		return false
@@ -223,7 +253,37 @@ func splitKeystroke(str string) ([]string, error) {
 // Print mods() key Print
 // <Control>Print mods(Control) key Print
 // check Keystroke.Keystr valid later
+// <Super>w t 按键序列，先按 <Super>w 再按 t
+// <Double>Shift_L 双击 Shift_L
+// <Hold>Super_L 长按 Super_L
 func ParseKeystroke(keystroke string) (*Keystroke, error) {
+	fields := strings.Fields(keystroke)
+	if len(fields) <= 1 {
+		return parseSingleKeystroke(keystroke)
+	}
+	if len(fields) > maxKeySequenceLength {
+		return nil, errors.New("key sequence is too long")
+	}
+
+	var result *Keystroke
+	for _, field := range fields {
+		ks, err := parseSingleKeystroke(field)
+		if err != nil {
+			return nil, err
+		}
+		if ks.Trigger != TriggerPress {
+			return nil, errors.New("<Double> or <Hold> found in key sequence")
+		}
+		if result == nil {
+			result = ks
+		} else {
+			result.Sequence = append(result.Sequence, ks)
+		}
+	}
+	return result, nil
+}
+
+func parseSingleKeystroke(keystroke string) (*Keystroke, error) {
 	parts, err := splitKeystroke(keystroke)
 	if err != nil {
 		return nil, err
@@ -247,8 +307,13 @@ func ParseKeystroke(keystroke string) (*Keystroke, error) {
 	}
 
 	var mods Modifiers
+	var trigger Trigger
 	for _, part := range parts[:len(parts)-1] {
 		switch strings.ToLower(part) {
+		case "double":
+			trigger = TriggerDoubleTap
+		case "hold":
+			trigger = TriggerHold
 		case "shift":
 			mods |= keysyms.ModMaskShift
 		case "control":
@@ -261,11 +326,15 @@ func ParseKeystroke(keystroke string) (*Keystroke, error) {
 			return nil, errors.New("unknown mod " + part)
 		}
 	}
+	if trigger != TriggerPress && mods != 0 {
+		return nil, errors.New("<Double> or <Hold> used with modifiers")
+	}
 
 	return &Keystroke{
 		Mods:             mods,
 		Keystr:           str,
 		Keysym:           sym,
+		Trigger:          trigger,
 		isKeystrAboveTab: isKeystrAboveTab,
 	}, nil
 }
@@ -283,7 +352,26 @@ func ParseKeystrokes(keystrokes []string) []*Keystroke {
 }
 
 func (ks *Keystroke) String() string {
+	return ks.sequenceString(len(ks.Sequence))
+}
+
+// sequenceString 返回按键序列中前 n+1 个按键的字符串
+func (ks *Keystroke) sequenceString(n int) string {
+	strs := []string{ks.singleString()}
+	for _, ks0 := range ks.Sequence[:n] {
+		strs = append(strs, ks0.singleString())
+	}
+	return strings.Join(strs, " ")
+}
+
+func (ks *Keystroke) singleString() string {
 	var keys []string
+	switch ks.Trigger {
+	case TriggerDoubleTap:
+		keys = append(keys, "<Double>")
+	case TriggerHold:
+		keys = append(keys, "<Hold>")
+	}
 	mods := ks.Mods
 	if mods&keysyms.ModMaskShift > 0 {
 		keys = append(keys, "<Shift>")
@@ -303,7 +391,21 @@ func (ks *Keystroke) String() string {
 }
 
 func (ks *Keystroke) searchString() string {
+	strs := []string{ks.singleSearchString()}
+	for _, ks0 := range ks.Sequence {
+		strs = append(strs, ks0.singleSearchString())
+	}
+	return strings.Join(strs, " ")
+}
+
+func (ks *Keystroke) singleSearchString() string {
 	var strs []string
+	switch ks.Trigger {
+	case TriggerDoubleTap:
+		strs = append(strs, "double")
+	case TriggerHold:
+		strs = append(strs, "hold")
+	}
 	mods := ks.Mods
 	if mods&keysyms.ModMaskShift > 0 {
 		strs = append(strs, "shift")
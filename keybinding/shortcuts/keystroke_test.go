@@ -142,6 +142,50 @@ func TestParseLoopback(t *testing.T) {
 	assert.Equal(t, ks.String(), "<Shift><Control><Alt><Super>t")
 }
 
+func TestParseKeySequence(t *testing.T) {
+	ks, err := ParseKeystroke("<Super>w  t")
+	assert.Nil(t, err)
+	assert.Equal(t, ks, &Keystroke{
+		Keystr: "w",
+		Keysym: keysyms.XK_w,
+		Mods:   keysyms.ModMaskSuper,
+		Sequence: []*Keystroke{
+			{Keystr: "t", Keysym: keysyms.XK_t},
+		},
+	})
+	assert.False(t, ks.IsSimple())
+	assert.Equal(t, ks.String(), "<Super>w t")
+	assert.Equal(t, ks.sequenceString(0), "<Super>w")
+
+	_, err = ParseKeystroke("<Super>w <Double>t")
+	assert.NotNil(t, err)
+
+	_, err = ParseKeystroke("<Super>w a b c d")
+	assert.NotNil(t, err)
+
+	_, err = ParseKeystroke("<Super>w XXXXX")
+	assert.NotNil(t, err)
+}
+
+func TestParseTrigger(t *testing.T) {
+	ks, err := ParseKeystroke("<Double>Shift_L")
+	assert.Nil(t, err)
+	assert.Equal(t, ks, &Keystroke{
+		Keystr:  "Shift_L",
+		Keysym:  keysyms.XK_Shift_L,
+		Trigger: TriggerDoubleTap,
+	})
+	assert.Equal(t, ks.String(), "<Double>Shift_L")
+
+	ks, err = ParseKeystroke("<hold>Super_L")
+	assert.Nil(t, err)
+	assert.Equal(t, ks.Trigger, TriggerHold)
+	assert.Equal(t, ks.String(), "<Hold>Super_L")
+
+	_, err = ParseKeystroke("<Double><Control>C")
+	assert.NotNil(t, err)
+}
+
 func TestParseMediaKey(t *testing.T) {
 	keys := []string{
 		"XF86Messenger",
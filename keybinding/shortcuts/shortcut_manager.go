@@ -82,6 +82,25 @@ type ShortcutManager struct {
 
 	ConflictingKeystrokes []*Keystroke
 	EliminateConflictDone bool
+
+	// 有作用范围的快捷键
+	atomNetActiveWindow x.Atom
+	scopeMu             sync.Mutex
+	activeApp           *activeApp
+	currentMode         string
+	modes               map[string]*ShortcutMode
+	modeChangedCb       func(mode string)
+	scopeGrabMu         sync.Mutex
+	// 当前生效的有作用范围的快捷键，和 keyKeystrokeMap 一起由 keyKeystrokeMapMu 保护
+	scopeKeystrokeMap map[Key]*Keystroke
+
+	// 按键序列，键是第一个按键；双击和长按，键的 Mods 为 0。都由 keyKeystrokeMapMu 保护
+	sequenceKeystrokeMap map[Key][]*Keystroke
+	triggerKeystrokeMap  map[Key][]*Keystroke
+	sequenceMu           sync.Mutex
+	pendingSequence      *pendingSequence
+	keystrokeEventCb     func(pressed bool, keystroke string)
+	tapDetector          *tapDetector
 }
 
 type KeyEvent struct {
@@ -100,7 +119,14 @@ func NewShortcutManager(conn *x.Conn, keySymbols *keysyms.KeySymbols, eventCb Ke
 		keyKeystrokeMap: make(map[Key]*Keystroke),
 		layoutChanged:   make(chan struct{}),
 		pinyinEnabled:   isZH(),
+
+		modes:             make(map[string]*ShortcutMode),
+		scopeKeystrokeMap: make(map[Key]*Keystroke),
+
+		sequenceKeystrokeMap: make(map[Key][]*Keystroke),
+		triggerKeystrokeMap:  make(map[Key][]*Keystroke),
 	}
+	ss.tapDetector = newTapDetector(ss.hasHoldTrigger, ss.handleTrigger)
 
 	ss.xRecordEventHandler = NewXRecordEventHandler(keySymbols)
 	ss.xRecordEventHandler.modKeyReleasedCb = func(code uint8, mods uint16) {
@@ -146,6 +172,11 @@ func NewShortcutManager(conn *x.Conn, keySymbols *keysyms.KeySymbols, eventCb Ke
 		logger.Warning("init system D-BUS failed: ", err)
 	}
 
+	err = ss.initActiveWindowWatch()
+	if err != nil {
+		logger.Warning("watch active window failed: ", err)
+	}
+
 	return ss
 }
 
@@ -332,6 +363,15 @@ func (sm *ShortcutManager) storeConflictingKeystroke(ks *Keystroke) {
 }
 
 func (sm *ShortcutManager) grabKeystroke(shortcut Shortcut, ks *Keystroke, dummy bool) {
+	if ks.Trigger != TriggerPress {
+		sm.addTriggerKeystroke(ks)
+		return
+	}
+	if len(ks.Sequence) > 0 {
+		sm.grabSequenceKeystroke(shortcut, ks, dummy)
+		return
+	}
+
 	keyList, err := ks.ToKeyList(sm.keySymbols)
 	if err != nil {
 		logger.Debugf("grabKeystroke failed, shortcut: %v, ks: %v, err: %v", shortcut.GetId(), ks, err)
@@ -386,6 +426,15 @@ func (sm *ShortcutManager) grabKeystroke(shortcut Shortcut, ks *Keystroke, dummy
 }
 
 func (sm *ShortcutManager) ungrabKeystroke(ks *Keystroke, dummy bool) {
+	if ks.Trigger != TriggerPress {
+		sm.removeTriggerKeystroke(ks)
+		return
+	}
+	if len(ks.Sequence) > 0 {
+		sm.ungrabSequenceKeystroke(ks, dummy)
+		return
+	}
+
 	keyList, err := ks.ToKeyList(sm.keySymbols)
 	if err != nil {
 		logger.Debug(err)
@@ -399,26 +448,36 @@ func (sm *ShortcutManager) ungrabKeystroke(ks *Keystroke, dummy bool) {
 	defer sm.keyKeystrokeMapMu.Unlock()
 	for _, key := range keyList {
 		delete(sm.keyKeystrokeMap, key)
+		// 有作用范围的快捷键或者按键序列还在使用这个按键
+		if sm.isKeyGrabbedLocked(key) {
+			continue
+		}
 		if !dummy {
 			key.Ungrab(sm.conn)
 		}
 	}
 }
 
+// 有作用范围的快捷键只在 updateScopeGrabs 中抓取
 func (sm *ShortcutManager) grabShortcut(shortcut Shortcut) {
 	//logger.Debug("grabShortcut shortcut id:", shortcut.GetId())
+	scoped := isScopedShortcut(shortcut)
 	for _, ks := range shortcut.GetKeystrokes() {
-		dummy := dummyGrab(shortcut, ks)
-		sm.grabKeystroke(shortcut, ks, dummy)
+		if !scoped {
+			dummy := dummyGrab(shortcut, ks)
+			sm.grabKeystroke(shortcut, ks, dummy)
+		}
 		ks.Shortcut = shortcut
 	}
 }
 
 func (sm *ShortcutManager) ungrabShortcut(shortcut Shortcut) {
-
+	scoped := isScopedShortcut(shortcut)
 	for _, ks := range shortcut.GetKeystrokes() {
-		dummy := dummyGrab(shortcut, ks)
-		sm.ungrabKeystroke(ks, dummy)
+		if !scoped {
+			dummy := dummyGrab(shortcut, ks)
+			sm.ungrabKeystroke(ks, dummy)
+		}
 		ks.Shortcut = nil
 	}
 }
@@ -428,6 +487,21 @@ func (sm *ShortcutManager) ModifyShortcutKeystrokes(shortcut Shortcut, newVal []
 	sm.ungrabShortcut(shortcut)
 	shortcut.setKeystrokes(newVal)
 	sm.grabShortcut(shortcut)
+	if isScopedShortcut(shortcut) {
+		sm.updateScopeGrabs()
+	}
+}
+
+// ModifyShortcutScope 修改自定义快捷键的作用范围
+func (sm *ShortcutManager) ModifyShortcutScope(shortcut *CustomShortcut, scope *ShortcutScope) {
+	logger.Debug("ShortcutManager.ModifyShortcutScope", shortcut, scope)
+	wasScoped := isScopedShortcut(shortcut)
+	sm.ungrabShortcut(shortcut)
+	shortcut.SetScope(scope)
+	sm.grabShortcut(shortcut)
+	if wasScoped || isScopedShortcut(shortcut) {
+		sm.updateScopeGrabs()
+	}
 }
 
 func (sm *ShortcutManager) AddShortcutKeystroke(shortcut Shortcut, ks *Keystroke) {
@@ -445,10 +519,15 @@ func (sm *ShortcutManager) AddShortcutKeystroke(shortcut Shortcut, ks *Keystroke
 		logger.Debug("shortcut.Keystrokes append", ks.DebugString())
 
 		// grab keystroke
-		dummy := dummyGrab(shortcut, ks)
-		sm.grabKeystroke(shortcut, ks, dummy)
+		if !isScopedShortcut(shortcut) {
+			dummy := dummyGrab(shortcut, ks)
+			sm.grabKeystroke(shortcut, ks, dummy)
+		}
 	}
 	ks.Shortcut = shortcut
+	if notExist && isScopedShortcut(shortcut) {
+		sm.updateScopeGrabs()
+	}
 }
 
 func (sm *ShortcutManager) DeleteShortcutKeystroke(shortcut Shortcut, ks *Keystroke) {
@@ -465,6 +544,11 @@ func (sm *ShortcutManager) DeleteShortcutKeystroke(shortcut Shortcut, ks *Keystr
 	logger.Debugf("shortcut.Keystrokes  %v -> %v", oldVal, newVal)
 
 	// ungrab keystroke
+	if isScopedShortcut(shortcut) {
+		ks.Shortcut = nil
+		sm.updateScopeGrabs()
+		return
+	}
 	dummy := dummyGrab(shortcut, ks)
 	sm.ungrabKeystroke(ks, dummy)
 	ks.Shortcut = nil
@@ -483,6 +567,7 @@ func dummyGrab(shortcut Shortcut, ks *Keystroke) bool {
 }
 
 func (sm *ShortcutManager) UngrabAll() {
+	sm.cancelSequence()
 	sm.keyKeystrokeMapMu.Lock()
 	// ungrab all grabbed keys
 	for key, keystroke := range sm.keyKeystrokeMap {
@@ -491,20 +576,37 @@ func (sm *ShortcutManager) UngrabAll() {
 			key.Ungrab(sm.conn)
 		}
 	}
+	for key, keystroke := range sm.scopeKeystrokeMap {
+		if !isDummyKeystroke(keystroke) {
+			key.Ungrab(sm.conn)
+		}
+	}
+	for key, list := range sm.sequenceKeystrokeMap {
+		if !isDummyKeystroke(list[0]) {
+			key.Ungrab(sm.conn)
+		}
+	}
 	// new map
 	count := len(sm.keyKeystrokeMap)
 	sm.keyKeystrokeMap = make(map[Key]*Keystroke, count)
+	sm.scopeKeystrokeMap = make(map[Key]*Keystroke)
+	sm.sequenceKeystrokeMap = make(map[Key][]*Keystroke)
+	sm.triggerKeystrokeMap = make(map[Key][]*Keystroke)
 	sm.keyKeystrokeMapMu.Unlock()
 }
 
 func (sm *ShortcutManager) GrabAll() {
 	sm.idShortcutMapMu.Lock()
-	defer sm.idShortcutMapMu.Unlock()
-
 	// re-grab all shortcuts
 	for _, shortcut := range sm.idShortcutMap {
 		sm.grabShortcut(shortcut)
 	}
+	sm.idShortcutMapMu.Unlock()
+
+	for _, mode := range sm.ListModes() {
+		sm.grabModeShortcut(mode)
+	}
+	sm.updateScopeGrabs()
 }
 
 func (sm *ShortcutManager) regrabAll() {
@@ -570,6 +672,9 @@ func (sm *ShortcutManager) handleKeyEvent(pressed bool, detail x.Keycode, state
 
 	if pressed {
 		// key press
+		if sm.handleSequenceKeyEvent(key) {
+			return
+		}
 		sm.emitKeyEvent(Modifiers(state), key)
 	}
 }
@@ -583,8 +688,17 @@ func (sm *ShortcutManager) emitFakeKeyEvent(action *Action) {
 
 func (sm *ShortcutManager) emitKeyEvent(mods Modifiers, key Key) {
 	sm.keyKeystrokeMapMu.Lock()
-	keystroke, ok := sm.keyKeystrokeMap[key]
+	// 有作用范围的快捷键优先
+	keystroke, inScope := sm.scopeKeystrokeMap[key]
+	ok := inScope
+	if !ok {
+		keystroke, ok = sm.keyKeystrokeMap[key]
+	}
 	sm.keyKeystrokeMapMu.Unlock()
+	if ok && keystroke.Shortcut == nil {
+		logger.Warningf("key %v is grabbed, keystroke.Shortcut is nil", key)
+		return
+	}
 	if ok {
 		logger.Debugf("emitKeyEvent keystroke: %#v", keystroke)
 		keyEvent := &KeyEvent{
@@ -594,6 +708,18 @@ func (sm *ShortcutManager) emitKeyEvent(mods Modifiers, key Key) {
 		}
 
 		sm.callEventCallback(keyEvent)
+		if inScope {
+			sm.handleScopeKeyEvent(keystroke.Shortcut)
+		}
+		return
+	}
+
+	sm.keyKeystrokeMapMu.Lock()
+	candidates := append([]*Keystroke(nil), sm.sequenceKeystrokeMap[key]...)
+	sm.keyKeystrokeMapMu.Unlock()
+	if len(candidates) > 0 {
+		logger.Debugf("start key sequence %v", key)
+		sm.startSequence(candidates)
 	} else {
 		logger.Debug("keystroke not found")
 	}
@@ -651,10 +777,14 @@ func (sm *ShortcutManager) getActiveWindowPid() (uint32, error) {
 		return 0, err
 	}
 
-	pid, err1 := ewmh.GetWMPid(sm.conn, activeWin).Reply(sm.conn)
-	if err1 != nil {
-		logger.Warning(err1)
-		return 0, err1
+	return sm.getWindowPid(activeWin)
+}
+
+func (sm *ShortcutManager) getWindowPid(win x.Window) (uint32, error) {
+	pid, err := ewmh.GetWMPid(sm.conn, win).Reply(sm.conn)
+	if err != nil {
+		logger.Warning(err)
+		return 0, err
 	}
 
 	return uint32(pid), nil
@@ -682,6 +812,11 @@ func (sm *ShortcutManager) initSysDaemon() error {
 }
 
 func (sm *ShortcutManager) handleXRecordKeyEvent(pressed bool, code uint8, state uint16) {
+	holdFired := sm.tapDetector.handleKeyEvent(pressed, code)
+	if holdFired {
+		// 长按已经触发了快捷键，释放时不再触发单独按下修饰键的快捷键，比如 Super 打开启动器
+		sm.xRecordEventHandler.nonModKeyPressed = true
+	}
 	sm.xRecordEventHandler.handleKeyEvent(pressed, code, state)
 
 	if pressed {
@@ -708,6 +843,7 @@ func (sm *ShortcutManager) handleXRecordKeyEvent(pressed bool, code uint8, state
 }
 
 func (sm *ShortcutManager) handleXRecordButtonEvent(pressed bool) {
+	sm.tapDetector.handleButtonEvent()
 	sm.xRecordEventHandler.handleButtonEvent(pressed)
 }
 
@@ -724,6 +860,11 @@ func (sm *ShortcutManager) EventLoop() {
 			event, _ := x.NewKeyReleaseEvent(ev)
 			logger.Debug(event)
 			sm.handleKeyEvent(false, event.Detail, event.State)
+		case x.PropertyNotifyEventCode:
+			event, _ := x.NewPropertyNotifyEvent(ev)
+			if event.Atom == sm.atomNetActiveWindow {
+				sm.handleActiveWindowChanged()
+			}
 		case x.MappingNotifyEventCode:
 			event, _ := x.NewMappingNotifyEvent(ev)
 			logger.Debug(event)
@@ -760,6 +901,9 @@ func (sm *ShortcutManager) Add(shortcut Shortcut) {
 	sm.idShortcutMapMu.Unlock()
 
 	sm.grabShortcut(shortcut)
+	if isScopedShortcut(shortcut) {
+		sm.updateScopeGrabs()
+	}
 }
 
 func (sm *ShortcutManager) addWithoutLock(shortcut Shortcut) {
@@ -779,6 +923,9 @@ func (sm *ShortcutManager) Delete(shortcut Shortcut) {
 	sm.idShortcutMapMu.Unlock()
 
 	sm.ungrabShortcut(shortcut)
+	if isScopedShortcut(shortcut) {
+		sm.updateScopeGrabs()
+	}
 }
 
 func (sm *ShortcutManager) GetByIdType(id string, type0 int32) Shortcut {
@@ -798,9 +945,15 @@ func (sm *ShortcutManager) GetByUid(uid string) Shortcut {
 	return shortcut
 }
 
+// FindConflictingKeystroke 查找在作用范围 scope 中和 ks 冲突的按键，scope 为 nil 表示全局。
+// 作用范围小的快捷键会覆盖作用范围大的快捷键，它们之间不算冲突。
 // ret0: Conflicting keystroke
 // ret1: error
-func (sm *ShortcutManager) FindConflictingKeystroke(ks *Keystroke) (*Keystroke, error) {
+func (sm *ShortcutManager) FindConflictingKeystroke(ks *Keystroke, scope *ShortcutScope) (*Keystroke, error) {
+	if !scope.IsGlobal() {
+		return sm.findScopeConflictingKeystroke(ks, scope), nil
+	}
+
 	keyList, err := ks.ToKeyList(sm.keySymbols)
 	if err != nil {
 		return nil, err
@@ -814,6 +967,14 @@ func (sm *ShortcutManager) FindConflictingKeystroke(ks *Keystroke) (*Keystroke,
 
 	sm.keyKeystrokeMapMu.Lock()
 	defer sm.keyKeystrokeMapMu.Unlock()
+	if ks1 := sm.findNonSimpleConflictingKeystroke(ks, keyList); ks1 != nil {
+		return ks1, nil
+	}
+	if ks.Trigger != TriggerPress {
+		// 双击和长按不会和按下时触发的快捷键冲突
+		return nil, nil
+	}
+
 	var count = 0
 	var ks1 *Keystroke
 	for _, key := range keyList {
@@ -920,6 +1081,7 @@ func (sm *ShortcutManager) AddCustom(csm *CustomShortcutManager) {
 	for _, shortcut := range csm.List() {
 		sm.addWithoutLock(shortcut)
 	}
+	sm.updateScopeGrabs()
 }
 
 func (sm *ShortcutManager) AddKWin(wmObj wm.Wm) {
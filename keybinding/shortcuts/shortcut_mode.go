@@ -0,0 +1,280 @@
+/*
+ * Copyright (C) 2016 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package shortcuts
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"pkg.deepin.io/lib/keyfile"
+)
+
+const (
+	kfKeyEnterKeystrokes = "EnterAccels"
+	kfKeyExitKeystrokes  = "ExitAccels"
+	kfKeyOneShot         = "OneShot"
+
+	DefaultModeExitKeystroke = "Escape"
+)
+
+var ErrModeNotFound = errors.New("mode not found")
+
+// ShortcutMode 是类似 vim 的快捷键模式，按下 EnterKeystrokes 进入模式后，模式中的快捷键才生效，
+// 按下 ExitKeystrokes 回到默认模式。在模式中全局快捷键仍然有效，但是模式中的快捷键优先。
+type ShortcutMode struct {
+	Name            string
+	EnterKeystrokes []*Keystroke `json:"EnterAccels"`
+	ExitKeystrokes  []*Keystroke `json:"ExitAccels"`
+	// 执行模式中的一个快捷键后就回到默认模式
+	OneShot bool
+
+	enterShortcut *FakeShortcut
+	exitShortcut  *FakeShortcut
+}
+
+func CheckModeName(name string) error {
+	if name == "" {
+		return errors.New("mode name is empty")
+	}
+	if strings.ContainsAny(name, "[]\n") {
+		return fmt.Errorf("invalid mode name %q", name)
+	}
+	return nil
+}
+
+// ShortcutModeManager 负责保存快捷键模式，每个模式是配置文件中的一个 section
+type ShortcutModeManager struct {
+	file  string
+	kfile *keyfile.KeyFile
+}
+
+func NewShortcutModeManager(file string) *ShortcutModeManager {
+	kfile := keyfile.NewKeyFile()
+	err := kfile.LoadFromFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		logger.Warning(err)
+	}
+
+	return &ShortcutModeManager{
+		file:  file,
+		kfile: kfile,
+	}
+}
+
+func (smm *ShortcutModeManager) List() []*ShortcutMode {
+	kfile := smm.kfile
+	sections := kfile.GetSections()
+	ret := make([]*ShortcutMode, 0, len(sections))
+	for _, section := range sections {
+		enterKeystrokes, _ := kfile.GetStringList(section, kfKeyEnterKeystrokes)
+		exitKeystrokes, _ := kfile.GetStringList(section, kfKeyExitKeystrokes)
+		oneShot, _ := kfile.GetBool(section, kfKeyOneShot)
+		if len(exitKeystrokes) == 0 {
+			exitKeystrokes = []string{DefaultModeExitKeystroke}
+		}
+
+		ret = append(ret, &ShortcutMode{
+			Name:            section,
+			EnterKeystrokes: ParseKeystrokes(enterKeystrokes),
+			ExitKeystrokes:  ParseKeystrokes(exitKeystrokes),
+			OneShot:         oneShot,
+		})
+	}
+	return ret
+}
+
+func (smm *ShortcutModeManager) Save() error {
+	err := os.MkdirAll(filepath.Dir(smm.file), 0755)
+	if err != nil {
+		return err
+	}
+	return smm.kfile.SaveToFile(smm.file)
+}
+
+func keystrokesToStrv(keystrokes []*Keystroke) []string {
+	strv := make([]string, 0, len(keystrokes))
+	for _, ks := range keystrokes {
+		strv = append(strv, ks.String())
+	}
+	return strv
+}
+
+func (smm *ShortcutModeManager) Add(mode *ShortcutMode) error {
+	section := mode.Name
+	smm.kfile.SetStringList(section, kfKeyEnterKeystrokes, keystrokesToStrv(mode.EnterKeystrokes))
+	smm.kfile.SetStringList(section, kfKeyExitKeystrokes, keystrokesToStrv(mode.ExitKeystrokes))
+	smm.kfile.SetBool(section, kfKeyOneShot, mode.OneShot)
+	return smm.Save()
+}
+
+func (smm *ShortcutModeManager) Delete(name string) error {
+	if _, err := smm.kfile.GetSection(name); err != nil {
+		return err
+	}
+
+	smm.kfile.DeleteSection(name)
+	return smm.Save()
+}
+
+func newModeShortcut(id, name string, keystrokes []*Keystroke, fn func(ev *KeyEvent)) *FakeShortcut {
+	shortcut := NewFakeShortcut(NewCallbackAction(fn))
+	shortcut.Id = id
+	shortcut.Name = name
+	shortcut.Keystrokes = keystrokes
+	for _, ks := range keystrokes {
+		ks.Shortcut = shortcut
+	}
+	return shortcut
+}
+
+func (sm *ShortcutManager) AddModes(smm *ShortcutModeManager) {
+	logger.Debug("AddModes")
+	for _, mode := range smm.List() {
+		sm.AddMode(mode)
+	}
+}
+
+// AddMode 添加模式并且抓取进入模式的按键
+func (sm *ShortcutManager) AddMode(mode *ShortcutMode) {
+	name := mode.Name
+	mode.enterShortcut = newModeShortcut("mode-enter-"+name, name, mode.EnterKeystrokes,
+		func(ev *KeyEvent) {
+			err := sm.SetMode(name)
+			if err != nil {
+				logger.Warning(err)
+			}
+		})
+	mode.exitShortcut = newModeShortcut("mode-exit-"+name, name, mode.ExitKeystrokes,
+		func(ev *KeyEvent) {
+			err := sm.SetMode("")
+			if err != nil {
+				logger.Warning(err)
+			}
+		})
+
+	sm.scopeMu.Lock()
+	sm.modes[name] = mode
+	sm.scopeMu.Unlock()
+
+	sm.grabModeShortcut(mode)
+}
+
+func (sm *ShortcutManager) grabModeShortcut(mode *ShortcutMode) {
+	for _, ks := range mode.enterShortcut.GetKeystrokes() {
+		sm.grabKeystroke(mode.enterShortcut, ks, dummyGrab(mode.enterShortcut, ks))
+	}
+}
+
+func (sm *ShortcutManager) DeleteMode(name string) error {
+	sm.scopeMu.Lock()
+	mode := sm.modes[name]
+	current := sm.currentMode
+	delete(sm.modes, name)
+	sm.scopeMu.Unlock()
+	if mode == nil {
+		return ErrModeNotFound
+	}
+
+	if current == name {
+		err := sm.SetMode("")
+		if err != nil {
+			return err
+		}
+	}
+	for _, ks := range mode.enterShortcut.GetKeystrokes() {
+		sm.ungrabKeystroke(ks, dummyGrab(mode.enterShortcut, ks))
+	}
+	return nil
+}
+
+func (sm *ShortcutManager) GetMode(name string) *ShortcutMode {
+	sm.scopeMu.Lock()
+	mode := sm.modes[name]
+	sm.scopeMu.Unlock()
+	return mode
+}
+
+func (sm *ShortcutManager) ListModes() []*ShortcutMode {
+	sm.scopeMu.Lock()
+	list := make([]*ShortcutMode, 0, len(sm.modes))
+	for _, mode := range sm.modes {
+		list = append(list, mode)
+	}
+	sm.scopeMu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+func (sm *ShortcutManager) CurrentMode() string {
+	sm.scopeMu.Lock()
+	mode := sm.currentMode
+	sm.scopeMu.Unlock()
+	return mode
+}
+
+// SetMode 切换到模式 name，name 为空时回到默认模式
+func (sm *ShortcutManager) SetMode(name string) error {
+	sm.scopeMu.Lock()
+	if name != "" && sm.modes[name] == nil {
+		sm.scopeMu.Unlock()
+		return ErrModeNotFound
+	}
+	changed := sm.currentMode != name
+	sm.currentMode = name
+	cb := sm.modeChangedCb
+	sm.scopeMu.Unlock()
+
+	if !changed {
+		return nil
+	}
+	logger.Debug("shortcut mode changed:", name)
+	sm.updateScopeGrabs()
+	if cb != nil {
+		cb(name)
+	}
+	return nil
+}
+
+func (sm *ShortcutManager) SetModeChangedCallback(cb func(mode string)) {
+	sm.scopeMu.Lock()
+	sm.modeChangedCb = cb
+	sm.scopeMu.Unlock()
+}
+
+// handleScopeKeyEvent 在有作用范围的快捷键触发后调用，一次性的模式需要回到默认模式
+func (sm *ShortcutManager) handleScopeKeyEvent(shortcut Shortcut) {
+	scope := GetShortcutScope(shortcut)
+	if scope.IsGlobal() || scope.Mode == "" {
+		return
+	}
+	mode := sm.GetMode(scope.Mode)
+	if mode == nil || !mode.OneShot {
+		return
+	}
+	err := sm.SetMode("")
+	if err != nil {
+		logger.Warning(err)
+	}
+}
@@ -0,0 +1,344 @@
+/*
+ * Copyright (C) 2016 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package shortcuts
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	x "github.com/linuxdeepin/go-x11-client"
+	"github.com/linuxdeepin/go-x11-client/util/wm/ewmh"
+	"github.com/linuxdeepin/go-x11-client/util/wm/icccm"
+	"pkg.deepin.io/lib/procfs"
+)
+
+// ShortcutScope 是快捷键生效的范围，Apps 和 Mode 都为空时快捷键是全局的。
+type ShortcutScope struct {
+	// 活动窗口的 WM_CLASS 或者 desktop ID，为空时不限制应用
+	Apps []string
+	// 快捷键所属的模式，为空时属于默认模式
+	Mode string
+}
+
+func (s *ShortcutScope) IsGlobal() bool {
+	return s == nil || (len(s.Apps) == 0 && s.Mode == "")
+}
+
+// Normalize 去掉 Apps 中的空白和重复项
+func (s *ShortcutScope) Normalize() {
+	apps := make([]string, 0, len(s.Apps))
+	for _, app := range s.Apps {
+		app = strings.TrimSpace(app)
+		if app == "" {
+			continue
+		}
+		dup := false
+		for _, app0 := range apps {
+			if normalizeAppName(app0) == normalizeAppName(app) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			apps = append(apps, app)
+		}
+	}
+	if len(apps) == 0 {
+		apps = nil
+	}
+	s.Apps = apps
+	s.Mode = strings.TrimSpace(s.Mode)
+}
+
+func (s *ShortcutScope) matchApp(app *activeApp) bool {
+	if len(s.Apps) == 0 {
+		return true
+	}
+	if app == nil {
+		return false
+	}
+	for _, name := range s.Apps {
+		if app.match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// priority 作用范围越小优先级越高：模式+应用 > 模式 > 应用 > 全局
+func (s *ShortcutScope) priority() int {
+	var p int
+	if len(s.Apps) > 0 {
+		p++
+	}
+	if s.Mode != "" {
+		p += 2
+	}
+	return p
+}
+
+// conflictsWith 判断两个作用范围中使用同一个按键是否冲突。
+// 作用范围小的快捷键会覆盖作用范围大的快捷键，所以只有模式相同，
+// 并且都不限制应用或者限制的应用有重合时才算冲突。
+func (s *ShortcutScope) conflictsWith(other *ShortcutScope) bool {
+	if s.Mode != other.Mode {
+		return false
+	}
+	if len(s.Apps) == 0 || len(other.Apps) == 0 {
+		return len(s.Apps) == len(other.Apps)
+	}
+	for _, a := range s.Apps {
+		for _, b := range other.Apps {
+			if normalizeAppName(a) == normalizeAppName(b) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func normalizeAppName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, ".desktop"))
+}
+
+// 目前只有自定义快捷键可以设置作用范围
+type scopedShortcut interface {
+	GetScope() *ShortcutScope
+}
+
+// GetShortcutScope 返回快捷键的作用范围，全局快捷键可能返回 nil
+func GetShortcutScope(shortcut Shortcut) *ShortcutScope {
+	if s, ok := shortcut.(scopedShortcut); ok {
+		return s.GetScope()
+	}
+	return nil
+}
+
+func isScopedShortcut(shortcut Shortcut) bool {
+	return !GetShortcutScope(shortcut).IsGlobal()
+}
+
+// getScopeKeystrokes 返回在模式 mode 中并且 app 是活动应用时生效的有作用范围的快捷键的按键，
+// 按照优先级从低到高排列。
+func getScopeKeystrokes(list []Shortcut, mode string, app *activeApp) []*Keystroke {
+	type item struct {
+		priority   int
+		keystrokes []*Keystroke
+	}
+	var items []item
+	for _, shortcut := range list {
+		scope := GetShortcutScope(shortcut)
+		if scope.IsGlobal() {
+			continue
+		}
+		if scope.Mode != "" && scope.Mode != mode {
+			continue
+		}
+		if !scope.matchApp(app) {
+			continue
+		}
+		items = append(items, item{
+			priority:   scope.priority(),
+			keystrokes: shortcut.GetKeystrokes(),
+		})
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].priority < items[j].priority
+	})
+
+	var result []*Keystroke
+	for _, item := range items {
+		result = append(result, item.keystrokes...)
+	}
+	return result
+}
+
+// activeApp 是活动窗口所属的应用
+type activeApp struct {
+	wmInstance string
+	wmClass    string
+	desktopId  string
+}
+
+func (app *activeApp) match(name string) bool {
+	name = normalizeAppName(name)
+	if name == "" {
+		return false
+	}
+	return name == strings.ToLower(app.wmInstance) ||
+		name == strings.ToLower(app.wmClass) ||
+		(app.desktopId != "" && name == normalizeAppName(app.desktopId))
+}
+
+func (sm *ShortcutManager) getActiveApp() *activeApp {
+	activeWin, err := ewmh.GetActiveWindow(sm.conn).Reply(sm.conn)
+	if err != nil {
+		logger.Warning(err)
+		return nil
+	}
+	if activeWin == 0 {
+		return nil
+	}
+
+	app := &activeApp{}
+	wmClass, err := icccm.GetWMClass(sm.conn, activeWin).Reply(sm.conn)
+	if err == nil {
+		app.wmInstance = wmClass.Instance
+		app.wmClass = wmClass.Class
+	}
+
+	pid, err := sm.getWindowPid(activeWin)
+	if err == nil && pid != 0 {
+		environ, err := procfs.Process(pid).Environ()
+		if err == nil {
+			if desktopFile := environ.Get("GIO_LAUNCHED_DESKTOP_FILE"); desktopFile != "" {
+				app.desktopId = filepath.Base(desktopFile)
+			}
+		}
+	}
+	return app
+}
+
+func (sm *ShortcutManager) initActiveWindowWatch() error {
+	var err error
+	sm.atomNetActiveWindow, err = sm.conn.GetAtom("_NET_ACTIVE_WINDOW")
+	if err != nil {
+		return err
+	}
+	rootWin := sm.conn.GetDefaultScreen().Root
+	err = x.ChangeWindowAttributesChecked(sm.conn, rootWin, x.CWEventMask,
+		[]uint32{x.EventMaskPropertyChange}).Check(sm.conn)
+	if err != nil {
+		return err
+	}
+
+	sm.scopeMu.Lock()
+	sm.activeApp = sm.getActiveApp()
+	sm.scopeMu.Unlock()
+	return nil
+}
+
+func (sm *ShortcutManager) handleActiveWindowChanged() {
+	app := sm.getActiveApp()
+	sm.scopeMu.Lock()
+	sm.activeApp = app
+	sm.scopeMu.Unlock()
+	sm.updateScopeGrabs()
+}
+
+// updateScopeGrabs 根据当前的模式和活动应用，重新抓取有作用范围的快捷键
+func (sm *ShortcutManager) updateScopeGrabs() {
+	sm.scopeGrabMu.Lock()
+	defer sm.scopeGrabMu.Unlock()
+
+	list := sm.List()
+	sm.scopeMu.Lock()
+	keystrokes := getScopeKeystrokes(list, sm.currentMode, sm.activeApp)
+	if mode := sm.modes[sm.currentMode]; mode != nil {
+		// 退出模式的按键优先级最高
+		keystrokes = append(keystrokes, mode.exitShortcut.GetKeystrokes()...)
+	}
+	sm.scopeMu.Unlock()
+
+	keyKeystrokeMap := make(map[Key]*Keystroke)
+	for _, ks := range keystrokes {
+		if !ks.IsSimple() {
+			// 有作用范围的快捷键不支持按键序列、双击和长按
+			continue
+		}
+		keyList, err := ks.ToKeyList(sm.keySymbols)
+		if err != nil {
+			logger.Debug(err)
+			continue
+		}
+		for _, key := range keyList {
+			keyKeystrokeMap[key] = ks
+		}
+	}
+
+	sm.keyKeystrokeMapMu.Lock()
+	defer sm.keyKeystrokeMapMu.Unlock()
+	for key, ks := range sm.scopeKeystrokeMap {
+		if _, ok := keyKeystrokeMap[key]; ok {
+			continue
+		}
+		// 全局快捷键还在使用这个按键
+		if _, ok := sm.keyKeystrokeMap[key]; ok {
+			continue
+		}
+		if len(sm.sequenceKeystrokeMap[key]) > 0 {
+			continue
+		}
+		if !isDummyKeystroke(ks) {
+			key.Ungrab(sm.conn)
+		}
+	}
+	for key, ks := range keyKeystrokeMap {
+		if _, ok := sm.scopeKeystrokeMap[key]; ok {
+			continue
+		}
+		if _, ok := sm.keyKeystrokeMap[key]; ok {
+			continue
+		}
+		if len(sm.sequenceKeystrokeMap[key]) > 0 {
+			continue
+		}
+		if !isDummyKeystroke(ks) {
+			err := key.Grab(sm.conn)
+			if err != nil {
+				logger.Debugf("grab key %v failed: %v", key, err)
+				delete(keyKeystrokeMap, key)
+			}
+		}
+	}
+	sm.scopeKeystrokeMap = keyKeystrokeMap
+}
+
+func isDummyKeystroke(ks *Keystroke) bool {
+	return ks.Shortcut != nil && dummyGrab(ks.Shortcut, ks)
+}
+
+// findScopeConflictingKeystroke 在有作用范围的快捷键中查找和 ks 冲突的按键
+func (sm *ShortcutManager) findScopeConflictingKeystroke(ks *Keystroke, scope *ShortcutScope) *Keystroke {
+	for _, shortcut := range sm.List() {
+		scope0 := GetShortcutScope(shortcut)
+		if scope0.IsGlobal() || !scope.conflictsWith(scope0) {
+			continue
+		}
+		for _, ks0 := range shortcut.GetKeystrokes() {
+			if ks.Equal(sm.keySymbols, ks0) {
+				return ks0
+			}
+		}
+	}
+
+	if scope.Mode != "" && len(scope.Apps) == 0 {
+		sm.scopeMu.Lock()
+		mode := sm.modes[scope.Mode]
+		sm.scopeMu.Unlock()
+		if mode != nil {
+			for _, ks0 := range mode.exitShortcut.GetKeystrokes() {
+				if ks.Equal(sm.keySymbols, ks0) {
+					return ks0
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+/*
+ * Copyright (C) 2016 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package shortcuts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShortcutScopeNormalize(t *testing.T) {
+	scope := &ShortcutScope{Apps: []string{" Firefox ", "", "firefox.desktop", "code"}, Mode: " resize "}
+	scope.Normalize()
+	assert.Equal(t, []string{"Firefox", "code"}, scope.Apps)
+	assert.Equal(t, "resize", scope.Mode)
+
+	scope = &ShortcutScope{Apps: []string{" "}}
+	scope.Normalize()
+	assert.Nil(t, scope.Apps)
+	assert.True(t, scope.IsGlobal())
+
+	var nilScope *ShortcutScope
+	assert.True(t, nilScope.IsGlobal())
+}
+
+func TestShortcutScopeConflictsWith(t *testing.T) {
+	global := &ShortcutScope{}
+	firefox := &ShortcutScope{Apps: []string{"firefox"}}
+	browsers := &ShortcutScope{Apps: []string{"chromium", "Firefox.desktop"}}
+	code := &ShortcutScope{Apps: []string{"code"}}
+	resize := &ShortcutScope{Mode: "resize"}
+	resizeFirefox := &ShortcutScope{Apps: []string{"firefox"}, Mode: "resize"}
+
+	assert.True(t, firefox.conflictsWith(browsers))
+	assert.False(t, firefox.conflictsWith(code))
+	// 作用范围小的快捷键覆盖作用范围大的，不算冲突
+	assert.False(t, firefox.conflictsWith(global))
+	assert.False(t, resizeFirefox.conflictsWith(resize))
+	assert.False(t, resize.conflictsWith(global))
+	assert.True(t, resize.conflictsWith(&ShortcutScope{Mode: "resize"}))
+	assert.False(t, resizeFirefox.conflictsWith(firefox))
+}
+
+func TestActiveAppMatch(t *testing.T) {
+	app := &activeApp{wmInstance: "code", wmClass: "Code", desktopId: "code-oss.desktop"}
+	assert.True(t, app.match("Code"))
+	assert.True(t, app.match("code-oss"))
+	assert.True(t, app.match("code-oss.desktop"))
+	assert.False(t, app.match("firefox"))
+	assert.False(t, app.match(""))
+
+	scope := &ShortcutScope{Apps: []string{"firefox", "code"}}
+	assert.True(t, scope.matchApp(app))
+	assert.False(t, scope.matchApp(nil))
+	assert.True(t, (&ShortcutScope{Mode: "resize"}).matchApp(nil))
+}
+
+func newTestCustomShortcut(id, keystr string, apps []string, mode string) *CustomShortcut {
+	ks := &Keystroke{Keystr: keystr}
+	return &CustomShortcut{
+		BaseShortcut: BaseShortcut{
+			Id:         id,
+			Type:       ShortcutTypeCustom,
+			Keystrokes: []*Keystroke{ks},
+		},
+		Apps: apps,
+		Mode: mode,
+	}
+}
+
+func TestGetScopeKeystrokes(t *testing.T) {
+	list := []Shortcut{
+		newTestCustomShortcut("global", "g", nil, ""),
+		newTestCustomShortcut("resize-code", "c", []string{"code"}, "resize"),
+		newTestCustomShortcut("resize", "r", nil, "resize"),
+		newTestCustomShortcut("code", "k", []string{"code"}, ""),
+		newTestCustomShortcut("firefox", "f", []string{"firefox"}, ""),
+	}
+	getIds := func(keystrokes []*Keystroke) []string {
+		var ids []string
+		for _, ks := range keystrokes {
+			for _, shortcut := range list {
+				if shortcut.GetKeystrokes()[0] == ks {
+					ids = append(ids, shortcut.GetId())
+				}
+			}
+		}
+		return ids
+	}
+	app := &activeApp{wmInstance: "code", wmClass: "Code"}
+
+	assert.Equal(t, []string{"code"}, getIds(getScopeKeystrokes(list, "", app)))
+	// 按照优先级从低到高排列
+	assert.Equal(t, []string{"code", "resize", "resize-code"}, getIds(getScopeKeystrokes(list, "resize", app)))
+	assert.Equal(t, []string{"resize"}, getIds(getScopeKeystrokes(list, "resize", nil)))
+	assert.Empty(t, getScopeKeystrokes(list, "move", nil))
+}
@@ -0,0 +1,357 @@
+/*
+ * Copyright (C) 2016 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package shortcuts
+
+import (
+	"time"
+
+	x "github.com/linuxdeepin/go-x11-client"
+	"github.com/linuxdeepin/go-x11-client/util/keybind"
+)
+
+// 按键序列中两次按键之间的最长间隔
+const sequenceTimeout = 1500 * time.Millisecond
+
+// pendingSequence 是已经按下了第一个按键，正在等待后面按键的按键序列
+type pendingSequence struct {
+	candidates []*Keystroke
+	// 已经匹配的 Sequence 中的按键数量
+	pos   int
+	timer *time.Timer
+}
+
+// SetKeystrokeEventCallback 设置按键序列、双击和长按的进度通知，
+// 按键序列每按下一个按键通知一次 (true, 已经按下的按键)，结束时通知 (false, 按键序列)；
+// 双击和长按触发时依次通知 (true, 按键) 和 (false, 按键)。
+func (sm *ShortcutManager) SetKeystrokeEventCallback(cb func(pressed bool, keystroke string)) {
+	sm.sequenceMu.Lock()
+	sm.keystrokeEventCb = cb
+	sm.sequenceMu.Unlock()
+}
+
+func (sm *ShortcutManager) emitKeystrokeEvent(pressed bool, keystroke string) {
+	sm.sequenceMu.Lock()
+	cb := sm.keystrokeEventCb
+	sm.sequenceMu.Unlock()
+	if cb != nil {
+		cb(pressed, keystroke)
+	}
+}
+
+// 调用者需要持有 keyKeystrokeMapMu
+func (sm *ShortcutManager) isKeyGrabbedLocked(key Key) bool {
+	if _, ok := sm.keyKeystrokeMap[key]; ok {
+		return true
+	}
+	if _, ok := sm.scopeKeystrokeMap[key]; ok {
+		return true
+	}
+	return len(sm.sequenceKeystrokeMap[key]) > 0
+}
+
+// grabSequenceKeystroke 只抓取按键序列的第一个按键，多个按键序列可以共用第一个按键
+func (sm *ShortcutManager) grabSequenceKeystroke(shortcut Shortcut, ks *Keystroke, dummy bool) {
+	keyList, err := ks.ToKeyList(sm.keySymbols)
+	if err != nil {
+		logger.Debugf("grabSequenceKeystroke failed, shortcut: %v, ks: %v, err: %v", shortcut.GetId(), ks, err)
+		return
+	}
+
+	var conflictCount int
+	sm.keyKeystrokeMapMu.Lock()
+	for _, key := range keyList {
+		if conflictKeystroke, ok := sm.keyKeystrokeMap[key]; ok {
+			conflictCount++
+			logger.Debugf("key %v is grabbed by %v", key, conflictKeystroke.DebugString())
+			continue
+		}
+
+		if !dummy && !sm.isKeyGrabbedLocked(key) {
+			err = key.Grab(sm.conn)
+			if err != nil {
+				logger.Debug(err)
+				continue
+			}
+		}
+		sm.sequenceKeystrokeMap[key] = append(sm.sequenceKeystrokeMap[key], ks)
+	}
+	sm.keyKeystrokeMapMu.Unlock()
+
+	if conflictCount == len(keyList) && !sm.EliminateConflictDone {
+		sm.storeConflictingKeystroke(ks)
+	}
+}
+
+func (sm *ShortcutManager) ungrabSequenceKeystroke(ks *Keystroke, dummy bool) {
+	keyList, err := ks.ToKeyList(sm.keySymbols)
+	if err != nil {
+		logger.Debug(err)
+		return
+	}
+
+	sm.keyKeystrokeMapMu.Lock()
+	defer sm.keyKeystrokeMapMu.Unlock()
+	for _, key := range keyList {
+		list := removeKeystroke(sm.sequenceKeystrokeMap[key], ks)
+		if len(list) > 0 {
+			sm.sequenceKeystrokeMap[key] = list
+			continue
+		}
+		delete(sm.sequenceKeystrokeMap, key)
+		if !dummy && !sm.isKeyGrabbedLocked(key) {
+			key.Ungrab(sm.conn)
+		}
+	}
+}
+
+// 双击和长按通过 record 得到的事件识别，不需要抓取按键
+func (sm *ShortcutManager) addTriggerKeystroke(ks *Keystroke) {
+	keyList, err := ks.ToKeyList(sm.keySymbols)
+	if err != nil {
+		logger.Debug(err)
+		return
+	}
+
+	sm.keyKeystrokeMapMu.Lock()
+	for _, key := range keyList {
+		sm.triggerKeystrokeMap[key] = append(sm.triggerKeystrokeMap[key], ks)
+	}
+	sm.keyKeystrokeMapMu.Unlock()
+}
+
+func (sm *ShortcutManager) removeTriggerKeystroke(ks *Keystroke) {
+	keyList, err := ks.ToKeyList(sm.keySymbols)
+	if err != nil {
+		logger.Debug(err)
+		return
+	}
+
+	sm.keyKeystrokeMapMu.Lock()
+	for _, key := range keyList {
+		list := removeKeystroke(sm.triggerKeystrokeMap[key], ks)
+		if len(list) > 0 {
+			sm.triggerKeystrokeMap[key] = list
+		} else {
+			delete(sm.triggerKeystrokeMap, key)
+		}
+	}
+	sm.keyKeystrokeMapMu.Unlock()
+}
+
+func removeKeystroke(list []*Keystroke, ks *Keystroke) []*Keystroke {
+	result := list[:0]
+	for _, ks0 := range list {
+		if ks0 != ks {
+			result = append(result, ks0)
+		}
+	}
+	return result
+}
+
+func (sm *ShortcutManager) findTriggerKeystroke(code uint8, trigger Trigger) *Keystroke {
+	sm.keyKeystrokeMapMu.Lock()
+	defer sm.keyKeystrokeMapMu.Unlock()
+	for _, ks := range sm.triggerKeystrokeMap[Key{Code: Keycode(code)}] {
+		if ks.Trigger == trigger && ks.Shortcut != nil {
+			return ks
+		}
+	}
+	return nil
+}
+
+func (sm *ShortcutManager) hasHoldTrigger(code uint8) bool {
+	return sm.findTriggerKeystroke(code, TriggerHold) != nil
+}
+
+func (sm *ShortcutManager) handleTrigger(code uint8, trigger Trigger) {
+	ks := sm.findTriggerKeystroke(code, trigger)
+	if ks == nil {
+		return
+	}
+	logger.Debugf("trigger %v fired", ks.DebugString())
+	str := ks.String()
+	sm.emitKeystrokeEvent(true, str)
+	sm.callEventCallback(&KeyEvent{
+		Code:     Keycode(code),
+		Shortcut: ks.Shortcut,
+	})
+	sm.emitKeystrokeEvent(false, str)
+}
+
+// startSequence 按下按键序列的第一个按键后抓取键盘，等待后面的按键
+func (sm *ShortcutManager) startSequence(candidates []*Keystroke) {
+	rootWin := sm.conn.GetDefaultScreen().Root
+	err := keybind.GrabKeyboard(sm.conn, rootWin)
+	if err != nil {
+		logger.Warning("start key sequence, grab keyboard failed:", err)
+		return
+	}
+
+	p := &pendingSequence{
+		candidates: candidates,
+	}
+	p.timer = time.AfterFunc(sequenceTimeout, func() {
+		logger.Debug("key sequence timeout")
+		sm.finishSequence(p, "")
+	})
+	sm.sequenceMu.Lock()
+	sm.pendingSequence = p
+	sm.sequenceMu.Unlock()
+
+	sm.emitKeystrokeEvent(true, candidates[0].sequenceString(0))
+}
+
+// finishSequence 结束按键序列 p，keystroke 是完整的按键序列，为空时表示没有匹配或者超时
+func (sm *ShortcutManager) finishSequence(p *pendingSequence, keystroke string) {
+	sm.sequenceMu.Lock()
+	if sm.pendingSequence != p {
+		sm.sequenceMu.Unlock()
+		return
+	}
+	sm.pendingSequence = nil
+	sm.sequenceMu.Unlock()
+
+	p.timer.Stop()
+	err := keybind.UngrabKeyboard(sm.conn)
+	if err != nil {
+		logger.Warning("ungrab keyboard failed:", err)
+	}
+	sm.emitKeystrokeEvent(false, keystroke)
+}
+
+func (sm *ShortcutManager) cancelSequence() {
+	sm.sequenceMu.Lock()
+	p := sm.pendingSequence
+	sm.sequenceMu.Unlock()
+	if p != nil {
+		sm.finishSequence(p, "")
+	}
+}
+
+// handleSequenceKeyEvent 在等待按键序列时处理按下的按键，返回 false 表示没有在等待按键序列。
+// 等待时按下的按键都不会再触发其他快捷键。
+func (sm *ShortcutManager) handleSequenceKeyEvent(key Key) bool {
+	sm.sequenceMu.Lock()
+	p := sm.pendingSequence
+	sm.sequenceMu.Unlock()
+	if p == nil {
+		return false
+	}
+
+	keystr, _ := sm.keySymbols.LookupString(x.Keycode(key.Code), 0)
+	if _, ok := key2Mod(keystr); ok {
+		// 等待修饰键和后面的按键一起按下
+		return true
+	}
+
+	var candidates []*Keystroke
+	var matched *Keystroke
+	for _, ks := range p.candidates {
+		if !sm.keystrokeMatchKey(ks.Sequence[p.pos], key) {
+			continue
+		}
+		if len(ks.Sequence) == p.pos+1 {
+			matched = ks
+			break
+		}
+		candidates = append(candidates, ks)
+	}
+
+	if matched != nil {
+		sm.finishSequence(p, matched.String())
+		if matched.Shortcut != nil {
+			logger.Debugf("key sequence %v matched", matched.DebugString())
+			sm.callEventCallback(&KeyEvent{
+				Mods:     key.Mods,
+				Code:     key.Code,
+				Shortcut: matched.Shortcut,
+			})
+		}
+		return true
+	}
+	if len(candidates) == 0 {
+		logger.Debug("key sequence not matched")
+		sm.finishSequence(p, "")
+		return true
+	}
+
+	sm.sequenceMu.Lock()
+	if sm.pendingSequence == p {
+		p.candidates = candidates
+		p.pos++
+		p.timer.Reset(sequenceTimeout)
+	}
+	sm.sequenceMu.Unlock()
+	sm.emitKeystrokeEvent(true, candidates[0].sequenceString(p.pos))
+	return true
+}
+
+func (sm *ShortcutManager) keystrokeMatchKey(ks *Keystroke, key Key) bool {
+	keyList, err := ks.ToKeyList(sm.keySymbols)
+	if err != nil {
+		return false
+	}
+	for _, key0 := range keyList {
+		if key0 == key {
+			return true
+		}
+	}
+	return false
+}
+
+// isSequencePrefix 判断按键序列 a 和 b 中的一个是不是另一个的前缀，相同也算，
+// 这时短的按键序列会让长的按键序列无法触发。调用者保证 a 和 b 的第一个按键相同。
+func (sm *ShortcutManager) isSequencePrefix(a, b *Keystroke) bool {
+	n := len(a.Sequence)
+	if len(b.Sequence) < n {
+		n = len(b.Sequence)
+	}
+	for i := 0; i < n; i++ {
+		if !a.Sequence[i].Equal(sm.keySymbols, b.Sequence[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// findNonSimpleConflictingKeystroke 在全局的按键序列、双击和长按中查找和 ks 冲突的按键，
+// 调用者需要持有 keyKeystrokeMapMu
+func (sm *ShortcutManager) findNonSimpleConflictingKeystroke(ks *Keystroke, keyList []Key) *Keystroke {
+	for _, key := range keyList {
+		switch {
+		case ks.Trigger != TriggerPress:
+			for _, ks0 := range sm.triggerKeystrokeMap[key] {
+				if ks0.Trigger == ks.Trigger {
+					return ks0
+				}
+			}
+		case len(ks.Sequence) > 0:
+			for _, ks0 := range sm.sequenceKeystrokeMap[key] {
+				if sm.isSequencePrefix(ks, ks0) {
+					return ks0
+				}
+			}
+		default:
+			// 按键已经是按键序列的第一个按键
+			if list := sm.sequenceKeystrokeMap[key]; len(list) > 0 {
+				return list[0]
+			}
+		}
+	}
+	return nil
+}
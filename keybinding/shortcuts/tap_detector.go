@@ -0,0 +1,159 @@
+/*
+ * Copyright (C) 2016 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package shortcuts
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultHoldTimeout       = 800 * time.Millisecond
+	defaultDoubleTapInterval = 400 * time.Millisecond
+)
+
+// tapDetector 根据 record 得到的按键事件识别单独按键的双击和长按，
+// 只有按下的是唯一的按键时才算，同时按下其他按键或者鼠标按钮会打断识别。
+type tapDetector struct {
+	mu       sync.Mutex
+	pressed  map[uint8]struct{}
+	loneCode uint8 // 唯一按下的按键，没有时为 0
+	// 每次按下唯一的按键时加 1，用于判断长按的定时器是否过期
+	pressSerial uint64
+	holdTimer   *time.Timer
+	holdFired   bool
+	lastTapCode uint8
+	lastTapTime time.Time
+
+	holdTimeout       time.Duration
+	doubleTapInterval time.Duration
+	// hasHold 返回按键 code 是否有长按触发的快捷键，没有时不启动定时器
+	hasHold   func(code uint8) bool
+	triggerCb func(code uint8, trigger Trigger)
+}
+
+func newTapDetector(hasHold func(code uint8) bool, triggerCb func(code uint8, trigger Trigger)) *tapDetector {
+	return &tapDetector{
+		pressed:           make(map[uint8]struct{}),
+		holdTimeout:       defaultHoldTimeout,
+		doubleTapInterval: defaultDoubleTapInterval,
+		hasHold:           hasHold,
+		triggerCb:         triggerCb,
+	}
+}
+
+// handleKeyEvent 处理按键事件，释放按键时返回这次按下是否已经触发了长按
+func (d *tapDetector) handleKeyEvent(pressed bool, code uint8) (holdFired bool) {
+	var trigger Trigger
+	d.mu.Lock()
+	if pressed {
+		d.handlePress(code)
+		d.mu.Unlock()
+		return false
+	}
+
+	_, ok := d.pressed[code]
+	if !ok {
+		d.mu.Unlock()
+		return false
+	}
+	delete(d.pressed, code)
+	if code == d.loneCode {
+		d.stopHoldTimer()
+		holdFired = d.holdFired
+		if !holdFired {
+			now := time.Now()
+			if d.lastTapCode == code && now.Sub(d.lastTapTime) <= d.doubleTapInterval {
+				trigger = TriggerDoubleTap
+				d.lastTapCode = 0
+			} else {
+				d.lastTapCode = code
+				d.lastTapTime = now
+			}
+		}
+		d.loneCode = 0
+		d.holdFired = false
+	}
+	d.mu.Unlock()
+
+	if trigger == TriggerDoubleTap && d.triggerCb != nil {
+		d.triggerCb(code, trigger)
+	}
+	return holdFired
+}
+
+func (d *tapDetector) handlePress(code uint8) {
+	if _, ok := d.pressed[code]; ok {
+		// 自动重复
+		return
+	}
+	d.pressed[code] = struct{}{}
+	if len(d.pressed) > 1 {
+		d.interrupt()
+		return
+	}
+
+	d.loneCode = code
+	d.holdFired = false
+	d.pressSerial++
+	if d.lastTapCode != code {
+		d.lastTapCode = 0
+	}
+	if d.hasHold != nil && d.hasHold(code) {
+		serial := d.pressSerial
+		d.holdTimer = time.AfterFunc(d.holdTimeout, func() {
+			d.handleHoldTimeout(code, serial)
+		})
+	}
+}
+
+func (d *tapDetector) handleHoldTimeout(code uint8, serial uint64) {
+	d.mu.Lock()
+	if d.pressSerial != serial || d.loneCode != code {
+		d.mu.Unlock()
+		return
+	}
+	d.holdFired = true
+	d.holdTimer = nil
+	d.mu.Unlock()
+
+	if d.triggerCb != nil {
+		d.triggerCb(code, TriggerHold)
+	}
+}
+
+func (d *tapDetector) handleButtonEvent() {
+	d.mu.Lock()
+	d.interrupt()
+	d.mu.Unlock()
+}
+
+// interrupt 打断正在进行的双击和长按识别
+func (d *tapDetector) interrupt() {
+	d.stopHoldTimer()
+	d.loneCode = 0
+	d.lastTapCode = 0
+	d.pressSerial++
+}
+
+func (d *tapDetector) stopHoldTimer() {
+	if d.holdTimer != nil {
+		d.holdTimer.Stop()
+		d.holdTimer = nil
+	}
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright (C) 2016 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package shortcuts
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tapRecorder struct {
+	mu       sync.Mutex
+	triggers []Trigger
+}
+
+func (r *tapRecorder) cb(code uint8, trigger Trigger) {
+	r.mu.Lock()
+	r.triggers = append(r.triggers, trigger)
+	r.mu.Unlock()
+}
+
+func (r *tapRecorder) get() []Trigger {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Trigger(nil), r.triggers...)
+}
+
+func newTestTapDetector(r *tapRecorder) *tapDetector {
+	d := newTapDetector(func(code uint8) bool {
+		return code == 50
+	}, r.cb)
+	d.holdTimeout = 50 * time.Millisecond
+	d.doubleTapInterval = 200 * time.Millisecond
+	return d
+}
+
+func TestTapDetectorDoubleTap(t *testing.T) {
+	r := &tapRecorder{}
+	d := newTestTapDetector(r)
+
+	d.handleKeyEvent(true, 62)
+	d.handleKeyEvent(false, 62)
+	assert.Empty(t, r.get())
+	d.handleKeyEvent(true, 62)
+	// 自动重复
+	d.handleKeyEvent(true, 62)
+	d.handleKeyEvent(false, 62)
+	assert.Equal(t, []Trigger{TriggerDoubleTap}, r.get())
+
+	// 中间按下其他按键
+	r = &tapRecorder{}
+	d = newTestTapDetector(r)
+	d.handleKeyEvent(true, 62)
+	d.handleKeyEvent(false, 62)
+	d.handleKeyEvent(true, 38)
+	d.handleKeyEvent(false, 38)
+	d.handleKeyEvent(true, 62)
+	d.handleKeyEvent(false, 62)
+	assert.Empty(t, r.get())
+
+	// 同时按下其他按键
+	d.handleKeyEvent(true, 62)
+	d.handleKeyEvent(true, 38)
+	d.handleKeyEvent(false, 38)
+	d.handleKeyEvent(false, 62)
+	d.handleKeyEvent(true, 62)
+	d.handleKeyEvent(false, 62)
+	assert.Empty(t, r.get())
+
+	// 鼠标按钮
+	d.handleKeyEvent(true, 62)
+	d.handleButtonEvent()
+	d.handleKeyEvent(false, 62)
+	d.handleKeyEvent(true, 62)
+	d.handleKeyEvent(false, 62)
+	assert.Empty(t, r.get())
+}
+
+func TestTapDetectorDoubleTapTimeout(t *testing.T) {
+	r := &tapRecorder{}
+	d := newTestTapDetector(r)
+	d.doubleTapInterval = 10 * time.Millisecond
+
+	d.handleKeyEvent(true, 62)
+	d.handleKeyEvent(false, 62)
+	time.Sleep(30 * time.Millisecond)
+	d.handleKeyEvent(true, 62)
+	d.handleKeyEvent(false, 62)
+	assert.Empty(t, r.get())
+}
+
+func TestTapDetectorHold(t *testing.T) {
+	r := &tapRecorder{}
+	d := newTestTapDetector(r)
+
+	d.handleKeyEvent(true, 50)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, []Trigger{TriggerHold}, r.get())
+	assert.True(t, d.handleKeyEvent(false, 50))
+
+	// 长按之后的释放不算单击
+	d.handleKeyEvent(true, 50)
+	assert.False(t, d.handleKeyEvent(false, 50))
+	assert.Equal(t, []Trigger{TriggerHold}, r.get())
+
+	// 没有长按的快捷键
+	r = &tapRecorder{}
+	d = newTestTapDetector(r)
+	d.handleKeyEvent(true, 62)
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, d.handleKeyEvent(false, 62))
+	assert.Empty(t, r.get())
+
+	// 释放过早
+	d.handleKeyEvent(true, 50)
+	assert.False(t, d.handleKeyEvent(false, 50))
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, r.get())
+}
@@ -73,8 +73,8 @@ func (builder *DAGBuilder) buildDAG() error {
 			if builder.flag.HasFlag(EnableFlagIgnoreMissingModule) {
 				if logLevel == log.LevelDebug {
 					builder.log.Info("no such a module named", name)
-					continue
 				}
+				continue
 			} else {
 				return &EnableError{ModuleName: name, Code: ErrorMissingModule}
 			}
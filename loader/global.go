@@ -69,6 +69,44 @@ func EnableModules(enablingModules []string, disableModules []string, flag Enabl
 	return getLoader().EnableModules(enablingModules, disableModules, flag)
 }
 
+func GetModuleState(name string) (ModuleState, error) {
+	return getLoader().GetModuleState(name)
+}
+
+func ListModuleStatus() []ModuleStatus {
+	return getLoader().ListModuleStatus()
+}
+
+func EnableModule(name string) error {
+	return getLoader().EnableModule(name)
+}
+
+func DisableModule(name string) error {
+	return getLoader().DisableModule(name)
+}
+
+func RestartModule(name string) error {
+	return getLoader().RestartModule(name)
+}
+
+// ConnectModuleStateChanged registers cb, which is called every time the
+// state of a module is changed by the loader.
+func ConnectModuleStateChanged(cb StateChangedCallback) {
+	getLoader().supervisor.connectStateChanged(cb)
+}
+
+func GetStartupProfiles() []*StartupProfile {
+	return getLoader().GetStartupProfiles()
+}
+
+func GetDependencyGraph() *DependencyGraph {
+	return getLoader().GetDependencyGraph()
+}
+
+func ExportDependencyGraph(format string) ([]byte, error) {
+	return getLoader().ExportDependencyGraph(format)
+}
+
 func ToggleLogDebug(enabled bool) {
 	var priority log.Priority = log.LevelInfo
 	if enabled {
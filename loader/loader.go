@@ -72,10 +72,16 @@ func (e *EnableError) Error() string {
 }
 
 type Loader struct {
-	modules Modules
-	log     *log.Logger
-	lock    sync.Mutex
-	service *dbusutil.Service
+	modules    Modules
+	log        *log.Logger
+	lock       sync.Mutex
+	service    *dbusutil.Service
+	supervisor supervisor
+
+	profilesMu sync.Mutex
+	profiles   []*StartupProfile
+	// the latest profile of each module, for the dependency graph
+	moduleProfiles map[string]*ModuleProfile
 }
 
 func (l *Loader) SetLogLevel(pri log.Priority) {
@@ -124,72 +130,82 @@ func (l *Loader) GetModule(name string) Module {
 	return l.modules[name]
 }
 
-func (l *Loader) WaitDependencies(module Module) {
-	for _, dependencyName := range module.GetDependencies() {
-		l.modules[dependencyName].WaitEnable()
-	}
-}
-
 func (l *Loader) EnableModules(enablingModules []string, disableModules []string, flag EnableFlag) error {
 	l.lock.Lock()
-	defer l.lock.Unlock()
-
 	// build a dag
 	startTime := time.Now()
 	builder := NewDAGBuilder(l, enablingModules, disableModules, flag)
 	dag, err := builder.Execute()
 	if err != nil {
+		l.lock.Unlock()
 		return err
 	}
 	endTime := time.Now()
 	duration := endTime.Sub(startTime)
 	l.log.Infof("build dag done, cost %s", duration)
+	profile := newStartupProfile(startTime)
+	profile.BuildDAGMs = toMs(duration)
 
 	// perform a topo sort
 	nodes, ok := dag.TopologicalDag()
+	// the lock is not held while starting modules, so that the state of
+	// modules can be queried during startup.
+	l.lock.Unlock()
 	if !ok {
 		return &EnableError{Code: ErrorCircleDependencies}
 	}
 	endTime = time.Now()
 	duration = endTime.Sub(startTime)
 	l.log.Infof("topo sort done, cost add up to %s", duration)
+	profile.TopoSortMs = toMs(duration) - profile.BuildDAGMs
 
 	// enable modules
+	names := make([]string, 0, len(nodes))
+	var startingModules []Module
+	var moduleProfiles []*ModuleProfile
 	for _, node := range nodes {
 		if node == nil {
 			continue
 		}
-		module := l.modules[node.ID]
-		name := node.ID
-
-		go func() {
-			l.log.Info("enable module", name)
-			startTime := time.Now()
-
-			// wait for its dependency
-			l.WaitDependencies(module)
-			endTime := time.Now()
-			duration := endTime.Sub(startTime)
-			l.log.Info("module", name, "wait done, cost", duration)
-
-			err := module.Enable(true)
-			endTime = time.Now()
-			duration = endTime.Sub(startTime)
-			if err != nil {
-				l.log.Fatalf("enable module %s failed: %s, cost %s", name, err, duration)
-			} else {
-				l.log.Infof("enable module %s done cost %s", name, duration)
-			}
-		}()
+		module := l.GetModule(node.ID)
+		if module == nil {
+			continue
+		}
+		names = append(names, node.ID)
+		opMu := l.supervisor.getOpLock(node.ID)
+		opMu.Lock()
+		if module.IsEnable() {
+			l.supervisor.setState(node.ID, ModuleStateRunning, nil)
+			opMu.Unlock()
+			continue
+		}
+		// mark all modules waiting before starting any of them, so that no
+		// module sees a stale state of its dependencies.
+		l.supervisor.setState(node.ID, ModuleStateWaiting, nil)
+		opMu.Unlock()
+		startingModules = append(startingModules, module)
+		moduleProfiles = append(moduleProfiles, profile.addModule(module))
 	}
 
-	for _, n := range nodes {
-		m := l.modules[n.ID]
-		m.WaitEnable()
+	for i, module := range startingModules {
+		go l.startModule(module, profile.Time, moduleProfiles[i])
 	}
 
+	// a module that fails to start does not stop the others, it is retried
+	// by the supervisor in background.
+	l.supervisor.waitSettled(names)
+
 	endTime = time.Now()
 	duration = endTime.Sub(startTime)
 	l.log.Infof("enable modules done, cost add up to %s", duration)
+
+	profile.TotalMs = toMs(duration)
+	for _, mp := range profile.Modules {
+		state, _ := l.supervisor.getState(mp.Name)
+		mp.State = state.String()
+	}
+	profile.calcCriticalPath()
+	l.addStartupProfile(profile)
+	l.log.Infof("startup critical path: %v", profile.CriticalPath)
 	return nil
 }
@@ -1,8 +1,10 @@
 package loader
 
 import (
+	"errors"
 	"math/rand"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -14,6 +16,9 @@ type Test_Module struct {
 	*ModuleBase
 	dependencies string
 	test         *testing.T
+	// startErr is read by the retry timer goroutine
+	startErrMu sync.Mutex
+	startErr   error
 }
 
 type testItem struct {
@@ -39,7 +44,15 @@ func (d *Test_Module) GetDependencies() []string {
 
 func (d *Test_Module) Start() error {
 	time.Sleep(time.Duration(int32(time.Second) * rand.Int31n(3)))
-	return nil
+	d.startErrMu.Lock()
+	defer d.startErrMu.Unlock()
+	return d.startErr
+}
+
+func (d *Test_Module) setStartErr(err error) {
+	d.startErrMu.Lock()
+	d.startErr = err
+	d.startErrMu.Unlock()
 }
 
 func (d *Test_Module) Stop() error {
@@ -96,3 +109,132 @@ func Test_Loader(t *testing.T) {
 		assert.Equal(t, err, data.output)
 	}
 }
+
+func Test_LoaderSupervise(t *testing.T) {
+	_loader = &Loader{
+		modules: Modules{},
+		log:     log.NewLogger("daemon/loader"),
+	}
+	m1 := NewTestModule("1", "", t)
+	m1.setStartErr(errors.New("start failed"))
+	m2 := NewTestModule("2", "1", t)
+	m3 := NewTestModule("3", "", t)
+	for _, m := range []Module{m1, m2, m3} {
+		Register(m)
+	}
+
+	err := EnableModules([]string{"1", "2", "3"}, nil, EnableFlagNone)
+	assert.Nil(t, err)
+
+	state, err := GetModuleState("1")
+	assert.Nil(t, err)
+	assert.Equal(t, ModuleStateFailed, state)
+	state, _ = GetModuleState("2")
+	assert.Equal(t, ModuleStateBlocked, state)
+	state, _ = GetModuleState("3")
+	assert.Equal(t, ModuleStateRunning, state)
+
+	_, err = GetModuleState("4")
+	assert.NotNil(t, err)
+
+	m1.setStartErr(nil)
+	err = RestartModule("1")
+	assert.Nil(t, err)
+	_loader.supervisor.waitSettled([]string{"2"})
+	state, _ = GetModuleState("2")
+	assert.Equal(t, ModuleStateRunning, state)
+
+	// 1 is depended by the running module 2
+	assert.NotNil(t, DisableModule("1"))
+	assert.NotNil(t, RestartModule("1"))
+	assert.Nil(t, DisableModule("2"))
+	state, _ = GetModuleState("2")
+	assert.Equal(t, ModuleStateDisabled, state)
+}
+
+func Test_getRetryBackoff(t *testing.T) {
+	assert.Equal(t, retryBaseBackoff, getRetryBackoff(1))
+	assert.Equal(t, 2*retryBaseBackoff, getRetryBackoff(2))
+	assert.Equal(t, retryMaxBackoff, getRetryBackoff(10))
+}
+
+func Test_calcCriticalPath(t *testing.T) {
+	p := &StartupProfile{
+		Modules: []*ModuleProfile{
+			{Name: "a", EndMs: 10},
+			{Name: "b", EndMs: 30},
+			{Name: "c", Dependencies: []string{"a", "b"}, EndMs: 50},
+			{Name: "d", Dependencies: []string{"a"}, EndMs: 20},
+		},
+	}
+	p.calcCriticalPath()
+	assert.Equal(t, []string{"b", "c"}, p.CriticalPath)
+	assert.True(t, p.getModule("c").Critical)
+	assert.False(t, p.getModule("a").Critical)
+}
+
+func Test_GetDependencyGraph(t *testing.T) {
+	l := &Loader{}
+	for i := 0; i < startupProfilesMaxLen+2; i++ {
+		l.addStartupProfile(&StartupProfile{
+			Modules: []*ModuleProfile{
+				{Name: "b", Dependencies: []string{"a"}, EnableMs: float64(i)},
+			},
+		})
+	}
+	l.addStartupProfile(&StartupProfile{
+		Modules: []*ModuleProfile{
+			{Name: "c", Dependencies: []string{"a", "b"}},
+		},
+	})
+	assert.Len(t, l.GetStartupProfiles(), startupProfilesMaxLen)
+
+	g := l.GetDependencyGraph()
+	assert.Len(t, g.Nodes, 3)
+	assert.Equal(t, float64(startupProfilesMaxLen+1), g.Nodes[1].EnableMs)
+	assert.Equal(t, []DependencyGraphEdge{
+		{From: "a", To: "b"},
+		{From: "a", To: "c"},
+		{From: "b", To: "c"},
+	}, g.Edges)
+}
+
+func Test_DependencyGraphDot(t *testing.T) {
+	g := &DependencyGraph{
+		Nodes: []DependencyGraphNode{
+			{Name: "a", State: "running", EnableMs: 1.5, Critical: true},
+			{Name: "b", State: "blocked"},
+		},
+		Edges: []DependencyGraphEdge{{From: "a", To: "b"}},
+	}
+	dot := string(g.Dot())
+	assert.Contains(t, dot, `"a" [label="a\nrunning 1.5ms", color=red];`)
+	assert.Contains(t, dot, `"b" [label="b\nblocked 0.0ms", style=dashed];`)
+	assert.Contains(t, dot, `"a" -> "b";`)
+}
+
+func Test_retryModuleAfterDisable(t *testing.T) {
+	l := &Loader{
+		modules: Modules{},
+		log:     log.NewLogger("daemon/loader"),
+	}
+	m := NewTestModule("1", "", t)
+	m.setStartErr(errors.New("start failed"))
+	l.AddModule(m)
+
+	opMu := l.supervisor.getOpLock("1")
+	opMu.Lock()
+	assert.NotNil(t, l.tryStart(m, nil))
+	opMu.Unlock()
+	l.supervisor.mu.Lock()
+	gen := l.supervisor.getStatusNoLock("1").retryGen
+	l.supervisor.mu.Unlock()
+
+	// the retry timer fires after the module is disabled
+	assert.Nil(t, l.DisableModule("1"))
+	m.setStartErr(nil)
+	l.retryModule("1", gen)
+	assert.False(t, m.IsEnable())
+	state, _ := l.GetModuleState("1")
+	assert.Equal(t, ModuleStateDisabled, state)
+}
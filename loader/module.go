@@ -50,6 +50,7 @@ type ModuleBase struct {
 	name    string
 	log     *log.Logger
 	wg      sync.WaitGroup
+	wgOnce  sync.Once
 }
 
 func NewModuleBase(name string, impl ModuleImpl, logger *log.Logger) *ModuleBase {
@@ -78,7 +79,8 @@ func (d *ModuleBase) doEnable(enable bool) error {
 		}
 
 		if enable {
-			d.wg.Done()
+			// a module may be restarted, but only the first enable releases the waiters.
+			d.wgOnce.Do(d.wg.Done)
 		}
 	}
 	d.enabled = enable
@@ -0,0 +1,273 @@
+/*
+ * Copyright (C) 2014 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package loader
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// StartupProfile records the timing of one EnableModules call, all durations
+// are in milliseconds.
+type StartupProfile struct {
+	Time         time.Time
+	BuildDAGMs   float64
+	TopoSortMs   float64
+	TotalMs      float64
+	Modules      []*ModuleProfile
+	CriticalPath []string
+}
+
+type ModuleProfile struct {
+	Name         string
+	Dependencies []string
+	State        string
+	// BeginMs and EndMs are offsets from StartupProfile.Time
+	BeginMs  float64
+	WaitMs   float64
+	EnableMs float64
+	EndMs    float64
+	Critical bool
+}
+
+// startupProfilesMaxLen is the number of the latest startup profiles to keep.
+const startupProfilesMaxLen = 10
+
+func toMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func newStartupProfile(startTime time.Time) *StartupProfile {
+	return &StartupProfile{
+		Time: startTime,
+	}
+}
+
+func (p *StartupProfile) addModule(module Module) *ModuleProfile {
+	mp := &ModuleProfile{
+		Name:         module.Name(),
+		Dependencies: module.GetDependencies(),
+	}
+	p.Modules = append(p.Modules, mp)
+	return mp
+}
+
+func (p *StartupProfile) getModule(name string) *ModuleProfile {
+	for _, mp := range p.Modules {
+		if mp.Name == name {
+			return mp
+		}
+	}
+	return nil
+}
+
+// calcCriticalPath finds the chain of modules which decides when the startup
+// is done: start from the module which finished last, and each time go back to
+// the dependency which finished last.
+func (p *StartupProfile) calcCriticalPath() {
+	var last *ModuleProfile
+	for _, mp := range p.Modules {
+		if last == nil || mp.EndMs > last.EndMs {
+			last = mp
+		}
+	}
+
+	var path []string
+	for cur := last; cur != nil; {
+		cur.Critical = true
+		path = append(path, cur.Name)
+
+		var next *ModuleProfile
+		for _, dep := range cur.Dependencies {
+			mp := p.getModule(dep)
+			if mp == nil || mp.Critical {
+				continue
+			}
+			if next == nil || mp.EndMs > next.EndMs {
+				next = mp
+			}
+		}
+		cur = next
+	}
+
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	p.CriticalPath = path
+}
+
+func (mp *ModuleProfile) recordWait(profileStart, begin time.Time, wait time.Duration) {
+	if mp == nil {
+		return
+	}
+	mp.BeginMs = toMs(begin.Sub(profileStart))
+	mp.WaitMs = toMs(wait)
+	mp.EndMs = mp.BeginMs + mp.WaitMs
+}
+
+func (mp *ModuleProfile) recordEnable(enable time.Duration) {
+	if mp == nil {
+		return
+	}
+	mp.EnableMs = toMs(enable)
+	mp.EndMs = mp.BeginMs + mp.WaitMs + mp.EnableMs
+}
+
+func (l *Loader) addStartupProfile(p *StartupProfile) {
+	l.profilesMu.Lock()
+	l.profiles = append(l.profiles, p)
+	if len(l.profiles) > startupProfilesMaxLen {
+		l.profiles = l.profiles[len(l.profiles)-startupProfilesMaxLen:]
+	}
+	if l.moduleProfiles == nil {
+		l.moduleProfiles = make(map[string]*ModuleProfile)
+	}
+	for _, mp := range p.Modules {
+		l.moduleProfiles[mp.Name] = mp
+	}
+	l.profilesMu.Unlock()
+}
+
+// GetStartupProfiles returns the profiles of the latest EnableModules calls in
+// order.
+func (l *Loader) GetStartupProfiles() []*StartupProfile {
+	l.profilesMu.Lock()
+	defer l.profilesMu.Unlock()
+	result := make([]*StartupProfile, len(l.profiles))
+	copy(result, l.profiles)
+	return result
+}
+
+type DependencyGraph struct {
+	Nodes []DependencyGraphNode
+	Edges []DependencyGraphEdge
+}
+
+type DependencyGraphNode struct {
+	Name     string
+	State    string
+	EnableMs float64
+	Critical bool
+}
+
+// DependencyGraphEdge means module To depends on module From.
+type DependencyGraphEdge struct {
+	From string
+	To   string
+}
+
+// GetDependencyGraph merges the resolved dependency DAGs of all startups, each
+// module uses its latest profile.
+func (l *Loader) GetDependencyGraph() *DependencyGraph {
+	l.profilesMu.Lock()
+	moduleProfiles := make([]*ModuleProfile, 0, len(l.moduleProfiles))
+	for _, mp := range l.moduleProfiles {
+		moduleProfiles = append(moduleProfiles, mp)
+	}
+	l.profilesMu.Unlock()
+
+	graph := &DependencyGraph{}
+	nodeIdx := make(map[string]int)
+	edgeSet := make(map[DependencyGraphEdge]struct{})
+	addNode := func(name string) int {
+		idx, ok := nodeIdx[name]
+		if !ok {
+			state, _ := l.supervisor.getState(name)
+			graph.Nodes = append(graph.Nodes, DependencyGraphNode{
+				Name:  name,
+				State: state.String(),
+			})
+			idx = len(graph.Nodes) - 1
+			nodeIdx[name] = idx
+		}
+		return idx
+	}
+
+	for _, mp := range moduleProfiles {
+		idx := addNode(mp.Name)
+		graph.Nodes[idx].EnableMs = mp.EnableMs
+		graph.Nodes[idx].Critical = mp.Critical
+		for _, dep := range mp.Dependencies {
+			addNode(dep)
+			edge := DependencyGraphEdge{
+				From: dep,
+				To:   mp.Name,
+			}
+			if _, ok := edgeSet[edge]; ok {
+				continue
+			}
+			edgeSet[edge] = struct{}{}
+			graph.Edges = append(graph.Edges, edge)
+		}
+	}
+
+	sort.Slice(graph.Nodes, func(i, j int) bool {
+		return graph.Nodes[i].Name < graph.Nodes[j].Name
+	})
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].From != graph.Edges[j].From {
+			return graph.Edges[i].From < graph.Edges[j].From
+		}
+		return graph.Edges[i].To < graph.Edges[j].To
+	})
+	return graph
+}
+
+func (g *DependencyGraph) JSON() ([]byte, error) {
+	return json.Marshal(g)
+}
+
+// Dot renders the graph in Graphviz dot language, modules on the critical
+// path are drawn in red.
+func (g *DependencyGraph) Dot() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("digraph modules {\n")
+	buf.WriteString("\trankdir=LR;\n")
+	buf.WriteString("\tnode [shape=box];\n")
+	for _, node := range g.Nodes {
+		attrs := fmt.Sprintf("label=\"%s\\n%s %.1fms\"", node.Name, node.State, node.EnableMs)
+		if node.Critical {
+			attrs += ", color=red"
+		}
+		if node.State == ModuleStateFailed.String() || node.State == ModuleStateBlocked.String() {
+			attrs += ", style=dashed"
+		}
+		fmt.Fprintf(&buf, "\t%q [%s];\n", node.Name, attrs)
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&buf, "\t%q -> %q;\n", edge.From, edge.To)
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+// ExportDependencyGraph encodes the dependency graph in format, which can be
+// "dot" or "json".
+func (l *Loader) ExportDependencyGraph(format string) ([]byte, error) {
+	graph := l.GetDependencyGraph()
+	switch format {
+	case "dot":
+		return graph.Dot(), nil
+	case "json":
+		return graph.JSON()
+	}
+	return nil, fmt.Errorf("unsupported graph format %q", format)
+}
@@ -0,0 +1,457 @@
+/*
+ * Copyright (C) 2014 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package loader
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+type ModuleState int
+
+const (
+	ModuleStateDisabled ModuleState = iota
+	ModuleStateWaiting
+	ModuleStateStarting
+	ModuleStateRunning
+	ModuleStateFailed
+	ModuleStateBlocked
+)
+
+func (s ModuleState) String() string {
+	switch s {
+	case ModuleStateDisabled:
+		return "disabled"
+	case ModuleStateWaiting:
+		return "waiting"
+	case ModuleStateStarting:
+		return "starting"
+	case ModuleStateRunning:
+		return "running"
+	case ModuleStateFailed:
+		return "failed"
+	case ModuleStateBlocked:
+		return "blocked"
+	}
+	return fmt.Sprintf("unknown(%d)", int(s))
+}
+
+// settled reports whether the current start attempt of a module is over.
+func (s ModuleState) settled() bool {
+	return s != ModuleStateWaiting && s != ModuleStateStarting
+}
+
+const (
+	retryMaxTimes    = 5
+	retryBaseBackoff = 2 * time.Second
+	retryMaxBackoff  = time.Minute
+)
+
+func getRetryBackoff(retries int) time.Duration {
+	backoff := retryBaseBackoff
+	for i := 1; i < retries; i++ {
+		backoff *= 2
+		if backoff >= retryMaxBackoff {
+			return retryMaxBackoff
+		}
+	}
+	return backoff
+}
+
+type ModuleStatus struct {
+	Name         string
+	State        string
+	Error        string
+	Retries      int
+	Dependencies []string
+}
+
+type moduleStatus struct {
+	state      ModuleState
+	err        error
+	retries    int
+	retryTimer *time.Timer
+	// retryGen is increased every time the retry timer is changed, so a
+	// timer which has already fired can tell it is out of date.
+	retryGen int
+	// opMu serializes starting and stopping the module.
+	opMu sync.Mutex
+}
+
+func (st *moduleStatus) stopRetry() {
+	if st.retryTimer != nil {
+		st.retryTimer.Stop()
+		st.retryTimer = nil
+	}
+	st.retryGen++
+}
+
+type StateChangedCallback func(name string, state ModuleState, err error)
+
+type supervisor struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	statuses  map[string]*moduleStatus
+	callbacks []StateChangedCallback
+}
+
+func (sv *supervisor) getStatusNoLock(name string) *moduleStatus {
+	if sv.statuses == nil {
+		sv.statuses = make(map[string]*moduleStatus)
+	}
+	st, ok := sv.statuses[name]
+	if !ok {
+		st = &moduleStatus{}
+		sv.statuses[name] = st
+	}
+	return st
+}
+
+func (sv *supervisor) getCondNoLock() *sync.Cond {
+	if sv.cond == nil {
+		sv.cond = sync.NewCond(&sv.mu)
+	}
+	return sv.cond
+}
+
+// getOpLock returns the lock which must be held while starting or stopping
+// the module name.
+func (sv *supervisor) getOpLock(name string) *sync.Mutex {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	return &sv.getStatusNoLock(name).opMu
+}
+
+func (sv *supervisor) getState(name string) (ModuleState, error) {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	st := sv.getStatusNoLock(name)
+	return st.state, st.err
+}
+
+func (sv *supervisor) setState(name string, state ModuleState, err error) {
+	sv.mu.Lock()
+	st := sv.getStatusNoLock(name)
+	changed := st.state != state || err != nil
+	st.state = state
+	st.err = err
+	if state == ModuleStateRunning || state == ModuleStateDisabled || state == ModuleStateWaiting {
+		st.retries = 0
+		st.stopRetry()
+	}
+	sv.getCondNoLock().Broadcast()
+	callbacks := sv.callbacks
+	sv.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	for _, cb := range callbacks {
+		cb(name, state, err)
+	}
+}
+
+func (sv *supervisor) connectStateChanged(cb StateChangedCallback) {
+	sv.mu.Lock()
+	sv.callbacks = append(sv.callbacks, cb)
+	sv.mu.Unlock()
+}
+
+// waitSettled blocks until the start attempt of every module in names is over.
+func (sv *supervisor) waitSettled(names []string) {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	for _, name := range names {
+		for !sv.getStatusNoLock(name).state.settled() {
+			sv.getCondNoLock().Wait()
+		}
+	}
+}
+
+// waitDependencies blocks until every dependency of module is settled, and
+// returns the first dependency which is not running.
+func (l *Loader) waitDependencies(module Module) (string, bool) {
+	sv := &l.supervisor
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	for _, dep := range module.GetDependencies() {
+		for {
+			state := sv.getStatusNoLock(dep).state
+			if state.settled() {
+				if state != ModuleStateRunning {
+					return dep, false
+				}
+				break
+			}
+			sv.getCondNoLock().Wait()
+		}
+	}
+	return "", true
+}
+
+func (l *Loader) isDependenciesRunning(module Module) (string, bool) {
+	for _, dep := range module.GetDependencies() {
+		state, _ := l.supervisor.getState(dep)
+		if state != ModuleStateRunning {
+			return dep, false
+		}
+	}
+	return "", true
+}
+
+// startModule waits for the dependencies of module, then starts it, the
+// timing is recorded into prof if it is not nil.
+// Should be called in a new goroutine.
+func (l *Loader) startModule(module Module, profileStart time.Time, prof *ModuleProfile) {
+	name := module.Name()
+	l.log.Info("enable module", name)
+	startTime := time.Now()
+
+	dep, ok := l.waitDependencies(module)
+	duration := time.Since(startTime)
+	l.log.Info("module", name, "wait done, cost", duration)
+	prof.recordWait(profileStart, startTime, duration)
+	if !ok {
+		l.log.Warningf("module %s is blocked, dependency %s is not running", name, dep)
+		l.supervisor.setState(name, ModuleStateBlocked,
+			&EnableError{ModuleName: name, Code: ErrorNoDependencies, detail: dep})
+		return
+	}
+
+	opMu := l.supervisor.getOpLock(name)
+	opMu.Lock()
+	defer opMu.Unlock()
+	// the module may be disabled or started by others while waiting
+	state, _ := l.supervisor.getState(name)
+	if state != ModuleStateWaiting || module.IsEnable() {
+		return
+	}
+	_ = l.tryStart(module, prof)
+}
+
+// tryStart starts module, the caller must hold the op lock of module.
+func (l *Loader) tryStart(module Module, prof *ModuleProfile) error {
+	name := module.Name()
+	startTime := time.Now()
+	l.supervisor.setState(name, ModuleStateStarting, nil)
+	err := module.Enable(true)
+	duration := time.Since(startTime)
+	// must be recorded before the state is settled
+	prof.recordEnable(duration)
+	if err != nil {
+		err = &EnableError{ModuleName: name, Code: ErrorInternalError, detail: err.Error()}
+		l.log.Warningf("enable module %s failed: %s, cost %s", name, err, duration)
+		l.handleStartFailed(name, err)
+		return err
+	}
+
+	l.log.Infof("enable module %s done cost %s", name, duration)
+	l.supervisor.setState(name, ModuleStateRunning, nil)
+	l.startBlockedDependents(name)
+	return nil
+}
+
+func (l *Loader) handleStartFailed(name string, err error) {
+	sv := &l.supervisor
+	sv.mu.Lock()
+	st := sv.getStatusNoLock(name)
+	st.stopRetry()
+	st.retries++
+	if st.retries <= retryMaxTimes {
+		backoff := getRetryBackoff(st.retries)
+		l.log.Infof("module %s will be restarted in %s (%d/%d)", name, backoff, st.retries, retryMaxTimes)
+		gen := st.retryGen
+		st.retryTimer = time.AfterFunc(backoff, func() {
+			l.retryModule(name, gen)
+		})
+	} else {
+		l.log.Warningf("module %s failed too many times, give up", name)
+	}
+	sv.mu.Unlock()
+
+	sv.setState(name, ModuleStateFailed, err)
+}
+
+func (l *Loader) retryModule(name string, gen int) {
+	module := l.GetModule(name)
+	if module == nil {
+		return
+	}
+
+	sv := &l.supervisor
+	opMu := sv.getOpLock(name)
+	opMu.Lock()
+	defer opMu.Unlock()
+
+	// the module may be disabled or restarted after the timer fired
+	sv.mu.Lock()
+	st := sv.getStatusNoLock(name)
+	valid := st.retryGen == gen && st.state == ModuleStateFailed
+	if valid {
+		st.retryTimer = nil
+	}
+	sv.mu.Unlock()
+	if !valid || module.IsEnable() {
+		return
+	}
+	l.log.Info("retry to enable module", name)
+	_ = l.tryStart(module, nil)
+}
+
+// startBlockedDependents starts the modules blocked by the module name, if
+// all of their dependencies are running now.
+func (l *Loader) startBlockedDependents(name string) {
+	for _, module := range l.List() {
+		state, _ := l.supervisor.getState(module.Name())
+		if state != ModuleStateBlocked || !isStrInList(name, module.GetDependencies()) {
+			continue
+		}
+		if _, ok := l.isDependenciesRunning(module); !ok {
+			continue
+		}
+		l.supervisor.setState(module.Name(), ModuleStateWaiting, nil)
+		go l.startModule(module, time.Now(), nil)
+	}
+}
+
+func (l *Loader) GetModuleState(name string) (ModuleState, error) {
+	if l.GetModule(name) == nil {
+		return ModuleStateDisabled, &EnableError{ModuleName: name, Code: ErrorMissingModule}
+	}
+	state, _ := l.supervisor.getState(name)
+	return state, nil
+}
+
+func (l *Loader) ListModuleStatus() []ModuleStatus {
+	modules := l.List()
+	result := make([]ModuleStatus, 0, len(modules))
+	l.supervisor.mu.Lock()
+	for _, m := range modules {
+		st := l.supervisor.getStatusNoLock(m.Name())
+		status := ModuleStatus{
+			Name:         m.Name(),
+			State:        st.state.String(),
+			Retries:      st.retries,
+			Dependencies: m.GetDependencies(),
+		}
+		if st.err != nil {
+			status.Error = st.err.Error()
+		}
+		result = append(result, status)
+	}
+	l.supervisor.mu.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// EnableModule enables the module name and its dependencies at runtime.
+func (l *Loader) EnableModule(name string) error {
+	err := l.EnableModules([]string{name}, nil, EnableFlagNone)
+	if err != nil {
+		return err
+	}
+	state, err := l.supervisor.getState(name)
+	if state != ModuleStateRunning {
+		return err
+	}
+	return nil
+}
+
+// checkDependents returns an error if any module which is running or being
+// started depends on the module name.
+func (l *Loader) checkDependents(name string) error {
+	for _, m := range l.List() {
+		if m.Name() == name || !isStrInList(name, m.GetDependencies()) {
+			continue
+		}
+		state, _ := l.supervisor.getState(m.Name())
+		switch state {
+		case ModuleStateRunning, ModuleStateStarting, ModuleStateWaiting:
+			return fmt.Errorf("can not stop module %s, it is depended by %s", name, m.Name())
+		}
+	}
+	return nil
+}
+
+// DisableModule stops the module name, it fails if any running module
+// depends on it.
+func (l *Loader) DisableModule(name string) error {
+	module := l.GetModule(name)
+	if module == nil {
+		return &EnableError{ModuleName: name, Code: ErrorMissingModule}
+	}
+	err := l.checkDependents(name)
+	if err != nil {
+		return err
+	}
+
+	opMu := l.supervisor.getOpLock(name)
+	opMu.Lock()
+	defer opMu.Unlock()
+	if module.IsEnable() {
+		err = module.Enable(false)
+		if err != nil {
+			return err
+		}
+	}
+	l.supervisor.setState(name, ModuleStateDisabled, nil)
+	return nil
+}
+
+// RestartModule stops the module name if it is running, and starts it again
+// with a fresh retry counter. Like DisableModule, it fails if any running
+// module depends on it.
+func (l *Loader) RestartModule(name string) error {
+	module := l.GetModule(name)
+	if module == nil {
+		return &EnableError{ModuleName: name, Code: ErrorMissingModule}
+	}
+	if dep, ok := l.isDependenciesRunning(module); !ok {
+		return &EnableError{ModuleName: name, Code: ErrorNoDependencies, detail: dep}
+	}
+	err := l.checkDependents(name)
+	if err != nil {
+		return err
+	}
+
+	opMu := l.supervisor.getOpLock(name)
+	opMu.Lock()
+	defer opMu.Unlock()
+	if module.IsEnable() {
+		err = module.Enable(false)
+		if err != nil {
+			return err
+		}
+	}
+	l.supervisor.setState(name, ModuleStateDisabled, nil)
+	return l.tryStart(module, nil)
+}
+
+func isStrInList(item string, list []string) bool {
+	for _, v := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
@@ -30,6 +30,9 @@ type Config struct {
 	Port     uint32
 	User     string
 	Password string
+
+	Profiles []*Profile `json:",omitempty"`
+	Rules    []*AppRule `json:",omitempty"`
 }
 
 func loadConfig(file string) (*Config, error) {
@@ -0,0 +1,317 @@
+/*
+ * Copyright (C) 2017 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package proxychains
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	testTimeout = 10 * time.Second
+	testMagic   = "dde-proxychains-test"
+)
+
+// dialChain 和 proxychains 一样依次通过 proxies 连接到 target
+func dialChain(proxies []*Proxy, chainType string, target string, timeout time.Duration) (net.Conn, error) {
+	if chainType == chainTypeDynamic {
+		// 跳过连接不上的代理
+		var alive []*Proxy
+		for _, p := range proxies {
+			conn, err := net.DialTimeout("tcp", p.addr(), timeout)
+			if err != nil {
+				logger.Debugf("skip proxy %s: %v", p.addr(), err)
+				continue
+			}
+			conn.Close()
+			alive = append(alive, p)
+		}
+		if len(alive) == 0 {
+			return nil, errors.New("all proxies are dead")
+		}
+		proxies = alive
+	}
+	if len(proxies) == 0 {
+		return nil, errors.New("no proxy")
+	}
+
+	conn, err := net.DialTimeout("tcp", proxies[0].addr(), timeout)
+	if err != nil {
+		return nil, err
+	}
+	err = conn.SetDeadline(time.Now().Add(timeout))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	for idx, p := range proxies {
+		next := target
+		if idx < len(proxies)-1 {
+			next = proxies[idx+1].addr()
+		}
+		err = proxyHandshake(conn, p, next)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("proxy %s: %v", p.addr(), err)
+		}
+	}
+
+	err = conn.SetDeadline(time.Time{})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// testProxies 通过代理连接 target，返回花费的时间。
+// target 为空时在本机监听一个端口作为目标，并检查能否通过代理收到数据。
+func testProxies(proxies []*Proxy, chainType string, target string) (time.Duration, error) {
+	if target != "" {
+		t0 := time.Now()
+		conn, err := dialChain(proxies, chainType, target, testTimeout)
+		if err != nil {
+			return 0, err
+		}
+		conn.Close()
+		return time.Since(t0), nil
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		_ = conn.SetDeadline(time.Now().Add(testTimeout))
+		_, _ = conn.Write([]byte(testMagic))
+		conn.Close()
+	}()
+
+	t0 := time.Now()
+	conn, err := dialChain(proxies, chainType, l.Addr().String(), testTimeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	err = conn.SetDeadline(time.Now().Add(testTimeout))
+	if err != nil {
+		return 0, err
+	}
+	buf := make([]byte, len(testMagic))
+	_, err = io.ReadFull(conn, buf)
+	if err != nil {
+		return 0, err
+	}
+	if string(buf) != testMagic {
+		return 0, errors.New("received unexpected data")
+	}
+	return time.Since(t0), nil
+}
+
+func proxyHandshake(conn net.Conn, p *Proxy, target string) error {
+	host, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return err
+	}
+
+	switch p.Type {
+	case "http":
+		return httpConnect(conn, p, target)
+	case "socks4":
+		return socks4Connect(conn, p, host, uint16(port))
+	case "socks5":
+		return socks5Connect(conn, p, host, uint16(port))
+	}
+	return InvalidParamError{"Type"}
+}
+
+func httpConnect(conn net.Conn, p *Proxy, target string) error {
+	req := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n", target, target)
+	if p.User != "" {
+		auth := base64.StdEncoding.EncodeToString([]byte(p.User + ":" + p.Password))
+		req += "Proxy-Authorization: Basic " + auth + "\r\n"
+	}
+	req += "\r\n"
+	_, err := conn.Write([]byte(req))
+	if err != nil {
+		return err
+	}
+
+	// 逐个字节读取，不能读取响应头之后的数据，它们属于下一级代理或者目标
+	var resp []byte
+	buf := make([]byte, 1)
+	for !strings.HasSuffix(string(resp), "\r\n\r\n") {
+		if len(resp) > 4096 {
+			return errors.New("http response header is too long")
+		}
+		_, err = io.ReadFull(conn, buf)
+		if err != nil {
+			return err
+		}
+		resp = append(resp, buf[0])
+	}
+	// HTTP/1.1 200 Connection established
+	fields := strings.Fields(string(resp))
+	if len(fields) < 2 || !strings.HasPrefix(fields[0], "HTTP/") {
+		return errors.New("invalid http response")
+	}
+	if fields[1] != "200" {
+		return fmt.Errorf("http status %s", fields[1])
+	}
+	return nil
+}
+
+func socks4Connect(conn net.Conn, p *Proxy, host string, port uint16) error {
+	req := []byte{4, 1, 0, 0}
+	binary.BigEndian.PutUint16(req[2:], port)
+	ip := net.ParseIP(host).To4()
+	if ip == nil {
+		// socks4a，由代理解析域名
+		ip = net.IPv4(0, 0, 0, 1).To4()
+	}
+	req = append(req, ip...)
+	req = append(req, p.User...)
+	req = append(req, 0)
+	if net.ParseIP(host) == nil {
+		req = append(req, host...)
+		req = append(req, 0)
+	}
+	_, err := conn.Write(req)
+	if err != nil {
+		return err
+	}
+
+	resp := make([]byte, 8)
+	_, err = io.ReadFull(conn, resp)
+	if err != nil {
+		return err
+	}
+	if resp[1] != 0x5a {
+		return fmt.Errorf("socks4 request rejected: %#x", resp[1])
+	}
+	return nil
+}
+
+func socks5Connect(conn net.Conn, p *Proxy, host string, port uint16) error {
+	methods := []byte{0}
+	if p.User != "" {
+		methods = append(methods, 2)
+	}
+	_, err := conn.Write(append([]byte{5, byte(len(methods))}, methods...))
+	if err != nil {
+		return err
+	}
+	resp := make([]byte, 2)
+	_, err = io.ReadFull(conn, resp)
+	if err != nil {
+		return err
+	}
+	if resp[0] != 5 {
+		return errors.New("invalid socks5 version")
+	}
+	switch resp[1] {
+	case 0:
+	case 2:
+		if len(p.User) > 255 || len(p.Password) > 255 {
+			return errors.New("user or password is too long")
+		}
+		auth := []byte{1, byte(len(p.User))}
+		auth = append(auth, p.User...)
+		auth = append(auth, byte(len(p.Password)))
+		auth = append(auth, p.Password...)
+		_, err = conn.Write(auth)
+		if err != nil {
+			return err
+		}
+		_, err = io.ReadFull(conn, resp)
+		if err != nil {
+			return err
+		}
+		if resp[1] != 0 {
+			return errors.New("socks5 authentication failed")
+		}
+	default:
+		return errors.New("no acceptable socks5 authentication method")
+	}
+
+	req := []byte{5, 1, 0}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			req = append(req, 1)
+			req = append(req, ip4...)
+		} else {
+			req = append(req, 4)
+			req = append(req, ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return errors.New("host name is too long")
+		}
+		req = append(req, 3, byte(len(host)))
+		req = append(req, host...)
+	}
+	req = append(req, byte(port>>8), byte(port))
+	_, err = conn.Write(req)
+	if err != nil {
+		return err
+	}
+
+	// VER REP RSV ATYP BND.ADDR BND.PORT
+	head := make([]byte, 4)
+	_, err = io.ReadFull(conn, head)
+	if err != nil {
+		return err
+	}
+	if head[1] != 0 {
+		return fmt.Errorf("socks5 request failed: %#x", head[1])
+	}
+	var addrLen int
+	switch head[3] {
+	case 1:
+		addrLen = net.IPv4len
+	case 4:
+		addrLen = net.IPv6len
+	case 3:
+		_, err = io.ReadFull(conn, resp[:1])
+		if err != nil {
+			return err
+		}
+		addrLen = int(resp[0])
+	default:
+		return errors.New("invalid socks5 address type")
+	}
+	_, err = io.ReadFull(conn, make([]byte, addrLen+2))
+	return err
+}
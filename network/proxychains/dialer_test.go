@@ -0,0 +1,155 @@
+/*
+ * Copyright (C) 2017 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package proxychains
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startFakeProxy 启动一个本地代理，serve 完成握手后返回要连接的地址
+func startFakeProxy(t *testing.T, serve func(conn net.Conn) string) (*Proxy, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				target := serve(conn)
+				if target == "" {
+					return
+				}
+				upstream, err := net.Dial("tcp", target)
+				if err != nil {
+					return
+				}
+				defer upstream.Close()
+				go func() {
+					_, _ = io.Copy(upstream, conn)
+				}()
+				_, _ = io.Copy(conn, upstream)
+			}()
+		}
+	}()
+	addr := l.Addr().(*net.TCPAddr)
+	return &Proxy{IP: "127.0.0.1", Port: uint32(addr.Port)}, func() { l.Close() }
+}
+
+func serveHTTP(conn net.Conn) string {
+	req, err := http.ReadRequest(bufio.NewReader(conn))
+	if err != nil || req.Method != http.MethodConnect {
+		return ""
+	}
+	_, err = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+	if err != nil {
+		return ""
+	}
+	return req.Host
+}
+
+func serveSocks5(user, password string) func(conn net.Conn) string {
+	return func(conn net.Conn) string {
+		buf := make([]byte, 256)
+		_, err := io.ReadFull(conn, buf[:2])
+		if err != nil {
+			return ""
+		}
+		_, err = io.ReadFull(conn, buf[:buf[1]])
+		if err != nil {
+			return ""
+		}
+		if user == "" {
+			_, _ = conn.Write([]byte{5, 0})
+		} else {
+			_, _ = conn.Write([]byte{5, 2})
+			_, err = io.ReadFull(conn, buf[:2])
+			if err != nil {
+				return ""
+			}
+			u := make([]byte, buf[1])
+			_, _ = io.ReadFull(conn, u)
+			_, _ = io.ReadFull(conn, buf[:1])
+			p := make([]byte, buf[0])
+			_, _ = io.ReadFull(conn, p)
+			if string(u) != user || string(p) != password {
+				_, _ = conn.Write([]byte{1, 1})
+				return ""
+			}
+			_, _ = conn.Write([]byte{1, 0})
+		}
+
+		_, err = io.ReadFull(conn, buf[:4])
+		if err != nil || buf[3] != 1 {
+			return ""
+		}
+		_, err = io.ReadFull(conn, buf[:6])
+		if err != nil {
+			return ""
+		}
+		host := net.IP(buf[:4]).String()
+		port := int(buf[4])<<8 | int(buf[5])
+		_, _ = conn.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0})
+		return net.JoinHostPort(host, strconv.Itoa(port))
+	}
+}
+
+func TestTestProxies(t *testing.T) {
+	httpProxy, stop := startFakeProxy(t, serveHTTP)
+	defer stop()
+	httpProxy.Type = "http"
+
+	socksProxy, stop := startFakeProxy(t, serveSocks5("u", "p"))
+	defer stop()
+	socksProxy.Type = "socks5"
+	socksProxy.User = "u"
+	socksProxy.Password = "p"
+
+	_, err := testProxies([]*Proxy{socksProxy, httpProxy}, chainTypeStrict, "")
+	assert.NoError(t, err)
+	_, err = testProxies([]*Proxy{httpProxy, socksProxy}, chainTypeStrict, "")
+	assert.NoError(t, err)
+
+	// 找一个没有监听的端口
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	deadProxy := &Proxy{Type: "http", IP: "127.0.0.1", Port: uint32(l.Addr().(*net.TCPAddr).Port)}
+	l.Close()
+
+	_, err = testProxies([]*Proxy{deadProxy, socksProxy}, chainTypeStrict, "")
+	assert.Error(t, err)
+	_, err = testProxies([]*Proxy{deadProxy, socksProxy}, chainTypeDynamic, "")
+	assert.NoError(t, err)
+	_, err = testProxies([]*Proxy{deadProxy}, chainTypeDynamic, "")
+	assert.Error(t, err)
+
+	badAuth := *socksProxy
+	badAuth.Password = "x"
+	_, err = testProxies([]*Proxy{&badAuth}, chainTypeStrict, "")
+	assert.Error(t, err)
+}
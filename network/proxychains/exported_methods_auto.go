@@ -8,10 +8,47 @@ import (
 
 func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 	return dbusutil.ExportedMethods{
+		{
+			Name:   "DeleteProfile",
+			Fn:     v.DeleteProfile,
+			InArgs: []string{"name"},
+		},
+		{
+			Name:    "GetAppConfFile",
+			Fn:      v.GetAppConfFile,
+			InArgs:  []string{"desktopId", "exe"},
+			OutArgs: []string{"confFile"},
+		},
+		{
+			Name:    "GetAppRules",
+			Fn:      v.GetAppRules,
+			OutArgs: []string{"rulesJSON"},
+		},
+		{
+			Name:    "GetProfiles",
+			Fn:      v.GetProfiles,
+			OutArgs: []string{"profilesJSON"},
+		},
 		{
 			Name:   "Set",
 			Fn:     v.Set,
 			InArgs: []string{"type0", "ip", "port", "user", "password"},
 		},
+		{
+			Name:   "SetAppRule",
+			Fn:     v.SetAppRule,
+			InArgs: []string{"app", "profile"},
+		},
+		{
+			Name:   "SetProfile",
+			Fn:     v.SetProfile,
+			InArgs: []string{"profileJSON"},
+		},
+		{
+			Name:    "TestProfile",
+			Fn:      v.TestProfile,
+			InArgs:  []string{"name", "target"},
+			OutArgs: []string{"latency"},
+		},
 	}
 }
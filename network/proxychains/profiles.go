@@ -0,0 +1,425 @@
+/*
+ * Copyright (C) 2017 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package proxychains
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	dbus "github.com/godbus/dbus"
+	"pkg.deepin.io/lib/dbusutil"
+)
+
+const (
+	chainTypeStrict  = "strict"
+	chainTypeDynamic = "dynamic"
+)
+
+type Proxy struct {
+	Type     string
+	IP       string
+	Port     uint32
+	User     string
+	Password string
+}
+
+func (p *Proxy) check() error {
+	if !validType(p.Type) {
+		return InvalidParamError{"Type"}
+	}
+	if !validIPv4(p.IP) {
+		return InvalidParamError{"IP"}
+	}
+	if p.Port == 0 || p.Port > 65535 {
+		return InvalidParamError{"Port"}
+	}
+	if !validUser(p.User) {
+		return InvalidParamError{"User"}
+	}
+	if !validPassword(p.Password) {
+		return InvalidParamError{"Password"}
+	}
+	if (p.User == "") != (p.Password == "") {
+		return errors.New("user and password are not provided at the same time")
+	}
+	return nil
+}
+
+func (p *Proxy) addr() string {
+	return fmt.Sprintf("%s:%d", p.IP, p.Port)
+}
+
+// Profile 是一组按顺序串联的代理，和 proxychains 一样，strict 要求所有代理都可用，
+// dynamic 跳过不可用的代理，但至少要有一个可用。
+type Profile struct {
+	Name      string
+	ChainType string
+	ProxyDNS  bool
+	Proxies   []*Proxy
+}
+
+func validProfileName(name string) bool {
+	// 名字用作配置文件的文件名
+	return name != "" && !strings.HasPrefix(name, ".") &&
+		!strings.ContainsAny(name, "/\x00")
+}
+
+func (p *Profile) check() error {
+	if !validProfileName(p.Name) {
+		return InvalidParamError{"Name"}
+	}
+	switch p.ChainType {
+	case chainTypeStrict, chainTypeDynamic:
+	default:
+		return InvalidParamError{"ChainType"}
+	}
+	if len(p.Proxies) == 0 {
+		return errors.New("no proxy in profile")
+	}
+	for idx, proxy := range p.Proxies {
+		if proxy == nil {
+			return fmt.Errorf("proxy %d is empty", idx)
+		}
+		err := proxy.check()
+		if err != nil {
+			return fmt.Errorf("proxy %d: %v", idx, err)
+		}
+	}
+	return nil
+}
+
+// AppRule 指定应用使用的代理配置，App 是 desktop ID，或者可执行文件的路径或文件名。
+type AppRule struct {
+	App     string
+	Profile string
+}
+
+func findProfile(profiles []*Profile, name string) (int, *Profile) {
+	for idx, p := range profiles {
+		if p.Name == name {
+			return idx, p
+		}
+	}
+	return -1, nil
+}
+
+// matchAppRule 按照 desktop ID，可执行文件路径，可执行文件名的顺序查找应用的规则
+func matchAppRule(rules []*AppRule, desktopId, exe string) *AppRule {
+	desktopId = strings.TrimSuffix(desktopId, ".desktop")
+	if desktopId != "" {
+		for _, rule := range rules {
+			if strings.TrimSuffix(rule.App, ".desktop") == desktopId {
+				return rule
+			}
+		}
+	}
+	if exe == "" {
+		return nil
+	}
+	for _, rule := range rules {
+		if rule.App == exe {
+			return rule
+		}
+	}
+	exeName := filepath.Base(exe)
+	for _, rule := range rules {
+		if !strings.Contains(rule.App, "/") && rule.App == exeName {
+			return rule
+		}
+	}
+	return nil
+}
+
+func getProxyChainsConfContent(chainType string, proxyDNS bool, proxies []*Proxy) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("# Written by " + dbusInterface + "\n")
+	if chainType == chainTypeDynamic {
+		buf.WriteString("dynamic_chain\n")
+	} else {
+		buf.WriteString("strict_chain\n")
+	}
+	buf.WriteString("quiet_mode\n")
+	if proxyDNS {
+		buf.WriteString("proxy_dns\n")
+	}
+	buf.WriteString(`remote_dns_subnet 224
+tcp_read_time_out 15000
+tcp_connect_time_out 8000
+localnet 127.0.0.0/255.0.0.0
+
+[ProxyList]
+`)
+	for _, p := range proxies {
+		fmt.Fprintf(&buf, "%s\t%s\t%v", p.Type, p.IP, p.Port)
+		if p.User != "" && p.Password != "" {
+			fmt.Fprintf(&buf, "\t%s\t%s", p.User, p.Password)
+		}
+		buf.WriteString("\n")
+	}
+	return buf.Bytes()
+}
+
+func writeProxyChainsConf(file, chainType string, proxyDNS bool, proxies []*Proxy) error {
+	err := os.MkdirAll(filepath.Dir(file), 0700)
+	if err != nil {
+		return err
+	}
+	// 包含代理的密码
+	return ioutil.WriteFile(file, getProxyChainsConfContent(chainType, proxyDNS, proxies), 0600)
+}
+
+func (m *Manager) getProfileConfFile(name string) string {
+	return filepath.Join(m.profilesDir, name+".conf")
+}
+
+func (m *Manager) writeProfileConf(p *Profile) error {
+	return writeProxyChainsConf(m.getProfileConfFile(p.Name), p.ChainType, p.ProxyDNS, p.Proxies)
+}
+
+// removeStaleProfileConfs 删除已经没有对应配置的 conf 文件
+func (m *Manager) removeStaleProfileConfs() {
+	fileInfoList, err := ioutil.ReadDir(m.profilesDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warning(err)
+		}
+		return
+	}
+	for _, fileInfo := range fileInfoList {
+		name := strings.TrimSuffix(fileInfo.Name(), ".conf")
+		if name == fileInfo.Name() {
+			continue
+		}
+		if _, p := findProfile(m.profiles, name); p == nil {
+			err = os.Remove(filepath.Join(m.profilesDir, fileInfo.Name()))
+			if err != nil {
+				logger.Warning(err)
+			}
+		}
+	}
+}
+
+func (m *Manager) setProfile(p *Profile) error {
+	if p.ChainType == "" {
+		p.ChainType = chainTypeStrict
+	}
+	err := p.check()
+	if err != nil {
+		return err
+	}
+
+	m.PropsMu.Lock()
+	defer m.PropsMu.Unlock()
+	idx, _ := findProfile(m.profiles, p.Name)
+	if idx >= 0 {
+		m.profiles[idx] = p
+	} else {
+		m.profiles = append(m.profiles, p)
+	}
+	err = m.saveConfig()
+	if err != nil {
+		return err
+	}
+	return m.writeProfileConf(p)
+}
+
+func (m *Manager) deleteProfile(name string) error {
+	m.PropsMu.Lock()
+	defer m.PropsMu.Unlock()
+	idx, _ := findProfile(m.profiles, name)
+	if idx < 0 {
+		return fmt.Errorf("profile %q not found", name)
+	}
+	m.profiles = append(m.profiles[:idx], m.profiles[idx+1:]...)
+
+	// 使用这个配置的应用不再使用代理
+	rules := m.rules[:0]
+	for _, rule := range m.rules {
+		if rule.Profile != name {
+			rules = append(rules, rule)
+		}
+	}
+	m.rules = rules
+
+	err := m.saveConfig()
+	if err != nil {
+		return err
+	}
+	err = os.Remove(m.getProfileConfFile(name))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// setAppRule 设置应用使用的代理配置，profile 为空时删除应用的规则
+func (m *Manager) setAppRule(app, profile string) error {
+	if app == "" {
+		return InvalidParamError{"App"}
+	}
+
+	m.PropsMu.Lock()
+	defer m.PropsMu.Unlock()
+	if profile != "" {
+		if _, p := findProfile(m.profiles, profile); p == nil {
+			return fmt.Errorf("profile %q not found", profile)
+		}
+	}
+
+	var found bool
+	rules := m.rules[:0]
+	for _, rule := range m.rules {
+		if rule.App == app {
+			found = true
+			if profile == "" {
+				continue
+			}
+			rule.Profile = profile
+		}
+		rules = append(rules, rule)
+	}
+	if !found && profile != "" {
+		rules = append(rules, &AppRule{App: app, Profile: profile})
+	}
+	m.rules = rules
+	return m.saveConfig()
+}
+
+func (m *Manager) getAppConfFile(desktopId, exe string) string {
+	m.PropsMu.RLock()
+	defer m.PropsMu.RUnlock()
+	rule := matchAppRule(m.rules, desktopId, exe)
+	if rule == nil {
+		return ""
+	}
+	return m.getProfileConfFile(rule.Profile)
+}
+
+func (m *Manager) getProfile(name string) (*Profile, error) {
+	m.PropsMu.RLock()
+	defer m.PropsMu.RUnlock()
+	_, p := findProfile(m.profiles, name)
+	if p == nil {
+		return nil, fmt.Errorf("profile %q not found", name)
+	}
+	return p, nil
+}
+
+// initProfiles 加载配置文件中的代理配置，忽略无效的配置，并重新生成 conf 文件
+func (m *Manager) initProfiles(cfg *Config) {
+	for _, p := range cfg.Profiles {
+		if p == nil {
+			continue
+		}
+		err := p.check()
+		if err != nil {
+			logger.Warningf("invalid profile %q: %v", p.Name, err)
+			continue
+		}
+		if _, p0 := findProfile(m.profiles, p.Name); p0 != nil {
+			logger.Warningf("duplicate profile %q", p.Name)
+			continue
+		}
+		m.profiles = append(m.profiles, p)
+	}
+	for _, rule := range cfg.Rules {
+		if rule == nil || rule.App == "" {
+			continue
+		}
+		if _, p := findProfile(m.profiles, rule.Profile); p == nil {
+			logger.Warningf("app %q uses nonexistent profile %q", rule.App, rule.Profile)
+			continue
+		}
+		m.rules = append(m.rules, rule)
+	}
+
+	for _, p := range m.profiles {
+		err := m.writeProfileConf(p)
+		if err != nil {
+			logger.Warning("failed to write profile conf:", err)
+		}
+	}
+	m.removeStaleProfileConfs()
+}
+
+func (m *Manager) GetProfiles() (profilesJSON string, busErr *dbus.Error) {
+	m.PropsMu.RLock()
+	data, err := json.Marshal(m.profiles)
+	m.PropsMu.RUnlock()
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+// SetProfile 添加或者修改代理配置，profileJSON 是 Profile 的 JSON 格式
+func (m *Manager) SetProfile(profileJSON string) *dbus.Error {
+	var p Profile
+	err := json.Unmarshal([]byte(profileJSON), &p)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	err = m.setProfile(&p)
+	return dbusutil.ToError(err)
+}
+
+func (m *Manager) DeleteProfile(name string) *dbus.Error {
+	err := m.deleteProfile(name)
+	return dbusutil.ToError(err)
+}
+
+func (m *Manager) GetAppRules() (rulesJSON string, busErr *dbus.Error) {
+	m.PropsMu.RLock()
+	data, err := json.Marshal(m.rules)
+	m.PropsMu.RUnlock()
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+func (m *Manager) SetAppRule(app, profile string) *dbus.Error {
+	err := m.setAppRule(app, profile)
+	return dbusutil.ToError(err)
+}
+
+// GetAppConfFile 返回应用应该使用的 proxychains 配置文件，没有匹配的规则时返回空字符串
+func (m *Manager) GetAppConfFile(desktopId, exe string) (confFile string, busErr *dbus.Error) {
+	return m.getAppConfFile(desktopId, exe), nil
+}
+
+// TestProfile 测试代理配置是否可用，返回连接 target 花费的毫秒数，
+// target 的格式是 host:port，为空时连接本机的一个临时端口。
+func (m *Manager) TestProfile(name, target string) (latency uint32, busErr *dbus.Error) {
+	p, err := m.getProfile(name)
+	if err != nil {
+		return 0, dbusutil.ToError(err)
+	}
+	d, err := testProxies(p.Proxies, p.ChainType, target)
+	if err != nil {
+		return 0, dbusutil.ToError(err)
+	}
+	return uint32(d / time.Millisecond), nil
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright (C) 2017 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package proxychains
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileCheck(t *testing.T) {
+	p := &Profile{
+		Name:      "work",
+		ChainType: chainTypeDynamic,
+		Proxies: []*Proxy{
+			{Type: "socks5", IP: "127.0.0.1", Port: 1080},
+			{Type: "http", IP: "10.0.0.1", Port: 3128, User: "u", Password: "p"},
+		},
+	}
+	assert.NoError(t, p.check())
+
+	p.Proxies[1].Password = ""
+	assert.Error(t, p.check())
+	p.Proxies[1].Password = "p"
+
+	p.Proxies[0].Port = 0
+	assert.Error(t, p.check())
+	p.Proxies[0].Port = 1080
+
+	p.ChainType = "random"
+	assert.Error(t, p.check())
+	p.ChainType = chainTypeStrict
+
+	for _, name := range []string{"", ".hidden", "a/b"} {
+		p.Name = name
+		assert.Error(t, p.check(), name)
+	}
+	p.Name = "work"
+
+	p.Proxies = nil
+	assert.Error(t, p.check())
+}
+
+func Test_matchAppRule(t *testing.T) {
+	rules := []*AppRule{
+		{App: "code.desktop", Profile: "a"},
+		{App: "/usr/bin/git", Profile: "b"},
+		{App: "curl", Profile: "c"},
+	}
+	assert.Equal(t, "a", matchAppRule(rules, "code", "/usr/share/code/code").Profile)
+	assert.Equal(t, "b", matchAppRule(rules, "", "/usr/bin/git").Profile)
+	assert.Nil(t, matchAppRule(rules, "", "/usr/local/bin/git"))
+	assert.Equal(t, "c", matchAppRule(rules, "", "/usr/bin/curl").Profile)
+	assert.Nil(t, matchAppRule(rules, "wget.desktop", "/usr/bin/wget"))
+}
+
+func Test_getProxyChainsConfContent(t *testing.T) {
+	content := getProxyChainsConfContent(chainTypeDynamic, false, []*Proxy{
+		{Type: "socks5", IP: "127.0.0.1", Port: 1080},
+		{Type: "http", IP: "10.0.0.1", Port: 3128, User: "u", Password: "p"},
+	})
+	assert.Equal(t, `# Written by com.deepin.daemon.Network.ProxyChains
+dynamic_chain
+quiet_mode
+remote_dns_subnet 224
+tcp_read_time_out 15000
+tcp_connect_time_out 8000
+localnet 127.0.0.0/255.0.0.0
+
+[ProxyList]
+socks5	127.0.0.1	1080
+http	10.0.0.1	3128	u	p
+`, string(content))
+}
+
+func TestManagerProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proxychains")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	m := &Manager{
+		jsonFile:    filepath.Join(dir, "proxychains.json"),
+		profilesDir: filepath.Join(dir, "proxychains"),
+	}
+	err = m.setProfile(&Profile{
+		Name:    "work",
+		Proxies: []*Proxy{{Type: "socks5", IP: "127.0.0.1", Port: 1080}},
+	})
+	require.NoError(t, err)
+	confFile := filepath.Join(dir, "proxychains", "work.conf")
+	_, err = os.Stat(confFile)
+	assert.NoError(t, err)
+
+	assert.Error(t, m.setAppRule("git", "home"))
+	require.NoError(t, m.setAppRule("git", "work"))
+	assert.Equal(t, confFile, m.getAppConfFile("", "/usr/bin/git"))
+
+	cfg, err := loadConfig(m.jsonFile)
+	require.NoError(t, err)
+	assert.Equal(t, chainTypeStrict, cfg.Profiles[0].ChainType)
+	assert.Equal(t, []*AppRule{{App: "git", Profile: "work"}}, cfg.Rules)
+
+	require.NoError(t, m.deleteProfile("work"))
+	assert.Empty(t, m.rules)
+	assert.Equal(t, "", m.getAppConfFile("", "/usr/bin/git"))
+	_, err = os.Stat(confFile)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -54,21 +54,28 @@ type Manager struct {
 
 	jsonFile string
 	confFile string
+
+	// 代理配置和应用规则，由 PropsMu 保护
+	profiles    []*Profile
+	rules       []*AppRule
+	profilesDir string
 }
 
 func NewManager(service *dbusutil.Service) *Manager {
 	cfgDir := basedir.GetUserConfigDir()
 	jsonFile := filepath.Join(cfgDir, "deepin", "proxychains.json")
 	confFile := filepath.Join(cfgDir, "deepin", "proxychains.conf")
+	profilesDir := filepath.Join(cfgDir, "deepin", "proxychains")
 	sysBus, err := dbus.SystemBus()
 	if err != nil {
 		logger.Warningf("get sys bus failed, err: %v", err)
 	}
 	m := &Manager{
-		jsonFile: jsonFile,
-		confFile: confFile,
-		service:  service,
-		appProxy: proxy.NewApp(sysBus),
+		jsonFile:    jsonFile,
+		confFile:    confFile,
+		profilesDir: profilesDir,
+		service:     service,
+		appProxy:    proxy.NewApp(sysBus),
 	}
 	go m.init()
 	return m
@@ -104,6 +111,7 @@ func (m *Manager) init() {
 	m.Port = cfg.Port
 	m.User = cfg.User
 	m.Password = cfg.Password
+	m.initProfiles(cfg)
 
 	changed := m.fixConfig()
 	logger.Debug("fixConfig changed:", changed)
@@ -154,6 +162,8 @@ func (m *Manager) saveConfig() error {
 		Port:     m.Port,
 		User:     m.User,
 		Password: m.Password,
+		Profiles: m.profiles,
+		Rules:    m.rules,
 	}
 	return cfg.save(m.jsonFile)
 }
@@ -340,41 +350,13 @@ func (m *Manager) set(type0, ip string, port uint32, user, password string) erro
 }
 
 func (m *Manager) writeConf() error {
-	const head = `# Written by ` + dbusInterface + `
-strict_chain
-quiet_mode
-proxy_dns
-remote_dns_subnet 224
-tcp_read_time_out 15000
-tcp_connect_time_out 8000
-localnet 127.0.0.0/255.0.0.0
-
-[ProxyList]
-`
-	fh, err := os.Create(m.confFile)
-	if err != nil {
-		return err
-	}
-	_, err = fh.WriteString(head)
-	if err != nil {
-		return err
-	}
-
-	proxy := fmt.Sprintf("%s\t%s\t%v", m.Type, m.IP, m.Port)
-	if m.User != "" && m.Password != "" {
-		proxy += fmt.Sprintf("\t%s\t%s", m.User, m.Password)
-	}
-	_, err = fh.WriteString(proxy + "\n")
-	if err != nil {
-		return err
-	}
-
-	err = fh.Sync()
-	if err != nil {
-		return err
-	}
-
-	return fh.Close()
+	return writeProxyChainsConf(m.confFile, chainTypeStrict, true, []*Proxy{{
+		Type:     m.Type,
+		IP:       m.IP,
+		Port:     m.Port,
+		User:     m.User,
+		Password: m.Password,
+	}})
 }
 
 func (m *Manager) removeConf() error {
@@ -0,0 +1,180 @@
+package service_trigger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/godbus/dbus"
+)
+
+// An argument expression selects one event argument, and the nested value in
+// it, variants are unwrapped automatically:
+//
+//	arg1                the second argument
+//	arg1.Volume         the value of key "Volume" of the dict arg1
+//	arg2[0]             the first item of the array arg2
+//	arg1.Metadata.title the nested dict value
+var argExprRegexp = regexp.MustCompile(`^arg(\d+)((?:\.[^.\[\]{}]+|\[\d+\])*)$`)
+var argPlaceholderRegexp = regexp.MustCompile(`%\{(arg\d+(?:\.[^.\[\]{}]+|\[\d+\])*)\}`)
+var argAccessorRegexp = regexp.MustCompile(`\.([^.\[\]{}]+)|\[(\d+)\]`)
+
+func checkArgExpr(expr string) error {
+	if !argExprRegexp.MatchString(expr) {
+		return fmt.Errorf("invalid argument expression %q", expr)
+	}
+	return nil
+}
+
+func getEventArg(eventArgs []interface{}, expr string) (interface{}, error) {
+	match := argExprRegexp.FindStringSubmatch(expr)
+	if match == nil {
+		return nil, fmt.Errorf("invalid argument expression %q", expr)
+	}
+
+	idx, err := strconv.Atoi(match[1])
+	if err != nil {
+		return nil, err
+	}
+	if idx >= len(eventArgs) {
+		return nil, fmt.Errorf("argument index %d out of range", idx)
+	}
+	value := unwrapVariant(eventArgs[idx])
+
+	for _, accessor := range argAccessorRegexp.FindAllStringSubmatch(match[2], -1) {
+		if accessor[1] != "" {
+			value, err = getDictValue(value, accessor[1])
+		} else {
+			value, err = getArrayItem(value, accessor[2])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", expr, err)
+		}
+	}
+	return value, nil
+}
+
+func unwrapVariant(v interface{}) interface{} {
+	for {
+		variant, ok := v.(dbus.Variant)
+		if !ok {
+			return v
+		}
+		v = variant.Value()
+	}
+}
+
+func getDictValue(v interface{}, key string) (interface{}, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return nil, errors.New("not a dict with string keys")
+	}
+	item := rv.MapIndex(reflect.ValueOf(key).Convert(rv.Type().Key()))
+	if !item.IsValid() {
+		return nil, fmt.Errorf("key %q not found", key)
+	}
+	return unwrapVariant(item.Interface()), nil
+}
+
+func getArrayItem(v interface{}, idxStr string) (interface{}, error) {
+	idx, err := strconv.Atoi(idxStr)
+	if err != nil {
+		return nil, err
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, errors.New("not an array")
+	}
+	if idx >= rv.Len() {
+		return nil, fmt.Errorf("index %d out of range", idx)
+	}
+	return unwrapVariant(rv.Index(idx).Interface()), nil
+}
+
+// toPlainValue converts v to a value which can be marshaled to json, the
+// variants in it are unwrapped.
+func toPlainValue(v interface{}) interface{} {
+	v = unwrapVariant(v)
+	switch vv := v.(type) {
+	case []byte:
+		return string(vv)
+	case dbus.ObjectPath:
+		return string(vv)
+	case dbus.Signature:
+		return vv.String()
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		result := make(map[string]interface{}, rv.Len())
+		for _, key := range rv.MapKeys() {
+			result[fmt.Sprint(key.Interface())] = toPlainValue(rv.MapIndex(key).Interface())
+		}
+		return result
+	case reflect.Slice, reflect.Array:
+		result := make([]interface{}, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			result[i] = toPlainValue(rv.Index(i).Interface())
+		}
+		return result
+	case reflect.Struct:
+		// dbus struct
+		result := make([]interface{}, rv.NumField())
+		for i := 0; i < rv.NumField(); i++ {
+			if rv.Type().Field(i).PkgPath != "" {
+				continue
+			}
+			result[i] = toPlainValue(rv.Field(i).Interface())
+		}
+		return result
+	}
+	return v
+}
+
+// formatEventArg formats the scalar value by fmt, and the dict, array and
+// struct value as json.
+func formatEventArg(v interface{}) string {
+	v = toPlainValue(v)
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err == nil {
+			return string(data)
+		}
+	}
+	return fmt.Sprint(v)
+}
+
+// expandExecArg replaces the %{expr} in arg with the formatted event
+// argument, the unresolvable one is kept as it is.
+func expandExecArg(arg string, eventArgs []interface{}) string {
+	return argPlaceholderRegexp.ReplaceAllStringFunc(arg, func(placeholder string) string {
+		expr := strings.TrimSuffix(strings.TrimPrefix(placeholder, "%{"), "}")
+		value, err := getEventArg(eventArgs, expr)
+		if err != nil {
+			logger.Debug(err)
+			return placeholder
+		}
+		return formatEventArg(value)
+	})
+}
+
+// matchEventArgs checks that every expression in conditions selects a value
+// formatted to the expected string.
+func matchEventArgs(eventArgs []interface{}, conditions map[string]string) bool {
+	for expr, expected := range conditions {
+		value, err := getEventArg(eventArgs, expr)
+		if err != nil {
+			return false
+		}
+		if formatEventArg(value) != expected {
+			return false
+		}
+	}
+	return true
+}
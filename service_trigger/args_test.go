@@ -0,0 +1,78 @@
+package service_trigger
+
+import (
+	"testing"
+
+	"github.com/godbus/dbus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_getEventArg(t *testing.T) {
+	// like org.freedesktop.DBus.Properties.PropertiesChanged
+	eventArgs := []interface{}{
+		"com.deepin.daemon.Audio.Sink",
+		map[string]dbus.Variant{
+			"Volume": dbus.MakeVariant(0.5),
+			"Mute":   dbus.MakeVariant(true),
+			"Ports":  dbus.MakeVariant([]string{"speaker", "headphone"}),
+			"Card":   dbus.MakeVariant(map[string]dbus.Variant{"Name": dbus.MakeVariant("hda")}),
+		},
+		[]string{},
+	}
+
+	v, err := getEventArg(eventArgs, "arg0")
+	assert.Nil(t, err)
+	assert.Equal(t, "com.deepin.daemon.Audio.Sink", v)
+
+	v, err = getEventArg(eventArgs, "arg1.Volume")
+	assert.Nil(t, err)
+	assert.Equal(t, 0.5, v)
+
+	v, err = getEventArg(eventArgs, "arg1.Ports[1]")
+	assert.Nil(t, err)
+	assert.Equal(t, "headphone", v)
+
+	v, err = getEventArg(eventArgs, "arg1.Card.Name")
+	assert.Nil(t, err)
+	assert.Equal(t, "hda", v)
+
+	_, err = getEventArg(eventArgs, "arg1.NoSuchKey")
+	assert.NotNil(t, err)
+	_, err = getEventArg(eventArgs, "arg2[0]")
+	assert.NotNil(t, err)
+	_, err = getEventArg(eventArgs, "arg3")
+	assert.NotNil(t, err)
+	_, err = getEventArg(eventArgs, "arg0.")
+	assert.NotNil(t, err)
+}
+
+func Test_expandExecArg(t *testing.T) {
+	eventArgs := []interface{}{
+		"iface",
+		map[string]dbus.Variant{
+			"Mute":  dbus.MakeVariant(true),
+			"Ports": dbus.MakeVariant([]string{"speaker"}),
+		},
+	}
+	assert.Equal(t, "iface mute=true", expandExecArg("%{arg0} mute=%{arg1.Mute}", eventArgs))
+	assert.Equal(t, `["speaker"]`, expandExecArg("%{arg1.Ports}", eventArgs))
+	assert.Equal(t, "%{arg5}", expandExecArg("%{arg5}", eventArgs))
+
+	assert.True(t, matchEventArgs(eventArgs, map[string]string{"arg1.Mute": "true"}))
+	assert.False(t, matchEventArgs(eventArgs, map[string]string{"arg1.Mute": "false"}))
+	assert.False(t, matchEventArgs(eventArgs, map[string]string{"arg1.Volume": "1"}))
+}
+
+func Test_limitedBuffer(t *testing.T) {
+	var buf limitedBuffer
+	data := make([]byte, runOutputMaxLen-1)
+	n, err := buf.Write(data)
+	assert.Nil(t, err)
+	assert.Equal(t, len(data), n)
+	assert.False(t, buf.truncated)
+
+	n, _ = buf.Write([]byte("abc"))
+	assert.Equal(t, 3, n)
+	assert.True(t, buf.truncated)
+	assert.Len(t, buf.data, runOutputMaxLen)
+}
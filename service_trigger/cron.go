@@ -0,0 +1,158 @@
+package service_trigger
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSpec is a crontab like time specification, fields are
+// minute(0-59) hour(0-23) day-of-month(1-31) month(1-12) day-of-week(0-6, 0 is Sunday).
+// Each field can be "*", a number, a range "a-b", a list "a,b" and a step "*/n" or "a-b/n".
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64 // bit sets
+	domStar, dowStar              bool
+}
+
+type cronFieldRange struct {
+	min, max int
+}
+
+var cronFieldRanges = []cronFieldRange{
+	{0, 59}, // minute
+	{0, 23}, // hour
+	{1, 31}, // day of month
+	{1, 12}, // month
+	{0, 7},  // day of week, 7 is Sunday too
+}
+
+func parseCronSpec(spec string) (*cronSpec, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields, got %d", len(fields))
+	}
+
+	var bits [5]uint64
+	for i, field := range fields {
+		var err error
+		bits[i], err = parseCronField(field, cronFieldRanges[i])
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %v", field, err)
+		}
+	}
+
+	// fold 7 into 0 for Sunday
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+		bits[4] &^= 1 << 7
+	}
+
+	// the same as vixie cron, a field starting with "*", such as "*/2",
+	// counts as "*" for the day of month or day of week rule
+	return &cronSpec{
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: strings.HasPrefix(fields[2], "*"),
+		dowStar: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+func parseCronField(field string, r cronFieldRange) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx != -1 {
+			var err error
+			step, err = strconv.Atoi(part[idx+1:])
+			if err != nil || step <= 0 {
+				return 0, errors.New("invalid step")
+			}
+			part = part[:idx]
+		}
+
+		var begin, end int
+		if part == "*" {
+			begin, end = r.min, r.max
+		} else if idx := strings.Index(part, "-"); idx != -1 {
+			var err error
+			begin, err = strconv.Atoi(part[:idx])
+			if err != nil {
+				return 0, err
+			}
+			end, err = strconv.Atoi(part[idx+1:])
+			if err != nil {
+				return 0, err
+			}
+		} else {
+			var err error
+			begin, err = strconv.Atoi(part)
+			if err != nil {
+				return 0, err
+			}
+			end = begin
+			if step != 1 {
+				end = r.max
+			}
+		}
+
+		if begin < r.min || end > r.max || begin > end {
+			return 0, fmt.Errorf("out of range [%d, %d]", r.min, r.max)
+		}
+		for i := begin; i <= end; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+	return bits, nil
+}
+
+func (spec *cronSpec) matchDay(t time.Time) bool {
+	domMatch := spec.dom&(1<<uint(t.Day())) != 0
+	dowMatch := spec.dow&(1<<uint(t.Weekday())) != 0
+	// same as crontab, if both day of month and day of week are restricted,
+	// either of them matches is ok.
+	if !spec.domStar && !spec.dowStar {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+func (spec *cronSpec) match(t time.Time) bool {
+	return spec.minute&(1<<uint(t.Minute())) != 0 &&
+		spec.hour&(1<<uint(t.Hour())) != 0 &&
+		spec.month&(1<<uint(t.Month())) != 0 &&
+		spec.matchDay(t)
+}
+
+// next returns the first matched time after t, or zero time if not found in
+// the next 5 years.
+func (spec *cronSpec) next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(5, 0, 0)
+	for t.Before(end) {
+		year, month, day := t.Date()
+		if spec.month&(1<<uint(month)) == 0 {
+			t = time.Date(year, month+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !spec.matchDay(t) {
+			t = time.Date(year, month, day+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if spec.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(year, month, day, t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if spec.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
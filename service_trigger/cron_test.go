@@ -0,0 +1,65 @@
+package service_trigger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseCronSpec(t *testing.T) {
+	_, err := parseCronSpec("* * * *")
+	assert.NotNil(t, err)
+	_, err = parseCronSpec("60 * * * *")
+	assert.NotNil(t, err)
+	_, err = parseCronSpec("*/0 * * * *")
+	assert.NotNil(t, err)
+	_, err = parseCronSpec("5-1 * * * *")
+	assert.NotNil(t, err)
+
+	spec, err := parseCronSpec("*/15 9-17 * * 1-5")
+	assert.Nil(t, err)
+	// Monday
+	assert.True(t, spec.match(time.Date(2020, 6, 1, 9, 30, 0, 0, time.UTC)))
+	assert.False(t, spec.match(time.Date(2020, 6, 1, 9, 31, 0, 0, time.UTC)))
+	assert.False(t, spec.match(time.Date(2020, 6, 1, 18, 0, 0, 0, time.UTC)))
+	// Sunday
+	assert.False(t, spec.match(time.Date(2020, 6, 7, 9, 30, 0, 0, time.UTC)))
+
+	spec, err = parseCronSpec("0 0 * * 7")
+	assert.Nil(t, err)
+	assert.True(t, spec.match(time.Date(2020, 6, 7, 0, 0, 0, 0, time.UTC)))
+}
+
+func Test_cronSpecNext(t *testing.T) {
+	spec, err := parseCronSpec("30 8 * * 1-5")
+	assert.Nil(t, err)
+	// Friday 9:00 -> Monday 8:30
+	next := spec.next(time.Date(2020, 6, 5, 9, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2020, 6, 8, 8, 30, 0, 0, time.UTC), next)
+
+	spec, err = parseCronSpec("0 12 29 2 *")
+	assert.Nil(t, err)
+	next = spec.next(time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC), next)
+
+	// day of month or day of week
+	spec, err = parseCronSpec("0 0 1 * 0")
+	assert.Nil(t, err)
+	next = spec.next(time.Date(2020, 6, 2, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2020, 6, 7, 0, 0, 0, 0, time.UTC), next)
+
+	// "*/2" counts as "*", so both the day of month and day of week must match
+	spec, err = parseCronSpec("0 0 */2 * 1")
+	assert.Nil(t, err)
+	assert.True(t, spec.match(time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)))
+	assert.False(t, spec.match(time.Date(2020, 6, 3, 0, 0, 0, 0, time.UTC)))
+	assert.False(t, spec.match(time.Date(2020, 6, 8, 0, 0, 0, 0, time.UTC)))
+	next = spec.next(time.Date(2020, 6, 2, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2020, 6, 15, 0, 0, 0, 0, time.UTC), next)
+
+	spec, err = parseCronSpec("* * * * *")
+	assert.Nil(t, err)
+	next = spec.next(time.Date(2020, 6, 2, 0, 0, 30, 0, time.UTC))
+	assert.Equal(t, time.Date(2020, 6, 2, 0, 1, 0, 0, time.UTC), next)
+}
@@ -28,12 +28,34 @@ func (d *Daemon) Start() error {
 	m := newManager()
 	m.start()
 	d.manager = m
+
+	service := loader.GetService()
+	err := service.Export(dbusPath, m)
+	if err != nil {
+		return err
+	}
+
+	err = service.RequestName(dbusServiceName)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
 func (d *Daemon) Stop() error {
 	if d.manager != nil {
-		err := d.manager.stop()
+		service := loader.GetService()
+		err := service.StopExport(d.manager)
+		if err != nil {
+			logger.Warning("StopExport error:", err)
+		}
+
+		err = service.ReleaseName(dbusServiceName)
+		if err != nil {
+			logger.Warning("ReleaseName error:", err)
+		}
+
+		err = d.manager.stop()
 		if err != nil {
 			return err
 		}
@@ -1,6 +1,7 @@
 package service_trigger
 
 import (
+	"os"
 	"strings"
 
 	"github.com/godbus/dbus"
@@ -74,11 +75,10 @@ func (sigMonitor *DBusSignalMonitor) findMatchedServices(signal *dbus.Signal) []
 		if dbusField.Sender == sender &&
 			signal.Name == dbusField.Interface+"."+dbusField.Signal {
 
-			if dbusField.Path != "" {
-				if dbusField.Path == string(signal.Path) {
-					matched = append(matched, service)
-				}
-			} else {
+			if dbusField.Path != "" && dbusField.Path != string(signal.Path) {
+				continue
+			}
+			if dbusField.matchSignal(signal) {
 				matched = append(matched, service)
 			}
 		}
@@ -86,6 +86,48 @@ func (sigMonitor *DBusSignalMonitor) findMatchedServices(signal *dbus.Signal) []
 	return matched
 }
 
+// getSessionPath returns the object path of the login1 session which this
+// process belongs to.
+func getSessionPath(conn *dbus.Conn) (dbus.ObjectPath, error) {
+	obj := conn.Object(login1ServiceName, login1ManagerPath)
+	var path dbus.ObjectPath
+	var err error
+	if sessionId := os.Getenv("XDG_SESSION_ID"); sessionId != "" {
+		err = obj.Call(login1ManagerInterface+".GetSession", 0, sessionId).Store(&path)
+	} else {
+		err = obj.Call(login1ManagerInterface+".GetSessionByPID", 0, uint32(os.Getpid())).Store(&path)
+	}
+	return path, err
+}
+
+// setSessionPaths restricts the login1.Session signals of the Login monitors
+// to the session of this process, otherwise the Lock and Unlock of the other
+// sessions would trigger them. The services are dropped if the session is
+// unknown.
+func (sigMonitor *DBusSignalMonitor) setSessionPaths(conn *dbus.Conn) {
+	var sessionPath dbus.ObjectPath
+	var sessionErr error
+	services := sigMonitor.services[:0]
+	for _, service := range sigMonitor.services {
+		dbusField := service.Monitor.DBus
+		if service.Monitor.Type == monitorTypeLogin && dbusField.Interface == login1SessionInterface {
+			if sessionPath == "" && sessionErr == nil {
+				sessionPath, sessionErr = getSessionPath(conn)
+				if sessionErr != nil {
+					logger.Warning("failed to get the login1 session path:", sessionErr)
+				}
+			}
+			if sessionErr != nil {
+				logger.Warningf("ignore service %v", service)
+				continue
+			}
+			dbusField.Path = string(sessionPath)
+		}
+		services = append(services, service)
+	}
+	sigMonitor.services = services
+}
+
 const ruleNameOwnerChanged = "type='signal'" +
 	",sender='org.freedesktop.DBus',path='/org/freedesktop/DBus'" +
 	",interface='org.freedesktop.DBus',member='NameOwnerChanged'"
@@ -96,6 +138,10 @@ func (sigMonitor *DBusSignalMonitor) init() {
 		logger.Warning(err)
 		return
 	}
+	if sigMonitor.Type == busTypeSystem {
+		sigMonitor.setSessionPaths(conn)
+	}
+
 	rules := []string{ruleNameOwnerChanged}
 	for _, service := range sigMonitor.services {
 		rules = append(rules, service.getDBusMatchRule())
@@ -199,7 +245,7 @@ func (sigMonitor *DBusSignalMonitor) signalLoop(m *Manager) {
 		services := sigMonitor.findMatchedServices(signal)
 		for _, service := range services {
 			logger.Debug("exec service", service)
-			go m.execService(service, signal)
+			go m.execService(service, signal.Body)
 		}
 	}
 	logger.Debug("signalLoop return", sigMonitor.Type)
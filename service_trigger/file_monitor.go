@@ -0,0 +1,302 @@
+package service_trigger
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"pkg.deepin.io/dde/daemon/session/common"
+)
+
+type FileMonitor struct {
+	watcher  *fsnotify.Watcher
+	services []*fileService
+	// the watched directories, only accessed in init and eventLoop
+	watchedDirs map[string]bool
+}
+
+type fileService struct {
+	service  *Service
+	patterns []string
+	ops      fsnotify.Op
+
+	mu             sync.Mutex
+	debounceTimer  *time.Timer
+	debounceSerial uint64
+}
+
+func newFileMonitor() *FileMonitor {
+	return &FileMonitor{}
+}
+
+var fileEventOpMap = map[string]fsnotify.Op{
+	"create": fsnotify.Create,
+	"write":  fsnotify.Write,
+	"remove": fsnotify.Remove,
+	"rename": fsnotify.Rename,
+	"chmod":  fsnotify.Chmod,
+}
+
+func getFileEventName(op fsnotify.Op) string {
+	for _, name := range fileEventNames {
+		if op&fileEventOpMap[name] != 0 {
+			return name
+		}
+	}
+	return ""
+}
+
+func (fm *FileMonitor) appendService(service *Service) {
+	fileField := service.Monitor.File
+	fs := &fileService{
+		service: service,
+	}
+	for _, p := range fileField.Paths {
+		fs.patterns = append(fs.patterns, common.ExpandPath(p))
+	}
+	if len(fileField.Events) == 0 {
+		for _, op := range fileEventOpMap {
+			fs.ops |= op
+		}
+	} else {
+		for _, event := range fileField.Events {
+			fs.ops |= fileEventOpMap[event]
+		}
+	}
+	fm.services = append(fm.services, fs)
+}
+
+func (fm *FileMonitor) init() error {
+	if len(fm.services) == 0 {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	fm.watcher = watcher
+	fm.watchedDirs = make(map[string]bool)
+	fm.updateWatches()
+	return nil
+}
+
+func (fm *FileMonitor) getPatterns() []string {
+	var patterns []string
+	for _, fs := range fm.services {
+		patterns = append(patterns, fs.patterns...)
+	}
+	return patterns
+}
+
+// updateWatches watches the directories returned by getWatchDirs, and stops
+// watching the others, returns the directories newly watched.
+func (fm *FileMonitor) updateWatches() []string {
+	dirs := getWatchDirs(fm.getPatterns())
+	var added []string
+	for dir := range dirs {
+		if fm.watchedDirs[dir] {
+			continue
+		}
+		logger.Debug("watch dir", dir)
+		err := fm.watcher.Add(dir)
+		if err != nil {
+			logger.Warningf("failed to watch %q: %v", dir, err)
+			continue
+		}
+		fm.watchedDirs[dir] = true
+		added = append(added, dir)
+	}
+	for dir := range fm.watchedDirs {
+		if !dirs[dir] {
+			logger.Debug("unwatch dir", dir)
+			// the watch is removed already if the directory is removed
+			_ = fm.watcher.Remove(dir)
+			delete(fm.watchedDirs, dir)
+		}
+	}
+	return added
+}
+
+// getWatchDirs returns the directories to watch for the patterns. The parent
+// directories of the patterns are watched, glob is allowed in the directory
+// part too. If a parent directory does not exist yet, the existing directory
+// in which it will be created is watched instead, so that the monitor starts
+// watching it once it is created.
+func getWatchDirs(patterns []string) map[string]bool {
+	dirs := make(map[string]bool)
+	for _, pattern := range patterns {
+		dirPattern := filepath.Dir(pattern)
+		for _, dir := range globDirs(dirPattern) {
+			dirs[dir] = true
+		}
+		addParentWatchDirs(dirs, dirPattern)
+	}
+	return dirs
+}
+
+// addParentWatchDirs adds the existing directories in which a directory
+// matching dirPattern may be created.
+func addParentWatchDirs(dirs map[string]bool, dirPattern string) {
+	parentPattern := filepath.Dir(dirPattern)
+	if parentPattern == dirPattern {
+		return
+	}
+	base := filepath.Base(dirPattern)
+	parents := globDirs(parentPattern)
+	for _, parent := range parents {
+		if hasGlobMeta(base) || len(globDirs(filepath.Join(parent, base))) == 0 {
+			dirs[parent] = true
+		}
+	}
+	if len(parents) == 0 || hasGlobMeta(parentPattern) {
+		addParentWatchDirs(dirs, parentPattern)
+	}
+}
+
+func globDirs(pattern string) []string {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		logger.Warning(err)
+		return nil
+	}
+	var result []string
+	for _, match := range matches {
+		fileInfo, err := os.Stat(match)
+		if err == nil && fileInfo.IsDir() {
+			result = append(result, match)
+		}
+	}
+	return result
+}
+
+func hasGlobMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
+func (fm *FileMonitor) eventLoop(m *Manager) {
+	if fm.watcher == nil {
+		return
+	}
+
+	for {
+		select {
+		case ev, ok := <-fm.watcher.Events:
+			if !ok {
+				logger.Debug("file monitor eventLoop return")
+				return
+			}
+			fm.handleEvent(m, ev)
+
+		case err, ok := <-fm.watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Warning("file watcher error:", err)
+		}
+	}
+}
+
+func (fm *FileMonitor) handleEvent(m *Manager, ev fsnotify.Event) {
+	if ev.Op&fsnotify.Create != 0 && isDir(ev.Name) ||
+		ev.Op&(fsnotify.Remove|fsnotify.Rename) != 0 && fm.watchedDirs[ev.Name] {
+		for _, dir := range fm.updateWatches() {
+			fm.handleExistingFiles(m, dir)
+		}
+	}
+
+	for _, fs := range fm.services {
+		if ev.Op&fs.ops == 0 || !fs.match(ev.Name) {
+			continue
+		}
+		service := fs.service
+		eventArgs := []interface{}{ev.Name, getFileEventName(ev.Op)}
+		fs.trigger(func() {
+			logger.Debug("exec service", service)
+			m.execService(service, eventArgs)
+		})
+	}
+}
+
+// handleExistingFiles handles the files in the newly watched directory as
+// created, they may be created before the directory is watched.
+func (fm *FileMonitor) handleExistingFiles(m *Manager, dir string) {
+	fileInfos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	for _, fileInfo := range fileInfos {
+		fm.handleEvent(m, fsnotify.Event{
+			Name: filepath.Join(dir, fileInfo.Name()),
+			Op:   fsnotify.Create,
+		})
+	}
+}
+
+func isDir(filename string) bool {
+	fileInfo, err := os.Stat(filename)
+	return err == nil && fileInfo.IsDir()
+}
+
+func (fs *fileService) match(filename string) bool {
+	for _, pattern := range fs.patterns {
+		matched, _ := filepath.Match(pattern, filename)
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// trigger calls exec, if Debounce is set, only the last event of a burst of
+// events calls it.
+func (fs *fileService) trigger(exec func()) {
+	debounce := fs.service.Monitor.File.Debounce
+	if debounce <= 0 {
+		go exec()
+		return
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.debounceTimer != nil {
+		fs.debounceTimer.Stop()
+	}
+	// the timer may be fired already while waiting for the lock, so use a
+	// serial number to find out the stale one.
+	fs.debounceSerial++
+	serial := fs.debounceSerial
+	fs.debounceTimer = time.AfterFunc(time.Duration(debounce)*time.Millisecond, func() {
+		fs.mu.Lock()
+		if serial != fs.debounceSerial {
+			fs.mu.Unlock()
+			return
+		}
+		fs.debounceTimer = nil
+		fs.mu.Unlock()
+
+		exec()
+	})
+}
+
+func (fm *FileMonitor) stop() error {
+	for _, fs := range fm.services {
+		fs.mu.Lock()
+		if fs.debounceTimer != nil {
+			fs.debounceTimer.Stop()
+			fs.debounceTimer = nil
+		}
+		fs.debounceSerial++
+		fs.mu.Unlock()
+	}
+
+	if fm.watcher != nil {
+		return fm.watcher.Close()
+	}
+	return nil
+}
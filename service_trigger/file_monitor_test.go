@@ -0,0 +1,108 @@
+package service_trigger
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFileService(t *testing.T, data string) *fileService {
+	var service Service
+	err := json.Unmarshal([]byte(data), &service)
+	require.Nil(t, err)
+	require.Nil(t, service.check())
+	fm := newFileMonitor()
+	fm.appendService(&service)
+	return fm.services[0]
+}
+
+func Test_fileServiceMatch(t *testing.T) {
+	fs := newTestFileService(t, `{"Name": "test", "Exec": ["true"],
+		"Monitor": {"Type": "File", "File": {"Paths": ["/tmp/a/*.log", "/tmp/*/b/c.conf"], "Events": ["create", "write"]}}}`)
+	assert.Equal(t, fsnotify.Create|fsnotify.Write, fs.ops)
+
+	assert.True(t, fs.match("/tmp/a/x.log"))
+	assert.False(t, fs.match("/tmp/a/x.txt"))
+	assert.False(t, fs.match("/tmp/a/sub/x.log"))
+	assert.True(t, fs.match("/tmp/x/b/c.conf"))
+	assert.False(t, fs.match("/tmp/x/y/b/c.conf"))
+
+	fs = newTestFileService(t, `{"Name": "test", "Exec": ["true"],
+		"Monitor": {"Type": "File", "File": {"Paths": ["/tmp/a"]}}}`)
+	assert.Equal(t, fsnotify.Create|fsnotify.Write|fsnotify.Remove|fsnotify.Rename|fsnotify.Chmod, fs.ops)
+	assert.True(t, fs.match("/tmp/a"))
+}
+
+func Test_fileServiceDebounce(t *testing.T) {
+	fs := newTestFileService(t, `{"Name": "test", "Exec": ["true"],
+		"Monitor": {"Type": "File", "File": {"Paths": ["/tmp/a"], "Debounce": 50}}}`)
+	var count int32
+	exec := func() {
+		atomic.AddInt32(&count, 1)
+	}
+	for i := 0; i < 5; i++ {
+		fs.trigger(exec)
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(150 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+
+	// stop cancels the pending run
+	fm := &FileMonitor{services: []*fileService{fs}}
+	fs.trigger(exec)
+	assert.Nil(t, fm.stop())
+	time.Sleep(150 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+}
+
+func Test_getWatchDirs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "service_trigger")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	require.Nil(t, os.MkdirAll(filepath.Join(dir, "exist"), 0755))
+	require.Nil(t, os.MkdirAll(filepath.Join(dir, "projects/p1/build"), 0755))
+	require.Nil(t, os.MkdirAll(filepath.Join(dir, "projects/p2"), 0755))
+
+	dirs := getWatchDirs([]string{
+		filepath.Join(dir, "exist/*.conf"),
+		// the parent directory does not exist, its parent is watched
+		filepath.Join(dir, "exist/sub/*.conf"),
+		filepath.Join(dir, "not-exist/sub/*.conf"),
+		filepath.Join(dir, "projects/*/build/*.log"),
+	})
+	assert.Equal(t, map[string]bool{
+		filepath.Join(dir, "exist"):             true,
+		dir:                                     true,
+		filepath.Join(dir, "projects"):          true,
+		filepath.Join(dir, "projects/p1/build"): true,
+		filepath.Join(dir, "projects/p2"):       true,
+	}, dirs)
+}
+
+func Test_FileMonitorUpdateWatches(t *testing.T) {
+	dir, err := ioutil.TempDir("", "service_trigger")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	fm := newFileMonitor()
+	fm.services = []*fileService{{patterns: []string{filepath.Join(dir, "a/b/*.conf")}}}
+	require.Nil(t, fm.init())
+	defer fm.stop()
+	assert.Equal(t, map[string]bool{dir: true}, fm.watchedDirs)
+
+	require.Nil(t, os.MkdirAll(filepath.Join(dir, "a/b"), 0755))
+	assert.Equal(t, []string{filepath.Join(dir, "a/b")}, fm.updateWatches())
+	assert.Equal(t, map[string]bool{filepath.Join(dir, "a/b"): true}, fm.watchedDirs)
+
+	require.Nil(t, os.RemoveAll(filepath.Join(dir, "a")))
+	assert.Equal(t, []string{dir}, fm.updateWatches())
+	assert.Equal(t, map[string]bool{dir: true}, fm.watchedDirs)
+}
@@ -0,0 +1,140 @@
+package service_trigger
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	serviceHistoryMaxLen = 20
+	// the skipped runs are kept separately, so that a burst of them does not
+	// push out the records of the real runs.
+	serviceSkippedHistoryMaxLen = 5
+	runOutputMaxLen             = 4096
+)
+
+type RunRecord struct {
+	Time     int64 // unix time in milliseconds
+	Args     []string
+	Skipped  string // the reason why the run is skipped, empty if it runs
+	ExitCode int
+	Stdout   string
+	Stderr   string
+	Error    string
+	Duration int64 // in milliseconds
+}
+
+// limitedBuffer keeps the first runOutputMaxLen bytes written to it.
+type limitedBuffer struct {
+	data      []byte
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	remain := runOutputMaxLen - len(b.data)
+	if remain < len(p) {
+		b.truncated = true
+		if remain > 0 {
+			b.data = append(b.data, p[:remain]...)
+		}
+	} else {
+		b.data = append(b.data, p...)
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return string(b.data) + "\n[truncated]"
+	}
+	return string(b.data)
+}
+
+// acquireRun checks the cooldown and concurrency limits of service, returns
+// the reason if the run should be skipped.
+func (service *Service) acquireRun(now time.Time) string {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	if service.Cooldown > 0 && !service.lastRun.IsZero() &&
+		now.Sub(service.lastRun) < time.Duration(service.Cooldown)*time.Millisecond {
+		return "cooldown"
+	}
+
+	if service.MaxConcurrency > 0 && service.running >= service.MaxConcurrency {
+		return "max concurrency reached"
+	}
+
+	service.running++
+	service.lastRun = now
+	return ""
+}
+
+func (service *Service) releaseRun() {
+	service.mu.Lock()
+	service.running--
+	service.mu.Unlock()
+}
+
+func appendRunRecord(history []*RunRecord, record *RunRecord, maxLen int) []*RunRecord {
+	history = append(history, record)
+	if len(history) > maxLen {
+		history = history[len(history)-maxLen:]
+	}
+	return history
+}
+
+func (service *Service) addRunRecord(record *RunRecord) {
+	service.mu.Lock()
+	if record.Skipped != "" {
+		service.skippedHistory = appendRunRecord(service.skippedHistory, record, serviceSkippedHistoryMaxLen)
+	} else {
+		service.history = appendRunRecord(service.history, record, serviceHistoryMaxLen)
+	}
+	service.mu.Unlock()
+}
+
+// getHistory returns the records of the runs and the skipped runs, sorted by
+// time.
+func (service *Service) getHistory() []*RunRecord {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	result := make([]*RunRecord, 0, len(service.history)+len(service.skippedHistory))
+	result = append(result, service.history...)
+	result = append(result, service.skippedHistory...)
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Time < result[j].Time
+	})
+	return result
+}
+
+type ServiceInfo struct {
+	Id          string
+	Name        string
+	Description string
+	Filename    string
+	Type        string
+	Running     int
+	LastRun     int64 // unix time in milliseconds, 0 if never runs
+}
+
+func (service *Service) getInfo() ServiceInfo {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	info := ServiceInfo{
+		Id:          service.basename,
+		Name:        service.Name,
+		Description: service.Description,
+		Filename:    service.filename,
+		Type:        service.Monitor.Type,
+		Running:     service.running,
+	}
+	if !service.lastRun.IsZero() {
+		info.LastRun = toUnixMs(service.lastRun)
+	}
+	return info
+}
+
+func toUnixMs(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
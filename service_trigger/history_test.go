@@ -0,0 +1,31 @@
+package service_trigger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_serviceHistory(t *testing.T) {
+	var service Service
+	for i := 0; i < serviceHistoryMaxLen; i++ {
+		service.addRunRecord(&RunRecord{Time: int64(i)})
+	}
+	// the skipped runs do not push out the real runs
+	for i := 0; i < serviceSkippedHistoryMaxLen*2; i++ {
+		service.addRunRecord(&RunRecord{Time: int64(100 + i), Skipped: "cooldown"})
+	}
+
+	history := service.getHistory()
+	assert.Len(t, history, serviceHistoryMaxLen+serviceSkippedHistoryMaxLen)
+	assert.Equal(t, int64(0), history[0].Time)
+	assert.Equal(t, "", history[serviceHistoryMaxLen-1].Skipped)
+	assert.Equal(t, int64(100+serviceSkippedHistoryMaxLen), history[serviceHistoryMaxLen].Time)
+	assert.Equal(t, "cooldown", history[len(history)-1].Skipped)
+
+	service.addRunRecord(&RunRecord{Time: 200})
+	history = service.getHistory()
+	assert.Len(t, history, serviceHistoryMaxLen+serviceSkippedHistoryMaxLen)
+	assert.Equal(t, int64(1), history[0].Time)
+	assert.Equal(t, int64(200), history[len(history)-1].Time)
+}
@@ -1,31 +1,53 @@
 package service_trigger
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/godbus/dbus"
+	"pkg.deepin.io/lib/dbusutil"
 	"pkg.deepin.io/lib/log"
 )
 
+//go:generate dbusutil-gen em -type Manager
+
+const (
+	dbusServiceName = "com.deepin.daemon.ServiceTrigger"
+	dbusPath        = "/com/deepin/daemon/ServiceTrigger"
+	dbusInterface   = dbusServiceName
+)
+
 type Manager struct {
 	serviceMap map[string]*Service
 
 	systemSigMonitor  *DBusSignalMonitor
 	sessionSigMonitor *DBusSignalMonitor
+	fileMonitor       *FileMonitor
+	timerMonitor      *TimerMonitor
+	udevMonitor       *UdevMonitor
 }
 
 func newManager() *Manager {
 	m := &Manager{
 		systemSigMonitor:  newDBusSignalMonitor(busTypeSystem),
 		sessionSigMonitor: newDBusSignalMonitor(busTypeSession),
+		fileMonitor:       newFileMonitor(),
+		timerMonitor:      newTimerMonitor(),
+		udevMonitor:       newUdevMonitor(),
 	}
 	return m
 }
 
+func (*Manager) GetInterfaceName() string {
+	return dbusInterface
+}
+
 func (m *Manager) start() {
 	m.loadServices()
 
@@ -34,10 +56,28 @@ func (m *Manager) start() {
 
 	m.systemSigMonitor.init()
 	go m.systemSigMonitor.signalLoop(m)
+
+	err := m.fileMonitor.init()
+	if err != nil {
+		logger.Warning("failed to init file monitor:", err)
+	} else {
+		go m.fileMonitor.eventLoop(m)
+	}
+
+	m.timerMonitor.start(m)
+	m.udevMonitor.start(m)
 }
 
 func (m *Manager) stop() error {
-	err := m.sessionSigMonitor.stop()
+	m.timerMonitor.stop()
+	m.udevMonitor.stop()
+
+	err := m.fileMonitor.stop()
+	if err != nil {
+		return err
+	}
+
+	err = m.sessionSigMonitor.stop()
 	if err != nil {
 		return err
 	}
@@ -51,13 +91,20 @@ func (m *Manager) loadServices() {
 	m.loadServicesFromDir("/etc/deepin-daemon/" + moduleName)
 
 	for _, service := range m.serviceMap {
-		if service.Monitor.Type == "DBus" {
+		switch service.Monitor.Type {
+		case monitorTypeDBus, monitorTypeLogin:
 			dbusField := service.Monitor.DBus
 			if dbusField.BusType == "System" {
 				m.systemSigMonitor.appendService(service)
 			} else if dbusField.BusType == "Session" {
 				m.sessionSigMonitor.appendService(service)
 			}
+		case monitorTypeFile:
+			m.fileMonitor.appendService(service)
+		case monitorTypeTimer:
+			m.timerMonitor.appendService(service)
+		case monitorTypeUdev:
+			m.udevMonitor.appendService(service)
 		}
 	}
 }
@@ -103,18 +150,10 @@ func getNameOwner(conn *dbus.Conn, name string) (string, error) {
 	return owner, err
 }
 
-func newReplacer(signal *dbus.Signal) *strings.Replacer {
-	var oldNewSlice []string
-	for idx, item := range signal.Body {
-		oldStr := fmt.Sprintf("%%{arg%d}", idx)
-		newStr := fmt.Sprintf("%v", item)
-		logger.Debugf("old %q => new %q", oldStr, newStr)
-		oldNewSlice = append(oldNewSlice, oldStr, newStr)
-	}
-	return strings.NewReplacer(oldNewSlice...)
-}
-
-func (m *Manager) execService(service *Service, signal *dbus.Signal) {
+// execService runs the Exec of service, %{argN} in Exec is replaced by the
+// N-th item of eventArgs, such as the body of the dbus signal, the nested
+// value can be selected by %{argN.key} or %{argN[idx]}.
+func (m *Manager) execService(service *Service, eventArgs []interface{}) {
 	if len(service.Exec) == 0 {
 		logger.Warning("service Exec empty")
 		return
@@ -129,16 +168,78 @@ func (m *Manager) execService(service *Service, signal *dbus.Signal) {
 		}
 	}
 
-	replacer := newReplacer(signal)
 	for _, arg := range execArgs {
-		args = append(args, replacer.Replace(arg))
+		args = append(args, expandExecArg(arg, eventArgs))
+	}
+
+	startTime := time.Now()
+	record := &RunRecord{
+		Time: toUnixMs(startTime),
+		Args: args,
 	}
+	defer service.addRunRecord(record)
+
+	record.Skipped = service.acquireRun(startTime)
+	if record.Skipped != "" {
+		logger.Debugf("skip service %v: %s", service, record.Skipped)
+		return
+	}
+	defer service.releaseRun()
 
 	logger.Debugf("run cmd %q %#v", service.Exec[0], args)
+	var stdout, stderr limitedBuffer
 	cmd := exec.Command(service.Exec[0], args...)
-	out, err := cmd.CombinedOutput()
-	logger.Debugf("cmd combined output: %s", out)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	record.Duration = toUnixMs(time.Now()) - record.Time
+	record.Stdout = stdout.String()
+	record.Stderr = stderr.String()
+	logger.Debugf("cmd stdout: %s, stderr: %s", record.Stdout, record.Stderr)
 	if err != nil {
 		logger.Warning(err)
+		record.Error = err.Error()
+		record.ExitCode = -1
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			record.ExitCode = exitErr.ExitCode()
+		}
+	}
+}
+
+func (m *Manager) getService(id string) *Service {
+	for _, service := range m.serviceMap {
+		if service.basename == id {
+			return service
+		}
+	}
+	return nil
+}
+
+func (m *Manager) ListServices() (services string, busErr *dbus.Error) {
+	infos := make([]ServiceInfo, 0, len(m.serviceMap))
+	for _, service := range m.serviceMap {
+		infos = append(infos, service.getInfo())
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Id < infos[j].Id
+	})
+
+	data, err := json.Marshal(infos)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+func (m *Manager) GetServiceHistory(id string) (history string, busErr *dbus.Error) {
+	service := m.getService(id)
+	if service == nil {
+		return "", dbusutil.ToError(fmt.Errorf("service %q not found", id))
+	}
+
+	data, err := json.Marshal(service.getHistory())
+	if err != nil {
+		return "", dbusutil.ToError(err)
 	}
+	return string(data), nil
 }
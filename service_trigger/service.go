@@ -6,7 +6,10 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/godbus/dbus"
 )
@@ -16,20 +19,60 @@ type Service struct {
 	basename string
 	Monitor  struct {
 		Type string
-		DBus *struct { // dbus signal monitor
-			BusType   string // System or Session
-			Sender    string
-			Interface string
-			Signal    string
-			Path      string // optional
+		DBus *DBusField // dbus signal monitor
+		File *struct {  // file monitor
+			Paths    []string // glob is allowed
+			Events   []string // optional, create, write, remove, rename or chmod
+			Debounce int      // optional, in milliseconds
+		}
+		Timer *struct { // timer monitor
+			Cron     string // like crontab, "minute hour day-of-month month day-of-week"
+			Interval string // monotonic timer, like "30m"
+			Delay    string // optional, the first run after start, default is Interval
+		}
+		Udev *struct { // udev monitor
+			Subsystem string
+			DevType   string   // optional
+			Actions   []string // optional, add, remove, change, ...
+		}
+		Login *struct { // systemd-logind monitor
+			Signal string
 		}
 	}
 
-	Name        string
-	Description string
-	Exec        []string
+	Name           string
+	Description    string
+	Exec           []string
+	Cooldown       int // optional, in milliseconds, the min interval between two runs
+	MaxConcurrency int // optional, the max number of runs at the same time, 0 means no limit
+
+	mu             sync.Mutex
+	running        int
+	lastRun        time.Time
+	history        []*RunRecord
+	skippedHistory []*RunRecord
+}
+
+type DBusField struct {
+	BusType   string // System or Session
+	Sender    string
+	Interface string
+	Signal    string
+	Path      string // optional
+
+	Args          map[int]string    // optional, argN='value' in dbus match rule, N in [0, 63]
+	Arg0Namespace string            // optional, arg0namespace='value' in dbus match rule
+	Match         map[string]string // optional, argument expression => expected value
 }
 
+const (
+	monitorTypeDBus  = "DBus"
+	monitorTypeFile  = "File"
+	monitorTypeTimer = "Timer"
+	monitorTypeUdev  = "Udev"
+	monitorTypeLogin = "Login"
+)
+
 func (service *Service) getDBusMatchRule() string {
 	dbusField := service.Monitor.DBus
 
@@ -41,21 +84,78 @@ func (service *Service) getDBusMatchRule() string {
 	if dbusField.Path != "" {
 		rule += fmt.Sprintf(",path='%s'", dbusField.Path)
 	}
+
+	var argIndexes []int
+	for idx := range dbusField.Args {
+		argIndexes = append(argIndexes, idx)
+	}
+	sort.Ints(argIndexes)
+	for _, idx := range argIndexes {
+		rule += fmt.Sprintf(",arg%d='%s'", idx, escapeMatchRuleValue(dbusField.Args[idx]))
+	}
+
+	if dbusField.Arg0Namespace != "" {
+		rule += fmt.Sprintf(",arg0namespace='%s'", dbusField.Arg0Namespace)
+	}
 	return rule
 }
 
-func (service *Service) check() error {
-	if service.Monitor.Type != "DBus" {
-		return fmt.Errorf("unknown Monitor.Type %q" + service.Monitor.Type)
+// escapeMatchRuleValue escapes the apostrophe in the value of match rule.
+func escapeMatchRuleValue(value string) string {
+	return strings.Replace(value, "'", `'\''`, -1)
+}
+
+// matchSignal checks the conditions which are not used in the match rule, and
+// the arg conditions again, since a signal matched other rules is received
+// too.
+func (dbusField *DBusField) matchSignal(signal *dbus.Signal) bool {
+	for idx, value := range dbusField.Args {
+		if idx >= len(signal.Body) {
+			return false
+		}
+		str, ok := signal.Body[idx].(string)
+		if !ok {
+			str = fmt.Sprint(signal.Body[idx])
+		}
+		if str != value {
+			return false
+		}
 	}
 
-	if service.Monitor.Type == "DBus" {
-		err := service.checkDBus()
-		if err != nil {
-			return err
+	if dbusField.Arg0Namespace != "" {
+		if len(signal.Body) == 0 {
+			return false
+		}
+		arg0, ok := signal.Body[0].(string)
+		if !ok || !(arg0 == dbusField.Arg0Namespace ||
+			strings.HasPrefix(arg0, dbusField.Arg0Namespace+".")) {
+			return false
 		}
 	}
 
+	return matchEventArgs(signal.Body, dbusField.Match)
+}
+
+func (service *Service) check() error {
+	var err error
+	switch service.Monitor.Type {
+	case monitorTypeDBus:
+		err = service.checkDBus()
+	case monitorTypeFile:
+		err = service.checkFile()
+	case monitorTypeTimer:
+		err = service.checkTimer()
+	case monitorTypeUdev:
+		err = service.checkUdev()
+	case monitorTypeLogin:
+		err = service.checkLogin()
+	default:
+		err = fmt.Errorf("unknown Monitor.Type %q", service.Monitor.Type)
+	}
+	if err != nil {
+		return err
+	}
+
 	if service.Name == "" {
 		return errors.New("field Name is empty")
 	}
@@ -64,6 +164,14 @@ func (service *Service) check() error {
 		return errors.New("field Exec is empty")
 	}
 
+	if service.Cooldown < 0 {
+		return errors.New("field Cooldown is negative")
+	}
+
+	if service.MaxConcurrency < 0 {
+		return errors.New("field MaxConcurrency is negative")
+	}
+
 	return nil
 }
 
@@ -94,9 +202,173 @@ func (service *Service) checkDBus() error {
 	if dbusField.Signal == "" {
 		return errors.New("field Monitor.DBus.Signal is empty")
 	}
+
+	for idx := range dbusField.Args {
+		if idx < 0 || idx > 63 {
+			return fmt.Errorf("field Monitor.DBus.Args has invalid index %d", idx)
+		}
+	}
+
+	if dbusField.Arg0Namespace != "" {
+		if !isValidNamespace(dbusField.Arg0Namespace) {
+			return errors.New("field Monitor.DBus.Arg0Namespace is invalid")
+		}
+	}
+
+	for expr := range dbusField.Match {
+		err := checkArgExpr(expr)
+		if err != nil {
+			return fmt.Errorf("field Monitor.DBus.Match is invalid: %v", err)
+		}
+	}
+	return nil
+}
+
+// isValidNamespace checks that ns is a bus name or an interface name like
+// string, the elements are separated by dot.
+func isValidNamespace(ns string) bool {
+	for _, elem := range strings.Split(ns, ".") {
+		if elem == "" {
+			return false
+		}
+		for _, r := range elem {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' ||
+				r >= '0' && r <= '9' || r == '_' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+var fileEventNames = []string{"create", "write", "remove", "rename", "chmod"}
+
+func (service *Service) checkFile() error {
+	fileField := service.Monitor.File
+	if fileField == nil {
+		return errors.New("field Monitor.File is nil")
+	}
+
+	if len(fileField.Paths) == 0 {
+		return errors.New("field Monitor.File.Paths is empty")
+	}
+	for _, p := range fileField.Paths {
+		_, err := filepath.Match(p, "")
+		if err != nil {
+			return fmt.Errorf("field Monitor.File.Paths has invalid pattern %q", p)
+		}
+	}
+
+	for _, event := range fileField.Events {
+		if !isStrInList(event, fileEventNames) {
+			return fmt.Errorf("field Monitor.File.Events has invalid event %q", event)
+		}
+	}
+
+	if fileField.Debounce < 0 {
+		return errors.New("field Monitor.File.Debounce is negative")
+	}
+	return nil
+}
+
+func (service *Service) checkTimer() error {
+	timerField := service.Monitor.Timer
+	if timerField == nil {
+		return errors.New("field Monitor.Timer is nil")
+	}
+
+	if (timerField.Cron == "") == (timerField.Interval == "") {
+		return errors.New("one and only one of field Monitor.Timer.Cron and Monitor.Timer.Interval should be set")
+	}
+
+	if timerField.Cron != "" {
+		_, err := parseCronSpec(timerField.Cron)
+		if err != nil {
+			return fmt.Errorf("field Monitor.Timer.Cron is invalid: %v", err)
+		}
+		return nil
+	}
+
+	interval, err := time.ParseDuration(timerField.Interval)
+	if err != nil || interval < time.Second {
+		return errors.New("field Monitor.Timer.Interval is invalid")
+	}
+	if timerField.Delay != "" {
+		delay, err := time.ParseDuration(timerField.Delay)
+		if err != nil || delay < 0 {
+			return errors.New("field Monitor.Timer.Delay is invalid")
+		}
+	}
 	return nil
 }
 
+func (service *Service) checkUdev() error {
+	udevField := service.Monitor.Udev
+	if udevField == nil {
+		return errors.New("field Monitor.Udev is nil")
+	}
+
+	if udevField.Subsystem == "" {
+		return errors.New("field Monitor.Udev.Subsystem is empty")
+	}
+	return nil
+}
+
+const (
+	login1ServiceName      = "org.freedesktop.login1"
+	login1ManagerInterface = login1ServiceName + ".Manager"
+	login1SessionInterface = login1ServiceName + ".Session"
+	login1ManagerPath      = "/org/freedesktop/login1"
+)
+
+var loginSignalInterfaceMap = map[string]string{
+	"SessionNew":         login1ManagerInterface,
+	"SessionRemoved":     login1ManagerInterface,
+	"UserNew":            login1ManagerInterface,
+	"UserRemoved":        login1ManagerInterface,
+	"PrepareForSleep":    login1ManagerInterface,
+	"PrepareForShutdown": login1ManagerInterface,
+	"Lock":               login1SessionInterface,
+	"Unlock":             login1SessionInterface,
+}
+
+// checkLogin checks Monitor.Login, and converts it to a dbus signal monitor
+// of the system bus.
+func (service *Service) checkLogin() error {
+	loginField := service.Monitor.Login
+	if loginField == nil {
+		return errors.New("field Monitor.Login is nil")
+	}
+
+	iface, ok := loginSignalInterfaceMap[loginField.Signal]
+	if !ok {
+		return fmt.Errorf("field Monitor.Login.Signal is invalid: %q", loginField.Signal)
+	}
+
+	dbusField := &DBusField{
+		BusType:   "System",
+		Sender:    login1ServiceName,
+		Interface: iface,
+		Signal:    loginField.Signal,
+	}
+	// the path of the login1.Session signals is set to the session of this
+	// process when the monitor starts, see DBusSignalMonitor.setSessionPaths
+	if iface == login1ManagerInterface {
+		dbusField.Path = login1ManagerPath
+	}
+	service.Monitor.DBus = dbusField
+	return nil
+}
+
+func isStrInList(item string, list []string) bool {
+	for _, v := range list {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
+
 func loadService(filename string) (*Service, error) {
 	data, err := ioutil.ReadFile(filename)
 	if err != nil {
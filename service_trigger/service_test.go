@@ -0,0 +1,73 @@
+package service_trigger
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func checkServiceJSON(t *testing.T, data string) error {
+	var service Service
+	err := json.Unmarshal([]byte(data), &service)
+	require.Nil(t, err, data)
+	return service.check()
+}
+
+func Test_serviceCheck(t *testing.T) {
+	valid := []string{
+		`{"Name": "a", "Exec": ["true"], "Monitor": {"Type": "File", "File": {"Paths": ["~/a/*.conf"], "Events": ["write"], "Debounce": 100}}}`,
+		`{"Name": "a", "Exec": ["true"], "Monitor": {"Type": "Timer", "Timer": {"Cron": "0 9 * * 1-5"}}}`,
+		`{"Name": "a", "Exec": ["true"], "Monitor": {"Type": "Timer", "Timer": {"Interval": "30m", "Delay": "1m"}}}`,
+		`{"Name": "a", "Exec": ["true"], "Monitor": {"Type": "Udev", "Udev": {"Subsystem": "block"}}}`,
+		`{"Name": "a", "Exec": ["true"], "Monitor": {"Type": "Login", "Login": {"Signal": "PrepareForSleep"}}}`,
+		`{"Name": "a", "Exec": ["true"], "Cooldown": 1000, "MaxConcurrency": 1, "Monitor": {"Type": "DBus", "DBus": {"BusType": "System",
+			"Sender": "org.freedesktop.login1", "Interface": "org.freedesktop.login1.Manager", "Signal": "PrepareForSleep",
+			"Path": "/org/freedesktop/login1", "Args": {"0": "x"}, "Arg0Namespace": "com.deepin", "Match": {"arg0": "true"}}}}`,
+	}
+	for _, data := range valid {
+		assert.Nil(t, checkServiceJSON(t, data), data)
+	}
+
+	invalid := []string{
+		`{"Name": "a", "Exec": ["true"], "Monitor": {"Type": "Unknown"}}`,
+		`{"Exec": ["true"], "Monitor": {"Type": "Timer", "Timer": {"Interval": "30m"}}}`,
+		`{"Name": "a", "Monitor": {"Type": "Timer", "Timer": {"Interval": "30m"}}}`,
+		`{"Name": "a", "Exec": ["true"], "Cooldown": -1, "Monitor": {"Type": "Timer", "Timer": {"Interval": "30m"}}}`,
+		`{"Name": "a", "Exec": ["true"], "MaxConcurrency": -1, "Monitor": {"Type": "Timer", "Timer": {"Interval": "30m"}}}`,
+		`{"Name": "a", "Exec": ["true"], "Monitor": {"Type": "File"}}`,
+		`{"Name": "a", "Exec": ["true"], "Monitor": {"Type": "File", "File": {"Paths": []}}}`,
+		`{"Name": "a", "Exec": ["true"], "Monitor": {"Type": "File", "File": {"Paths": ["/a/[b"]}}}`,
+		`{"Name": "a", "Exec": ["true"], "Monitor": {"Type": "File", "File": {"Paths": ["/a"], "Events": ["open"]}}}`,
+		`{"Name": "a", "Exec": ["true"], "Monitor": {"Type": "File", "File": {"Paths": ["/a"], "Debounce": -1}}}`,
+		`{"Name": "a", "Exec": ["true"], "Monitor": {"Type": "Timer", "Timer": {}}}`,
+		`{"Name": "a", "Exec": ["true"], "Monitor": {"Type": "Timer", "Timer": {"Cron": "* * * * *", "Interval": "30m"}}}`,
+		`{"Name": "a", "Exec": ["true"], "Monitor": {"Type": "Timer", "Timer": {"Cron": "* * *"}}}`,
+		`{"Name": "a", "Exec": ["true"], "Monitor": {"Type": "Timer", "Timer": {"Interval": "100ms"}}}`,
+		`{"Name": "a", "Exec": ["true"], "Monitor": {"Type": "Timer", "Timer": {"Interval": "30m", "Delay": "-1m"}}}`,
+		`{"Name": "a", "Exec": ["true"], "Monitor": {"Type": "Udev", "Udev": {}}}`,
+		`{"Name": "a", "Exec": ["true"], "Monitor": {"Type": "Login", "Login": {"Signal": "Unknown"}}}`,
+		`{"Name": "a", "Exec": ["true"], "Monitor": {"Type": "DBus", "DBus": {"BusType": "Other",
+			"Sender": "a.b", "Interface": "a.b", "Signal": "C"}}}`,
+		`{"Name": "a", "Exec": ["true"], "Monitor": {"Type": "DBus", "DBus": {"BusType": "System",
+			"Sender": "a.b", "Interface": "a.b", "Signal": "C", "Path": "a/b"}}}`,
+		`{"Name": "a", "Exec": ["true"], "Monitor": {"Type": "DBus", "DBus": {"BusType": "System",
+			"Sender": "a.b", "Interface": "a.b", "Signal": "C", "Args": {"64": "x"}}}}`,
+		`{"Name": "a", "Exec": ["true"], "Monitor": {"Type": "DBus", "DBus": {"BusType": "System",
+			"Sender": "a.b", "Interface": "a.b", "Signal": "C", "Arg0Namespace": "a..b"}}}`,
+	}
+	for _, data := range invalid {
+		assert.NotNil(t, checkServiceJSON(t, data), data)
+	}
+}
+
+func Test_checkLoginConvertsToDBus(t *testing.T) {
+	var service Service
+	err := json.Unmarshal([]byte(`{"Name": "a", "Exec": ["true"], "Monitor": {"Type": "Login", "Login": {"Signal": "Lock"}}}`), &service)
+	require.Nil(t, err)
+	require.Nil(t, service.check())
+	require.NotNil(t, service.Monitor.DBus)
+	assert.Equal(t, login1SessionInterface, service.Monitor.DBus.Interface)
+	assert.Equal(t, "", service.Monitor.DBus.Path)
+}
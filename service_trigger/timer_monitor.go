@@ -0,0 +1,88 @@
+package service_trigger
+
+import (
+	"time"
+)
+
+type TimerMonitor struct {
+	services []*Service
+	quit     chan struct{}
+}
+
+func newTimerMonitor() *TimerMonitor {
+	return &TimerMonitor{}
+}
+
+func (tm *TimerMonitor) appendService(service *Service) {
+	tm.services = append(tm.services, service)
+}
+
+func (tm *TimerMonitor) start(m *Manager) {
+	tm.quit = make(chan struct{})
+	for _, service := range tm.services {
+		timerField := service.Monitor.Timer
+		if timerField.Cron != "" {
+			// already checked in Service.check
+			spec, _ := parseCronSpec(timerField.Cron)
+			go tm.cronLoop(m, service, spec)
+		} else {
+			interval, _ := time.ParseDuration(timerField.Interval)
+			delay := interval
+			if timerField.Delay != "" {
+				delay, _ = time.ParseDuration(timerField.Delay)
+			}
+			go tm.intervalLoop(m, service, delay, interval)
+		}
+	}
+}
+
+func (tm *TimerMonitor) cronLoop(m *Manager, service *Service, spec *cronSpec) {
+	for {
+		now := time.Now()
+		next := spec.next(now)
+		if next.IsZero() {
+			logger.Warningf("service %v will never be triggered", service)
+			return
+		}
+
+		// wake up at least once a minute, in case of the system time
+		// is changed or the system is suspended.
+		wait := next.Sub(now)
+		if wait > time.Minute {
+			wait = time.Minute
+		}
+
+		select {
+		case <-tm.quit:
+			return
+		case <-time.After(wait):
+		}
+
+		if !time.Now().Before(next) {
+			logger.Debug("exec service", service)
+			go m.execService(service, []interface{}{next.Format(time.RFC3339)})
+		}
+	}
+}
+
+func (tm *TimerMonitor) intervalLoop(m *Manager, service *Service, delay, interval time.Duration) {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-tm.quit:
+			return
+		case now := <-timer.C:
+			logger.Debug("exec service", service)
+			go m.execService(service, []interface{}{now.Format(time.RFC3339)})
+			timer.Reset(interval)
+		}
+	}
+}
+
+func (tm *TimerMonitor) stop() {
+	if tm.quit != nil {
+		close(tm.quit)
+		tm.quit = nil
+	}
+}
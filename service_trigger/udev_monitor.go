@@ -0,0 +1,78 @@
+package service_trigger
+
+import (
+	gudev "pkg.deepin.io/gir/gudev-1.0"
+)
+
+type UdevMonitor struct {
+	services []*Service
+	client   *gudev.Client
+}
+
+func newUdevMonitor() *UdevMonitor {
+	return &UdevMonitor{}
+}
+
+func (um *UdevMonitor) appendService(service *Service) {
+	um.services = append(um.services, service)
+}
+
+// start listens the uevent of gudev client, it requires the glib main loop.
+func (um *UdevMonitor) start(m *Manager) {
+	if len(um.services) == 0 {
+		return
+	}
+
+	var subsystems []string
+	for _, service := range um.services {
+		udevField := service.Monitor.Udev
+		subsystem := udevField.Subsystem
+		if udevField.DevType != "" {
+			subsystem += "/" + udevField.DevType
+		}
+		if !isStrInList(subsystem, subsystems) {
+			subsystems = append(subsystems, subsystem)
+		}
+	}
+
+	um.client = gudev.NewClient(subsystems)
+	if um.client == nil {
+		logger.Warning("failed to new gudev client")
+		return
+	}
+	um.client.Connect("uevent", func(client *gudev.Client, action string, device *gudev.Device) {
+		defer device.Unref()
+		um.handleUEvent(m, action, device)
+	})
+}
+
+func (um *UdevMonitor) handleUEvent(m *Manager, action string, device *gudev.Device) {
+	subsystem := device.GetSubsystem()
+	devType := device.GetDevtype()
+	logger.Debugf("uevent action: %s, subsystem: %s, devtype: %s", action, subsystem, devType)
+
+	for _, service := range um.services {
+		udevField := service.Monitor.Udev
+		if udevField.Subsystem != subsystem {
+			continue
+		}
+		if udevField.DevType != "" && udevField.DevType != devType {
+			continue
+		}
+		if len(udevField.Actions) != 0 && !isStrInList(action, udevField.Actions) {
+			continue
+		}
+
+		eventArgs := []interface{}{action, device.GetSysfsPath(), device.GetDeviceFile(),
+			subsystem, devType}
+		logger.Debug("exec service", service)
+		go m.execService(service, eventArgs)
+	}
+}
+
+func (um *UdevMonitor) stop() {
+	if um.client != nil {
+		um.client.Unref()
+		um.client = nil
+	}
+}
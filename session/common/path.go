@@ -0,0 +1,17 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"pkg.deepin.io/lib/xdg/basedir"
+)
+
+// ExpandPath expands the leading ~ and the environment variables in p.
+func ExpandPath(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		p = basedir.GetUserHomeDir() + p[1:]
+	}
+	return filepath.Clean(os.ExpandEnv(p))
+}
@@ -39,6 +39,7 @@ struct raw_multitouch_event {
     double scale;
     int fingers;
     uint64_t t_start_tap;
+    uint64_t t_begin_tap; // 手指按下的时间，用于判断长按
     guint tap_id;
     bool dblclick;
     bool ignore;
@@ -184,6 +185,7 @@ raw_event_reset(struct raw_multitouch_event *event, bool reset_dblclick)
     event->scale = 0.0;
     event->fingers = 0;
     event->t_start_tap = 0;
+    event->t_begin_tap = 0;
     event->tap_id = 0;
     if (reset_dblclick)
         event->dblclick = false;
@@ -460,7 +462,9 @@ handle_gesture_events(struct libinput_event *ev, int type)
             handle_tap_stop();
             raw_event_reset(raw, true);
             raw->dblclick = true;
+            break;
         }
+        raw->t_begin_tap = libinput_event_gesture_get_time_usec(gesture);
         break;
     case LIBINPUT_EVENT_GESTURE_TAP_END:
         if (libinput_event_gesture_get_cancelled(gesture)) {
@@ -470,6 +474,15 @@ handle_gesture_events(struct libinput_event *ev, int type)
 
         if (!raw->dblclick) {
             raw->fingers = libinput_event_gesture_get_finger_count(gesture);
+            // 按住的时间超过长按时间，当作 hold 而不是 tap，
+            // 没有绑定 hold 的手指数由 dde-session-daemon 当作 tap 处理
+            if (raw->t_begin_tap > 0
+            && (libinput_event_gesture_get_time_usec(gesture) - raw->t_begin_tap) / 1000 >= long_press_duration) {
+                g_debug("[Hold] fingers: %d", raw->fingers);
+                handleGestureEvent(GESTURE_TYPE_HOLD, GESTURE_DIRECTION_NONE, raw->fingers);
+                raw_event_reset(raw, true);
+                break;
+            }
             raw->t_start_tap = libinput_event_gesture_get_time_usec(gesture);
             handle_tap_delay();
         } else {
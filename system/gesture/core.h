@@ -31,6 +31,7 @@
 #define GESTURE_TYPE_SWIPE 100
 #define GESTURE_TYPE_PINCH 101
 #define GESTURE_TYPE_TAP 102
+#define GESTURE_TYPE_HOLD 103
 
 // tap
 #define GESTURE_DIRECTION_NONE 0
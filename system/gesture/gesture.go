@@ -48,6 +48,7 @@ var (
 	GestureTypeSwipe = GestureType(C.GESTURE_TYPE_SWIPE)
 	GestureTypePinch = GestureType(C.GESTURE_TYPE_PINCH)
 	GestureTypeTap   = GestureType(C.GESTURE_TYPE_TAP)
+	GestureTypeHold  = GestureType(C.GESTURE_TYPE_HOLD)
 
 	GestureDirectionNone  = GestureType(C.GESTURE_DIRECTION_NONE)
 	GestureDirectionUp    = GestureType(C.GESTURE_DIRECTION_UP)
@@ -82,6 +83,8 @@ func (t GestureType) String() string {
 		return "pinch"
 	case GestureTypeTap:
 		return "tap"
+	case GestureTypeHold:
+		return "hold"
 	case GestureDirectionNone:
 		return "none"
 	case GestureDirectionUp:
@@ -0,0 +1,384 @@
+package swapsched
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	ctrlMemory  = "memory"
+	ctrlFreezer = "freezer"
+	ctrlBlkio   = "blkio"
+	ctrlCpu     = "cpu"
+)
+
+// v1 中用到的 controller，v2 中对应的是 memory, cpu 和 io，冻结不需要 controller
+var v1Controllers = []string{ctrlMemory, ctrlFreezer, ctrlBlkio, ctrlCpu}
+
+const v2SubtreeControl = "+memory +cpu +io"
+
+// v2 中不允许有进程的 cgroup 开启 controller，开启之前把 cgroup 中的进程移到这个子 cgroup 中。
+// 名字不是合法的应用 ID，不会和应用的 cgroup 冲突。
+const v2LeafCgroup = "@leaf"
+
+// cgroupFS 直接读写 cgroup 文件系统，支持 v1 和 v2(unified)。
+type cgroupFS struct {
+	v2 bool
+	// v1 时是各个 controller 的挂载点，v2 时只有 unified 的挂载点，key 为空字符串
+	mounts map[string]string
+}
+
+func newCgroupFS() (*cgroupFS, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseMountInfo(f)
+}
+
+func parseMountInfo(r io.Reader) (*cgroupFS, error) {
+	v1Mounts := make(map[string]string)
+	var unified string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// 36 25 0:31 / /sys/fs/cgroup/memory rw,nosuid shared:16 - cgroup cgroup rw,memory
+		fields := strings.Fields(scanner.Text())
+		sepIdx := -1
+		for idx, field := range fields {
+			if field == "-" {
+				sepIdx = idx
+				break
+			}
+		}
+		if sepIdx < 5 || len(fields) < sepIdx+4 {
+			continue
+		}
+		mountPoint := fields[4]
+		switch fields[sepIdx+1] {
+		case "cgroup2":
+			unified = mountPoint
+		case "cgroup":
+			for _, opt := range strings.Split(fields[sepIdx+3], ",") {
+				for _, ctrl := range v1Controllers {
+					if opt == ctrl {
+						v1Mounts[ctrl] = mountPoint
+					}
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// 混合模式下 controller 都在 v1 中
+	if v1Mounts[ctrlMemory] != "" {
+		return &cgroupFS{mounts: v1Mounts}, nil
+	}
+	if unified != "" {
+		return &cgroupFS{v2: true, mounts: map[string]string{"": unified}}, nil
+	}
+	return nil, errors.New("cgroup memory controller is not mounted")
+}
+
+func (fs *cgroupFS) version() int {
+	if fs.v2 {
+		return 2
+	}
+	return 1
+}
+
+func (fs *cgroupFS) ctrlDir(ctrl, name string) (string, error) {
+	if fs.v2 {
+		ctrl = ""
+	}
+	mountPoint, ok := fs.mounts[ctrl]
+	if !ok {
+		return "", fmt.Errorf("cgroup controller %s is not mounted", ctrl)
+	}
+	return filepath.Join(mountPoint, name), nil
+}
+
+// dirs 返回 cgroup 在各个挂载点中的目录，v1 中多个 controller 可能挂载在同一个地方，比如 cpu,cpuacct
+func (fs *cgroupFS) dirs(name string) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, mountPoint := range fs.mounts {
+		if seen[mountPoint] {
+			continue
+		}
+		seen[mountPoint] = true
+		result = append(result, filepath.Join(mountPoint, name))
+	}
+	return result
+}
+
+func (fs *cgroupFS) exists(name string) bool {
+	dir, err := fs.ctrlDir(ctrlMemory, name)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(dir)
+	return err == nil
+}
+
+func writeCgroupFile(dir, file, value string) error {
+	return ioutil.WriteFile(filepath.Join(dir, file), []byte(value), 0644)
+}
+
+func readCgroupFile(dir, file string) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// create 创建 cgroup，并把目录和 cgroup.procs 的所有者改为 uid，允许这个用户把自己的进程移进来。
+func (fs *cgroupFS) create(name string, uid int) error {
+	if fs.v2 {
+		// v2 中要在每一级父 cgroup 开启 controller，子 cgroup 才能设置对应的限制
+		root := fs.mounts[""]
+		dir := root
+		parts := strings.Split(filepath.Clean(name), "/")
+		for idx, part := range parts {
+			var err error
+			if dir == root {
+				err = writeCgroupFile(dir, "cgroup.subtree_control", v2SubtreeControl)
+				if err != nil {
+					// 根 cgroup 的 controller 一般已经由 systemd 开启了
+					logger.Debug("failed to enable controllers in root cgroup:", err)
+				}
+			} else {
+				err = enableV2Controllers(dir, uid)
+				if err != nil {
+					return err
+				}
+			}
+			dir = filepath.Join(dir, part)
+			if idx < len(parts)-1 {
+				err = os.MkdirAll(dir, 0755)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for _, dir := range fs.dirs(name) {
+		err := os.MkdirAll(dir, 0755)
+		if err != nil {
+			return err
+		}
+		files := []string{"", "cgroup.procs"}
+		if !fs.v2 {
+			files = append(files, "tasks")
+		}
+		for _, file := range files {
+			err = os.Chown(filepath.Join(dir, file), uid, uid)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// enableV2Controllers 为 dir 的子 cgroup 开启 controller，dir 中有进程时先把进程移到 v2LeafCgroup 中，
+// 比如直接启动在 uiapps 中的进程，否则写入 cgroup.subtree_control 会返回 EBUSY。
+func enableV2Controllers(dir string, uid int) error {
+	content, err := readCgroupFile(dir, "cgroup.procs")
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	pids := strings.Fields(content)
+	if len(pids) > 0 {
+		leaf := filepath.Join(dir, v2LeafCgroup)
+		err = os.MkdirAll(leaf, 0755)
+		if err != nil {
+			return err
+		}
+		for _, file := range []string{"", "cgroup.procs"} {
+			err = os.Chown(filepath.Join(leaf, file), uid, uid)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		for _, pid := range pids {
+			err = writeCgroupFile(leaf, "cgroup.procs", pid)
+			if err != nil {
+				// 进程可能已经退出，没有移走的进程会导致下面开启 controller 失败
+				logger.Debugf("failed to move process %s to %s: %v", pid, leaf, err)
+			}
+		}
+	}
+	return writeCgroupFile(dir, "cgroup.subtree_control", v2SubtreeControl)
+}
+
+// remove 删除 cgroup 和它的子 cgroup，cgroup 中不能还有进程
+func (fs *cgroupFS) remove(name string) error {
+	var lastErr error
+	for _, dir := range fs.dirs(name) {
+		var subDirs []string
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				subDirs = append(subDirs, path)
+			}
+			return nil
+		})
+		if err != nil {
+			if !os.IsNotExist(err) {
+				lastErr = err
+			}
+			continue
+		}
+		// 先删除最深的
+		for i := len(subDirs) - 1; i >= 0; i-- {
+			err = os.Remove(subDirs[i])
+			if err != nil {
+				lastErr = err
+			}
+		}
+	}
+	return lastErr
+}
+
+func (fs *cgroupFS) attach(name string, pid int) error {
+	for _, dir := range fs.dirs(name) {
+		err := writeCgroupFile(dir, "cgroup.procs", strconv.Itoa(pid))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fs *cgroupFS) getProcs(name string) ([]int, error) {
+	dir, err := fs.ctrlDir(ctrlMemory, name)
+	if err != nil {
+		return nil, err
+	}
+	content, err := readCgroupFile(dir, "cgroup.procs")
+	if err != nil {
+		return nil, err
+	}
+	var result []int
+	for _, line := range strings.Fields(content) {
+		pid, err := strconv.Atoi(line)
+		if err == nil {
+			result = append(result, pid)
+		}
+	}
+	return result, nil
+}
+
+// getMemoryUsage 返回 cgroup 使用的内存，单位字节
+func (fs *cgroupFS) getMemoryUsage(name string) (uint64, error) {
+	dir, err := fs.ctrlDir(ctrlMemory, name)
+	if err != nil {
+		return 0, err
+	}
+	file := "memory.usage_in_bytes"
+	if fs.v2 {
+		file = "memory.current"
+	}
+	content, err := readCgroupFile(dir, file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(content, 10, 64)
+}
+
+func (fs *cgroupFS) setFrozen(name string, frozen bool) error {
+	dir, err := fs.ctrlDir(ctrlFreezer, name)
+	if err != nil {
+		return err
+	}
+	if fs.v2 {
+		value := "0"
+		if frozen {
+			value = "1"
+		}
+		return writeCgroupFile(dir, "cgroup.freeze", value)
+	}
+	value := "THAWED"
+	if frozen {
+		value = "FROZEN"
+	}
+	return writeCgroupFile(dir, "freezer.state", value)
+}
+
+type cgroupValue struct {
+	ctrl  string
+	file  string
+	value string
+}
+
+func (fs *cgroupFS) getLimitsValues(l *Limits) []cgroupValue {
+	cpuWeight := l.CPUWeight
+	if cpuWeight == 0 {
+		cpuWeight = defaultWeight
+	}
+	ioWeight := l.IOWeight
+	if ioWeight == 0 {
+		ioWeight = defaultWeight
+	}
+
+	if fs.v2 {
+		high := "max"
+		if l.MemoryHigh > 0 {
+			high = strconv.FormatUint(l.MemoryHigh, 10)
+		}
+		return []cgroupValue{
+			{ctrlMemory, "memory.high", high},
+			{ctrlMemory, "memory.low", strconv.FormatUint(l.MemoryLow, 10)},
+			{ctrlCpu, "cpu.weight", strconv.FormatUint(cpuWeight, 10)},
+			{ctrlBlkio, "io.weight", "default " + strconv.FormatUint(ioWeight, 10)},
+		}
+	}
+
+	// v1 没有 memory.high 和 memory.low，用软限制代替 memory.high，不支持 memory.low。
+	// cpu.shares 默认是 1024，blkio.weight 的范围是 10~1000，默认是 500。
+	softLimit := "-1"
+	if l.MemoryHigh > 0 {
+		softLimit = strconv.FormatUint(l.MemoryHigh, 10)
+	}
+	blkioWeight := ioWeight * 5
+	if blkioWeight < 10 {
+		blkioWeight = 10
+	} else if blkioWeight > 1000 {
+		blkioWeight = 1000
+	}
+	return []cgroupValue{
+		{ctrlMemory, "memory.soft_limit_in_bytes", softLimit},
+		{ctrlCpu, "cpu.shares", strconv.FormatUint(cpuWeight*1024/defaultWeight, 10)},
+		{ctrlBlkio, "blkio.weight", strconv.FormatUint(blkioWeight, 10)},
+	}
+}
+
+func (fs *cgroupFS) setLimits(name string, l *Limits) error {
+	var lastErr error
+	for _, v := range fs.getLimitsValues(l) {
+		dir, err := fs.ctrlDir(v.ctrl, name)
+		if err == nil {
+			err = writeCgroupFile(dir, v.file, v.value)
+		}
+		if err != nil {
+			// 某个 controller 不可用时，其他的限制仍然有效
+			logger.Warningf("failed to set %s of cgroup %s: %v", v.file, name, err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
@@ -0,0 +1,174 @@
+package swapsched
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	configFile = "/var/lib/dde-daemon/swapsched/config.json"
+
+	defaultWeight = 100
+	maxWeight     = 10000
+
+	mb = 1024 * 1024
+)
+
+// Limits 是一个 cgroup 的资源限制，值为 0 时表示不限制或者使用默认值。
+// 权重的含义和 cgroup v2 相同，范围是 1~10000，默认是 100。
+type Limits struct {
+	MemoryHigh uint64 // 字节，超过后内存回收会变得激进
+	MemoryLow  uint64 // 字节，低于这个值时内存不会被回收，只在 cgroup v2 中有效
+	CPUWeight  uint64
+	IOWeight   uint64
+}
+
+func (l *Limits) check() error {
+	if l.CPUWeight > maxWeight || l.IOWeight > maxWeight {
+		return fmt.Errorf("weight must be in range 1~%d", maxWeight)
+	}
+	if l.MemoryHigh > 0 && l.MemoryLow > l.MemoryHigh {
+		return errors.New("MemoryLow is greater than MemoryHigh")
+	}
+	return nil
+}
+
+type Config struct {
+	// DE 是桌面环境的组件，uiapps 是所有应用
+	DE     Limits
+	UIApps Limits
+	// 每个应用默认的限制，Apps 中可以按应用 ID 单独设置
+	App  Limits
+	Apps map[string]*Limits
+
+	// 内存压力(/proc/pressure/memory 中 some 的 avg10)大于 FreezePressure 时冻结后台应用，
+	// 小于 ThawPressure 时恢复，FreezePressure 为 0 时不冻结。
+	FreezePressure float64
+	ThawPressure   float64
+}
+
+func getDefaultConfig(memTotal uint64) *Config {
+	deLow := memTotal / 8
+	if deLow > 512*mb {
+		deLow = 512 * mb
+	}
+	return &Config{
+		DE: Limits{
+			MemoryLow: deLow,
+			CPUWeight: 200,
+			IOWeight:  200,
+		},
+		App: Limits{
+			// 单个应用最多使用 60% 的内存
+			MemoryHigh: memTotal / 10 * 6,
+		},
+		FreezePressure: 40,
+		ThawPressure:   10,
+	}
+}
+
+func (cfg *Config) check() error {
+	for _, l := range []*Limits{&cfg.DE, &cfg.UIApps, &cfg.App} {
+		err := l.check()
+		if err != nil {
+			return err
+		}
+	}
+	for appID, l := range cfg.Apps {
+		err := checkAppID(appID)
+		if err != nil {
+			return err
+		}
+		if l == nil {
+			return fmt.Errorf("app %q: empty limits", appID)
+		}
+		err = l.check()
+		if err != nil {
+			return fmt.Errorf("app %q: %v", appID, err)
+		}
+	}
+	if cfg.FreezePressure < 0 || cfg.FreezePressure > 100 ||
+		cfg.ThawPressure < 0 || cfg.ThawPressure > cfg.FreezePressure {
+		return errors.New("invalid pressure threshold")
+	}
+	return nil
+}
+
+func (cfg *Config) getAppLimits(appID string) *Limits {
+	if l, ok := cfg.Apps[appID]; ok {
+		return l
+	}
+	return &cfg.App
+}
+
+func loadConfig(filename string, memTotal uint64) (*Config, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	cfg := getDefaultConfig(memTotal)
+	err = json.Unmarshal(data, cfg)
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.check()
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func saveConfig(filename string, cfg *Config) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0644)
+}
+
+var appIDReg = regexp.MustCompile(`^[A-Za-z0-9_.+-]+$`)
+
+// checkAppID 检查应用 ID 能不能作为 cgroup 的名字
+func checkAppID(appID string) error {
+	if appID == "." || appID == ".." || !appIDReg.MatchString(appID) {
+		return fmt.Errorf("invalid app id %q", appID)
+	}
+	return nil
+}
+
+func getMemTotal() (uint64, error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// MemTotal:        8055352 kB
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			v, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			return v * 1024, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, errors.New("not found MemTotal")
+}
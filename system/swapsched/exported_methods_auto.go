@@ -8,10 +8,35 @@ import (
 
 func (v *Helper) GetExportedMethods() dbusutil.ExportedMethods {
 	return dbusutil.ExportedMethods{
+		{
+			Name:    "GetAppsUsage",
+			Fn:      v.GetAppsUsage,
+			OutArgs: []string{"usageJSON"},
+		},
+		{
+			Name:    "GetConfig",
+			Fn:      v.GetConfig,
+			OutArgs: []string{"configJSON"},
+		},
+		{
+			Name:   "MoveProcess",
+			Fn:     v.MoveProcess,
+			InArgs: []string{"appID", "pid"},
+		},
 		{
 			Name:   "Prepare",
 			Fn:     v.Prepare,
 			InArgs: []string{"sessionID"},
 		},
+		{
+			Name:   "SetConfig",
+			Fn:     v.SetConfig,
+			InArgs: []string{"configJSON"},
+		},
+		{
+			Name:   "SetForegroundApp",
+			Fn:     v.SetForegroundApp,
+			InArgs: []string{"pid"},
+		},
 	}
 }
@@ -0,0 +1,446 @@
+package swapsched
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const psiMemoryFile = "/proc/pressure/memory"
+
+type session struct {
+	id         string
+	uid        uint32
+	foreground string          // 前台应用的 ID
+	frozen     map[string]bool // 被冻结的应用
+}
+
+type AppUsage struct {
+	App        string
+	Memory     uint64
+	Procs      int
+	Frozen     bool
+	Foreground bool
+}
+
+func getSessionCgroup(sessionID string) string {
+	return sessionID + "@dde"
+}
+
+func getDECgroup(sessionID string) string {
+	return getSessionCgroup(sessionID) + "/DE"
+}
+
+func getUIAppsCgroup(sessionID string) string {
+	return getSessionCgroup(sessionID) + "/uiapps"
+}
+
+func getAppCgroup(sessionID, appID string) string {
+	return getUIAppsCgroup(sessionID) + "/" + appID
+}
+
+// parseProcCgroup 从 /proc/<pid>/cgroup 的内容中找出进程所在的会话和应用，
+// 进程不在 DDE 的 cgroup 中时，会话 ID 从 systemd 的 session-<id>.scope 中获取。
+func parseProcCgroup(content string) (sessionID, appID string) {
+	for _, line := range strings.Split(content, "\n") {
+		// 4:memory:/2@dde/uiapps/deepin-editor
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		segs := strings.Split(strings.Trim(parts[2], "/"), "/")
+		for idx, seg := range segs {
+			if strings.HasSuffix(seg, "@dde") && len(seg) > len("@dde") {
+				sessionID = strings.TrimSuffix(seg, "@dde")
+				if idx+2 < len(segs) && segs[idx+1] == "uiapps" && segs[idx+2] != v2LeafCgroup {
+					appID = segs[idx+2]
+				}
+				return
+			}
+			if strings.HasPrefix(seg, "session-") && strings.HasSuffix(seg, ".scope") {
+				sessionID = strings.TrimSuffix(strings.TrimPrefix(seg, "session-"), ".scope")
+			}
+		}
+	}
+	return
+}
+
+func getProcessCgroupInfo(pid uint32) (sessionID, appID string, err error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return "", "", err
+	}
+	sessionID, appID = parseProcCgroup(string(data))
+	if sessionID == "" {
+		return "", "", fmt.Errorf("process %d is not in any session", pid)
+	}
+	return sessionID, appID, nil
+}
+
+func getProcessUid(pid uint32) (uint32, error) {
+	fileInfo, err := os.Stat(fmt.Sprintf("/proc/%d", pid))
+	if err != nil {
+		return 0, err
+	}
+	return fileInfo.Sys().(*syscall.Stat_t).Uid, nil
+}
+
+// parsePSIAvg10 返回 PSI 文件中 some 的 avg10
+func parsePSIAvg10(content string) (float64, error) {
+	for _, line := range strings.Split(content, "\n") {
+		// some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "some" {
+			continue
+		}
+		for _, field := range fields[1:] {
+			if strings.HasPrefix(field, "avg10=") {
+				return strconv.ParseFloat(strings.TrimPrefix(field, "avg10="), 64)
+			}
+		}
+	}
+	return 0, errors.New("not found some avg10")
+}
+
+// pickAppToFreeze 选出使用内存最多的后台应用，没有可以冻结的应用时返回空字符串
+func pickAppToFreeze(usages []AppUsage) string {
+	var result string
+	var maxMemory uint64
+	for _, u := range usages {
+		if u.Foreground || u.Frozen || u.Procs == 0 {
+			continue
+		}
+		if result == "" || u.Memory > maxMemory {
+			result = u.App
+			maxMemory = u.Memory
+		}
+	}
+	return result
+}
+
+// createDDECGroups 创建会话的 cgroup 并设置资源限制
+func (sw *Helper) createDDECGroups(uid uint32, sessionID string) error {
+	uid0 := int(uid)
+	for _, name := range []string{getSessionCgroup(sessionID),
+		getDECgroup(sessionID), getUIAppsCgroup(sessionID)} {
+		logger.Debugf("create cgroup %s, uid: %d", name, uid)
+		err := sw.fs.create(name, uid0)
+		if err != nil {
+			return err
+		}
+	}
+
+	sw.applySessionLimitsNoLock(sessionID)
+	return nil
+}
+
+func (sw *Helper) deleteDDECGroups(sessionID string) error {
+	logger.Debugf("delete cgroup for session %s", sessionID)
+	return sw.fs.remove(getSessionCgroup(sessionID))
+}
+
+func (sw *Helper) applySessionLimitsNoLock(sessionID string) {
+	err := sw.fs.setLimits(getDECgroup(sessionID), &sw.cfg.DE)
+	if err != nil {
+		logger.Warning(err)
+	}
+	err = sw.fs.setLimits(getUIAppsCgroup(sessionID), &sw.cfg.UIApps)
+	if err != nil {
+		logger.Warning(err)
+	}
+
+	apps, err := sw.listAppsNoLock(sessionID)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	for _, appID := range apps {
+		err = sw.fs.setLimits(getAppCgroup(sessionID, appID), sw.cfg.getAppLimits(appID))
+		if err != nil {
+			logger.Warning(err)
+		}
+	}
+}
+
+func (sw *Helper) prepareSessionNoLock(sessionID string, uid uint32) (*session, error) {
+	err := sw.createDDECGroups(uid, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	sess := &session{
+		id:     sessionID,
+		uid:    uid,
+		frozen: make(map[string]bool),
+	}
+	sw.sessions[sessionID] = sess
+	return sess, nil
+}
+
+// getSessionNoLock 获取会话，会话的 cgroup 还没有准备好时先准备好，比如 helper 重启之后
+func (sw *Helper) getSessionNoLock(sessionID string) (*session, error) {
+	sess, ok := sw.sessions[sessionID]
+	if ok {
+		return sess, nil
+	}
+	uid, err := sw.getSessionUid(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return sw.prepareSessionNoLock(sessionID, uid)
+}
+
+func (sw *Helper) listAppsNoLock(sessionID string) ([]string, error) {
+	dir, err := sw.fs.ctrlDir(ctrlMemory, getUIAppsCgroup(sessionID))
+	if err != nil {
+		return nil, err
+	}
+	fileInfoList, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, fileInfo := range fileInfoList {
+		// 跳过存放 uiapps 中原有进程的 v2LeafCgroup
+		if fileInfo.IsDir() && fileInfo.Name() != v2LeafCgroup {
+			result = append(result, fileInfo.Name())
+		}
+	}
+	return result, nil
+}
+
+func (sw *Helper) getAppsUsageNoLock(sess *session) ([]AppUsage, error) {
+	apps, err := sw.listAppsNoLock(sess.id)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]AppUsage, 0, len(apps))
+	for _, appID := range apps {
+		name := getAppCgroup(sess.id, appID)
+		usage := AppUsage{
+			App:        appID,
+			Frozen:     sess.frozen[appID],
+			Foreground: sess.foreground == appID,
+		}
+		usage.Memory, err = sw.fs.getMemoryUsage(name)
+		if err != nil {
+			logger.Warning(err)
+		}
+		procs, err := sw.fs.getProcs(name)
+		if err != nil {
+			logger.Warning(err)
+		}
+		usage.Procs = len(procs)
+		result = append(result, usage)
+	}
+	return result, nil
+}
+
+// checkCallerNoLock 检查调用者能不能操作 pid 这个进程，返回进程所在的会话
+func (sw *Helper) checkCallerNoLock(callerUid uint32, pid uint32) (*session, string, error) {
+	uid, err := getProcessUid(pid)
+	if err != nil {
+		return nil, "", err
+	}
+	if callerUid != 0 && uid != callerUid {
+		return nil, "", fmt.Errorf("process %d does not belong to the caller", pid)
+	}
+	sessionID, appID, err := getProcessCgroupInfo(pid)
+	if err != nil {
+		return nil, "", err
+	}
+	sess, err := sw.getSessionNoLock(sessionID)
+	if err != nil {
+		return nil, "", err
+	}
+	if sess.uid != uid {
+		return nil, "", fmt.Errorf("process %d does not belong to the session %s", pid, sessionID)
+	}
+	return sess, appID, nil
+}
+
+// moveProcess 把进程移到所在会话中应用的 cgroup 中
+func (sw *Helper) moveProcess(callerUid uint32, appID string, pid uint32) error {
+	err := checkAppID(appID)
+	if err != nil {
+		return err
+	}
+
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sess, _, err := sw.checkCallerNoLock(callerUid, pid)
+	if err != nil {
+		return err
+	}
+
+	name := getAppCgroup(sess.id, appID)
+	if !sw.fs.exists(name) {
+		err = sw.fs.create(name, int(sess.uid))
+		if err != nil {
+			return err
+		}
+		err = sw.fs.setLimits(name, sw.cfg.getAppLimits(appID))
+		if err != nil {
+			logger.Warning(err)
+		}
+	}
+	// 用户刚启动了这个应用，不能让它处于冻结状态
+	sw.thawAppNoLock(sess, appID)
+
+	logger.Debugf("move process %d to %s", pid, name)
+	return sw.fs.attach(name, int(pid))
+}
+
+func (sw *Helper) setForegroundApp(callerUid uint32, pid uint32) error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sess, appID, err := sw.checkCallerNoLock(callerUid, pid)
+	if err != nil {
+		return err
+	}
+	sess.foreground = appID
+	if appID != "" {
+		sw.thawAppNoLock(sess, appID)
+	}
+	return nil
+}
+
+func (sw *Helper) getAppsUsage(callerUid uint32, callerPid uint32) ([]AppUsage, error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sess, _, err := sw.checkCallerNoLock(callerUid, callerPid)
+	if err != nil {
+		return nil, err
+	}
+	return sw.getAppsUsageNoLock(sess)
+}
+
+func (sw *Helper) setConfig(cfg *Config) error {
+	err := cfg.check()
+	if err != nil {
+		return err
+	}
+
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	err = saveConfig(configFile, cfg)
+	if err != nil {
+		return err
+	}
+	sw.cfg = cfg
+	for sessionID := range sw.sessions {
+		sw.applySessionLimitsNoLock(sessionID)
+	}
+	return nil
+}
+
+func (sw *Helper) freezeAppNoLock(sess *session, appID string) {
+	err := sw.fs.setFrozen(getAppCgroup(sess.id, appID), true)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	logger.Infof("freeze app %s in session %s", appID, sess.id)
+	sess.frozen[appID] = true
+}
+
+func (sw *Helper) thawAppNoLock(sess *session, appID string) {
+	if !sess.frozen[appID] {
+		return
+	}
+	err := sw.fs.setFrozen(getAppCgroup(sess.id, appID), false)
+	if err != nil && !os.IsNotExist(err) {
+		logger.Warning(err)
+		return
+	}
+	logger.Infof("thaw app %s in session %s", appID, sess.id)
+	delete(sess.frozen, appID)
+}
+
+func (sw *Helper) thawAllNoLock(sess *session) {
+	for appID := range sess.frozen {
+		sw.thawAppNoLock(sess, appID)
+	}
+}
+
+// check 定时执行，删除已经没有进程的应用 cgroup，根据内存压力冻结或者恢复后台应用。
+func (sw *Helper) check() {
+	var pressure float64
+	var pressureOk bool
+	content, err := ioutil.ReadFile(psiMemoryFile)
+	if err == nil {
+		pressure, err = parsePSIAvg10(string(content))
+	}
+	if err != nil {
+		if !sw.psiWarned {
+			logger.Warning("failed to get memory pressure, freezing is disabled:", err)
+			sw.psiWarned = true
+		}
+	} else {
+		pressureOk = true
+	}
+
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	cfg := sw.cfg
+	for _, sess := range sw.sessions {
+		usages, err := sw.getAppsUsageNoLock(sess)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				logger.Warning(err)
+			}
+			continue
+		}
+		for _, u := range usages {
+			if u.Procs == 0 && !u.Frozen {
+				err = sw.fs.remove(getAppCgroup(sess.id, u.App))
+				if err != nil {
+					logger.Debug(err)
+				}
+			}
+		}
+
+		if !pressureOk || cfg.FreezePressure == 0 || pressure < cfg.ThawPressure {
+			sw.thawAllNoLock(sess)
+		} else if pressure >= cfg.FreezePressure {
+			// 每次只冻结一个，下次检查时压力还大再冻结下一个
+			appID := pickAppToFreeze(usages)
+			if appID != "" {
+				sw.freezeAppNoLock(sess, appID)
+			}
+		}
+	}
+}
+
+func (sw *Helper) removeSession(sessionID string) {
+	sw.mu.Lock()
+	sess, ok := sw.sessions[sessionID]
+	if ok {
+		// v1 中被冻结的进程无法被杀死
+		sw.thawAllNoLock(sess)
+		delete(sw.sessions, sessionID)
+	}
+	exists := sw.fs.exists(getSessionCgroup(sessionID))
+	sw.mu.Unlock()
+
+	if !exists {
+		return
+	}
+	go func() {
+		// 等待会话中的进程退出
+		time.Sleep(10 * time.Second)
+		sw.mu.Lock()
+		defer sw.mu.Unlock()
+		if _, ok := sw.sessions[sessionID]; ok {
+			return
+		}
+		err := sw.deleteDDECGroups(sessionID)
+		if err != nil {
+			logger.Warning("failed to delete DDE cgroups:", err)
+		}
+	}()
+}
@@ -1,16 +1,18 @@
 package swapsched
 
 import (
+	"encoding/json"
 	"errors"
 	"os"
-	"path/filepath"
+	"sync"
 	"time"
 
 	dbus "github.com/godbus/dbus"
 	login1 "github.com/linuxdeepin/go-dbus-factory/org.freedesktop.login1"
+	polkit "github.com/linuxdeepin/go-dbus-factory/org.freedesktop.policykit1"
 	"pkg.deepin.io/dde/daemon/loader"
-	"pkg.deepin.io/lib/cgroup"
 	"pkg.deepin.io/lib/dbusutil"
+	"pkg.deepin.io/lib/dbusutil/proxy"
 	"pkg.deepin.io/lib/log"
 )
 
@@ -18,6 +20,10 @@ const (
 	dbusServiceName = "com.deepin.daemon.SwapSchedHelper"
 	dbusPath        = "/com/deepin/daemon/SwapSchedHelper"
 	dbusInterface   = dbusServiceName
+
+	polkitActionSetConfig = "com.deepin.daemon.swapsched.set-config"
+
+	checkInterval = 5 * time.Second
 )
 
 var logger = log.NewLogger("daemon/system/swapsched")
@@ -42,21 +48,19 @@ func (d *Daemon) GetDependencies() []string {
 }
 
 func (d *Daemon) Start() error {
-	err := cgroup.Init()
-	if err != nil {
-		return err
+	if d.sessionWatcher != nil {
+		return nil
 	}
 
 	logger.Debug("swap sched helper start")
-	sw, err := newHelper()
+	service := loader.GetService()
+	sw, err := newHelper(service)
 	if err != nil {
 		return err
 	}
 
 	sw.init()
-	d.sessionWatcher = sw
 
-	service := loader.GetService()
 	err = service.Export(dbusPath, sw)
 	if err != nil {
 		return err
@@ -64,29 +68,71 @@ func (d *Daemon) Start() error {
 
 	err = service.RequestName(dbusServiceName)
 	if err != nil {
+		_ = service.StopExport(sw)
 		return err
 	}
+	d.sessionWatcher = sw
 
 	return nil
 }
 
 func (d *Daemon) Stop() error {
-	// TODO:
+	if d.sessionWatcher == nil {
+		return nil
+	}
+	service := loader.GetService()
+	err := service.ReleaseName(dbusServiceName)
+	if err != nil {
+		logger.Warning(err)
+	}
+	err = service.StopExport(d.sessionWatcher)
+	if err != nil {
+		logger.Warning(err)
+	}
+	d.sessionWatcher.destroy()
+	d.sessionWatcher = nil
 	return nil
 }
 
 //go:generate dbusutil-gen em -type Helper
 
 type Helper struct {
+	service      *dbusutil.Service
 	loginManager login1.Manager
 	sysSigLoop   *dbusutil.SignalLoop
+	fs           *cgroupFS
+	ticker       *time.Ticker
+	quit         chan struct{}
+	psiWarned    bool
+
+	mu       sync.Mutex
+	cfg      *Config
+	sessions map[string]*session
 }
 
 func (*Helper) GetInterfaceName() string {
 	return dbusInterface
 }
 
-func newHelper() (*Helper, error) {
+func newHelper(service *dbusutil.Service) (*Helper, error) {
+	fs, err := newCgroupFS()
+	if err != nil {
+		return nil, err
+	}
+	logger.Debug("cgroup version:", fs.version())
+
+	memTotal, err := getMemTotal()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := loadConfig(configFile, memTotal)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warning("failed to load config:", err)
+		}
+		cfg = getDefaultConfig(memTotal)
+	}
+
 	systemBus, err := dbus.SystemBus()
 	if err != nil {
 		return nil, err
@@ -95,8 +141,13 @@ func newHelper() (*Helper, error) {
 	sysSigLoop.Start()
 	loginManager := login1.NewManager(systemBus)
 	return &Helper{
+		service:      service,
 		loginManager: loginManager,
 		sysSigLoop:   sysSigLoop,
+		fs:           fs,
+		cfg:          cfg,
+		sessions:     make(map[string]*session),
+		quit:         make(chan struct{}),
 	}, nil
 }
 
@@ -106,7 +157,9 @@ func (sw *Helper) Prepare(sessionID string) *dbus.Error {
 		return dbusutil.ToError(err)
 	}
 
-	err = createDDECGroups(uid, sessionID)
+	sw.mu.Lock()
+	_, err = sw.prepareSessionNoLock(sessionID, uid)
+	sw.mu.Unlock()
 	if err != nil {
 		logger.Warning("failed to create cgroup:", err)
 		return dbusutil.ToError(err)
@@ -115,6 +168,95 @@ func (sw *Helper) Prepare(sessionID string) *dbus.Error {
 	return nil
 }
 
+func (sw *Helper) getCallerUid(sender dbus.Sender) (uint32, error) {
+	return sw.service.GetConnUID(string(sender))
+}
+
+// MoveProcess 把调用者的进程 pid 移到应用 appID 的 cgroup 中，由 dock 在应用的窗口出现时调用
+func (sw *Helper) MoveProcess(sender dbus.Sender, appID string, pid uint32) *dbus.Error {
+	uid, err := sw.getCallerUid(sender)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	err = sw.moveProcess(uid, appID, pid)
+	return dbusutil.ToError(err)
+}
+
+// SetForegroundApp 设置进程 pid 所在的应用为前台应用，前台应用不会被冻结
+func (sw *Helper) SetForegroundApp(sender dbus.Sender, pid uint32) *dbus.Error {
+	uid, err := sw.getCallerUid(sender)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	err = sw.setForegroundApp(uid, pid)
+	return dbusutil.ToError(err)
+}
+
+// GetAppsUsage 返回调用者所在会话中每个应用的资源使用情况
+func (sw *Helper) GetAppsUsage(sender dbus.Sender) (usageJSON string, busErr *dbus.Error) {
+	uid, err := sw.getCallerUid(sender)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	pid, err := sw.service.GetConnPID(string(sender))
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	usages, err := sw.getAppsUsage(uid, pid)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	data, err := json.Marshal(usages)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+func (sw *Helper) GetConfig() (configJSON string, busErr *dbus.Error) {
+	sw.mu.Lock()
+	data, err := json.Marshal(sw.cfg)
+	sw.mu.Unlock()
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return string(data), nil
+}
+
+func (sw *Helper) SetConfig(sender dbus.Sender, configJSON string) *dbus.Error {
+	err := checkAuthorization(polkitActionSetConfig, string(sender))
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	var cfg Config
+	err = json.Unmarshal([]byte(configJSON), &cfg)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+	err = sw.setConfig(&cfg)
+	return dbusutil.ToError(err)
+}
+
+func checkAuthorization(actionId string, sysBusName string) error {
+	systemBus, err := dbus.SystemBus()
+	if err != nil {
+		return err
+	}
+	authority := polkit.NewAuthority(systemBus)
+	subject := polkit.MakeSubject(polkit.SubjectKindSystemBusName)
+	subject.SetDetail("name", sysBusName)
+
+	ret, err := authority.CheckAuthorization(0, subject, actionId,
+		nil, polkit.CheckAuthorizationFlagsAllowUserInteraction, "")
+	if err != nil {
+		return err
+	}
+	if !ret.IsAuthorized {
+		return errors.New("not authorized")
+	}
+	return nil
+}
+
 func (sw *Helper) getSessionUid(sessionID string) (uint32, error) {
 	sessions, err := sw.loginManager.ListSessions(0)
 	if err != nil {
@@ -135,57 +277,36 @@ func (sw *Helper) init() {
 	_, err := sw.loginManager.ConnectSessionRemoved(
 		func(sessionID string, sessionPath dbus.ObjectPath) {
 			logger.Debug("session removed", sessionID, sessionPath)
-			memMountPoint := cgroup.GetSubSysMountPoint(cgroup.Memory)
-			_, err := os.Stat(filepath.Join(memMountPoint, sessionID+"@dde"))
-			if err == nil {
-				// path exit
-				go func() {
-					time.Sleep(10 * time.Second)
-					err := deleteDDECGroups(sessionID)
-					if err != nil {
-						logger.Warning("failed to delete DDE cgroups:", err)
-					}
-				}()
-			}
+			sw.removeSession(sessionID)
 		})
 
 	if err != nil {
 		logger.Warning(err)
 	}
-}
-
-func createDDECGroups(uid uint32, sessionID string) error {
-	dir := sessionID + "@dde/"
-	err := createCGroup(uid, dir+"uiapps")
-	if err != nil {
-		return err
-	}
 
-	err = createCGroup(uid, dir+"DE")
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func createCGroup(uid uint32, name string) error {
-	cg := newCgroup(name)
-	uid0 := int(uid)
-	cg.SetUidGid(uid0, uid0, uid0, uid0)
-	logger.Debugf("create cgroup %s, uid: %d", name, uid)
-	return cg.Create(false)
+	sw.ticker = time.NewTicker(checkInterval)
+	go func() {
+		for {
+			select {
+			case <-sw.ticker.C:
+				sw.check()
+			case <-sw.quit:
+				return
+			}
+		}
+	}()
 }
 
-func deleteDDECGroups(sessionID string) error {
-	logger.Debugf("delete cgroup for session %s", sessionID)
-	cg := newCgroup(sessionID + "@dde")
-	return cg.Delete(cgroup.DeleteFlagRecursive)
-}
+func (sw *Helper) destroy() {
+	sw.ticker.Stop()
+	close(sw.quit)
+	sw.loginManager.RemoveHandler(proxy.RemoveAllHandlers)
+	sw.sysSigLoop.Stop()
 
-func newCgroup(name string) *cgroup.Cgroup {
-	cg := cgroup.NewCgroup(name)
-	cg.AddController(cgroup.Memory)
-	cg.AddController(cgroup.Freezer)
-	cg.AddController(cgroup.Blkio)
-	return cg
+	// 退出之前恢复所有被冻结的应用
+	sw.mu.Lock()
+	for _, sess := range sw.sessions {
+		sw.thawAllNoLock(sess)
+	}
+	sw.mu.Unlock()
 }
@@ -0,0 +1,216 @@
+package swapsched
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseMountInfo(t *testing.T) {
+	v1 := `25 30 0:23 / /sys rw,nosuid,nodev,noexec,relatime shared:7 - sysfs sysfs rw
+31 25 0:26 / /sys/fs/cgroup ro,nosuid,nodev,noexec shared:9 - tmpfs tmpfs ro,mode=755
+32 31 0:27 / /sys/fs/cgroup/unified rw,nosuid,nodev,noexec,relatime shared:10 - cgroup2 cgroup2 rw
+36 31 0:31 / /sys/fs/cgroup/memory rw,nosuid,nodev,noexec,relatime shared:16 - cgroup cgroup rw,memory
+37 31 0:32 / /sys/fs/cgroup/cpu,cpuacct rw,nosuid,nodev,noexec,relatime shared:17 - cgroup cgroup rw,cpu,cpuacct
+38 31 0:33 / /sys/fs/cgroup/freezer rw,nosuid,nodev,noexec,relatime shared:18 - cgroup cgroup rw,freezer
+39 31 0:34 / /sys/fs/cgroup/blkio rw,nosuid,nodev,noexec,relatime shared:19 - cgroup cgroup rw,blkio
+`
+	fs, err := parseMountInfo(strings.NewReader(v1))
+	require.NoError(t, err)
+	assert.Equal(t, 1, fs.version())
+	assert.Equal(t, map[string]string{
+		ctrlMemory:  "/sys/fs/cgroup/memory",
+		ctrlCpu:     "/sys/fs/cgroup/cpu,cpuacct",
+		ctrlFreezer: "/sys/fs/cgroup/freezer",
+		ctrlBlkio:   "/sys/fs/cgroup/blkio",
+	}, fs.mounts)
+
+	v2 := `25 30 0:23 / /sys rw,nosuid,nodev,noexec,relatime shared:7 - sysfs sysfs rw
+31 25 0:26 / /sys/fs/cgroup rw,nosuid,nodev,noexec,relatime shared:9 - cgroup2 cgroup2 rw,nsdelegate
+`
+	fs, err = parseMountInfo(strings.NewReader(v2))
+	require.NoError(t, err)
+	assert.Equal(t, 2, fs.version())
+	dir, err := fs.ctrlDir(ctrlFreezer, "2@dde")
+	assert.NoError(t, err)
+	assert.Equal(t, "/sys/fs/cgroup/2@dde", dir)
+
+	_, err = parseMountInfo(strings.NewReader("25 30 0:23 / /sys rw - sysfs sysfs rw\n"))
+	assert.Error(t, err)
+}
+
+func readFile(t *testing.T, filename string) string {
+	data, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestCgroupFSV1(t *testing.T) {
+	root, err := ioutil.TempDir("", "swapsched")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	fs := &cgroupFS{mounts: make(map[string]string)}
+	for _, ctrl := range v1Controllers {
+		fs.mounts[ctrl] = filepath.Join(root, ctrl)
+	}
+	name := getAppCgroup("2", "deepin-editor")
+	err = fs.create(name, os.Getuid())
+	require.NoError(t, err)
+	assert.True(t, fs.exists(name))
+
+	err = fs.setLimits(name, &Limits{MemoryHigh: 100 * mb, CPUWeight: 50})
+	assert.NoError(t, err)
+	assert.Equal(t, "104857600", readFile(t, filepath.Join(root, "memory", name, "memory.soft_limit_in_bytes")))
+	assert.Equal(t, "512", readFile(t, filepath.Join(root, "cpu", name, "cpu.shares")))
+	assert.Equal(t, "500", readFile(t, filepath.Join(root, "blkio", name, "blkio.weight")))
+
+	err = fs.setFrozen(name, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "FROZEN", readFile(t, filepath.Join(root, "freezer", name, "freezer.state")))
+
+	err = fs.attach(name, 42)
+	assert.NoError(t, err)
+	for _, ctrl := range v1Controllers {
+		assert.Equal(t, "42", readFile(t, filepath.Join(root, ctrl, name, "cgroup.procs")))
+	}
+	procs, err := fs.getProcs(name)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{42}, procs)
+
+	err = ioutil.WriteFile(filepath.Join(root, "memory", name, "memory.usage_in_bytes"), []byte("1024\n"), 0644)
+	require.NoError(t, err)
+	usage, err := fs.getMemoryUsage(name)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1024), usage)
+
+	// 真实的 cgroup 目录中的文件不影响删除目录，这里只删除没有文件的 cgroup
+	name1 := getDECgroup("3")
+	err = fs.create(name1, os.Getuid())
+	require.NoError(t, err)
+	err = fs.remove(getSessionCgroup("3"))
+	assert.NoError(t, err)
+	assert.False(t, fs.exists(getSessionCgroup("3")))
+}
+
+func TestCgroupFSV2(t *testing.T) {
+	root, err := ioutil.TempDir("", "swapsched")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	fs := &cgroupFS{v2: true, mounts: map[string]string{"": root}}
+	name := getAppCgroup("2", "deepin-editor")
+	err = fs.create(name, os.Getuid())
+	require.NoError(t, err)
+	for _, dir := range []string{"", "2@dde", "2@dde/uiapps"} {
+		assert.Equal(t, v2SubtreeControl, readFile(t, filepath.Join(root, dir, "cgroup.subtree_control")))
+	}
+	_, err = os.Stat(filepath.Join(root, name, "cgroup.subtree_control"))
+	assert.True(t, os.IsNotExist(err))
+
+	err = fs.setLimits(name, &Limits{MemoryLow: 10 * mb, IOWeight: 300})
+	assert.NoError(t, err)
+	dir := filepath.Join(root, name)
+	assert.Equal(t, "max", readFile(t, filepath.Join(dir, "memory.high")))
+	assert.Equal(t, "10485760", readFile(t, filepath.Join(dir, "memory.low")))
+	assert.Equal(t, "100", readFile(t, filepath.Join(dir, "cpu.weight")))
+	assert.Equal(t, "default 300", readFile(t, filepath.Join(dir, "io.weight")))
+
+	err = fs.setFrozen(name, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", readFile(t, filepath.Join(dir, "cgroup.freeze")))
+	err = fs.setFrozen(name, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "0", readFile(t, filepath.Join(dir, "cgroup.freeze")))
+}
+
+// uiapps 中已经有进程时，先把进程移到 v2LeafCgroup 中再开启 controller
+func TestCgroupFSV2MoveProcs(t *testing.T) {
+	root, err := ioutil.TempDir("", "swapsched")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	fs := &cgroupFS{v2: true, mounts: map[string]string{"": root}}
+	uiapps := filepath.Join(root, getUIAppsCgroup("2"))
+	require.NoError(t, os.MkdirAll(uiapps, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(uiapps, "cgroup.procs"), []byte("100\n"), 0644))
+
+	err = fs.create(getAppCgroup("2", "deepin-editor"), os.Getuid())
+	require.NoError(t, err)
+	assert.Equal(t, "100", readFile(t, filepath.Join(uiapps, v2LeafCgroup, "cgroup.procs")))
+	assert.Equal(t, v2SubtreeControl, readFile(t, filepath.Join(uiapps, "cgroup.subtree_control")))
+
+	sessionID, appID := parseProcCgroup("0::/2@dde/uiapps/" + v2LeafCgroup)
+	assert.Equal(t, "2", sessionID)
+	assert.Equal(t, "", appID)
+}
+
+func Test_parseProcCgroup(t *testing.T) {
+	sessionID, appID := parseProcCgroup(`12:freezer:/2@dde/uiapps/google-chrome
+4:memory:/2@dde/uiapps/google-chrome
+1:name=systemd:/user.slice/user-1000.slice/session-2.scope
+`)
+	assert.Equal(t, "2", sessionID)
+	assert.Equal(t, "google-chrome", appID)
+
+	sessionID, appID = parseProcCgroup("0::/2@dde/DE\n")
+	assert.Equal(t, "2", sessionID)
+	assert.Equal(t, "", appID)
+
+	sessionID, appID = parseProcCgroup("0::/user.slice/user-1000.slice/session-c1.scope\n")
+	assert.Equal(t, "c1", sessionID)
+	assert.Equal(t, "", appID)
+
+	sessionID, _ = parseProcCgroup("0::/system.slice/lightdm.service\n")
+	assert.Equal(t, "", sessionID)
+}
+
+func Test_parsePSIAvg10(t *testing.T) {
+	v, err := parsePSIAvg10(`some avg10=12.50 avg60=3.17 avg300=0.09 total=2383755
+full avg10=0.01 avg60=0.08 avg300=0.04 total=1704636
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, 12.5, v)
+
+	_, err = parsePSIAvg10("")
+	assert.Error(t, err)
+}
+
+func Test_pickAppToFreeze(t *testing.T) {
+	usages := []AppUsage{
+		{App: "a", Memory: 300, Procs: 1, Foreground: true},
+		{App: "b", Memory: 100, Procs: 2},
+		{App: "c", Memory: 200, Procs: 1},
+		{App: "d", Memory: 400, Procs: 1, Frozen: true},
+		{App: "e", Memory: 500},
+	}
+	assert.Equal(t, "c", pickAppToFreeze(usages))
+	assert.Equal(t, "", pickAppToFreeze(usages[:1]))
+}
+
+func TestConfig(t *testing.T) {
+	cfg := getDefaultConfig(4096 * mb)
+	assert.NoError(t, cfg.check())
+	assert.Equal(t, uint64(512*mb), cfg.DE.MemoryLow)
+	assert.Equal(t, &cfg.App, cfg.getAppLimits("google-chrome"))
+
+	cfg.Apps = map[string]*Limits{"google-chrome": {MemoryHigh: mb}}
+	assert.NoError(t, cfg.check())
+	assert.Equal(t, uint64(mb), cfg.getAppLimits("google-chrome").MemoryHigh)
+
+	cfg.Apps = map[string]*Limits{"../a": {}}
+	assert.Error(t, cfg.check())
+	cfg.Apps = nil
+
+	cfg.App.CPUWeight = maxWeight + 1
+	assert.Error(t, cfg.check())
+	cfg.App.CPUWeight = 0
+
+	cfg.ThawPressure = cfg.FreezePressure + 1
+	assert.Error(t, cfg.check())
+}
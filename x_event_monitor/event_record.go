@@ -0,0 +1,260 @@
+/*
+ * Copyright (C) 2014 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package x_event_monitor
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// 录制文件中的事件类型
+const (
+	recordTypeMotion        = "motion"
+	recordTypeKeyPress      = "key-press"
+	recordTypeKeyRelease    = "key-release"
+	recordTypeButtonPress   = "button-press"
+	recordTypeButtonRelease = "button-release"
+)
+
+const (
+	minReplaySpeed = 0.1
+	maxReplaySpeed = 100
+)
+
+var errReplayStopped = errors.New("replay stopped")
+
+// recordEvent 是录制文件中的一行，文件的每一行是一个 JSON 对象
+type recordEvent struct {
+	// 距离开始录制的毫秒数
+	Time int64
+	Type string
+	// 按键的 keycode 或者鼠标按钮，motion 事件为 0
+	Detail int32 `json:",omitempty"`
+	// 按键名，只是为了方便阅读，回放时使用 Detail
+	Key  string `json:",omitempty"`
+	X, Y int32
+}
+
+func (ev *recordEvent) check() error {
+	switch ev.Type {
+	case recordTypeMotion:
+		return nil
+	case recordTypeKeyPress, recordTypeKeyRelease,
+		recordTypeButtonPress, recordTypeButtonRelease:
+		if ev.Detail <= 0 || ev.Detail > 255 {
+			return fmt.Errorf("invalid detail %d", ev.Detail)
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid event type %q", ev.Type)
+}
+
+// eventRecorder 把事件写入录制文件
+type eventRecorder struct {
+	w     *bufio.Writer
+	enc   *json.Encoder
+	start time.Time
+	count uint32
+}
+
+func newEventRecorder(w io.Writer) *eventRecorder {
+	bw := bufio.NewWriter(w)
+	return &eventRecorder{
+		w:     bw,
+		enc:   json.NewEncoder(bw),
+		start: time.Now(),
+	}
+}
+
+func (r *eventRecorder) record(ev recordEvent) error {
+	ev.Time = int64(time.Since(r.start) / time.Millisecond)
+	err := r.enc.Encode(&ev)
+	if err != nil {
+		return err
+	}
+	r.count++
+	return nil
+}
+
+func (r *eventRecorder) flush() error {
+	return r.w.Flush()
+}
+
+// readRecordEvents 读取录制文件，忽略空行
+func readRecordEvents(r io.Reader) ([]recordEvent, error) {
+	var events []recordEvent
+	scanner := bufio.NewScanner(r)
+	var lineNum int
+	var lastTime int64
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var ev recordEvent
+		err := json.Unmarshal(line, &ev)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		err = ev.check()
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		if ev.Time < lastTime {
+			return nil, fmt.Errorf("line %d: time goes backwards", lineNum)
+		}
+		lastTime = ev.Time
+		events = append(events, ev)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// eventInjector 用于回放事件，实际使用 XTest，测试时可以替换
+type eventInjector interface {
+	fakeMotion(x, y int32) error
+	fakeKey(code int32, press bool) error
+	fakeButton(button int32, press bool) error
+}
+
+func checkReplaySpeed(speed float64) error {
+	if speed < minReplaySpeed || speed > maxReplaySpeed {
+		return fmt.Errorf("invalid speed %v, should be in [%v, %v]", speed, minReplaySpeed, maxReplaySpeed)
+	}
+	return nil
+}
+
+// pressedInputs 记录回放时按下还没有释放的键和鼠标按钮
+type pressedInputs struct {
+	keys    map[int32]bool
+	buttons map[int32]bool
+}
+
+func newPressedInputs() *pressedInputs {
+	return &pressedInputs{
+		keys:    make(map[int32]bool),
+		buttons: make(map[int32]bool),
+	}
+}
+
+func (p *pressedInputs) update(ev *recordEvent) {
+	switch ev.Type {
+	case recordTypeKeyPress:
+		p.keys[ev.Detail] = true
+	case recordTypeKeyRelease:
+		delete(p.keys, ev.Detail)
+	case recordTypeButtonPress:
+		p.buttons[ev.Detail] = true
+	case recordTypeButtonRelease:
+		delete(p.buttons, ev.Detail)
+	}
+}
+
+// releaseAll 释放所有按下的键和按钮
+func (p *pressedInputs) releaseAll(injector eventInjector) {
+	for _, code := range sortedInt32Keys(p.keys) {
+		err := injector.fakeKey(code, false)
+		if err != nil {
+			logger.Warningf("failed to release key %d: %v", code, err)
+		}
+		delete(p.keys, code)
+	}
+	for _, button := range sortedInt32Keys(p.buttons) {
+		err := injector.fakeButton(button, false)
+		if err != nil {
+			logger.Warningf("failed to release button %d: %v", button, err)
+		}
+		delete(p.buttons, button)
+	}
+}
+
+func sortedInt32Keys(m map[int32]bool) []int32 {
+	result := make([]int32, 0, len(m))
+	for k := range m {
+		result = append(result, k)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i] < result[j]
+	})
+	return result
+}
+
+// replayEvents 按照录制时的间隔除以 speed 回放事件，stop 关闭时停止回放并返回 errReplayStopped。
+// 返回之前释放回放时按下还没有释放的键和按钮。
+func replayEvents(events []recordEvent, speed float64, injector eventInjector, stop <-chan struct{}) error {
+	err := checkReplaySpeed(speed)
+	if err != nil {
+		return err
+	}
+
+	pressed := newPressedInputs()
+	defer pressed.releaseAll(injector)
+
+	var lastTime int64
+	for _, ev := range events {
+		delay := time.Duration(float64(ev.Time-lastTime) * float64(time.Millisecond) / speed)
+		lastTime = ev.Time
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-stop:
+				timer.Stop()
+				return errReplayStopped
+			case <-timer.C:
+			}
+		} else {
+			select {
+			case <-stop:
+				return errReplayStopped
+			default:
+			}
+		}
+
+		err = injectEvent(injector, &ev)
+		if err != nil {
+			return err
+		}
+		pressed.update(&ev)
+	}
+	return nil
+}
+
+func injectEvent(injector eventInjector, ev *recordEvent) error {
+	switch ev.Type {
+	case recordTypeMotion:
+		return injector.fakeMotion(ev.X, ev.Y)
+	case recordTypeKeyPress, recordTypeKeyRelease:
+		return injector.fakeKey(ev.Detail, ev.Type == recordTypeKeyPress)
+	case recordTypeButtonPress, recordTypeButtonRelease:
+		// 先把指针移动到录制时的位置
+		err := injector.fakeMotion(ev.X, ev.Y)
+		if err != nil {
+			return err
+		}
+		return injector.fakeButton(ev.Detail, ev.Type == recordTypeButtonPress)
+	}
+	return fmt.Errorf("invalid event type %q", ev.Type)
+}
@@ -0,0 +1,187 @@
+/*
+ * Copyright (C) 2014 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package x_event_monitor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	C "gopkg.in/check.v1"
+)
+
+type fakeInjector struct {
+	calls []string
+	// 调用次数达到 failAt 时返回错误，0 表示不返回错误
+	failAt int
+}
+
+func (inj *fakeInjector) add(call string) error {
+	inj.calls = append(inj.calls, call)
+	if inj.failAt > 0 && len(inj.calls) == inj.failAt {
+		return errors.New("inject failed")
+	}
+	return nil
+}
+
+func (inj *fakeInjector) fakeMotion(x, y int32) error {
+	return inj.add(fmt.Sprintf("motion %d %d", x, y))
+}
+
+func (inj *fakeInjector) fakeKey(code int32, press bool) error {
+	return inj.add(fmt.Sprintf("key %d %v", code, press))
+}
+
+func (inj *fakeInjector) fakeButton(button int32, press bool) error {
+	return inj.add(fmt.Sprintf("button %d %v", button, press))
+}
+
+func (s *mySuite) TestRecordAndRead(c *C.C) {
+	var buf bytes.Buffer
+	r := newEventRecorder(&buf)
+	c.Check(r.record(recordEvent{Type: recordTypeMotion, X: 10, Y: 20}), C.IsNil)
+	c.Check(r.record(recordEvent{Type: recordTypeKeyPress, Detail: 38, Key: "a", X: 10, Y: 20}), C.IsNil)
+	c.Check(r.record(recordEvent{Type: recordTypeKeyRelease, Detail: 38, Key: "a", X: 10, Y: 20}), C.IsNil)
+	c.Check(r.flush(), C.IsNil)
+	c.Check(r.count, C.Equals, uint32(3))
+
+	events, err := readRecordEvents(&buf)
+	c.Assert(err, C.IsNil)
+	c.Assert(events, C.HasLen, 3)
+	c.Check(events[0].Type, C.Equals, recordTypeMotion)
+	c.Check(events[0].X, C.Equals, int32(10))
+	c.Check(events[1].Detail, C.Equals, int32(38))
+	c.Check(events[1].Key, C.Equals, "a")
+	c.Check(events[2].Type, C.Equals, recordTypeKeyRelease)
+}
+
+func (s *mySuite) TestReadRecordEventsInvalid(c *C.C) {
+	_, err := readRecordEvents(strings.NewReader(`{"Time":0,"Type":"scroll"}`))
+	c.Check(err, C.NotNil)
+
+	_, err = readRecordEvents(strings.NewReader(`{"Time":0,"Type":"key-press"}`))
+	c.Check(err, C.NotNil)
+
+	_, err = readRecordEvents(strings.NewReader(`{"Time":5,"Type":"motion"}
+{"Time":1,"Type":"motion"}`))
+	c.Check(err, C.NotNil)
+
+	events, err := readRecordEvents(strings.NewReader(`{"Time":0,"Type":"motion"}
+
+{"Time":1,"Type":"button-press","Detail":1}
+`))
+	c.Check(err, C.IsNil)
+	c.Check(events, C.HasLen, 2)
+}
+
+func (s *mySuite) TestReplayEvents(c *C.C) {
+	events := []recordEvent{
+		{Time: 0, Type: recordTypeMotion, X: 1, Y: 2},
+		{Time: 10, Type: recordTypeButtonPress, Detail: 1, X: 3, Y: 4},
+		{Time: 20, Type: recordTypeButtonRelease, Detail: 1, X: 3, Y: 4},
+		{Time: 30, Type: recordTypeKeyPress, Detail: 38},
+		{Time: 40, Type: recordTypeKeyRelease, Detail: 38},
+	}
+	inj := &fakeInjector{}
+	err := replayEvents(events, 10, inj, make(chan struct{}))
+	c.Assert(err, C.IsNil)
+	c.Check(inj.calls, C.DeepEquals, []string{
+		"motion 1 2",
+		"motion 3 4",
+		"button 1 true",
+		"motion 3 4",
+		"button 1 false",
+		"key 38 true",
+		"key 38 false",
+	})
+
+	err = replayEvents(events, 0, inj, make(chan struct{}))
+	c.Check(err, C.NotNil)
+
+	stop := make(chan struct{})
+	close(stop)
+	inj = &fakeInjector{}
+	err = replayEvents(events, 1, inj, stop)
+	c.Check(err, C.Equals, errReplayStopped)
+	c.Check(inj.calls, C.HasLen, 0)
+}
+
+func (s *mySuite) TestReplayEventsReleasePressed(c *C.C) {
+	events := []recordEvent{
+		{Time: 0, Type: recordTypeKeyPress, Detail: 38},
+		{Time: 0, Type: recordTypeButtonPress, Detail: 1, X: 3, Y: 4},
+		{Time: 0, Type: recordTypeKeyPress, Detail: 50},
+		{Time: 0, Type: recordTypeKeyRelease, Detail: 50},
+		{Time: 60000, Type: recordTypeKeyRelease, Detail: 38},
+	}
+
+	// 在按下之后停止回放
+	stop := make(chan struct{})
+	inj := &fakeInjector{}
+	done := make(chan error)
+	go func() {
+		done <- replayEvents(events, 1, inj, stop)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(stop)
+	c.Check(<-done, C.Equals, errReplayStopped)
+	c.Check(inj.calls, C.DeepEquals, []string{
+		"key 38 true",
+		"motion 3 4",
+		"button 1 true",
+		"key 50 true",
+		"key 50 false",
+		"key 38 false",
+		"button 1 false",
+	})
+
+	// 注入失败时释放已经按下的键和按钮
+	inj = &fakeInjector{failAt: 4}
+	err := replayEvents(events, 1, inj, make(chan struct{}))
+	c.Check(err, C.NotNil)
+	c.Check(inj.calls, C.DeepEquals, []string{
+		"key 38 true",
+		"motion 3 4",
+		"button 1 true",
+		"key 50 true",
+		"key 38 false",
+		"button 1 false",
+	})
+
+	// 录制文件中没有释放的键在回放结束后释放
+	inj = &fakeInjector{}
+	err = replayEvents(events[:3], 1, inj, make(chan struct{}))
+	c.Check(err, C.IsNil)
+	c.Check(inj.calls[len(inj.calls)-3:], C.DeepEquals, []string{
+		"key 38 false",
+		"key 50 false",
+		"button 1 false",
+	})
+}
+
+func (s *mySuite) TestParseProcessStartTime(c *C.C) {
+	stat := "1234 (a) b (c)) S 1 1234 1234 0 -1 4194560 100 0 0 0 1 2 0 0 20 0 1 0 987654 1000 100"
+	t, err := parseProcessStartTime(stat)
+	c.Check(err, C.IsNil)
+	c.Check(t, C.Equals, uint64(987654))
+
+	_, err = parseProcessStartTime("1234 (a) S 1")
+	c.Check(err, C.NotNil)
+}
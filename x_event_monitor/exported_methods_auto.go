@@ -38,6 +38,26 @@ func (v *Manager) GetExportedMethods() dbusutil.ExportedMethods {
 			Name: "RegisterFullScreenMotionFlag",
 			Fn:   v.RegisterFullScreenMotionFlag,
 		},
+		{
+			Name:   "Replay",
+			Fn:     v.Replay,
+			InArgs: []string{"filename", "speed"},
+		},
+		{
+			Name:    "StartRecording",
+			Fn:      v.StartRecording,
+			InArgs:  []string{"filename"},
+			OutArgs: []string{"path"},
+		},
+		{
+			Name:    "StopRecording",
+			Fn:      v.StopRecording,
+			OutArgs: []string{"count"},
+		},
+		{
+			Name: "StopReplay",
+			Fn:   v.StopReplay,
+		},
 		{
 			Name:    "UnregisterArea",
 			Fn:      v.UnregisterArea,
@@ -81,6 +81,10 @@ type Manager struct {
 			id   string
 		}
 		CursorShowAgain struct{}
+		ReplayFinished  struct {
+			filename string
+			errMsg   string
+		}
 	}
 
 	pidAidsMap            map[uint32]strv.Strv
@@ -89,6 +93,11 @@ type Manager struct {
 	fullscreenMotionCount int32
 
 	mu sync.Mutex
+
+	// 录制和回放不能同时进行
+	recordMu      sync.Mutex
+	recordSession *recordSession
+	replayStop    chan struct{}
 }
 
 func newManager(service *dbusutil.Service) (*Manager, error) {
@@ -250,14 +259,18 @@ func (m *Manager) handleXEvent() {
 						m.beginMoveMouse()
 					}
 
+					recording := m.isRecording()
 					_, ok := m.idReferCountMap[fullscreenId]
-					if len(m.idAreaInfoMap) == 0 && !ok {
+					if len(m.idAreaInfoMap) == 0 && !ok && !recording {
 						break
 					}
 					qpReply, err := m.queryPointer()
 					if err != nil {
 						logger.Warning(err)
 					} else {
+						if recording {
+							m.recordEvent(recordTypeMotion, 0, int32(qpReply.RootX), int32(qpReply.RootY))
+						}
 						/**
 						mouse left press: mask = 256
 						mouse right press: mask = 512
@@ -277,6 +290,7 @@ func (m *Manager) handleXEvent() {
 					if err != nil {
 						logger.Warning(err)
 					} else {
+						m.recordEvent(recordTypeKeyPress, int32(e.Detail), int32(qpReply.RootX), int32(qpReply.RootY))
 						m.handleKeyboardEvent(int32(e.Detail), true, int32(qpReply.RootX),
 							int32(qpReply.RootY))
 					}
@@ -286,6 +300,7 @@ func (m *Manager) handleXEvent() {
 					if err != nil {
 						logger.Warning(err)
 					} else {
+						m.recordEvent(recordTypeKeyRelease, int32(e.Detail), int32(qpReply.RootX), int32(qpReply.RootY))
 						m.handleKeyboardEvent(int32(e.Detail), false, int32(qpReply.RootX),
 							int32(qpReply.RootY))
 					}
@@ -296,6 +311,7 @@ func (m *Manager) handleXEvent() {
 					if err != nil {
 						logger.Warning(err)
 					} else {
+						m.recordEvent(recordTypeButtonPress, int32(e.Detail), int32(qpReply.RootX), int32(qpReply.RootY))
 						m.handleButtonEvent(int32(e.Detail), true, int32(qpReply.RootX),
 							int32(qpReply.RootY))
 					}
@@ -306,6 +322,7 @@ func (m *Manager) handleXEvent() {
 					if err != nil {
 						logger.Warning(err)
 					} else {
+						m.recordEvent(recordTypeButtonRelease, int32(e.Detail), int32(qpReply.RootX), int32(qpReply.RootY))
 						m.handleButtonEvent(int32(e.Detail), false, int32(qpReply.RootX),
 							int32(qpReply.RootY))
 					}
@@ -319,6 +336,8 @@ func (m *Manager) handleXEvent() {
 					if err != nil {
 						logger.Warning(err)
 					} else {
+						// 触摸按照鼠标左键录制
+						m.recordEvent(recordTypeButtonPress, 1, int32(qpReply.RootX), int32(qpReply.RootY))
 						m.handleButtonEvent(1, true, int32(qpReply.RootX),
 							int32(qpReply.RootY))
 					}
@@ -328,6 +347,7 @@ func (m *Manager) handleXEvent() {
 					if err != nil {
 						logger.Warning(err)
 					} else {
+						m.recordEvent(recordTypeButtonRelease, 1, int32(qpReply.RootX), int32(qpReply.RootY))
 						m.handleButtonEvent(1, false, int32(qpReply.RootX),
 							int32(qpReply.RootY))
 					}
@@ -502,7 +522,7 @@ func (m *Manager) unregisterPidArea(pid uint32, areasId string) {
 		delete(m.pidAidsMap, pid)
 	}
 
-	if len(m.pidAidsMap) == 0 {
+	if len(m.pidAidsMap) == 0 && !m.isRecording() {
 		m.deselectXInputEvents()
 	}
 }
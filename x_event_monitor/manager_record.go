@@ -0,0 +1,353 @@
+/*
+ * Copyright (C) 2014 ~ 2018 Deepin Technology Co., Ltd.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package x_event_monitor
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	dbus "github.com/godbus/dbus"
+	polkit "github.com/linuxdeepin/go-dbus-factory/org.freedesktop.policykit1"
+	x "github.com/linuxdeepin/go-x11-client"
+	"github.com/linuxdeepin/go-x11-client/ext/test"
+	"pkg.deepin.io/lib/dbusutil"
+	"pkg.deepin.io/lib/xdg/basedir"
+)
+
+const polkitActionRecord = "com.deepin.api.xeventmonitor.record-and-replay"
+
+var (
+	errRecording       = errors.New("recording is in progress")
+	errNotRecording    = errors.New("recording is not in progress")
+	errReplaying       = errors.New("replay is in progress")
+	errNotReplaying    = errors.New("replay is not in progress")
+	errNotAbsolutePath = errors.New("the file path is not absolute")
+)
+
+type recordSession struct {
+	file     *os.File
+	recorder *eventRecorder
+}
+
+func getRecordDir() string {
+	return filepath.Join(basedir.GetUserCacheDir(), "deepin/dde-daemon/x-event-monitor")
+}
+
+// getProcessStartTime 读取 /proc/<pid>/stat 中进程的启动时间
+func getProcessStartTime(pid uint32) (uint64, error) {
+	content, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+	return parseProcessStartTime(string(content))
+}
+
+// parseProcessStartTime 解析 stat 文件的第 22 个字段，进程名中可能有空格和括号，所以从最后一个 ) 之后开始
+func parseProcessStartTime(stat string) (uint64, error) {
+	idx := strings.LastIndex(stat, ")")
+	if idx == -1 {
+		return 0, errors.New("invalid stat")
+	}
+	// ) 之后从第 3 个字段 state 开始
+	fields := strings.Fields(stat[idx+1:])
+	if len(fields) < 20 {
+		return 0, errors.New("invalid stat")
+	}
+	return strconv.ParseUint(fields[19], 10, 64)
+}
+
+// checkAuthorization 检查调用者进程是否有录制和回放的权限。
+// 这是会话总线上的服务，不能用 system-bus-name，所以用带启动时间和 uid 的 unix-process，
+// polkit 会检查启动时间，避免 pid 被重用。
+func (m *Manager) checkAuthorization(sender dbus.Sender) error {
+	pid, err := m.service.GetConnPID(string(sender))
+	if err != nil {
+		return err
+	}
+	uid, err := m.service.GetConnUID(string(sender))
+	if err != nil {
+		return err
+	}
+	startTime, err := getProcessStartTime(pid)
+	if err != nil {
+		return err
+	}
+
+	systemBus, err := dbus.SystemBus()
+	if err != nil {
+		return err
+	}
+	authority := polkit.NewAuthority(systemBus)
+	subject := polkit.MakeSubject(polkit.SubjectKindUnixProcess)
+	subject.SetDetail("pid", pid)
+	subject.SetDetail("start-time", startTime)
+	subject.SetDetail("uid", int32(uid))
+
+	ret, err := authority.CheckAuthorization(0, subject, polkitActionRecord,
+		nil, polkit.CheckAuthorizationFlagsAllowUserInteraction, "")
+	if err != nil {
+		return err
+	}
+	if !ret.IsAuthorized {
+		return errors.New("not authorized")
+	}
+	return nil
+}
+
+func (m *Manager) isRecording() bool {
+	m.recordMu.Lock()
+	recording := m.recordSession != nil
+	m.recordMu.Unlock()
+	return recording
+}
+
+// startRecording 开始把事件录制到文件 filename，也供测试使用
+func (m *Manager) startRecording(filename string) error {
+	m.recordMu.Lock()
+	if m.recordSession != nil {
+		m.recordMu.Unlock()
+		return errRecording
+	}
+	if m.replayStop != nil {
+		m.recordMu.Unlock()
+		return errReplaying
+	}
+
+	err := os.MkdirAll(filepath.Dir(filename), 0700)
+	if err != nil {
+		m.recordMu.Unlock()
+		return err
+	}
+	fh, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		m.recordMu.Unlock()
+		return err
+	}
+	m.recordSession = &recordSession{
+		file:     fh,
+		recorder: newEventRecorder(fh),
+	}
+	m.recordMu.Unlock()
+	logger.Debug("start recording to", filename)
+
+	// unregisterPidArea 持有 mu 时会获取 recordMu，这里不能同时持有两个锁
+	m.mu.Lock()
+	m.selectXInputEvents()
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Manager) stopRecording() (uint32, error) {
+	m.recordMu.Lock()
+	session := m.recordSession
+	m.recordSession = nil
+	m.recordMu.Unlock()
+	if session == nil {
+		return 0, errNotRecording
+	}
+
+	m.mu.Lock()
+	if len(m.pidAidsMap) == 0 {
+		m.deselectXInputEvents()
+	}
+	m.mu.Unlock()
+
+	err := session.recorder.flush()
+	closeErr := session.file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	logger.Debugf("stop recording, %d events", session.recorder.count)
+	return session.recorder.count, err
+}
+
+func (m *Manager) recordEvent(typ string, detail int32, x, y int32) {
+	m.recordMu.Lock()
+	defer m.recordMu.Unlock()
+	if m.recordSession == nil {
+		return
+	}
+
+	ev := recordEvent{
+		Type:   typ,
+		Detail: detail,
+		X:      x,
+		Y:      y,
+	}
+	if typ == recordTypeKeyPress || typ == recordTypeKeyRelease {
+		ev.Key = m.keyCode2Str(detail)
+	}
+	err := m.recordSession.recorder.record(ev)
+	if err != nil {
+		logger.Warning("record event failed:", err)
+	}
+}
+
+// replay 读取录制文件 filename，异步回放，结束后发送 ReplayFinished 信号
+func (m *Manager) replay(filename string, speed float64, injector eventInjector) error {
+	err := checkReplaySpeed(speed)
+	if err != nil {
+		return err
+	}
+	fh, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	events, err := readRecordEvents(fh)
+	fh.Close()
+	if err != nil {
+		return err
+	}
+
+	m.recordMu.Lock()
+	if m.recordSession != nil {
+		m.recordMu.Unlock()
+		return errRecording
+	}
+	if m.replayStop != nil {
+		m.recordMu.Unlock()
+		return errReplaying
+	}
+	stop := make(chan struct{})
+	m.replayStop = stop
+	m.recordMu.Unlock()
+
+	logger.Debugf("replay %s, %d events, speed %v", filename, len(events), speed)
+	go func() {
+		err := replayEvents(events, speed, injector, stop)
+		m.recordMu.Lock()
+		if m.replayStop == stop {
+			m.replayStop = nil
+		}
+		m.recordMu.Unlock()
+
+		var errMsg string
+		if err != nil {
+			logger.Warning("replay failed:", err)
+			errMsg = err.Error()
+		}
+		err = m.service.Emit(m, "ReplayFinished", filename, errMsg)
+		if err != nil {
+			logger.Warning("Emit error:", err)
+		}
+	}()
+	return nil
+}
+
+func (m *Manager) stopReplay() error {
+	m.recordMu.Lock()
+	defer m.recordMu.Unlock()
+	if m.replayStop == nil {
+		return errNotReplaying
+	}
+	close(m.replayStop)
+	m.replayStop = nil
+	return nil
+}
+
+// StartRecording 开始录制键盘、鼠标和触摸事件到文件 filename，
+// filename 为空时在缓存目录下生成文件，返回实际使用的文件路径。
+func (m *Manager) StartRecording(sender dbus.Sender, filename string) (path string, busErr *dbus.Error) {
+	if filename == "" {
+		filename = filepath.Join(getRecordDir(),
+			fmt.Sprintf("record-%s.jsonl", time.Now().Format("20060102-150405")))
+	} else if !filepath.IsAbs(filename) {
+		return "", dbusutil.ToError(errNotAbsolutePath)
+	}
+
+	err := m.checkAuthorization(sender)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+
+	err = m.startRecording(filename)
+	if err != nil {
+		return "", dbusutil.ToError(err)
+	}
+	return filename, nil
+}
+
+func (m *Manager) StopRecording(sender dbus.Sender) (count uint32, busErr *dbus.Error) {
+	err := m.checkAuthorization(sender)
+	if err != nil {
+		return 0, dbusutil.ToError(err)
+	}
+
+	count, err = m.stopRecording()
+	return count, dbusutil.ToError(err)
+}
+
+// Replay 通过 XTest 回放录制文件 filename，speed 为回放速度的倍数
+func (m *Manager) Replay(sender dbus.Sender, filename string, speed float64) *dbus.Error {
+	if !filepath.IsAbs(filename) {
+		return dbusutil.ToError(errNotAbsolutePath)
+	}
+	err := m.checkAuthorization(sender)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	err = m.replay(filename, speed, &xTestInjector{conn: m.xConn})
+	return dbusutil.ToError(err)
+}
+
+func (m *Manager) StopReplay(sender dbus.Sender) *dbus.Error {
+	err := m.checkAuthorization(sender)
+	if err != nil {
+		return dbusutil.ToError(err)
+	}
+
+	return dbusutil.ToError(m.stopReplay())
+}
+
+// xTestInjector 通过 XTest 扩展产生事件
+type xTestInjector struct {
+	conn *x.Conn
+}
+
+func (inj *xTestInjector) fakeInput(evType uint8, detail uint8, rootX, rootY int16) error {
+	rootWin := inj.conn.GetDefaultScreen().Root
+	return test.FakeInputChecked(inj.conn, evType, detail, x.TimeCurrentTime,
+		rootWin, rootX, rootY, 0).Check(inj.conn)
+}
+
+func (inj *xTestInjector) fakeMotion(x0, y0 int32) error {
+	return inj.fakeInput(x.MotionNotifyEventCode, 0, int16(x0), int16(y0))
+}
+
+func (inj *xTestInjector) fakeKey(code int32, press bool) error {
+	evType := uint8(x.KeyReleaseEventCode)
+	if press {
+		evType = x.KeyPressEventCode
+	}
+	return inj.fakeInput(evType, uint8(code), 0, 0)
+}
+
+func (inj *xTestInjector) fakeButton(button int32, press bool) error {
+	evType := uint8(x.ButtonReleaseEventCode)
+	if press {
+		evType = x.ButtonPressEventCode
+	}
+	return inj.fakeInput(evType, uint8(button), 0, 0)
+}